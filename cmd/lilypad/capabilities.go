@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/access"
+)
+
+func runCapabilities(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		account  = fs.String("account", "", "account to check")
+		contract = fs.String("contract", "", "only check this contract, e.g. LilypadProxy")
+		method   = fs.String("method", "", "only check this method of -contract, e.g. setDeal; exits with an error unless allowed")
+		asJSON   = fs.Bool("json", false, "write the matrix as JSON")
+	)
+	fs.Parse(args)
+
+	if !common.IsHexAddress(*account) {
+		return fmt.Errorf("invalid -account address %q", *account)
+	}
+	if *method != "" && *contract == "" {
+		return fmt.Errorf("-method needs -contract")
+	}
+	ec, book, err := chain.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	m, err := access.Capabilities(ctx, ec, book, common.HexToAddress(*account))
+	if err != nil {
+		return err
+	}
+	if *contract != "" {
+		var kept []access.Capability
+		for _, c := range m.Capabilities {
+			if c.Contract == *contract && (*method == "" || c.Method == *method) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 && *method != "" {
+			return fmt.Errorf("%s has no state-changing method %q", *contract, *method)
+		}
+		if len(kept) == 0 {
+			return fmt.Errorf("no contract %q in the address book", *contract)
+		}
+		m.Capabilities = kept
+	}
+	if *asJSON {
+		err = m.WriteJSON(os.Stdout)
+	} else {
+		err = m.WriteText(os.Stdout)
+	}
+	if err != nil || *method == "" {
+		return err
+	}
+	for _, c := range m.Capabilities {
+		if c.Verdict != access.Allowed {
+			return fmt.Errorf("%s.%s is %s: %s", c.Contract, c.Method, c.Verdict, c.Reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/backfill"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/export"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/follower"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/preimage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		db         = fs.String("db", "", "indexed SQLite store to read; with -backfill, defaults to a temporary file")
+		fill       = fs.Bool("backfill", false, "fetch the events from -rpc into the store before exporting")
+		from       = fs.Uint64("from", 0, "first block to backfill")
+		to         = fs.Uint64("to", 0, "last block to backfill (default latest)")
+		controller = fs.String("controller", "", "CONTROLLER_ROLE holder used as the sender of storage reads when backfilling")
+		out        = fs.String("out", "export", "output directory")
+		formats    = fs.String("formats", "csv,parquet", "comma separated output formats")
+		datasets   = fs.String("datasets", "", "comma separated datasets (default all, less the escrow datasets without -rpc)")
+		since      = fs.String("since", "", "first day to export, YYYY-MM-DD")
+		until      = fs.String("until", "", "day after the last day to export, YYYY-MM-DD")
+	)
+	fs.Parse(args)
+
+	cfg := export.Config{Dir: *out}
+	for _, f := range split(*formats) {
+		cfg.Formats = append(cfg.Formats, export.Format(f))
+	}
+	for _, d := range split(*datasets) {
+		cfg.Datasets = append(cfg.Datasets, export.Dataset(d))
+	}
+	var err error
+	if cfg.Since, err = parseDay(*since); err != nil {
+		return err
+	}
+	if cfg.Until, err = parseDay(*until); err != nil {
+		return err
+	}
+
+	var (
+		ec   *ethclient.Client
+		book client.AddressBook
+	)
+	if chain.rpc != "" {
+		if ec, book, err = chain.dial(ctx); err != nil {
+			return err
+		}
+		defer ec.Close()
+		cfg.Headers = ec
+	}
+	path := *db
+	if path == "" {
+		if !*fill {
+			return fmt.Errorf("no -db to read; pass -backfill to fetch the events instead")
+		}
+		dir, err := os.MkdirTemp("", "lilypad-export")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "lilypad.db")
+	}
+	store, err := sqlite.Open(ctx, path, book)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *fill {
+		if ec == nil {
+			return fmt.Errorf("-backfill needs -rpc and -registry")
+		}
+		if !common.IsHexAddress(*controller) {
+			return fmt.Errorf("-backfill needs a -controller address")
+		}
+		if err := backfillStore(ctx, ec, book, store, common.HexToAddress(*controller), *from, *to); err != nil {
+			return err
+		}
+	}
+
+	written, err := export.Run(ctx, store, cfg)
+	for _, p := range written {
+		fmt.Printf("%s\t%s\t%d rows\n", p.Path, p.Day, p.Rows)
+	}
+	return err
+}
+
+// backfillStore indexes the storage records and records the events of every
+// contract in book between from and to into store. Blocks the store already
+// holds are skipped, so an interrupted backfill can be re-run.
+func backfillStore(ctx context.Context, ec *ethclient.Client, book client.AddressBook, store *sqlite.Store,
+	controller common.Address, from, to uint64) error {
+	c, err := client.New(ec, book)
+	if err != nil {
+		return err
+	}
+	resolver, err := preimage.OpenResolver(ctx, store, ec, c.ABIs()...)
+	if err != nil {
+		return err
+	}
+	ix, err := indexer.New(c, store, resolver, controller)
+	if err != nil {
+		return err
+	}
+	dec, err := preimage.NewDecoder(c, resolver)
+	if err != nil {
+		return err
+	}
+	if to == 0 {
+		head, err := ec.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return err
+		}
+		to = head.Number.Uint64()
+	}
+	contracts := make(map[common.Address]string)
+	for name, addr := range book.Named() {
+		contracts[addr] = name
+	}
+	cfg := backfill.Config{
+		Query: ethereum.FilterQuery{Addresses: follower.Addresses(book)},
+		From:  from,
+		To:    to,
+		Progress: func(p backfill.Progress) {
+			fmt.Fprintf(os.Stderr, "backfill: block %d of %d, %d logs\n", p.Done, p.To, p.Logs)
+		},
+	}
+	err = backfill.Run(ctx, ec, cfg, func(chunk backfill.Chunk) error {
+		// Learn the IDs events carry in plain and index the storage records
+		// first, so the resolver knows the IDs that the other contracts'
+		// events only carry as hashes.
+		for _, log := range chunk.Logs {
+			if err := dec.Learn(ctx, log); err != nil {
+				return err
+			}
+		}
+		if err := ix.IndexLogs(ctx, chunk.Logs); err != nil {
+			return err
+		}
+		byContract := make(map[string][]types.Log)
+		for _, log := range chunk.Logs {
+			if name, ok := contracts[log.Address]; ok {
+				byContract[name] = append(byContract[name], log)
+			}
+		}
+		for name, logs := range byContract {
+			if err := store.RecordLogs(ctx, sqlite.RecordKey(name), logs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Advance the checkpoints over the blocks without events.
+	keys := []string{indexer.CheckpointKey}
+	for name := range book.Named() {
+		keys = append(keys, sqlite.RecordKey(name))
+	}
+	for _, key := range keys {
+		if err := store.ApplyBlock(ctx, key, to, func(context.Context) error { return nil }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func split(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day %q, want YYYY-MM-DD", s)
+	}
+	return day, nil
+}
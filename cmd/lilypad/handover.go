@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/access"
+)
+
+func runAdminHandover(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("admin-handover", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		to       = fs.String("to", "", "new DEFAULT_ADMIN_ROLE holder, usually a multisig")
+		from     = fs.String("from", "", "current admin to check when only reading the status (default the -key account)")
+		key      = fs.String("key", os.Getenv("LILYPAD_PRIVATE_KEY"), "hex private key of the current admin (default $LILYPAD_PRIVATE_KEY)")
+		status   = fs.Bool("status", false, "only print the progress of the handover")
+		asJSON   = fs.Bool("json", false, "write the status as JSON")
+		allowEOA = fs.Bool("allow-eoa", false, "allow a new admin without code")
+		exclude  = fs.String("exclude", "", "comma separated contracts to leave out, e.g. LilypadTokenomics on a deployment without it")
+		audit    = fs.String("audit", "", "role-audit -json report to suggest admins from when a call is denied")
+		yes      = fs.Bool("yes", false, "send without asking for confirmation")
+	)
+	fs.Parse(args)
+
+	if !common.IsHexAddress(*to) {
+		return fmt.Errorf("invalid -to address %q", *to)
+	}
+	var (
+		oldAdmin common.Address
+		pk       *ecdsa.PrivateKey
+	)
+	switch {
+	case *status && *from != "":
+		if !common.IsHexAddress(*from) {
+			return fmt.Errorf("invalid -from address %q", *from)
+		}
+		oldAdmin = common.HexToAddress(*from)
+	case *key == "":
+		return fmt.Errorf("no -key of the current admin")
+	default:
+		var err error
+		if pk, err = crypto.HexToECDSA(strings.TrimPrefix(*key, "0x")); err != nil {
+			return fmt.Errorf("invalid -key: %w", err)
+		}
+		oldAdmin = crypto.PubkeyToAddress(pk.PublicKey)
+	}
+
+	admins, err := loadAdmins(*audit)
+	if err != nil {
+		return err
+	}
+
+	ec, book, err := chain.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+
+	h := access.NewHandover(ec, book, oldAdmin, common.HexToAddress(*to))
+	h.AllowEOA = *allowEOA
+	h.Exclude = split(*exclude)
+	h.Admins = admins
+	st, err := h.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		err = st.WriteJSON(os.Stdout)
+	} else {
+		err = st.WriteText(os.Stdout)
+	}
+	if err != nil || *status {
+		return err
+	}
+	if len(st.Missing) > 0 {
+		return fmt.Errorf("%w: %s; pass -tokenomics and -validation, or -exclude the contracts",
+			access.ErrMissingContract, strings.Join(st.Missing, ", "))
+	}
+	if st.Done() {
+		fmt.Fprintln(os.Stderr, "handover complete")
+		return nil
+	}
+
+	chainID, err := ec.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(pk, chainID)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		what := "grant DEFAULT_ADMIN_ROLE to " + h.New.Hex() + ", then renounce it"
+		if st.Granted() {
+			what = "renounce DEFAULT_ADMIN_ROLE"
+		}
+		fmt.Fprintf(os.Stderr, "%s from %s on every contract? This cannot be undone. [y/N] ", what, opts.From.Hex())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(answer); a != "y" && a != "yes" {
+			return fmt.Errorf("aborted")
+		}
+	}
+	sent, err := h.Run(ctx, ec, opts)
+	for _, tx := range sent {
+		fmt.Fprintln(os.Stderr, tx.Hash().Hex())
+	}
+	if err != nil {
+		return fmt.Errorf("%w; rerun to resume", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/ids"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/integrity"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+func runIntegrity(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("integrity", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		db         = fs.String("db", "", "indexed SQLite store to check")
+		controller = fs.String("controller", "", "CONTROLLER_ROLE holder; confirms orphans against LilypadStorage")
+		atBlock    = fs.Bool("at-record-block", false, "check roles at the block each record was saved in (archive node)")
+		asJSON     = fs.Bool("json", false, "write the report as JSON")
+		strict     = fs.Bool("strict", false, "exit with an error if anomalies are found")
+	)
+	fs.Parse(args)
+	if *db == "" {
+		return fmt.Errorf("no -db to check")
+	}
+
+	var book client.AddressBook
+	var users integrity.Users
+	var storage *ids.Checker
+	if chain.rpc != "" {
+		ec, b, err := chain.dial(ctx)
+		if err != nil {
+			return err
+		}
+		defer ec.Close()
+		book = b
+		if users, err = lilypaduser.NewLilypadUserCaller(book.User, ec); err != nil {
+			return err
+		}
+		if *controller != "" {
+			if !common.IsHexAddress(*controller) {
+				return fmt.Errorf("invalid -controller address %q", *controller)
+			}
+			caller, err := client.New(ec, book)
+			if err != nil {
+				return err
+			}
+			if storage, err = ids.NewChecker(&caller.Storage.LilypadStorageCaller, common.HexToAddress(*controller)); err != nil {
+				return err
+			}
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "integrity: no -rpc, skipping role checks")
+	}
+
+	store, err := sqlite.Open(ctx, *db, book)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	checker, err := integrity.NewChecker(store, users, storage)
+	if err != nil {
+		return err
+	}
+	checker.AtRecordBlock = *atBlock
+	report, err := checker.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if *strict && len(report.Anomalies) > 0 {
+		return fmt.Errorf("%d anomalies", len(report.Anomalies))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Command lilypad is a toolbox for operating a Lilypad protocol deployment.
+//
+// Usage:
+//
+//	lilypad <command> [flags]
+//
+// Run "lilypad <command> -h" for the flags of a command.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// command is a subcommand; run receives the arguments after its name.
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"admin-handover": {"move DEFAULT_ADMIN_ROLE to a new admin on every contract, resuming if interrupted", runAdminHandover},
+	"capabilities":   {"list which state-changing methods an account may call", runCapabilities},
+	"export":         {"export protocol history to CSV and Parquet", runExport},
+	"integrity":      {"check indexed storage records for orphans and anomalies", runIntegrity},
+	"onboard":        {"register a resource provider, deposit its collateral and report its readiness", runOnboard},
+	"role-apply":     {"grant and revoke roles to match a role policy, or export them for a multisig", runRoleApply},
+	"role-audit":     {"list AccessControl role holders of every contract and flag surprises", runRoleAudit},
+	"role-plan":      {"diff a role policy against the roles held on chain", runRolePlan},
+	"user-import":    {"mirror accounts from a CSV or JSON file into LilypadUser", runUserImport},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "lilypad: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.run(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "lilypad %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lilypad <command> [flags]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].summary)
+	}
+}
+
+// chainFlags are the flags of commands that talk to a node.
+type chainFlags struct {
+	rpc        string
+	registry   string
+	tokenomics string
+	validation string
+}
+
+func (f *chainFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.rpc, "rpc", os.Getenv("LILYPAD_RPC_URL"), "JSON-RPC endpoint (default $LILYPAD_RPC_URL)")
+	fs.StringVar(&f.registry, "registry", os.Getenv("LILYPAD_REGISTRY"), "LilypadContractRegistry address (default $LILYPAD_REGISTRY)")
+	fs.StringVar(&f.tokenomics, "tokenomics", "", "LilypadTokenomics address, not tracked by the registry")
+	fs.StringVar(&f.validation, "validation", "", "LilypadValidation address, not tracked by the registry")
+}
+
+// dial connects to the node and loads the address book from the registry.
+func (f *chainFlags) dial(ctx context.Context) (*ethclient.Client, client.AddressBook, error) {
+	if f.rpc == "" {
+		return nil, client.AddressBook{}, fmt.Errorf("no -rpc endpoint")
+	}
+	if !common.IsHexAddress(f.registry) {
+		return nil, client.AddressBook{}, fmt.Errorf("invalid -registry address %q", f.registry)
+	}
+	ec, err := ethclient.DialContext(ctx, f.rpc)
+	if err != nil {
+		return nil, client.AddressBook{}, err
+	}
+	book, err := client.LoadAddressBook(ctx, ec, common.HexToAddress(f.registry))
+	if err != nil {
+		ec.Close()
+		return nil, client.AddressBook{}, err
+	}
+	for _, opt := range []struct {
+		flag, value string
+		dst         *common.Address
+	}{
+		{"tokenomics", f.tokenomics, &book.Tokenomics},
+		{"validation", f.validation, &book.Validation},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		if !common.IsHexAddress(opt.value) {
+			ec.Close()
+			return nil, client.AddressBook{}, fmt.Errorf("invalid -%s address %q", opt.flag, opt.value)
+		}
+		*opt.dst = common.HexToAddress(opt.value)
+	}
+	return ec, book, nil
+}
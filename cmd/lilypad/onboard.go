@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/metadata"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/onboard"
+)
+
+func runOnboard(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("onboard", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		key           = fs.String("key", os.Getenv("LILYPAD_PRIVATE_KEY"), "hex private key of the resource provider (default $LILYPAD_PRIVATE_KEY)")
+		controllerKey = fs.String("controller-key", os.Getenv("LILYPAD_CONTROLLER_KEY"), "hex private key of a LilypadUser controller (default $LILYPAD_CONTROLLER_KEY)")
+		metadataID    = fs.String("metadata-id", "", "metadata ID, usually the CID of the metadata document")
+		url           = fs.String("url", "", "metadata URL")
+		validator     = fs.Bool("validator", false, "also assign the Validator role")
+		collateral    = fs.String("collateral", "", "escrow to hold in token base units (default the proxy's minimum)")
+		gateway       = fs.String("gateway", "", "IPFS gateway to fetch and validate the metadata document through")
+		check         = fs.String("check", "", "only report the readiness of this account")
+		audit         = fs.String("audit", "", "role-audit -json report to suggest admins from when a call is denied")
+		asJSON        = fs.Bool("json", false, "write the report as JSON")
+	)
+	fs.Parse(args)
+
+	cfg := onboard.Config{MetadataID: *metadataID, URL: *url, Validator: *validator}
+	if *collateral != "" {
+		amount, ok := new(big.Int).SetString(*collateral, 10)
+		if !ok || amount.Sign() <= 0 {
+			return fmt.Errorf("invalid -collateral %q", *collateral)
+		}
+		cfg.Collateral = amount
+	}
+	if *gateway != "" {
+		gw, err := metadata.NewGatewayResolver(*gateway, http.DefaultClient)
+		if err != nil {
+			return err
+		}
+		loader, err := metadata.NewLoader(metadata.Config{Resolvers: []metadata.Resolver{gw, metadata.NewHTTPResolver(nil)}})
+		if err != nil {
+			return err
+		}
+		cfg.Metadata = loader
+	}
+	if *check != "" && !common.IsHexAddress(*check) {
+		return fmt.Errorf("invalid -check address %q", *check)
+	}
+	if *check == "" && *key == "" {
+		return fmt.Errorf("no -key of the resource provider")
+	}
+	admins, err := loadAdmins(*audit)
+	if err != nil {
+		return err
+	}
+
+	ec, book, err := chain.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	c, err := client.New(ec, book)
+	if err != nil {
+		return err
+	}
+	c.Admins = admins
+
+	if *check != "" {
+		r, err := onboard.Check(ctx, c, common.HexToAddress(*check), cfg)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			err = r.WriteJSON(os.Stdout)
+		} else {
+			err = r.WriteText(os.Stdout)
+		}
+		if err == nil && !r.Ready() {
+			err = fmt.Errorf("not ready")
+		}
+		return err
+	}
+
+	if cfg.Provider, err = transactor(ctx, ec, *key); err != nil {
+		return fmt.Errorf("-key: %w", err)
+	}
+	if *controllerKey != "" {
+		if cfg.Controller, err = transactor(ctx, ec, *controllerKey); err != nil {
+			return fmt.Errorf("-controller-key: %w", err)
+		}
+	}
+	report, err := onboard.Run(ctx, c, cfg)
+	if report != nil {
+		var werr error
+		if *asJSON {
+			werr = report.WriteJSON(os.Stdout)
+		} else {
+			werr = report.WriteText(os.Stdout)
+		}
+		if err == nil {
+			err = werr
+		}
+	}
+	if err == nil && !report.Readiness.Ready() {
+		err = fmt.Errorf("not ready")
+	}
+	return err
+}
+
+// transactor returns a signer for a hex private key on the node's chain.
+func transactor(ctx context.Context, ec *ethclient.Client, key string) (*bind.TransactOpts, error) {
+	pk, err := crypto.HexToECDSA(strings.TrimPrefix(key, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := ec.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewKeyedTransactorWithChainID(pk, chainID)
+}
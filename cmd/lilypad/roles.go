@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/access"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+func runRoleAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("role-audit", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		from   = fs.Uint64("from", 0, "first block to replay, usually the deployment block")
+		to     = fs.Uint64("to", 0, "last block to replay and confirm at (default latest)")
+		allow  = fs.String("allow", "", "comma separated accounts allowed to hold contract-only roles")
+		chunk  = fs.Uint64("chunk", 0, "initial eth_getLogs span in blocks")
+		asJSON = fs.Bool("json", false, "write the report as JSON")
+		strict = fs.Bool("strict", false, "exit with an error if findings are reported")
+	)
+	fs.Parse(args)
+
+	ec, book, err := chain.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	auditor := access.NewAuditor(ec, book)
+	auditor.From, auditor.ChunkSize = *from, *chunk
+	if *to != 0 {
+		auditor.To = to
+	}
+	for _, s := range split(*allow) {
+		if !common.IsHexAddress(s) {
+			return fmt.Errorf("invalid -allow address %q", s)
+		}
+		auditor.Allow = append(auditor.Allow, common.HexToAddress(s))
+	}
+	report, err := auditor.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if *strict && len(report.Findings) > 0 {
+		return fmt.Errorf("%d findings", len(report.Findings))
+	}
+	return nil
+}
+
+// policyFlags are the flags shared by role-plan and role-apply.
+type policyFlags struct {
+	chain  chainFlags
+	policy string
+	from   uint64
+	chunk  uint64
+}
+
+func (f *policyFlags) register(fs *flag.FlagSet) {
+	f.chain.register(fs)
+	fs.StringVar(&f.policy, "policy", "", "YAML or JSON role policy")
+	fs.Uint64Var(&f.from, "from", 0, "first block to replay current holders from, usually the deployment block")
+	fs.Uint64Var(&f.chunk, "chunk", 0, "initial eth_getLogs span in blocks")
+}
+
+// plan dials the node and diffs the policy against the current holders,
+// returning the audit report it read them from as well.
+func (f *policyFlags) plan(ctx context.Context) (*ethclient.Client, *access.Report, *access.Plan, error) {
+	if f.policy == "" {
+		return nil, nil, nil, fmt.Errorf("no -policy")
+	}
+	policy, err := access.LoadPolicy(f.policy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ec, book, err := f.chain.dial(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	auditor := access.NewAuditor(ec, book)
+	auditor.From, auditor.ChunkSize = f.from, f.chunk
+	report, err := auditor.Run(ctx)
+	if err != nil {
+		ec.Close()
+		return nil, nil, nil, err
+	}
+	plan, err := access.NewPlan(policy, book, report)
+	if err != nil {
+		ec.Close()
+		return nil, nil, nil, err
+	}
+	return ec, report, plan, nil
+}
+
+func runRolePlan(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("role-plan", flag.ExitOnError)
+	var pf policyFlags
+	pf.register(fs)
+	var (
+		asJSON = fs.Bool("json", false, "write the plan as JSON")
+		safe   = fs.String("safe", "", "also write the plan as a Safe Transaction Builder batch to this file")
+	)
+	fs.Parse(args)
+
+	ec, _, plan, err := pf.plan(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	if *asJSON {
+		err = plan.WriteJSON(os.Stdout)
+	} else {
+		err = plan.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if *safe != "" {
+		return writeSafeBatch(ctx, ec, plan, *safe)
+	}
+	return nil
+}
+
+func runRoleApply(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("role-apply", flag.ExitOnError)
+	var pf policyFlags
+	pf.register(fs)
+	var (
+		key  = fs.String("key", os.Getenv("LILYPAD_PRIVATE_KEY"), "hex private key of an admin (default $LILYPAD_PRIVATE_KEY)")
+		safe = fs.String("safe", "", "write a Safe Transaction Builder batch to this file instead of sending")
+		yes  = fs.Bool("yes", false, "send without asking for confirmation")
+	)
+	fs.Parse(args)
+
+	ec, report, plan, err := pf.plan(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	if err := plan.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+	if *safe != "" {
+		return writeSafeBatch(ctx, ec, plan, *safe)
+	}
+
+	if *key == "" {
+		return fmt.Errorf("no -key to sign with; use -safe to export the changes instead")
+	}
+	pk, err := crypto.HexToECDSA(strings.TrimPrefix(*key, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid -key: %w", err)
+	}
+	chainID, err := ec.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(pk, chainID)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		fmt.Printf("send %d transactions from %s? [y/N] ", len(plan.Changes), opts.From.Hex())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(answer); a != "y" && a != "yes" {
+			return fmt.Errorf("aborted")
+		}
+	}
+	sent, err := plan.Apply(ctx, ec, ec, opts, report)
+	for _, tx := range sent {
+		fmt.Println(tx.Hash().Hex())
+	}
+	return err
+}
+
+// loadAdmins reads a role-audit -json report to suggest who can grant a
+// role when a call is denied. It returns nil if path is empty.
+func loadAdmins(path string) (client.RoleAdmins, error) {
+	if path == "" {
+		return nil, nil
+	}
+	report, err := access.LoadReport(path)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func writeSafeBatch(ctx context.Context, ec *ethclient.Client, plan *access.Plan, path string) error {
+	chainID, err := ec.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := plan.WriteSafeBatch(f, chainID); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
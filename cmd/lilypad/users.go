@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/users"
+)
+
+func runUserImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("user-import", flag.ExitOnError)
+	var chain chainFlags
+	chain.register(fs)
+	var (
+		file     = fs.String("file", "", "CSV or JSON file of accounts, by extension")
+		from     = fs.Uint64("from", 0, "first block to load the user directory from, usually the deployment block")
+		key      = fs.String("key", os.Getenv("LILYPAD_PRIVATE_KEY"), "hex private key of a LilypadUser controller (default $LILYPAD_PRIVATE_KEY)")
+		batch    = fs.Int("batch", 20, "transactions in flight per batch")
+		interval = fs.Duration("interval", 5*time.Second, "pause between batches")
+		dryRun   = fs.Bool("dry-run", false, "only plan the calls")
+		results  = fs.String("results", "", "write the per-row results to this CSV file (default stdout)")
+		audit    = fs.String("audit", "", "role-audit -json report to suggest admins from when a call is denied")
+		managed  = fs.String("roles", "", "comma-separated roles the file is authoritative for (default JobCreator,ResourceProvider)")
+		unmanage = fs.Bool("remove-unmanaged", false, "also remove roles outside -roles that a row does not list, such as Solver or Validator")
+		yes      = fs.Bool("yes", false, "send without asking for confirmation")
+	)
+	fs.Parse(args)
+
+	scope := users.Scope{RemoveUnmanaged: *unmanage}
+	for _, name := range split(*managed) {
+		role, err := roles.ParseUserType(name)
+		if err != nil {
+			return fmt.Errorf("-roles: %w", err)
+		}
+		scope.Managed = append(scope.Managed, role)
+	}
+
+	if *file == "" {
+		return fmt.Errorf("no -file")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	var records []users.Record
+	if strings.EqualFold(filepath.Ext(*file), ".json") {
+		records, err = users.ReadJSON(f)
+	} else {
+		records, err = users.ReadCSV(f)
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if !*dryRun && *key == "" {
+		return fmt.Errorf("no -key to sign with; use -dry-run to only plan the calls")
+	}
+	admins, err := loadAdmins(*audit)
+	if err != nil {
+		return err
+	}
+
+	ec, book, err := chain.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer ec.Close()
+	filterer, err := lilypaduser.NewLilypadUserFilterer(book.User, ec)
+	if err != nil {
+		return err
+	}
+	sender, err := lilypaduser.NewLilypadUserTransactor(book.User, ec)
+	if err != nil {
+		return err
+	}
+	dir := users.NewDirectory()
+	if err := dir.Load(ctx, filterer, *from, nil); err != nil {
+		return err
+	}
+
+	// Plan first, so the confirmation can say how much will be sent.
+	im := users.NewImporter(dir, sender, ec, nil)
+	im.DryRun, im.Scope = true, scope
+	planned, err := im.Import(ctx, records)
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	calls := 0
+	for _, res := range planned {
+		counts[res.Status]++
+		calls += len(res.Ops)
+	}
+	fmt.Fprintf(os.Stderr, "%d rows: %d to change with %d calls, %d unchanged, %d invalid\n",
+		len(planned), counts[users.Planned], calls, counts[users.Unchanged], counts[users.Invalid])
+	if *dryRun || calls == 0 {
+		return writeResults(planned, *results)
+	}
+
+	opts, err := transactor(ctx, ec, *key)
+	if err != nil {
+		return fmt.Errorf("-key: %w", err)
+	}
+	if !*yes {
+		fmt.Fprintf(os.Stderr, "send %d transactions from %s? [y/N] ", calls, opts.From.Hex())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(answer); a != "y" && a != "yes" {
+			return fmt.Errorf("aborted")
+		}
+	}
+	im = users.NewImporter(dir, sender, ec, opts)
+	im.BatchSize, im.Interval, im.Admins, im.Scope = *batch, *interval, admins, scope
+	res, err := im.Import(ctx, records)
+	if werr := writeResults(res, *results); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func writeResults(results []users.Result, path string) error {
+	if path == "" {
+		return users.WriteResults(os.Stdout, results)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := users.WriteResults(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
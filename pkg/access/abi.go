@@ -0,0 +1,44 @@
+// Package access audits and manages the OpenZeppelin AccessControl roles of
+// the Lilypad contracts.
+//
+// Every contract inherits AccessControl, which keeps role membership in a
+// mapping and offers no enumeration. The Auditor replays RoleGranted,
+// RoleRevoked and RoleAdminChanged across the address book to list the
+// holders of every role, confirms them with hasRole and reports surprises.
+// A Plan brings the holders in line with a declarative Policy, and a
+// Handover moves DEFAULT_ADMIN_ROLE from one account to another.
+package access
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// accessControlABI is the part of the OpenZeppelin AccessControl interface
+// every Lilypad contract shares.
+const accessControlABI = `[
+{"type":"function","name":"hasRole","stateMutability":"view","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+{"type":"function","name":"getRoleAdmin","stateMutability":"view","inputs":[{"name":"role","type":"bytes32"}],"outputs":[{"name":"","type":"bytes32"}]},
+{"type":"function","name":"grantRole","stateMutability":"nonpayable","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[]},
+{"type":"function","name":"revokeRole","stateMutability":"nonpayable","inputs":[{"name":"role","type":"bytes32"},{"name":"account","type":"address"}],"outputs":[]},
+{"type":"function","name":"renounceRole","stateMutability":"nonpayable","inputs":[{"name":"role","type":"bytes32"},{"name":"callerConfirmation","type":"address"}],"outputs":[]},
+{"type":"event","name":"RoleGranted","anonymous":false,"inputs":[{"name":"role","type":"bytes32","indexed":true},{"name":"account","type":"address","indexed":true},{"name":"sender","type":"address","indexed":true}]},
+{"type":"event","name":"RoleRevoked","anonymous":false,"inputs":[{"name":"role","type":"bytes32","indexed":true},{"name":"account","type":"address","indexed":true},{"name":"sender","type":"address","indexed":true}]},
+{"type":"event","name":"RoleAdminChanged","anonymous":false,"inputs":[{"name":"role","type":"bytes32","indexed":true},{"name":"previousAdminRole","type":"bytes32","indexed":true},{"name":"newAdminRole","type":"bytes32","indexed":true}]}
+]`
+
+// AccessControlABI returns the parsed AccessControl interface.
+func AccessControlABI() abi.ABI {
+	return parsedABI
+}
+
+var parsedABI = mustParse(accessControlABI)
+
+func mustParse(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
@@ -0,0 +1,441 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/backfill"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Kind classifies a finding.
+type Kind string
+
+const (
+	// Stale is a holder granted in the replayed events that hasRole denies.
+	Stale Kind = "stale"
+	// Unreplayed is a holder hasRole confirms without a grant in the
+	// replayed range, e.g. one granted before the first replayed block.
+	Unreplayed Kind = "unreplayed"
+	// EOAHolder is an account without code holding a role that should only
+	// be held by contracts.
+	EOAHolder Kind = "eoa_holder"
+	// UnknownRole is a role that is not one of roles.All.
+	UnknownRole Kind = "unknown_role"
+	// AdminChanged is a role administered by a role other than
+	// DEFAULT_ADMIN_ROLE.
+	AdminChanged Kind = "admin_changed"
+	// NoAdmin is a contract without a DEFAULT_ADMIN_ROLE holder; its roles
+	// can no longer be changed.
+	NoAdmin Kind = "no_admin"
+)
+
+// Rule names a role on a contract.
+type Rule struct {
+	Contract string
+	Role     common.Hash
+}
+
+// DefaultContractOnly are the roles only contracts are expected to hold:
+// the controller of storage, the payment engine and the user registry is
+// the proxy, the payment engine or the module directory, never an EOA.
+var DefaultContractOnly = []Rule{
+	{"LilypadPaymentEngine", roles.Controller},
+	{"LilypadStorage", roles.Controller},
+	{"LilypadUser", roles.Controller},
+}
+
+// Holder is an account holding a role on a contract.
+type Holder struct {
+	Contract        string         `json:"contract"`
+	ContractAddress common.Address `json:"contract_address"`
+	Role            string         `json:"role"`
+	RoleHash        common.Hash    `json:"role_hash"`
+	Account         common.Address `json:"account"`
+	// Name is the address book name of the account, if any.
+	Name       string `json:"name,omitempty"`
+	IsContract bool   `json:"is_contract"`
+	// GrantedBy and GrantedAt come from the last replayed RoleGranted.
+	GrantedBy *common.Address `json:"granted_by,omitempty"`
+	GrantedAt uint64          `json:"granted_at,omitempty"`
+	// Confirmed is set when hasRole returned true.
+	Confirmed bool `json:"confirmed"`
+}
+
+// Finding is a surprise in the role wiring.
+type Finding struct {
+	Kind     Kind            `json:"kind"`
+	Contract string          `json:"contract"`
+	Role     string          `json:"role"`
+	Account  *common.Address `json:"account,omitempty"`
+	Detail   string          `json:"detail"`
+}
+
+// Report is the outcome of an audit.
+type Report struct {
+	// From and Block are the first replayed block and the block the
+	// holders were confirmed at.
+	From     uint64    `json:"from"`
+	Block    uint64    `json:"block"`
+	Holders  []Holder  `json:"holders"`
+	Findings []Finding `json:"findings"`
+	// Admins lists the admin role of every role checked.
+	Admins []RoleAdmin `json:"admins"`
+}
+
+// RoleAdmin names the role administering a role on a contract, whose
+// holders can grant and revoke it.
+type RoleAdmin struct {
+	Contract  string      `json:"contract"`
+	Role      string      `json:"role"`
+	RoleHash  common.Hash `json:"role_hash"`
+	Admin     string      `json:"admin"`
+	AdminHash common.Hash `json:"admin_hash"`
+}
+
+// LoadReport reads a report written by WriteJSON.
+func LoadReport(path string) (*Report, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("access: parsing report: %w", err)
+	}
+	return &r, nil
+}
+
+// Confirmed returns the holders hasRole confirmed.
+func (r *Report) Confirmed() []Holder {
+	var out []Holder
+	for _, h := range r.Holders {
+		if h.Confirmed {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// RoleAdmins returns the admin role of role on the named contract and its
+// confirmed holders, implementing client.RoleAdmins. Roles the report did
+// not check are assumed to be administered by DEFAULT_ADMIN_ROLE.
+func (r *Report) RoleAdmins(contract string, role common.Hash) (common.Hash, []client.Admin) {
+	admin := roles.DefaultAdmin
+	for _, a := range r.Admins {
+		if a.Contract == contract && a.RoleHash == role {
+			admin = a.AdminHash
+			break
+		}
+	}
+	var admins []client.Admin
+	for _, h := range r.Confirmed() {
+		if h.Contract == contract && h.RoleHash == admin {
+			admins = append(admins, client.Admin{Account: h.Account, Name: h.Name})
+		}
+	}
+	return admin, admins
+}
+
+// WriteText writes the holders grouped by contract and role, then the
+// findings.
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "roles replayed from block %d, confirmed at block %d\n", r.From, r.Block); err != nil {
+		return err
+	}
+	var contract, role string
+	for _, h := range r.Holders {
+		if h.Contract != contract {
+			contract, role = h.Contract, ""
+			if _, err := fmt.Fprintf(w, "\n%s %s\n", h.Contract, h.ContractAddress.Hex()); err != nil {
+				return err
+			}
+		}
+		if h.Role != role {
+			role = h.Role
+			if _, err := fmt.Fprintf(w, "  %s\n", h.Role); err != nil {
+				return err
+			}
+		}
+		kind := "eoa"
+		if h.IsContract {
+			kind = "contract"
+		}
+		if h.Name != "" {
+			kind += " " + h.Name
+		}
+		if !h.Confirmed {
+			kind += ", not confirmed"
+		}
+		if _, err := fmt.Fprintf(w, "    %s (%s)\n", h.Account.Hex(), kind); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "\n%d findings\n", len(r.Findings)); err != nil {
+		return err
+	}
+	for _, f := range r.Findings {
+		if _, err := fmt.Fprintf(w, "%s\t%s %s\t%s\n", f.Kind, f.Contract, f.Role, f.Detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Auditor lists the role holders of every contract in an address book.
+type Auditor struct {
+	backend bind.ContractBackend
+	book    client.AddressBook
+	// From is the first block to replay, usually the deployment block.
+	From uint64
+	// To is the last block to replay and the block holders are confirmed
+	// at; nil means the latest block.
+	To *uint64
+	// ContractOnly are the roles an EOA must not hold.
+	ContractOnly []Rule
+	// Allow lists accounts that may hold ContractOnly roles anyway.
+	Allow []common.Address
+	// ChunkSize is passed to backfill.
+	ChunkSize uint64
+}
+
+// NewAuditor returns an Auditor for the contracts in book.
+func NewAuditor(backend bind.ContractBackend, book client.AddressBook) *Auditor {
+	return &Auditor{backend: backend, book: book, ContractOnly: DefaultContractOnly}
+}
+
+type grant struct {
+	sender common.Address
+	block  uint64
+}
+
+type roleKey struct {
+	contract common.Address
+	role     common.Hash
+}
+
+// Run replays the role events and confirms every holder.
+func (a *Auditor) Run(ctx context.Context) (*Report, error) {
+	to := a.To
+	if to == nil {
+		head, err := a.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("access: reading head: %w", err)
+		}
+		n := head.Number.Uint64()
+		to = &n
+	}
+	named := a.book.Named()
+	names := make(map[common.Address]string, len(named))
+	var addrs []common.Address
+	for name, addr := range named {
+		names[addr] = name
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return names[addrs[i]] < names[addrs[j]] })
+
+	// Replay.
+	holders := make(map[roleKey]map[common.Address]grant)
+	seen := make(map[roleKey]bool)
+	granted := parsedABI.Events["RoleGranted"].ID
+	revoked := parsedABI.Events["RoleRevoked"].ID
+	adminChanged := parsedABI.Events["RoleAdminChanged"].ID
+	cfg := backfill.Config{
+		Query:     ethereum.FilterQuery{Addresses: addrs, Topics: [][]common.Hash{{granted, revoked, adminChanged}}},
+		From:      a.From,
+		To:        *to,
+		ChunkSize: a.ChunkSize,
+	}
+	err := backfill.Run(ctx, a.backend, cfg, func(chunk backfill.Chunk) error {
+		for _, log := range chunk.Logs {
+			if len(log.Topics) != 4 {
+				continue
+			}
+			key := roleKey{contract: log.Address, role: log.Topics[1]}
+			seen[key] = true
+			account := common.BytesToAddress(log.Topics[2].Bytes())
+			switch log.Topics[0] {
+			case granted:
+				if holders[key] == nil {
+					holders[key] = make(map[common.Address]grant)
+				}
+				holders[key][account] = grant{sender: common.BytesToAddress(log.Topics[3].Bytes()), block: log.BlockNumber}
+			case revoked:
+				delete(holders[key], account)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("access: replaying role events: %w", err)
+	}
+
+	// Confirm.
+	report := &Report{From: a.From, Block: *to}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(*to)}
+	codes := make(map[common.Address]bool)
+	isContract := func(addr common.Address) (bool, error) {
+		if has, ok := codes[addr]; ok {
+			return has, nil
+		}
+		code, err := a.backend.CodeAt(ctx, addr, opts.BlockNumber)
+		if err != nil {
+			return false, err
+		}
+		codes[addr] = len(code) > 0
+		return codes[addr], nil
+	}
+	allowed := make(map[common.Address]bool, len(a.Allow))
+	for _, addr := range a.Allow {
+		allowed[addr] = true
+	}
+	contractOnly := make(map[string]map[common.Hash]bool)
+	for _, rule := range a.ContractOnly {
+		if contractOnly[rule.Contract] == nil {
+			contractOnly[rule.Contract] = make(map[common.Hash]bool)
+		}
+		contractOnly[rule.Contract][rule.Role] = true
+	}
+
+	for _, contract := range addrs {
+		name := names[contract]
+		bound := bind.NewBoundContract(contract, parsedABI, a.backend, a.backend, a.backend)
+		var unknown []common.Hash
+		for key := range seen {
+			if _, known := roles.Name(key.role); key.contract == contract && !known {
+				unknown = append(unknown, key.role)
+			}
+		}
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i].Hex() < unknown[j].Hex() })
+		checked := append(append([]common.Hash(nil), roles.All...), unknown...)
+
+		for _, role := range checked {
+			key := roleKey{contract: contract, role: role}
+			finding := func(kind Kind, account *common.Address, detail string) {
+				report.Findings = append(report.Findings, Finding{Kind: kind, Contract: name, Role: roles.String(role), Account: account, Detail: detail})
+			}
+			if _, known := roles.Name(role); !known {
+				finding(UnknownRole, nil, fmt.Sprintf("role %s is not a known role", role.Hex()))
+			}
+			var admin common.Hash
+			if err := call(bound, opts, &admin, "getRoleAdmin", role); err != nil {
+				return nil, fmt.Errorf("access: reading admin of %s on %s: %w", roles.String(role), name, err)
+			}
+			report.Admins = append(report.Admins, RoleAdmin{
+				Contract:  name,
+				Role:      roles.String(role),
+				RoleHash:  role,
+				Admin:     roles.String(admin),
+				AdminHash: admin,
+			})
+			if admin != roles.DefaultAdmin {
+				finding(AdminChanged, nil, fmt.Sprintf("administered by %s", roles.String(admin)))
+			}
+
+			// Candidates are the replayed holders and every contract in
+			// the address book, which catches grants outside the range.
+			candidates := make(map[common.Address]bool)
+			for account := range holders[key] {
+				candidates[account] = true
+			}
+			for _, addr := range addrs {
+				candidates[addr] = true
+			}
+			accounts := make([]common.Address, 0, len(candidates))
+			for account := range candidates {
+				accounts = append(accounts, account)
+			}
+			sort.Slice(accounts, func(i, j int) bool { return accounts[i].Cmp(accounts[j]) < 0 })
+
+			confirmed := 0
+			for _, account := range accounts {
+				var held bool
+				if err := call(bound, opts, &held, "hasRole", role, account); err != nil {
+					return nil, fmt.Errorf("access: checking %s of %s on %s: %w", roles.String(role), account.Hex(), name, err)
+				}
+				g, replayed := holders[key][account]
+				if !held && !replayed {
+					continue
+				}
+				account := account
+				code, err := isContract(account)
+				if err != nil {
+					return nil, fmt.Errorf("access: reading code of %s: %w", account.Hex(), err)
+				}
+				h := Holder{
+					Contract:        name,
+					ContractAddress: contract,
+					Role:            roles.String(role),
+					RoleHash:        role,
+					Account:         account,
+					Name:            names[account],
+					IsContract:      code,
+					Confirmed:       held,
+				}
+				if replayed {
+					sender := g.sender
+					h.GrantedBy, h.GrantedAt = &sender, g.block
+				}
+				report.Holders = append(report.Holders, h)
+				switch {
+				case !held:
+					finding(Stale, &account, fmt.Sprintf("%s granted in block %d but hasRole is false", account.Hex(), g.block))
+					continue
+				case !replayed:
+					finding(Unreplayed, &account, fmt.Sprintf("%s holds the role without a grant since block %d", account.Hex(), a.From))
+				}
+				confirmed++
+				if !code && contractOnly[name][role] && !allowed[account] {
+					finding(EOAHolder, &account, fmt.Sprintf("EOA %s holds %s, which only contracts should", account.Hex(), roles.String(role)))
+				}
+			}
+			if role == roles.DefaultAdmin && confirmed == 0 {
+				finding(NoAdmin, nil, "no account holds DEFAULT_ADMIN_ROLE")
+			}
+		}
+	}
+	return report, nil
+}
+
+// call calls a view method with a single result.
+func call(bound *bind.BoundContract, opts *bind.CallOpts, out interface{}, method string, args ...interface{}) error {
+	var res []interface{}
+	if err := bound.Call(opts, &res, method, args...); err != nil {
+		return err
+	}
+	if len(res) != 1 {
+		return fmt.Errorf("%s returned %d values", method, len(res))
+	}
+	switch out := out.(type) {
+	case *bool:
+		v, ok := res[0].(bool)
+		if !ok {
+			return fmt.Errorf("%s returned %T", method, res[0])
+		}
+		*out = v
+	case *common.Hash:
+		v, ok := res[0].([32]byte)
+		if !ok {
+			return fmt.Errorf("%s returned %T", method, res[0])
+		}
+		*out = v
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// roleChain holds role events, the hasRole and getRoleAdmin state they led
+// to and the accounts with code.
+type roleChain struct {
+	bind.ContractBackend
+
+	logs   []types.Log
+	held   map[common.Address]map[common.Hash]map[common.Address]bool
+	admin  map[common.Address]map[common.Hash]common.Hash
+	code   map[common.Address]bool
+	blocks map[uint64]bool
+}
+
+func newRoleChain(book client.AddressBook) *roleChain {
+	c := &roleChain{
+		held:   make(map[common.Address]map[common.Hash]map[common.Address]bool),
+		admin:  make(map[common.Address]map[common.Hash]common.Hash),
+		code:   make(map[common.Address]bool),
+		blocks: make(map[uint64]bool),
+	}
+	for _, addr := range book.Named() {
+		c.code[addr] = true
+	}
+	return c
+}
+
+// event appends a role event and applies it to the state unless stale.
+func (c *roleChain) event(name string, block uint64, contract common.Address, role common.Hash, account, sender common.Address, stale bool) {
+	c.logs = append(c.logs, types.Log{
+		Address:     contract,
+		Topics:      []common.Hash{parsedABI.Events[name].ID, role, common.BytesToHash(account.Bytes()), common.BytesToHash(sender.Bytes())},
+		BlockNumber: block,
+	})
+	if !stale {
+		c.set(contract, role, account, name == "RoleGranted")
+	}
+}
+
+func (c *roleChain) set(contract common.Address, role common.Hash, account common.Address, held bool) {
+	if c.held[contract] == nil {
+		c.held[contract] = make(map[common.Hash]map[common.Address]bool)
+	}
+	if c.held[contract][role] == nil {
+		c.held[contract][role] = make(map[common.Address]bool)
+	}
+	c.held[contract][role][account] = held
+}
+
+func (c *roleChain) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100)}, nil
+}
+
+func (c *roleChain) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var out []types.Log
+	for _, log := range c.logs {
+		if log.BlockNumber >= q.FromBlock.Uint64() && log.BlockNumber <= q.ToBlock.Uint64() {
+			out = append(out, log)
+		}
+	}
+	return out, nil
+}
+
+func (c *roleChain) CodeAt(_ context.Context, addr common.Address, _ *big.Int) ([]byte, error) {
+	if c.code[addr] {
+		return []byte{0x60}, nil
+	}
+	return nil, nil
+}
+
+func (c *roleChain) CallContract(_ context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	if block != nil {
+		c.blocks[block.Uint64()] = true
+	}
+	method, err := parsedABI.MethodById(msg.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	role := common.Hash(args[0].([32]byte))
+	switch method.Name {
+	case "hasRole":
+		return method.Outputs.Pack(c.held[*msg.To][role][args[1].(common.Address)])
+	case "getRoleAdmin":
+		return method.Outputs.Pack([32]byte(c.admin[*msg.To][role]))
+	}
+	return nil, errors.New("unexpected call")
+}
+
+type finding struct {
+	kind     Kind
+	contract string
+	account  common.Address
+}
+
+func TestAudit(t *testing.T) {
+	book := client.AddressBook{
+		Token:   common.HexToAddress("0x02"),
+		User:    common.HexToAddress("0x03"),
+		Storage: common.HexToAddress("0x05"),
+	}
+	admin, eoa, revoked, stale, other := common.HexToAddress("0xad"), common.HexToAddress("0xe0"), common.HexToAddress("0xe1"), common.HexToAddress("0xe2"), common.HexToAddress("0xe3")
+	custom := crypto.Keccak256Hash([]byte("CUSTOM_ROLE"))
+
+	chain := newRoleChain(book)
+	chain.event("RoleGranted", 1, book.Storage, roles.DefaultAdmin, admin, admin, false)
+	chain.event("RoleGranted", 2, book.Storage, roles.Controller, eoa, admin, false)
+	chain.event("RoleGranted", 3, book.Storage, roles.Controller, revoked, admin, false)
+	chain.event("RoleRevoked", 4, book.Storage, roles.Controller, revoked, admin, false)
+	chain.event("RoleGranted", 5, book.Storage, roles.Minter, stale, admin, true)
+	chain.event("RoleGranted", 6, book.User, roles.Controller, book.Storage, admin, false)
+	chain.event("RoleGranted", 7, book.User, custom, other, admin, false)
+	// Granted before the first replayed block: only the contracts of the
+	// address book are found as holders.
+	chain.set(book.Token, roles.DefaultAdmin, admin, true)
+	chain.set(book.Token, roles.Minter, book.User, true)
+	chain.admin[book.Token] = map[common.Hash]common.Hash{roles.Pauser: roles.Minter}
+
+	a := NewAuditor(chain, book)
+	a.From = 1
+	report, err := a.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Block != 100 || !reflect.DeepEqual(chain.blocks, map[uint64]bool{100: true}) {
+		t.Errorf("confirmed at block %d, called at %v, want the head 100", report.Block, chain.blocks)
+	}
+
+	var got []finding
+	for _, f := range report.Findings {
+		var account common.Address
+		if f.Account != nil {
+			account = *f.Account
+		}
+		got = append(got, finding{f.Kind, f.Contract, account})
+	}
+	want := []finding{
+		{EOAHolder, "LilypadStorage", eoa},
+		{Stale, "LilypadStorage", stale},
+		{NoAdmin, "LilypadToken", common.Address{}},
+		{Unreplayed, "LilypadToken", book.User},
+		{AdminChanged, "LilypadToken", common.Address{}},
+		{NoAdmin, "LilypadUser", common.Address{}},
+		{UnknownRole, "LilypadUser", common.Address{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings\n%+v\nwant\n%+v", got, want)
+	}
+
+	var holders []string
+	for _, h := range report.Holders {
+		holders = append(holders, h.Contract+" "+h.Role+" "+h.Account.Hex())
+	}
+	wantHolders := []string{
+		"LilypadStorage DEFAULT_ADMIN_ROLE " + admin.Hex(),
+		"LilypadStorage CONTROLLER_ROLE " + eoa.Hex(),
+		"LilypadStorage MINTER_ROLE " + stale.Hex(),
+		"LilypadToken MINTER_ROLE " + book.User.Hex(),
+		"LilypadUser CONTROLLER_ROLE " + book.Storage.Hex(),
+		"LilypadUser " + roles.String(custom) + " " + other.Hex(),
+	}
+	if !reflect.DeepEqual(holders, wantHolders) {
+		t.Errorf("holders\n%v\nwant\n%v", holders, wantHolders)
+	}
+	h := report.Holders[1]
+	if h.GrantedBy == nil || *h.GrantedBy != admin || h.GrantedAt != 2 || h.IsContract || !h.Confirmed {
+		t.Errorf("controller holder %+v", h)
+	}
+	if h := report.Holders[4]; !h.IsContract || h.Name != "LilypadStorage" {
+		t.Errorf("contract holder %+v", h)
+	}
+
+	// The admins of a role come from the confirmed holders of its admin
+	// role, and survive a round trip through a report file.
+	path := filepath.Join(t.TempDir(), "audit.json")
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		contract string
+		role     common.Hash
+		admin    common.Hash
+		admins   []client.Admin
+	}{
+		{"LilypadToken", roles.Pauser, roles.Minter, []client.Admin{{Account: book.User, Name: "LilypadUser"}}},
+		{"LilypadStorage", roles.Controller, roles.DefaultAdmin, []client.Admin{{Account: admin}}},
+		{"LilypadUser", roles.Controller, roles.DefaultAdmin, nil},
+	} {
+		adminRole, admins := loaded.RoleAdmins(tc.contract, tc.role)
+		if adminRole != tc.admin || !reflect.DeepEqual(admins, tc.admins) {
+			t.Errorf("RoleAdmins(%s, %s) = %s %v, want %s %v", tc.contract, roles.String(tc.role), roles.String(adminRole), admins, roles.String(tc.admin), tc.admins)
+		}
+	}
+
+	// Allowed accounts may hold contract-only roles.
+	a.Allow = []common.Address{eoa}
+	report, err = a.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Findings[0].Kind == EOAHolder {
+		t.Errorf("allowed EOA reported: %+v", report.Findings[0])
+	}
+}
@@ -0,0 +1,357 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Requirement is what a state-changing method asks of its caller, as
+// declared by its modifiers and guards in the Solidity source.
+type Requirement struct {
+	// Role is the AccessControl role the caller must hold; the zero value
+	// with NeedsRole false means anyone may call.
+	Role      common.Hash
+	NeedsRole bool
+	// RoleAdmin marks grantRole and revokeRole, which need the admin role
+	// of their role argument.
+	RoleAdmin bool
+	// Condition describes a check on the caller beyond roles, such as
+	// owning the module or being the beneficiary.
+	Condition string
+	// Pausable methods move LilypadToken balances and revert while the
+	// token is paused.
+	Pausable bool
+}
+
+func (r Requirement) String() string {
+	switch {
+	case r.RoleAdmin:
+		return "admin of the role"
+	case r.NeedsRole:
+		return roles.String(r.Role)
+	case r.Condition != "":
+		return r.Condition
+	}
+	return "anyone"
+}
+
+func needsRole(h common.Hash) Requirement { return Requirement{Role: h, NeedsRole: true} }
+func needsCaller(s string) Requirement    { return Requirement{Condition: s} }
+func pausable(r Requirement) Requirement  { r.Pausable = true; return r }
+
+var (
+	adminOnly      = needsRole(roles.DefaultAdmin)
+	controllerOnly = needsRole(roles.Controller)
+	anyone         = Requirement{}
+	initializer    = needsCaller("initializer, callable once at deployment")
+)
+
+// accessControlMethods are the AccessControl methods every contract has.
+var accessControlMethods = map[string]Requirement{
+	"grantRole":    {RoleAdmin: true},
+	"revokeRole":   {RoleAdmin: true},
+	"renounceRole": needsCaller("only the caller's own roles"),
+}
+
+// Requirements maps contract name and method name to what the method
+// requires of its caller. The AccessControl methods grantRole, revokeRole
+// and renounceRole are common to every contract and not repeated here.
+var Requirements = map[string]map[string]Requirement{
+	"LilypadContractRegistry": {
+		"initialize":                       initializer,
+		"setL2LilypadTokenAddress":         adminOnly,
+		"setLilypadUserAddress":            adminOnly,
+		"setLilypadModuleDirectoryAddress": adminOnly,
+		"setLilypadStorageAddress":         adminOnly,
+		"setLilypadPaymentEngineAddress":   adminOnly,
+		"setLilypadProxyAddress":           adminOnly,
+		"setLilypadVestingAddress":         adminOnly,
+	},
+	"LilypadModuleDirectory": {
+		"initialize":               initializer,
+		"registerModuleCreator":    controllerOnly,
+		"registerModuleForCreator": controllerOnly,
+		"updateModuleName":         needsCaller("module owner"),
+		"updateModuleUrl":          needsCaller("module owner"),
+		"approveTransfer":          needsCaller("module owner"),
+		"transferModuleOwnership":  needsCaller("module owner"),
+		"revokeTransferApproval":   needsCaller("module owner"),
+		"setLilypadUser":           adminOnly,
+	},
+	"LilypadPaymentEngine": {
+		"initialize":                   initializer,
+		"setTreasuryWallet":            adminOnly,
+		"setValueBasedRewardsWallet":   adminOnly,
+		"setValidationPoolWallet":      adminOnly,
+		"setLilypadTokenomics":         adminOnly,
+		"setLilypadUser":               adminOnly,
+		"setLilypadStorage":            adminOnly,
+		"setL2Token":                   adminOnly,
+		"payEscrow":                    pausable(controllerOnly),
+		"withdrawEscrow":               pausable(needsCaller("the withdrawer, after the collateral lock")),
+		"initiateLockupOfEscrowForJob": controllerOnly,
+		"updateActiveBurnTokens":       controllerOnly,
+		"handleJobCompletion":          pausable(controllerOnly),
+		"handleJobFailure":             pausable(controllerOnly),
+		"handleValidationPassed":       pausable(controllerOnly),
+		"handleValidationFailed":       pausable(controllerOnly),
+	},
+	"LilypadProxy": {
+		"initialize":                       initializer,
+		"setStorageContract":               adminOnly,
+		"setPaymentEngineContract":         adminOnly,
+		"setUserContract":                  adminOnly,
+		"setL2LilypadTokenContract":        adminOnly,
+		"acceptJobPayment":                 pausable(anyone),
+		"acceptResourceProviderCollateral": pausable(needsCaller("resource provider or unregistered account")),
+		"setDeal":                          controllerOnly,
+		"setResult":                        controllerOnly,
+	},
+	"LilypadStorage": {
+		"initialize":                   initializer,
+		"changeDealStatus":             controllerOnly,
+		"changeValidationResultStatus": controllerOnly,
+		"changeResultStatus":           controllerOnly,
+		"saveResult":                   controllerOnly,
+		"saveDeal":                     controllerOnly,
+		"saveValidationResult":         controllerOnly,
+	},
+	"LilypadToken": {
+		"setAlpha":     adminOnly,
+		"mint":         pausable(needsRole(roles.Minter)),
+		"burn":         pausable(anyone),
+		"burnFrom":     pausable(needsCaller("allowance from the holder")),
+		"pause":        needsRole(roles.Pauser),
+		"unpause":      needsRole(roles.Pauser),
+		"approve":      anyone,
+		"transfer":     pausable(anyone),
+		"transferFrom": pausable(needsCaller("allowance from the holder")),
+	},
+	"LilypadTokenomics": {
+		"initialize":                            initializer,
+		"setPvalues":                            adminOnly,
+		"setP":                                  adminOnly,
+		"setM":                                  adminOnly,
+		"setVValues":                            adminOnly,
+		"setResourceProviderActiveEscrowScaler": adminOnly,
+	},
+	"LilypadUser": {
+		"initialize":         initializer,
+		"insertUser":         controllerOnly,
+		"updateUserMetadata": controllerOnly,
+		"addRole":            controllerOnly,
+		"removeRole":         controllerOnly,
+	},
+	"LilypadValidation": {
+		"initialize":        initializer,
+		"requestValidation": controllerOnly,
+		"processValidation": controllerOnly,
+	},
+	"LilypadVesting": {
+		"createVestingSchedule": pausable(needsRole(roles.Vesting)),
+		"releaseTokens":         pausable(needsCaller("the schedule's beneficiary")),
+		"withdraw":              pausable(needsRole(roles.Vesting)),
+	},
+}
+
+// Verdict is whether an account may call a method.
+type Verdict string
+
+const (
+	// Allowed methods pass every check on the caller.
+	Allowed Verdict = "allowed"
+	// Denied methods revert for the account whatever the arguments.
+	Denied Verdict = "denied"
+	// Conditional methods depend on the arguments or on state other than
+	// roles, see Capability.Reason.
+	Conditional Verdict = "conditional"
+	// Unknown methods have no entry in Requirements.
+	Unknown Verdict = "unknown"
+)
+
+// Capability is whether an account may call a method.
+type Capability struct {
+	Contract        string         `json:"contract"`
+	ContractAddress common.Address `json:"contract_address"`
+	Method          string         `json:"method"`
+	// Role is the role argument of grantRole and revokeRole, which are
+	// listed once per known role.
+	Role     string  `json:"role,omitempty"`
+	Requires string  `json:"requires"`
+	Verdict  Verdict `json:"verdict"`
+	Reason   string  `json:"reason"`
+}
+
+// Matrix lists the capabilities of an account on every contract.
+type Matrix struct {
+	Account      common.Address `json:"account"`
+	Block        uint64         `json:"block"`
+	Capabilities []Capability   `json:"capabilities"`
+}
+
+// Lookup returns the capability of method on contract. For grantRole and
+// revokeRole it returns the first role listed; use the Capabilities for
+// the others.
+func (m *Matrix) Lookup(contract, method string) (Capability, bool) {
+	for _, c := range m.Capabilities {
+		if c.Contract == contract && c.Method == method {
+			return c, true
+		}
+	}
+	return Capability{}, false
+}
+
+// WriteText writes one line per method, grouped by contract.
+func (m *Matrix) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "capabilities of %s at block %d\n", m.Account.Hex(), m.Block); err != nil {
+		return err
+	}
+	var contract string
+	for _, c := range m.Capabilities {
+		if c.Contract != contract {
+			contract = c.Contract
+			if _, err := fmt.Fprintf(w, "\n%s %s\n", c.Contract, c.ContractAddress.Hex()); err != nil {
+				return err
+			}
+		}
+		method := c.Method
+		if c.Role != "" {
+			method += "(" + c.Role + ")"
+		}
+		if _, err := fmt.Fprintf(w, "  %-12s %-48s %s\n", c.Verdict, method, c.Reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the matrix as indented JSON.
+func (m *Matrix) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// Capabilities checks which state-changing methods of every contract in
+// book account may call, at the latest block. Methods come from the
+// contract ABIs, requirements from Requirements and role membership from
+// live hasRole and getRoleAdmin calls.
+func Capabilities(ctx context.Context, backend bind.ContractBackend, book client.AddressBook, account common.Address) (*Matrix, error) {
+	head, err := backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("access: reading head: %w", err)
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).Set(head.Number)}
+	m := &Matrix{Account: account, Block: head.Number.Uint64()}
+
+	paused := false
+	if book.Token != (common.Address{}) {
+		parsed, ok := client.ContractABI("LilypadToken")
+		if !ok {
+			return nil, fmt.Errorf("access: no LilypadToken ABI")
+		}
+		token := bind.NewBoundContract(book.Token, *parsed, backend, backend, backend)
+		if err := call(token, opts, &paused, "paused"); err != nil {
+			return nil, fmt.Errorf("access: reading token pause state: %w", err)
+		}
+	}
+
+	named := book.Named()
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addr := named[name]
+		parsed, ok := client.ContractABI(name)
+		if !ok {
+			continue
+		}
+		bound := bind.NewBoundContract(addr, parsedABI, backend, backend, backend)
+		held := make(map[common.Hash]bool)
+		hasRole := func(role common.Hash) (bool, error) {
+			if h, ok := held[role]; ok {
+				return h, nil
+			}
+			var h bool
+			if err := call(bound, opts, &h, "hasRole", role, account); err != nil {
+				return false, fmt.Errorf("access: checking %s on %s: %w", roles.String(role), name, err)
+			}
+			held[role] = h
+			return h, nil
+		}
+
+		methods := make([]string, 0, len(parsed.Methods))
+		for method, def := range parsed.Methods {
+			if !def.IsConstant() {
+				methods = append(methods, method)
+			}
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			req, known := Requirements[name][method]
+			if !known {
+				req, known = accessControlMethods[method]
+			}
+			c := Capability{Contract: name, ContractAddress: addr, Method: method}
+			switch {
+			case !known:
+				c.Verdict, c.Reason = Unknown, "no entry in the requirement table"
+				m.Capabilities = append(m.Capabilities, c)
+				continue
+			case req.RoleAdmin:
+				for _, target := range roles.All {
+					var adminRole common.Hash
+					if err := call(bound, opts, &adminRole, "getRoleAdmin", target); err != nil {
+						return nil, fmt.Errorf("access: reading admin of %s on %s: %w", roles.String(target), name, err)
+					}
+					h, err := hasRole(adminRole)
+					if err != nil {
+						return nil, err
+					}
+					c := c
+					c.Role, c.Requires = roles.String(target), roles.String(adminRole)
+					c.Verdict, c.Reason = verdict(h, c.Requires)
+					m.Capabilities = append(m.Capabilities, c)
+				}
+				continue
+			}
+			c.Requires = req.String()
+			switch {
+			case req.NeedsRole:
+				h, err := hasRole(req.Role)
+				if err != nil {
+					return nil, err
+				}
+				c.Verdict, c.Reason = verdict(h, c.Requires)
+			case req.Condition != "":
+				c.Verdict, c.Reason = Conditional, "requires "+req.Condition
+			default:
+				c.Verdict, c.Reason = Allowed, "open to anyone"
+			}
+			if req.Pausable && paused && c.Verdict != Denied {
+				c.Verdict, c.Reason = Denied, "LilypadToken is paused"
+			}
+			m.Capabilities = append(m.Capabilities, c)
+		}
+	}
+	return m, nil
+}
+
+func verdict(held bool, role string) (Verdict, string) {
+	if held {
+		return Allowed, "holds " + role
+	}
+	return Denied, "lacks " + role
+}
@@ -0,0 +1,137 @@
+package access
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// The hand-written table must follow the bindings: every state-changing
+// method of every contract has a requirement, and every requirement names
+// a method the contract has.
+func TestRequirementsCoverABIs(t *testing.T) {
+	var names []string
+	for name := range fullBook().Named() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parsed, ok := client.ContractABI(name)
+		if !ok {
+			t.Errorf("no ABI for %s", name)
+			continue
+		}
+		for method, def := range parsed.Methods {
+			if def.IsConstant() {
+				continue
+			}
+			_, listed := Requirements[name][method]
+			if _, shared := accessControlMethods[method]; !listed && !shared {
+				t.Errorf("%s.%s has no requirement", name, method)
+			}
+		}
+		for method := range Requirements[name] {
+			if def, ok := parsed.Methods[method]; !ok || def.IsConstant() {
+				t.Errorf("%s.%s is not a state-changing method of the ABI", name, method)
+			}
+		}
+	}
+	for name := range Requirements {
+		if _, ok := fullBook().Named()[name]; !ok {
+			t.Errorf("requirements for unknown contract %s", name)
+		}
+	}
+}
+
+// pausedChain is a roleChain that also answers LilypadToken.paused.
+type pausedChain struct {
+	*roleChain
+	paused bool
+}
+
+func (c *pausedChain) CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	token, _ := client.ContractABI("LilypadToken")
+	if method := token.Methods["paused"]; string(msg.Data[:4]) == string(method.ID) {
+		return method.Outputs.Pack(c.paused)
+	}
+	return c.roleChain.CallContract(ctx, msg, block)
+}
+
+func TestCapabilities(t *testing.T) {
+	book := fullBook()
+	account := common.HexToAddress("0xa1")
+	chain := &pausedChain{roleChain: newRoleChain(book)}
+	chain.set(book.Proxy, roles.Controller, account, true)
+	chain.set(book.Token, roles.Minter, account, true)
+	chain.set(book.Vesting, roles.Vesting, account, true)
+	chain.set(book.Registry, roles.DefaultAdmin, account, true)
+	chain.admin[book.Token] = map[common.Hash]common.Hash{roles.Pauser: roles.Minter}
+
+	for _, tc := range []struct {
+		paused   bool
+		contract string
+		method   string
+		verdict  Verdict
+		reason   string
+	}{
+		{false, "LilypadProxy", "setDeal", Allowed, "holds CONTROLLER_ROLE"},
+		{false, "LilypadStorage", "saveDeal", Denied, "lacks CONTROLLER_ROLE"},
+		{false, "LilypadToken", "mint", Allowed, "holds MINTER_ROLE"},
+		{false, "LilypadToken", "pause", Denied, "lacks PAUSER_ROLE"},
+		{false, "LilypadVesting", "withdraw", Allowed, "holds VESTING_ROLE"},
+		{false, "LilypadContractRegistry", "setLilypadUserAddress", Allowed, "holds DEFAULT_ADMIN_ROLE"},
+		{false, "LilypadProxy", "setUserContract", Denied, "lacks DEFAULT_ADMIN_ROLE"},
+		{false, "LilypadModuleDirectory", "updateModuleName", Conditional, "requires module owner"},
+		{false, "LilypadProxy", "acceptJobPayment", Allowed, "open to anyone"},
+		// A paused token denies the methods that move it, whatever the
+		// caller holds, and leaves the others alone.
+		{true, "LilypadToken", "mint", Denied, "LilypadToken is paused"},
+		{true, "LilypadVesting", "withdraw", Denied, "LilypadToken is paused"},
+		{true, "LilypadVesting", "releaseTokens", Denied, "LilypadToken is paused"},
+		{true, "LilypadProxy", "acceptJobPayment", Denied, "LilypadToken is paused"},
+		{true, "LilypadStorage", "saveDeal", Denied, "lacks CONTROLLER_ROLE"},
+		{true, "LilypadProxy", "setDeal", Allowed, "holds CONTROLLER_ROLE"},
+	} {
+		chain.paused = tc.paused
+		m, err := Capabilities(context.Background(), chain, book, account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, ok := m.Lookup(tc.contract, tc.method)
+		if !ok || c.Verdict != tc.verdict || c.Reason != tc.reason {
+			t.Errorf("paused %t: %s.%s = %s %q, want %s %q", tc.paused, tc.contract, tc.method, c.Verdict, c.Reason, tc.verdict, tc.reason)
+		}
+	}
+
+	// grantRole is listed per role and needs that role's admin.
+	m, err := Capabilities(context.Background(), chain, book, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Block != 100 {
+		t.Errorf("Block = %d, want 100", m.Block)
+	}
+	grants := make(map[string]Capability)
+	for _, c := range m.Capabilities {
+		if c.Method == "grantRole" {
+			grants[c.Contract+" "+c.Role] = c
+		}
+	}
+	for key, want := range map[string]Verdict{
+		"LilypadContractRegistry CONTROLLER_ROLE": Allowed,
+		"LilypadToken PAUSER_ROLE":                Allowed,
+		"LilypadToken MINTER_ROLE":                Denied,
+		"LilypadUser CONTROLLER_ROLE":             Denied,
+	} {
+		if c := grants[key]; c.Verdict != want {
+			t.Errorf("grantRole on %s = %s (requires %s), want %s", key, c.Verdict, c.Requires, want)
+		}
+	}
+}
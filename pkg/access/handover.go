@@ -0,0 +1,299 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// ErrStranded is returned when a contract has neither the old nor the new
+// admin holding DEFAULT_ADMIN_ROLE; the handover cannot grant it there.
+var ErrStranded = errors.New("access: contract has neither admin")
+
+// ErrMissingContract is returned when the address book lacks a contract,
+// typically LilypadTokenomics or LilypadValidation, which the registry does
+// not track. Its admin would silently stay with the old account; set the
+// address or list the contract in Handover.Exclude.
+var ErrMissingContract = errors.New("access: contract missing from the address book")
+
+// AdminState is who holds DEFAULT_ADMIN_ROLE on a contract.
+type AdminState struct {
+	Contract        string         `json:"contract"`
+	ContractAddress common.Address `json:"contract_address"`
+	Old             bool           `json:"old"`
+	New             bool           `json:"new"`
+}
+
+// HandoverStatus is the progress of a handover as read from the chain.
+type HandoverStatus struct {
+	Block     uint64         `json:"block"`
+	Old       common.Address `json:"old"`
+	New       common.Address `json:"new"`
+	Contracts []AdminState   `json:"contracts"`
+	// Missing names the contracts without an address in the book that were
+	// not excluded; the handover cannot complete while there are any.
+	Missing []string `json:"missing,omitempty"`
+	// Excluded names the contracts left out of the handover on purpose.
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// Granted reports whether the new admin holds the role everywhere.
+func (s *HandoverStatus) Granted() bool {
+	if len(s.Missing) > 0 {
+		return false
+	}
+	for _, c := range s.Contracts {
+		if !c.New {
+			return false
+		}
+	}
+	return true
+}
+
+// Done reports whether the new admin holds the role everywhere and the old
+// one nowhere. It is false while contracts are missing from the book.
+func (s *HandoverStatus) Done() bool {
+	if len(s.Missing) > 0 {
+		return false
+	}
+	for _, c := range s.Contracts {
+		if !c.New || c.Old {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteText writes one line per contract.
+func (s *HandoverStatus) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "DEFAULT_ADMIN_ROLE %s -> %s at block %d\n", s.Old.Hex(), s.New.Hex(), s.Block); err != nil {
+		return err
+	}
+	for _, c := range s.Contracts {
+		var state string
+		switch {
+		case c.New && !c.Old:
+			state = "done"
+		case c.New:
+			state = "granted, old admin to renounce"
+		case c.Old:
+			state = "to grant"
+		default:
+			state = "stranded, neither admin holds the role"
+		}
+		if _, err := fmt.Fprintf(w, "  %-24s %s  %s\n", c.Contract, c.ContractAddress.Hex(), state); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.Missing {
+		if _, err := fmt.Fprintf(w, "  %-24s %-42s  missing, set its address or exclude it\n", name, "-"); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.Excluded {
+		if _, err := fmt.Fprintf(w, "  %-24s %-42s  excluded\n", name, "-"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the status as indented JSON.
+func (s *HandoverStatus) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// Handover moves DEFAULT_ADMIN_ROLE from Old to New on every contract of an
+// address book, typically from the deployer EOA to a multisig.
+//
+// It runs in two phases. The grant phase grants the role to New wherever it
+// lacks it and then confirms with hasRole that New holds it on every
+// contract. Only then does the renounce phase have Old renounce the role,
+// contract by contract. Both phases skip contracts that are already done,
+// so an interrupted handover is resumed by running it again.
+//
+// Every contract of the book takes part. A contract without an address,
+// such as LilypadTokenomics or LilypadValidation when they were not set,
+// stops the handover with ErrMissingContract unless it is listed in
+// Exclude.
+type Handover struct {
+	backend   bind.ContractBackend
+	contracts []namedAddress
+	missing   []string
+	Old, New  common.Address
+	// AllowEOA permits a New without code. Handing the admin role to
+	// another EOA is usually a mistake.
+	AllowEOA bool
+	// Exclude names contracts to leave out of the handover, e.g.
+	// LilypadTokenomics on a deployment without it. Excluded contracts
+	// keep their admin.
+	Exclude []string
+	// Admins, if set, suggests who can grant DEFAULT_ADMIN_ROLE when a
+	// call is denied.
+	Admins client.RoleAdmins
+}
+
+type namedAddress struct {
+	name string
+	addr common.Address
+}
+
+// NewHandover returns a Handover of the contracts in book.
+func NewHandover(backend bind.ContractBackend, book client.AddressBook, oldAdmin, newAdmin common.Address) *Handover {
+	var contracts []namedAddress
+	for name, addr := range book.Named() {
+		contracts = append(contracts, namedAddress{name, addr})
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].name < contracts[j].name })
+	return &Handover{backend: backend, contracts: contracts, missing: book.Missing(), Old: oldAdmin, New: newAdmin}
+}
+
+func (h *Handover) excluded(name string) bool {
+	for _, ex := range h.Exclude {
+		if ex == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Status reads who holds DEFAULT_ADMIN_ROLE on every contract at the latest
+// block.
+func (h *Handover) Status(ctx context.Context) (*HandoverStatus, error) {
+	head, err := h.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("access: reading head: %w", err)
+	}
+	status := &HandoverStatus{Block: head.Number.Uint64(), Old: h.Old, New: h.New}
+	for _, name := range h.missing {
+		if h.excluded(name) {
+			status.Excluded = append(status.Excluded, name)
+		} else {
+			status.Missing = append(status.Missing, name)
+		}
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).Set(head.Number)}
+	for _, c := range h.contracts {
+		if h.excluded(c.name) {
+			status.Excluded = append(status.Excluded, c.name)
+			continue
+		}
+		bound := bind.NewBoundContract(c.addr, parsedABI, h.backend, h.backend, h.backend)
+		state := AdminState{Contract: c.name, ContractAddress: c.addr}
+		if err := call(bound, opts, &state.Old, "hasRole", roles.DefaultAdmin, h.Old); err != nil {
+			return nil, fmt.Errorf("access: checking old admin on %s: %w", c.name, err)
+		}
+		if err := call(bound, opts, &state.New, "hasRole", roles.DefaultAdmin, h.New); err != nil {
+			return nil, fmt.Errorf("access: checking new admin on %s: %w", c.name, err)
+		}
+		status.Contracts = append(status.Contracts, state)
+	}
+	sort.Strings(status.Excluded)
+	return status, nil
+}
+
+// Run carries out or resumes the handover, sending from opts.From, which
+// must be Old. It returns the mined transactions, including those sent
+// before a failure. Nothing is renounced unless hasRole confirms New on
+// every contract after the grant phase.
+func (h *Handover) Run(ctx context.Context, receipts bind.DeployBackend, opts *bind.TransactOpts) ([]*types.Transaction, error) {
+	if opts.From != h.Old {
+		return nil, fmt.Errorf("access: handover must be sent by the old admin %s, not %s", h.Old.Hex(), opts.From.Hex())
+	}
+	if h.New == (common.Address{}) || h.New == h.Old {
+		return nil, fmt.Errorf("access: invalid new admin %s", h.New.Hex())
+	}
+	if !h.AllowEOA {
+		code, err := h.backend.CodeAt(ctx, h.New, nil)
+		if err != nil {
+			return nil, fmt.Errorf("access: reading code of %s: %w", h.New.Hex(), err)
+		}
+		if len(code) == 0 {
+			return nil, fmt.Errorf("access: new admin %s has no code; set AllowEOA to hand over to an EOA", h.New.Hex())
+		}
+	}
+
+	status, err := h.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingContract, strings.Join(status.Missing, ", "))
+	}
+	for _, c := range status.Contracts {
+		if !c.Old && !c.New {
+			return nil, fmt.Errorf("%w: %s", ErrStranded, c.Contract)
+		}
+	}
+
+	var sent []*types.Transaction
+	for _, c := range status.Contracts {
+		if c.New {
+			continue
+		}
+		tx, err := h.send(ctx, receipts, opts, c, "grantRole", h.New)
+		if tx != nil {
+			sent = append(sent, tx)
+		}
+		if err != nil {
+			return sent, err
+		}
+	}
+
+	if status, err = h.Status(ctx); err != nil {
+		return sent, err
+	}
+	for _, c := range status.Contracts {
+		if !c.New {
+			return sent, fmt.Errorf("access: %s does not hold DEFAULT_ADMIN_ROLE on %s after the grant phase; nothing renounced", h.New.Hex(), c.Contract)
+		}
+	}
+
+	for _, c := range status.Contracts {
+		if !c.Old {
+			continue
+		}
+		tx, err := h.send(ctx, receipts, opts, c, "renounceRole", h.Old)
+		if tx != nil {
+			sent = append(sent, tx)
+		}
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// send calls method(DEFAULT_ADMIN_ROLE, account) on a contract and waits
+// for it to be mined.
+func (h *Handover) send(ctx context.Context, receipts bind.DeployBackend, opts *bind.TransactOpts, c AdminState, method string, account common.Address) (*types.Transaction, error) {
+	bound := bind.NewBoundContract(c.ContractAddress, parsedABI, h.backend, h.backend, h.backend)
+	o := *opts
+	o.Context = ctx
+	tx, err := bound.Transact(&o, method, roles.DefaultAdmin, account)
+	if err != nil {
+		return nil, fmt.Errorf("access: %s on %s: %w", method, c.Contract, client.Explain(err, c.Contract, method, opts.From, h.Admins))
+	}
+	receipt, err := bind.WaitMined(ctx, receipts, tx)
+	if err != nil {
+		return nil, fmt.Errorf("access: waiting for %s: %w", tx.Hash().Hex(), err)
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		return tx, fmt.Errorf("access: %s on %s: transaction %s reverted", method, c.Contract, tx.Hash().Hex())
+	}
+	return tx, nil
+}
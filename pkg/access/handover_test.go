@@ -0,0 +1,226 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// fakeChain answers hasRole from a table of DEFAULT_ADMIN_ROLE holders and
+// applies the grantRole and renounceRole transactions sent to it.
+type fakeChain struct {
+	admins map[common.Address]map[common.Address]bool
+	// rejectGrant fails sending grantRole to a contract, revertGrant mines it
+	// as reverted and ignoreGrant mines it without effect.
+	rejectGrant, revertGrant, ignoreGrant common.Address
+
+	sent     []string
+	receipts map[common.Hash]*types.Receipt
+}
+
+func newFakeChain(book client.AddressBook, admin common.Address) *fakeChain {
+	f := &fakeChain{admins: make(map[common.Address]map[common.Address]bool), receipts: make(map[common.Hash]*types.Receipt)}
+	for _, addr := range book.Named() {
+		f.admins[addr] = map[common.Address]bool{admin: true}
+	}
+	return f
+}
+
+func (f *fakeChain) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100)}, nil
+}
+
+func (f *fakeChain) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (f *fakeChain) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	method, err := parsedABI.MethodById(msg.Data[:4])
+	if err != nil || method.Name != "hasRole" {
+		return nil, errors.New("unexpected call")
+	}
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	role, account := args[0].([32]byte), args[1].(common.Address)
+	return method.Outputs.Pack(role == roles.DefaultAdmin && f.admins[*msg.To][account])
+}
+
+func (f *fakeChain) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	method, err := parsedABI.MethodById(tx.Data()[:4])
+	if err != nil {
+		return err
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return err
+	}
+	to, account := *tx.To(), args[1].(common.Address)
+	if method.Name == "grantRole" && to == f.rejectGrant {
+		return errors.New("rejected")
+	}
+	f.sent = append(f.sent, method.Name)
+	status := types.ReceiptStatusSuccessful
+	switch {
+	case method.Name == "grantRole" && to == f.revertGrant:
+		status = types.ReceiptStatusFailed
+	case method.Name == "grantRole" && to == f.ignoreGrant:
+	case method.Name == "grantRole":
+		f.admins[to][account] = true
+	case method.Name == "renounceRole":
+		delete(f.admins[to], account)
+	}
+	f.receipts[tx.Hash()] = &types.Receipt{Status: status, TxHash: tx.Hash(), BlockNumber: big.NewInt(101)}
+	return nil
+}
+
+func (f *fakeChain) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := f.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (f *fakeChain) PendingCodeAt(ctx context.Context, addr common.Address) ([]byte, error) {
+	return f.CodeAt(ctx, addr, nil)
+}
+
+func (f *fakeChain) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return uint64(len(f.sent)), nil
+}
+
+func (f *fakeChain) SuggestGasPrice(context.Context) (*big.Int, error)  { return big.NewInt(1), nil }
+func (f *fakeChain) SuggestGasTipCap(context.Context) (*big.Int, error) { return big.NewInt(1), nil }
+func (f *fakeChain) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 100000, nil
+}
+
+func (f *fakeChain) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (f *fakeChain) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func fullBook() client.AddressBook {
+	return client.AddressBook{
+		Registry:        common.HexToAddress("0x01"),
+		Token:           common.HexToAddress("0x02"),
+		User:            common.HexToAddress("0x03"),
+		ModuleDirectory: common.HexToAddress("0x04"),
+		Storage:         common.HexToAddress("0x05"),
+		PaymentEngine:   common.HexToAddress("0x06"),
+		Proxy:           common.HexToAddress("0x07"),
+		Vesting:         common.HexToAddress("0x08"),
+		Tokenomics:      common.HexToAddress("0x09"),
+		Validation:      common.HexToAddress("0x0a"),
+	}
+}
+
+func transactor(t *testing.T) *bind.TransactOpts {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestHandoverGrantFailureRenouncesNothing(t *testing.T) {
+	storage := fullBook().Storage
+	tests := []struct {
+		name  string
+		setup func(f *fakeChain)
+	}{
+		{"rejected grant", func(f *fakeChain) { f.rejectGrant = storage }},
+		{"reverted grant", func(f *fakeChain) { f.revertGrant = storage }},
+		{"grant without effect", func(f *fakeChain) { f.ignoreGrant = storage }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := transactor(t)
+			book := fullBook()
+			chain := newFakeChain(book, opts.From)
+			tt.setup(chain)
+			h := NewHandover(chain, book, opts.From, common.HexToAddress("0xad"))
+			if _, err := h.Run(context.Background(), chain, opts); err == nil {
+				t.Fatal("Run succeeded, want the grant failure")
+			}
+			for _, method := range chain.sent {
+				if method == "renounceRole" {
+					t.Fatalf("renounceRole sent after a failed grant phase: %v", chain.sent)
+				}
+			}
+			for addr, admins := range chain.admins {
+				if !admins[opts.From] {
+					t.Errorf("old admin lost the role on %s", addr.Hex())
+				}
+			}
+		})
+	}
+}
+
+func TestHandoverRun(t *testing.T) {
+	opts := transactor(t)
+	book := fullBook()
+	chain := newFakeChain(book, opts.From)
+	newAdmin := common.HexToAddress("0xad")
+	h := NewHandover(chain, book, opts.From, newAdmin)
+	sent, err := h.Run(context.Background(), chain, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := 2 * len(book.Named()); len(sent) != want {
+		t.Errorf("%d transactions, want %d", len(sent), want)
+	}
+	status, err := h.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Done() {
+		t.Errorf("status not done after Run: %+v", status.Contracts)
+	}
+}
+
+func TestHandoverMissingContract(t *testing.T) {
+	opts := transactor(t)
+	book := fullBook()
+	book.Validation = common.Address{}
+	chain := newFakeChain(book, opts.From)
+	h := NewHandover(chain, book, opts.From, common.HexToAddress("0xad"))
+
+	if _, err := h.Run(context.Background(), chain, opts); !errors.Is(err, ErrMissingContract) {
+		t.Fatalf("Run = %v, want ErrMissingContract", err)
+	}
+	if len(chain.sent) != 0 {
+		t.Fatalf("sent %v with a contract missing", chain.sent)
+	}
+
+	h.Exclude = []string{"LilypadValidation"}
+	if _, err := h.Run(context.Background(), chain, opts); err != nil {
+		t.Fatalf("Run with LilypadValidation excluded: %v", err)
+	}
+	status, err := h.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Done() || len(status.Excluded) != 1 {
+		t.Errorf("status done %v, excluded %v", status.Done(), status.Excluded)
+	}
+}
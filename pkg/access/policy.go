@@ -0,0 +1,327 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Policy is the desired role wiring, keyed by contract name, then role
+// name, listing the accounts that must hold the role. Accounts are address
+// book names such as "LilypadProxy" or hex addresses. The holders of a
+// listed role are exact: accounts not listed lose the role. Roles that are
+// not listed are left alone.
+//
+//	contracts:
+//	  LilypadStorage:
+//	    CONTROLLER_ROLE: [LilypadProxy, LilypadPaymentEngine]
+//	  LilypadUser:
+//	    CONTROLLER_ROLE: [LilypadProxy, LilypadPaymentEngine, LilypadModuleDirectory]
+//	  LilypadPaymentEngine:
+//	    CONTROLLER_ROLE: [LilypadProxy]
+//
+// JSON policies use the same layout.
+type Policy struct {
+	Contracts map[string]map[string][]string `yaml:"contracts" json:"contracts"`
+}
+
+// LoadPolicy reads a YAML or JSON policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(raw)
+}
+
+// ParsePolicy parses a YAML or JSON policy.
+func ParsePolicy(raw []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("access: parsing policy: %w", err)
+	}
+	return &p, nil
+}
+
+// Change is a single grant or revocation.
+type Change struct {
+	Grant           bool           `json:"grant"`
+	Contract        string         `json:"contract"`
+	ContractAddress common.Address `json:"contract_address"`
+	Role            string         `json:"role"`
+	RoleHash        common.Hash    `json:"role_hash"`
+	Account         common.Address `json:"account"`
+	// Name is the address book name of the account, if any.
+	Name string `json:"name,omitempty"`
+}
+
+// Method returns the AccessControl method that makes the change.
+func (c Change) Method() string {
+	if c.Grant {
+		return "grantRole"
+	}
+	return "revokeRole"
+}
+
+// Data returns the calldata of the change.
+func (c Change) Data() []byte {
+	data, err := parsedABI.Pack(c.Method(), c.RoleHash, c.Account)
+	if err != nil {
+		panic(fmt.Sprintf("access: packing %s: %v", c.Method(), err))
+	}
+	return data
+}
+
+func (c Change) String() string {
+	sign, verb := "+", "grant"
+	if !c.Grant {
+		sign, verb = "-", "revoke"
+	}
+	account := c.Account.Hex()
+	if c.Name != "" {
+		account += " (" + c.Name + ")"
+	}
+	return fmt.Sprintf("%s %s %s %s on %s", sign, verb, c.Role, account, c.Contract)
+}
+
+// Plan is the set of changes that bring the chain in line with a policy.
+// Grants come before revocations, so a contract never passes through a
+// state with fewer holders than either end, and revocations of
+// DEFAULT_ADMIN_ROLE come last.
+type Plan struct {
+	// Block is the block the current holders were read at.
+	Block   uint64   `json:"block"`
+	Changes []Change `json:"changes"`
+}
+
+// NewPlan diffs policy against the holders of an audit report, which must
+// cover every contract the policy names.
+func NewPlan(policy *Policy, book client.AddressBook, report *Report) (*Plan, error) {
+	named := book.Named()
+	names := make(map[common.Address]string, len(named))
+	for name, addr := range named {
+		names[addr] = name
+	}
+	current := make(map[Rule]map[common.Address]bool)
+	for _, h := range report.Confirmed() {
+		rule := Rule{Contract: h.Contract, Role: h.RoleHash}
+		if current[rule] == nil {
+			current[rule] = make(map[common.Address]bool)
+		}
+		current[rule][h.Account] = true
+	}
+
+	plan := &Plan{Block: report.Block}
+	contracts := make([]string, 0, len(policy.Contracts))
+	for contract := range policy.Contracts {
+		contracts = append(contracts, contract)
+	}
+	sort.Strings(contracts)
+	for _, contract := range contracts {
+		addr, ok := named[contract]
+		if !ok {
+			return nil, fmt.Errorf("access: policy names %s, which is not in the address book", contract)
+		}
+		listed := make([]string, 0, len(policy.Contracts[contract]))
+		for role := range policy.Contracts[contract] {
+			listed = append(listed, role)
+		}
+		sort.Strings(listed)
+		for _, roleName := range listed {
+			role, err := roles.Parse(roleName)
+			if err != nil {
+				return nil, err
+			}
+			want := make(map[common.Address]bool)
+			for _, account := range policy.Contracts[contract][roleName] {
+				a, err := resolveAccount(account, named)
+				if err != nil {
+					return nil, fmt.Errorf("access: %s %s: %w", contract, roleName, err)
+				}
+				want[a] = true
+			}
+			if role == roles.DefaultAdmin && len(want) == 0 {
+				return nil, fmt.Errorf("access: policy leaves %s without a DEFAULT_ADMIN_ROLE holder", contract)
+			}
+			have := current[Rule{Contract: contract, Role: role}]
+			change := func(grant bool, account common.Address) {
+				plan.Changes = append(plan.Changes, Change{
+					Grant:           grant,
+					Contract:        contract,
+					ContractAddress: addr,
+					Role:            roles.String(role),
+					RoleHash:        role,
+					Account:         account,
+					Name:            names[account],
+				})
+			}
+			for _, account := range sorted(want) {
+				if !have[account] {
+					change(true, account)
+				}
+			}
+			for _, account := range sorted(have) {
+				if !want[account] {
+					change(false, account)
+				}
+			}
+		}
+	}
+	rank := func(c Change) int {
+		switch {
+		case c.Grant:
+			return 0
+		case c.RoleHash != roles.DefaultAdmin:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(plan.Changes, func(i, j int) bool { return rank(plan.Changes[i]) < rank(plan.Changes[j]) })
+	return plan, nil
+}
+
+func resolveAccount(s string, named map[string]common.Address) (common.Address, error) {
+	if addr, ok := named[s]; ok {
+		return addr, nil
+	}
+	if common.IsHexAddress(s) {
+		return common.HexToAddress(s), nil
+	}
+	return common.Address{}, fmt.Errorf("unknown account %q", s)
+}
+
+func sorted(set map[common.Address]bool) []common.Address {
+	out := make([]common.Address, 0, len(set))
+	for addr := range set {
+		out = append(out, addr)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cmp(out[j]) < 0 })
+	return out
+}
+
+// WriteText writes one line per change.
+func (p *Plan) WriteText(w io.Writer) error {
+	if len(p.Changes) == 0 {
+		_, err := fmt.Fprintf(w, "no changes at block %d\n", p.Block)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%d changes at block %d\n", len(p.Changes), p.Block); err != nil {
+		return err
+	}
+	for _, c := range p.Changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// safeBatch is the Safe Transaction Builder batch file format.
+type safeBatch struct {
+	Version      string        `json:"version"`
+	ChainID      string        `json:"chainId"`
+	CreatedAt    int64         `json:"createdAt"`
+	Meta         safeMeta      `json:"meta"`
+	Transactions []safeTxEntry `json:"transactions"`
+}
+
+type safeMeta struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type safeTxEntry struct {
+	To    common.Address `json:"to"`
+	Value string         `json:"value"`
+	Data  hexutil.Bytes  `json:"data"`
+}
+
+// WriteSafeBatch writes the plan as a Safe Transaction Builder batch, to be
+// proposed by a multisig holding the admin roles.
+func (p *Plan) WriteSafeBatch(w io.Writer, chainID *big.Int) error {
+	batch := safeBatch{
+		Version:   "1.0",
+		ChainID:   chainID.String(),
+		CreatedAt: time.Now().UnixMilli(),
+		Meta: safeMeta{
+			Name:        "Lilypad role policy",
+			Description: fmt.Sprintf("%d role changes planned at block %d", len(p.Changes), p.Block),
+		},
+		Transactions: make([]safeTxEntry, 0, len(p.Changes)),
+	}
+	for _, c := range p.Changes {
+		batch.Transactions = append(batch.Transactions, safeTxEntry{To: c.ContractAddress, Value: "0", Data: c.Data()})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(batch)
+}
+
+// Apply sends the changes of p from opts.From, one transaction at a time,
+// waiting for each to be mined. It first checks that the sender holds the
+// admin role of every role it changes and sends nothing if it does not. It
+// returns the mined transactions, including those sent before a failure.
+// admins, usually the audit report the plan was made from, suggests who can
+// grant a role the sender turns out to lack; it may be nil.
+func (p *Plan) Apply(ctx context.Context, backend bind.ContractBackend, receipts bind.DeployBackend, opts *bind.TransactOpts, admins client.RoleAdmins) ([]*types.Transaction, error) {
+	callOpts := &bind.CallOpts{Context: ctx}
+	for _, c := range p.Changes {
+		bound := bind.NewBoundContract(c.ContractAddress, parsedABI, backend, backend, backend)
+		var admin common.Hash
+		if err := call(bound, callOpts, &admin, "getRoleAdmin", c.RoleHash); err != nil {
+			return nil, fmt.Errorf("access: reading admin of %s on %s: %w", c.Role, c.Contract, err)
+		}
+		var held bool
+		if err := call(bound, callOpts, &held, "hasRole", admin, opts.From); err != nil {
+			return nil, fmt.Errorf("access: checking %s of %s on %s: %w", roles.String(admin), opts.From.Hex(), c.Contract, err)
+		}
+		if !held {
+			return nil, fmt.Errorf("access: %s lacks %s on %s, needed to %s %s", opts.From.Hex(), roles.String(admin), c.Contract, c.Method(), c.Role)
+		}
+	}
+
+	var sent []*types.Transaction
+	for _, c := range p.Changes {
+		bound := bind.NewBoundContract(c.ContractAddress, parsedABI, backend, backend, backend)
+		o := *opts
+		o.Context = ctx
+		tx, err := bound.Transact(&o, c.Method(), c.RoleHash, c.Account)
+		if err != nil {
+			return sent, fmt.Errorf("access: %s: %w", c, client.Explain(err, c.Contract, c.Method(), opts.From, admins))
+		}
+		receipt, err := bind.WaitMined(ctx, receipts, tx)
+		if err != nil {
+			return sent, fmt.Errorf("access: waiting for %s: %w", tx.Hash().Hex(), err)
+		}
+		sent = append(sent, tx)
+		if receipt.Status == types.ReceiptStatusFailed {
+			return sent, fmt.Errorf("access: %s: transaction %s reverted", c, tx.Hash().Hex())
+		}
+	}
+	return sent, nil
+}
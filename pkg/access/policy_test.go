@@ -0,0 +1,220 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+var (
+	oldAdmin = common.HexToAddress("0xad")
+	newAdmin = common.HexToAddress("0xae")
+	stray    = common.HexToAddress("0xe0")
+)
+
+// planReport is an audit report of fullBook in which oldAdmin holds
+// DEFAULT_ADMIN_ROLE everywhere, stray holds CONTROLLER_ROLE on
+// LilypadStorage and LilypadProxy holds it on LilypadUser.
+func planReport() *Report {
+	book := fullBook()
+	r := &Report{Block: 42}
+	holder := func(contract string, role common.Hash, account common.Address) {
+		r.Holders = append(r.Holders, Holder{Contract: contract, Role: roles.String(role), RoleHash: role, Account: account, Confirmed: true})
+	}
+	for name := range book.Named() {
+		holder(name, roles.DefaultAdmin, oldAdmin)
+	}
+	holder("LilypadStorage", roles.Controller, stray)
+	holder("LilypadUser", roles.Controller, book.Proxy)
+	return r
+}
+
+func TestNewPlan(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+contracts:
+  LilypadUser:
+    CONTROLLER_ROLE: [LilypadProxy]
+    DEFAULT_ADMIN_ROLE: ["0x00000000000000000000000000000000000000ae"]
+  LilypadStorage:
+    DEFAULT_ADMIN_ROLE: ["0x00000000000000000000000000000000000000ae"]
+    CONTROLLER_ROLE: [LilypadProxy, LilypadPaymentEngine]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := NewPlan(policy, fullBook(), planReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range plan.Changes {
+		got = append(got, c.String())
+	}
+	book := fullBook()
+	want := []string{
+		"+ grant CONTROLLER_ROLE " + book.PaymentEngine.Hex() + " (LilypadPaymentEngine) on LilypadStorage",
+		"+ grant CONTROLLER_ROLE " + book.Proxy.Hex() + " (LilypadProxy) on LilypadStorage",
+		"+ grant DEFAULT_ADMIN_ROLE " + newAdmin.Hex() + " on LilypadStorage",
+		"+ grant DEFAULT_ADMIN_ROLE " + newAdmin.Hex() + " on LilypadUser",
+		"- revoke CONTROLLER_ROLE " + stray.Hex() + " on LilypadStorage",
+		"- revoke DEFAULT_ADMIN_ROLE " + oldAdmin.Hex() + " on LilypadStorage",
+		"- revoke DEFAULT_ADMIN_ROLE " + oldAdmin.Hex() + " on LilypadUser",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if plan.Block != 42 {
+		t.Errorf("Block = %d, want 42", plan.Block)
+	}
+}
+
+func TestNewPlanErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, policy, err string
+	}{
+		{"empty admin", `{"contracts": {"LilypadUser": {"DEFAULT_ADMIN_ROLE": []}}}`, "without a DEFAULT_ADMIN_ROLE holder"},
+		{"unknown contract", `{"contracts": {"LilypadBank": {"CONTROLLER_ROLE": []}}}`, "LilypadBank, which is not in the address book"},
+		{"unknown account", `{"contracts": {"LilypadUser": {"CONTROLLER_ROLE": [LilypadBank]}}}`, `unknown account "LilypadBank"`},
+		{"unknown role", `{"contracts": {"LilypadUser": {"BANKER_ROLE": []}}}`, "BANKER_ROLE"},
+	} {
+		policy, err := ParsePolicy([]byte(tc.policy))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if _, err := NewPlan(policy, fullBook(), planReport()); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: NewPlan = %v, want an error containing %q", tc.name, err, tc.err)
+		}
+	}
+	if _, err := ParsePolicy([]byte("contract: {}")); err == nil {
+		t.Error("ParsePolicy accepted an unknown field")
+	}
+}
+
+func TestWriteSafeBatch(t *testing.T) {
+	book := fullBook()
+	plan := &Plan{Block: 42, Changes: []Change{
+		{Grant: true, Contract: "LilypadUser", ContractAddress: book.User, Role: "CONTROLLER_ROLE", RoleHash: roles.Controller, Account: book.Proxy},
+		{Contract: "LilypadStorage", ContractAddress: book.Storage, Role: "DEFAULT_ADMIN_ROLE", RoleHash: roles.DefaultAdmin, Account: oldAdmin},
+	}}
+	var buf bytes.Buffer
+	if err := plan.WriteSafeBatch(&buf, big.NewInt(412346)); err != nil {
+		t.Fatal(err)
+	}
+	var batch struct {
+		ChainID      string `json:"chainId"`
+		Transactions []struct {
+			To    common.Address `json:"to"`
+			Value string         `json:"value"`
+			Data  hexutil.Bytes  `json:"data"`
+		} `json:"transactions"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.ChainID != "412346" || len(batch.Transactions) != 2 {
+		t.Fatalf("batch %s", buf.Bytes())
+	}
+	for i, c := range plan.Changes {
+		tx := batch.Transactions[i]
+		method, err := parsedABI.MethodById(tx.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, err := method.Inputs.Unpack(tx.Data[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.To != c.ContractAddress || tx.Value != "0" || method.Name != c.Method() ||
+			common.Hash(args[0].([32]byte)) != c.RoleHash || args[1].(common.Address) != c.Account {
+			t.Errorf("transaction %d: %s %s(%x, %s), want %s", i, tx.To.Hex(), method.Name, args[0], args[1], c)
+		}
+	}
+}
+
+// revertError carries revert data the way go-ethereum's RPC errors do.
+type revertError []byte
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e) }
+
+// denyingChain is a roleChain that rejects every transaction with an
+// AccessControlUnauthorizedAccount revert when estimating its gas.
+type denyingChain struct {
+	*roleChain
+	sent int
+}
+
+func (c *denyingChain) PendingCodeAt(context.Context, common.Address) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (c *denyingChain) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return 0, nil
+}
+
+func (c *denyingChain) SuggestGasPrice(context.Context) (*big.Int, error) { return big.NewInt(1), nil }
+
+func (c *denyingChain) EstimateGas(_ context.Context, msg ethereum.CallMsg) (uint64, error) {
+	parsed, _ := client.ContractABI("LilypadStorage")
+	abiErr := parsed.Errors["AccessControlUnauthorizedAccount"]
+	data, err := abiErr.Inputs.Pack(msg.From, [32]byte(roles.Controller))
+	if err != nil {
+		return 0, err
+	}
+	id := abiErr.ID
+	return 0, revertError(append(id[:4:4], data...))
+}
+
+func (c *denyingChain) SendTransaction(context.Context, *types.Transaction) error {
+	c.sent++
+	return nil
+}
+
+func TestApplySuggestsAdmins(t *testing.T) {
+	opts := transactor(t)
+	book := fullBook()
+	chain := &denyingChain{roleChain: newRoleChain(book)}
+	chain.set(book.Storage, roles.DefaultAdmin, opts.From, true)
+	plan := &Plan{Changes: []Change{
+		{Grant: true, Contract: "LilypadStorage", ContractAddress: book.Storage, Role: "CONTROLLER_ROLE", RoleHash: roles.Controller, Account: book.Proxy},
+	}}
+	// A plan read back from JSON still suggests admins from the report.
+	var buf bytes.Buffer
+	if err := plan.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Plan
+	if err := json.Unmarshal(buf.Bytes(), &loaded); err != nil {
+		t.Fatal(err)
+	}
+	_, err := loaded.Apply(context.Background(), chain, nil, opts, planReport())
+	var d *client.Denial
+	if !errors.As(err, &d) {
+		t.Fatalf("Apply = %v, want a denial", err)
+	}
+	if d.AdminRole != roles.DefaultAdmin || !reflect.DeepEqual(d.Admins, []client.Admin{{Account: oldAdmin}}) {
+		t.Errorf("denial suggests %s %v, want %s", roles.String(d.AdminRole), d.Admins, oldAdmin.Hex())
+	}
+
+	// Without the admin role the sender sends nothing.
+	chain.set(book.Storage, roles.DefaultAdmin, opts.From, false)
+	if _, err := loaded.Apply(context.Background(), chain, nil, opts, nil); err == nil || !strings.Contains(err.Error(), "lacks DEFAULT_ADMIN_ROLE on LilypadStorage") {
+		t.Errorf("Apply = %v, want the missing admin role", err)
+	}
+	if chain.sent != 0 {
+		t.Errorf("sent %d transactions", chain.sent)
+	}
+}
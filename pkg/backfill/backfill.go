@@ -0,0 +1,254 @@
+// Package backfill fetches historical logs over wide block ranges from
+// providers that cap the size or span of eth_getLogs responses.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Chunk is a contiguous block range and every matching log in it.
+type Chunk struct {
+	From, To uint64
+	Logs     []types.Log
+}
+
+// Progress is reported after every delivered chunk.
+type Progress struct {
+	From, To uint64 // the whole range
+	Done     uint64 // last block delivered
+	Logs     int    // logs delivered so far
+	// ChunkSize is the current request span after adaptation.
+	ChunkSize uint64
+}
+
+// Config configures a backfill. Zero fields take their defaults.
+type Config struct {
+	// Query selects the logs; its block fields are ignored.
+	Query ethereum.FilterQuery
+	// From and To are the first and last block, inclusive. To resume, set
+	// From to one past the To of the last chunk that was delivered.
+	From, To uint64
+	// ChunkSize is the initial and largest span of a request. Defaults to
+	// 2000 blocks.
+	ChunkSize uint64
+	// Concurrency limits requests in flight. Defaults to 4.
+	Concurrency int
+	// Timeout bounds a single request; a timed out request is split like
+	// one the provider rejected as too large. Defaults to 30 seconds.
+	Timeout time.Duration
+	// Progress, if set, is called after every delivered chunk.
+	Progress func(Progress)
+}
+
+// Run fetches the logs of cfg and passes them to deliver one chunk at a time,
+// in block order, never calling deliver concurrently. Requests that the
+// provider rejects as too large or that time out are split in half until
+// they succeed. Run stops at the first error from deliver or the provider.
+func Run(ctx context.Context, logs ethereum.LogFilterer, cfg Config, deliver func(Chunk) error) error {
+	if cfg.From > cfg.To {
+		return nil
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = 2000
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	b := &backfill{logs: logs, cfg: cfg, size: cfg.ChunkSize}
+	type result struct {
+		chunk Chunk
+		err   error
+	}
+	// Each chunk gets a buffered slot; slots are queued in order so the
+	// collector can deliver in order while fetches run in parallel. The
+	// queue plus the slot being awaited bound the fetches in flight.
+	slots := make(chan chan result, cfg.Concurrency-1)
+	go func() {
+		defer close(slots)
+		for next := cfg.From; next <= cfg.To; {
+			to := next + b.chunkSize() - 1
+			if to > cfg.To || to < next {
+				to = cfg.To
+			}
+			slot := make(chan result, 1)
+			select {
+			case slots <- slot:
+			case <-ctx.Done():
+				return
+			}
+			go func(from, to uint64) {
+				found, err := b.fetch(ctx, from, to)
+				slot <- result{Chunk{From: from, To: to, Logs: found}, err}
+			}(next, to)
+			if to == cfg.To {
+				return
+			}
+			next = to + 1
+		}
+	}()
+
+	total := 0
+	for slot := range slots {
+		var res result
+		select {
+		case res = <-slot:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if res.err != nil {
+			return res.err
+		}
+		if err := deliver(res.chunk); err != nil {
+			return err
+		}
+		total += len(res.chunk.Logs)
+		if cfg.Progress != nil {
+			cfg.Progress(Progress{From: cfg.From, To: cfg.To, Done: res.chunk.To, Logs: total, ChunkSize: b.chunkSize()})
+		}
+	}
+	return ctx.Err()
+}
+
+type backfill struct {
+	logs ethereum.LogFilterer
+	cfg  Config
+
+	mu        sync.Mutex
+	size      uint64
+	successes int
+}
+
+func (b *backfill) chunkSize() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// shrink lowers the span of future requests after one had to be split.
+func (b *backfill) shrink(span uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if half := span / 2; half >= 1 && half < b.size {
+		b.size = half
+	}
+	b.successes = 0
+}
+
+// grow doubles the span after a run of successful requests.
+func (b *backfill) grow() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.successes++
+	if b.successes >= 4 && b.size < b.cfg.ChunkSize {
+		b.size *= 2
+		if b.size > b.cfg.ChunkSize {
+			b.size = b.cfg.ChunkSize
+		}
+		b.successes = 0
+	}
+}
+
+// fetch returns the logs between from and to, splitting the range while the
+// provider rejects it.
+func (b *backfill) fetch(ctx context.Context, from, to uint64) ([]types.Log, error) {
+	q := b.cfg.Query
+	q.BlockHash = nil
+	q.FromBlock = new(big.Int).SetUint64(from)
+	q.ToBlock = new(big.Int).SetUint64(to)
+
+	reqCtx, cancel := context.WithTimeout(ctx, b.cfg.Timeout)
+	found, err := b.logs.FilterLogs(reqCtx, q)
+	timedOut := reqCtx.Err() == context.DeadlineExceeded
+	cancel()
+	if err == nil {
+		b.grow()
+		return found, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if !(timedOut || TooLarge(err)) || from == to {
+		return nil, fmt.Errorf("backfill: blocks %d-%d: %w", from, to, err)
+	}
+	b.shrink(to - from + 1)
+	mid := from + (to-from)/2
+	left, err := b.fetch(ctx, from, mid)
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.fetch(ctx, mid+1, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// EventQuery returns a query selecting the named events of the contract at
+// addr, e.g. LilypadPayment__escrowPayout, for use in Config.Query. The
+// delivered logs can be decoded with the binding's Parse methods.
+func EventQuery(addr common.Address, parsed *abi.ABI, events ...string) (ethereum.FilterQuery, error) {
+	ids := make([]common.Hash, 0, len(events))
+	for _, name := range events {
+		event, ok := parsed.Events[name]
+		if !ok {
+			return ethereum.FilterQuery{}, fmt.Errorf("backfill: no event %s", name)
+		}
+		ids = append(ids, event.ID)
+	}
+	q := ethereum.FilterQuery{Addresses: []common.Address{addr}}
+	if len(ids) > 0 {
+		q.Topics = [][]common.Hash{ids}
+	}
+	return q, nil
+}
+
+// limitCode is the JSON-RPC error code for "limit exceeded" (EIP-1474).
+const limitCode = -32005
+
+// tooLargeHints are fragments of the messages providers return when a
+// range or response is too large: geth and Alchemy ("query returned more
+// than 10000 results"), Infura and QuickNode ("block range is too wide",
+// "exceed maximum block range") and Ankr ("response size exceeded").
+var tooLargeHints = []string{
+	"query returned more than",
+	"block range",
+	"response size exceeded",
+}
+
+// TooLarge reports whether err is a provider rejecting a request as too
+// large, so that it should be retried over a smaller range. Timeouts are
+// not reported; Run splits requests that exceed Config.Timeout itself.
+func TooLarge(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == limitCode {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range tooLargeHints {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeLogs serves one log per block and rejects ranges wider than maxSpan
+// with err. With jitter, requests finish in random order.
+type fakeLogs struct {
+	maxSpan uint64
+	err     error
+	jitter  bool
+	// hang makes requests wider than maxSpan block until their context is
+	// done instead of failing.
+	hang bool
+
+	mu    sync.Mutex
+	spans [][2]uint64
+}
+
+func (f *fakeLogs) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	f.mu.Lock()
+	f.spans = append(f.spans, [2]uint64{from, to})
+	f.mu.Unlock()
+	if f.jitter {
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+	}
+	if to-from+1 > f.maxSpan {
+		if f.hang {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, f.err
+	}
+	logs := make([]types.Log, 0, to-from+1)
+	for b := from; b <= to; b++ {
+		logs = append(logs, types.Log{BlockNumber: b})
+	}
+	return logs, nil
+}
+
+func (f *fakeLogs) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeLogs) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.spans)
+}
+
+type rpcError struct {
+	code int
+	msg  string
+}
+
+func (e rpcError) Error() string  { return e.msg }
+func (e rpcError) ErrorCode() int { return e.code }
+
+// collect runs a backfill and checks that the chunks cover the range
+// contiguously, in order, with every block's log.
+func collect(t *testing.T, logs ethereum.LogFilterer, cfg Config) []Chunk {
+	t.Helper()
+	var chunks []Chunk
+	err := Run(context.Background(), logs, cfg, func(c Chunk) error {
+		chunks = append(chunks, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	next := cfg.From
+	for _, c := range chunks {
+		if c.From != next || c.To < c.From {
+			t.Fatalf("chunk %d-%d, want it to start at %d", c.From, c.To, next)
+		}
+		for i, log := range c.Logs {
+			if log.BlockNumber != c.From+uint64(i) {
+				t.Fatalf("chunk %d-%d: log %d is from block %d", c.From, c.To, i, log.BlockNumber)
+			}
+		}
+		if uint64(len(c.Logs)) != c.To-c.From+1 {
+			t.Fatalf("chunk %d-%d has %d logs", c.From, c.To, len(c.Logs))
+		}
+		next = c.To + 1
+	}
+	if next != cfg.To+1 {
+		t.Fatalf("chunks end at %d, want %d", next-1, cfg.To)
+	}
+	return chunks
+}
+
+func TestRunSplitsTooLarge(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"result count", errors.New("query returned more than 10000 results")},
+		{"block range", errors.New("exceed maximum block range: 50")},
+		{"limit code", rpcError{limitCode, "limit exceeded"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLogs{maxSpan: 50, err: tt.err}
+			collect(t, f, Config{From: 10, To: 1009, ChunkSize: 200})
+		})
+	}
+}
+
+func TestRunDeliversInOrder(t *testing.T) {
+	f := &fakeLogs{maxSpan: 64, err: errors.New("block range is too wide"), jitter: true}
+	var sizes []uint64
+	cfg := Config{From: 0, To: 4999, ChunkSize: 100, Concurrency: 8, Progress: func(p Progress) {
+		sizes = append(sizes, p.ChunkSize)
+	}}
+	collect(t, f, cfg)
+	for _, size := range sizes {
+		if size > cfg.ChunkSize {
+			t.Fatalf("chunk size grew to %d, above %d", size, cfg.ChunkSize)
+		}
+	}
+}
+
+func TestRunFailsOnOtherErrors(t *testing.T) {
+	f := &fakeLogs{maxSpan: 10, err: errors.New("internal error")}
+	err := Run(context.Background(), f, Config{From: 0, To: 99, ChunkSize: 100}, func(Chunk) error { return nil })
+	if err == nil {
+		t.Fatal("Run succeeded, want the provider error")
+	}
+	if n := f.requests(); n != 1 {
+		t.Errorf("%d requests, want 1 without splitting", n)
+	}
+}
+
+func TestRunSplitsTimedOutRequests(t *testing.T) {
+	f := &fakeLogs{maxSpan: 25, hang: true}
+	collect(t, f, Config{From: 0, To: 99, ChunkSize: 100, Timeout: 20 * time.Millisecond})
+}
+
+func TestRunStopsWithParentContext(t *testing.T) {
+	f := &fakeLogs{maxSpan: 1, hang: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := Run(ctx, f, Config{From: 0, To: 99, ChunkSize: 100, Concurrency: 1, Timeout: time.Minute}, func(Chunk) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want context.DeadlineExceeded", err)
+	}
+	if n := f.requests(); n != 1 {
+		t.Errorf("%d requests, want 1: an expired parent context must not split", n)
+	}
+}
+
+func TestRunStopsOnDeliverError(t *testing.T) {
+	f := &fakeLogs{maxSpan: 1000}
+	stop := errors.New("stop")
+	calls := 0
+	err := Run(context.Background(), f, Config{From: 0, To: 999, ChunkSize: 100}, func(Chunk) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("Run = %v after %d deliveries, want stop after 1", err, calls)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("query returned more than 10000 results"), true},
+		{errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range"), true},
+		{errors.New("exceed maximum block range: 5000"), true},
+		{fmt.Errorf("wrapped: %w", rpcError{limitCode, "limit exceeded"}), true},
+		{rpcError{-32000, "header not found"}, false},
+		{context.DeadlineExceeded, false},
+		{context.Canceled, false},
+		{errors.New("i/o timeout"), false},
+		{errors.New("rate limit exceeded, too many requests"), false},
+		{errors.New("execution reverted"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := TooLarge(tt.err); got != tt.want {
+			t.Errorf("TooLarge(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package cid parses and validates the IPFS content identifiers stored in
+// deals, results and validation results. LilypadStorage only rejects empty
+// CIDs, so anything else must be caught before a transaction is signed.
+package cid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalid is wrapped by every parse error.
+var ErrInvalid = errors.New("cid: invalid")
+
+// Codecs that may appear in a CIDv1, keyed by multicodec code.
+var Codecs = map[uint64]string{
+	0x55:   "raw",
+	0x70:   "dag-pb",
+	0x71:   "dag-cbor",
+	0x72:   "libp2p-key",
+	0x0129: "dag-json",
+	0x0200: "json",
+}
+
+// hashLengths maps the supported multihash functions to their digest length.
+// A zero length means the digest length is not fixed.
+var hashLengths = map[uint64]struct {
+	name   string
+	length int
+}{
+	0x00:   {"identity", 0},
+	0x11:   {"sha1", 20},
+	0x12:   {"sha2-256", 32},
+	0x13:   {"sha2-512", 64},
+	0x16:   {"sha3-256", 32},
+	0x1b:   {"keccak-256", 32},
+	0x1e:   {"blake3", 0},
+	0xb220: {"blake2b-256", 32},
+}
+
+// Multihash is a self-describing hash digest.
+type Multihash struct {
+	Code   uint64
+	Digest []byte
+}
+
+// Name returns the name of the hash function.
+func (m Multihash) Name() string {
+	return hashLengths[m.Code].name
+}
+
+// CID is a parsed content identifier.
+type CID struct {
+	Version uint64
+	Codec   uint64
+	Hash    Multihash
+}
+
+// CodecName returns the name of the content codec.
+func (c CID) CodecName() string {
+	return Codecs[c.Codec]
+}
+
+// Parse parses a CIDv0 ("Qm...") or a multibase encoded CIDv1.
+func Parse(s string) (CID, error) {
+	if s == "" {
+		return CID{}, fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	if s != strings.TrimSpace(s) {
+		return CID{}, fmt.Errorf("%w: surrounding whitespace", ErrInvalid)
+	}
+	if len(s) == 46 && strings.HasPrefix(s, "Qm") {
+		return parseV0(s)
+	}
+	raw, err := decodeMultibase(s)
+	if err != nil {
+		return CID{}, err
+	}
+	return parseV1(raw)
+}
+
+// Valid reports whether s parses as a CID.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// parseV0 handles the base58btc encoded sha2-256 multihash that implies
+// version 0 and the dag-pb codec.
+func parseV0(s string) (CID, error) {
+	raw, err := decodeBase58(s)
+	if err != nil {
+		return CID{}, err
+	}
+	if len(raw) != 34 || raw[0] != 0x12 || raw[1] != 0x20 {
+		return CID{}, fmt.Errorf("%w: CIDv0 must be a sha2-256 multihash", ErrInvalid)
+	}
+	return CID{Version: 0, Codec: 0x70, Hash: Multihash{Code: 0x12, Digest: raw[2:]}}, nil
+}
+
+func parseV1(raw []byte) (CID, error) {
+	version, raw, err := uvarint(raw)
+	if err != nil {
+		return CID{}, err
+	}
+	// A multibase encoded CIDv0 is not allowed by the spec; 0x12 is what it
+	// would start with.
+	if version != 1 {
+		return CID{}, fmt.Errorf("%w: unsupported version %d", ErrInvalid, version)
+	}
+	codec, raw, err := uvarint(raw)
+	if err != nil {
+		return CID{}, err
+	}
+	if _, ok := Codecs[codec]; !ok {
+		return CID{}, fmt.Errorf("%w: unknown codec 0x%x", ErrInvalid, codec)
+	}
+	hash, err := parseMultihash(raw)
+	if err != nil {
+		return CID{}, err
+	}
+	return CID{Version: 1, Codec: codec, Hash: hash}, nil
+}
+
+func parseMultihash(raw []byte) (Multihash, error) {
+	code, raw, err := uvarint(raw)
+	if err != nil {
+		return Multihash{}, err
+	}
+	length, raw, err := uvarint(raw)
+	if err != nil {
+		return Multihash{}, err
+	}
+	fn, ok := hashLengths[code]
+	if !ok {
+		return Multihash{}, fmt.Errorf("%w: unknown multihash function 0x%x", ErrInvalid, code)
+	}
+	if uint64(len(raw)) != length {
+		return Multihash{}, fmt.Errorf("%w: multihash declares %d digest bytes, has %d", ErrInvalid, length, len(raw))
+	}
+	if fn.length != 0 && int(length) != fn.length {
+		return Multihash{}, fmt.Errorf("%w: %s digest must be %d bytes, got %d", ErrInvalid, fn.name, fn.length, length)
+	}
+	if code != 0x00 && length == 0 {
+		return Multihash{}, fmt.Errorf("%w: empty digest", ErrInvalid)
+	}
+	return Multihash{Code: code, Digest: raw}, nil
+}
+
+// uvarint reads an unsigned LEB128 varint as used by multiformats, which
+// caps them at nine bytes and forbids non-minimal encodings.
+func uvarint(buf []byte) (uint64, []byte, error) {
+	var x uint64
+	for i := 0; i < len(buf) && i < 9; i++ {
+		b := buf[i]
+		x |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			if b == 0 && i > 0 {
+				return 0, nil, fmt.Errorf("%w: non-minimal varint", ErrInvalid)
+			}
+			return x, buf[i+1:], nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: truncated varint", ErrInvalid)
+}
@@ -0,0 +1,159 @@
+package cid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// encodeBaseN is the inverse of decodeBaseN.
+func encodeBaseN(raw []byte, alphabet string) string {
+	var out []byte
+	n := new(big.Int).SetBytes(raw)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	for _, b := range raw {
+		if b != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// v1 returns the binary CIDv1 of codec and a sha2-256 digest of data.
+func v1(codec byte, data string) []byte {
+	digest := sha256.Sum256([]byte(data))
+	return append([]byte{0x01, codec, 0x12, 0x20}, digest[:]...)
+}
+
+func TestParseMultibases(t *testing.T) {
+	raw := v1(0x55, "hello")
+	digest := sha256.Sum256([]byte("hello"))
+	b32 := base32Lower.EncodeToString(raw)
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"base32", "b" + b32},
+		{"base32upper", "B" + strings.ToUpper(b32)},
+		{"base58btc", "z" + encodeBaseN(raw, base58Alphabet)},
+		{"base36", "k" + encodeBaseN(raw, base36Alphabet)},
+		{"base36upper", "K" + strings.ToUpper(encodeBaseN(raw, base36Alphabet))},
+		{"base16", "f" + hex.EncodeToString(raw)},
+		{"base16upper", "F" + strings.ToUpper(hex.EncodeToString(raw))},
+		{"base64", "m" + base64.RawStdEncoding.EncodeToString(raw)},
+		{"base64url", "u" + base64.RawURLEncoding.EncodeToString(raw)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(tt.s)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.s, err)
+			}
+			if c.Version != 1 || c.CodecName() != "raw" || c.Hash.Name() != "sha2-256" {
+				t.Errorf("got version %d, codec %s, hash %s", c.Version, c.CodecName(), c.Hash.Name())
+			}
+			if !bytes.Equal(c.Hash.Digest, digest[:]) {
+				t.Errorf("digest %x, want %x", c.Hash.Digest, digest)
+			}
+		})
+	}
+}
+
+func TestParseKnown(t *testing.T) {
+	tests := []struct {
+		s       string
+		version uint64
+		codec   string
+	}{
+		{"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB", 0, "dag-pb"},
+		{"bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", 1, "dag-pb"},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.s, err)
+			continue
+		}
+		if c.Version != tt.version || c.CodecName() != tt.codec || len(c.Hash.Digest) != 32 {
+			t.Errorf("Parse(%q) = version %d, codec %s, %d digest bytes", tt.s, c.Version, c.CodecName(), len(c.Hash.Digest))
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	good := v1(0x55, "hello")
+	b32 := func(raw []byte) string { return "b" + base32Lower.EncodeToString(raw) }
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"empty", ""},
+		{"whitespace", " " + b32(good)},
+		{"prefix only", "b"},
+		{"unknown multibase", "x" + hex.EncodeToString(good)},
+		{"mixed case base32upper", "B" + strings.ToUpper(b32(good)[1:5]) + b32(good)[5:]},
+		{"mixed case base16", "f" + strings.ToUpper(hex.EncodeToString(good))},
+		{"bad base58 character", "z0" + encodeBaseN(good, base58Alphabet)},
+		{"CIDv0 of the wrong hash", "Qm" + strings.Repeat("1", 44)},
+		{"multibase CIDv0", b32(append([]byte{0x12, 0x20}, good[4:]...))},
+		{"version 2", b32(append([]byte{0x02}, good[1:]...))},
+		{"truncated varint", b32([]byte{0x01, 0x80})},
+		{"varint over nine bytes", b32(append([]byte{0x01}, bytes.Repeat([]byte{0xff}, 10)...))},
+		{"non-minimal varint", b32(append([]byte{0x81, 0x00}, good[1:]...))},
+		{"unknown codec", b32(append([]byte{0x01, 0x7f}, good[2:]...))},
+		{"unknown multihash", b32(append([]byte{0x01, 0x55, 0x14, 0x20}, good[4:]...))},
+		{"truncated digest", b32(good[:len(good)-1])},
+		{"digest longer than declared", b32(append(append([]byte(nil), good...), 0x00))},
+		{"wrong sha2-256 length", b32(append([]byte{0x01, 0x55, 0x12, 0x1f}, good[4:35]...))},
+		{"empty digest", b32([]byte{0x01, 0x55, 0x1e, 0x00})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.s); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) = %v, want ErrInvalid", tt.s, err)
+			}
+		})
+	}
+}
+
+func TestUvarint(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want uint64
+		rest int
+		ok   bool
+	}{
+		{[]byte{0x00}, 0, 0, true},
+		{[]byte{0x7f, 0xaa}, 0x7f, 1, true},
+		{[]byte{0x80, 0x01}, 0x80, 0, true},
+		{[]byte{0xa0, 0xe4, 0x02}, 0xb220, 0, true},
+		{[]byte{}, 0, 0, false},
+		{[]byte{0x80}, 0, 0, false},
+		{[]byte{0x80, 0x00}, 0, 0, false},
+		{bytes.Repeat([]byte{0x80}, 9), 0, 0, false},
+	}
+	for _, tt := range tests {
+		got, rest, err := uvarint(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("uvarint(%x) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && (got != tt.want || len(rest) != tt.rest) {
+			t.Errorf("uvarint(%x) = %d with %d bytes left, want %d with %d", tt.in, got, len(rest), tt.want, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cid
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	base36Alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// decodeMultibase decodes s according to its multibase prefix. Only the
+// encodings commonly used for CIDs are supported.
+func decodeMultibase(s string) ([]byte, error) {
+	prefix, body := s[0], s[1:]
+	if body == "" {
+		return nil, fmt.Errorf("%w: no data after multibase prefix", ErrInvalid)
+	}
+	var (
+		raw []byte
+		err error
+	)
+	switch prefix {
+	case 'b':
+		raw, err = base32Lower.DecodeString(body)
+	case 'B':
+		raw, err = base32Lower.DecodeString(strings.ToLower(body))
+		if err == nil && body != strings.ToUpper(body) {
+			err = fmt.Errorf("mixed case")
+		}
+	case 'z':
+		return decodeBase58(body)
+	case 'k':
+		return decodeBaseN(body, base36Alphabet, "base36")
+	case 'K':
+		if body != strings.ToUpper(body) {
+			return nil, fmt.Errorf("%w: base36upper: mixed case", ErrInvalid)
+		}
+		return decodeBaseN(strings.ToLower(body), base36Alphabet, "base36upper")
+	case 'f':
+		if body != strings.ToLower(body) {
+			return nil, fmt.Errorf("%w: base16: mixed case", ErrInvalid)
+		}
+		raw, err = hex.DecodeString(body)
+	case 'F':
+		if body != strings.ToUpper(body) {
+			return nil, fmt.Errorf("%w: base16upper: mixed case", ErrInvalid)
+		}
+		raw, err = hex.DecodeString(body)
+	case 'm':
+		raw, err = base64.RawStdEncoding.DecodeString(body)
+	case 'u':
+		raw, err = base64.RawURLEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("%w: unsupported multibase prefix %q", ErrInvalid, prefix)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: multibase %q: %v", ErrInvalid, prefix, err)
+	}
+	return raw, nil
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	return decodeBaseN(s, base58Alphabet, "base58btc")
+}
+
+// decodeBaseN decodes a big-endian positional encoding in which each leading
+// zero digit stands for a zero byte, as base58btc and base36 do.
+func decodeBaseN(s, alphabet, name string) ([]byte, error) {
+	base := big.NewInt(int64(len(alphabet)))
+	n := new(big.Int)
+	zeros := 0
+	for i := 0; i < len(s) && s[i] == alphabet[0]; i++ {
+		zeros++
+	}
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(alphabet, s[i])
+		if d < 0 {
+			return nil, fmt.Errorf("%w: %s: invalid character %q", ErrInvalid, name, s[i])
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(d)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
@@ -0,0 +1,108 @@
+package cid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned in strict mode when the content store does not
+// hold the referenced content.
+var ErrNotFound = errors.New("cid: content not found")
+
+// ContentStore reports whether content is retrievable.
+type ContentStore interface {
+	Has(ctx context.Context, c CID, raw string) (bool, error)
+}
+
+// FieldError reports which field of a submission held a bad CID.
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.Field, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validator checks CIDs before they are submitted. Without a store it only
+// checks syntax; with one it also requires the content to exist.
+type Validator struct {
+	store ContentStore
+}
+
+// NewValidator returns a Validator. Pass a nil store for syntax checks only.
+func NewValidator(store ContentStore) *Validator {
+	return &Validator{store: store}
+}
+
+// Strict reports whether the validator checks that content exists.
+func (v *Validator) Strict() bool {
+	return v.store != nil
+}
+
+// Validate checks the CID held in the named field.
+func (v *Validator) Validate(ctx context.Context, field, value string) error {
+	c, err := Parse(value)
+	if err != nil {
+		return &FieldError{Field: field, Value: value, Err: err}
+	}
+	if v.store == nil {
+		return nil
+	}
+	ok, err := v.store.Has(ctx, c, value)
+	if err != nil {
+		return &FieldError{Field: field, Value: value, Err: err}
+	}
+	if !ok {
+		return &FieldError{Field: field, Value: value, Err: ErrNotFound}
+	}
+	return nil
+}
+
+// GatewayStore checks content through an IPFS HTTP gateway.
+type GatewayStore struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewGatewayStore returns a store asking the gateway at base, e.g.
+// "https://ipfs.io". A nil client uses http.DefaultClient.
+func NewGatewayStore(base string, client *http.Client) (*GatewayStore, error) {
+	u, err := url.Parse(strings.TrimSuffix(base, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("cid: gateway url: %w", err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &GatewayStore{base: u, client: client}, nil
+}
+
+// Has issues a HEAD request for /ipfs/<cid>.
+func (s *GatewayStore) Has(ctx context.Context, _ CID, raw string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.base.JoinPath("ipfs", raw).String(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, nil
+	}
+	return false, fmt.Errorf("cid: gateway returned %s", resp.Status)
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypadcontractregistry "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadContractRegistry"
+	lilypadmoduledirectory "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadModuleDirectory"
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadproxy "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadProxy"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	lilypadtoken "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadToken"
+	lilypadtokenomics "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadTokenomics"
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	lilypadvalidation "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadValidation"
+	lilypadvesting "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadVesting"
+)
+
+// metadata maps the contract names used by AddressBook.Named to their
+// generated binding metadata.
+var metadata = map[string]*bind.MetaData{
+	"LilypadContractRegistry": lilypadcontractregistry.LilypadContractRegistryMetaData,
+	"LilypadToken":            lilypadtoken.LilypadTokenMetaData,
+	"LilypadUser":             lilypaduser.LilypadUserMetaData,
+	"LilypadModuleDirectory":  lilypadmoduledirectory.LilypadModuleDirectoryMetaData,
+	"LilypadStorage":          lilypadstorage.LilypadStorageMetaData,
+	"LilypadPaymentEngine":    lilypadpaymentengine.LilypadPaymentEngineMetaData,
+	"LilypadProxy":            lilypadproxy.LilypadProxyMetaData,
+	"LilypadVesting":          lilypadvesting.LilypadVestingMetaData,
+	"LilypadTokenomics":       lilypadtokenomics.LilypadTokenomicsMetaData,
+	"LilypadValidation":       lilypadvalidation.LilypadValidationMetaData,
+}
+
+// ContractNames returns the names of every Lilypad contract with a binding,
+// sorted.
+func ContractNames() []string {
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContractABI returns the parsed ABI of the named contract, e.g.
+// "LilypadStorage".
+func ContractABI(name string) (*abi.ABI, bool) {
+	meta, ok := metadata[name]
+	if !ok {
+		return nil, false
+	}
+	parsed, err := meta.GetAbi()
+	if err != nil {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// ABI returns the name and ABI of the contract the client has at addr.
+func (c *Client) ABI(addr common.Address) (string, *abi.ABI, bool) {
+	for name, known := range c.Addresses.Named() {
+		if known != addr {
+			continue
+		}
+		parsed, ok := ContractABI(name)
+		return name, parsed, ok
+	}
+	return "", nil, false
+}
+
+// ABIs returns the ABIs of every contract in the client's address book,
+// ordered by contract name, e.g. for revert.Decode.
+func (c *Client) ABIs() []*abi.ABI {
+	named := c.Addresses.Named()
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	abis := make([]*abi.ABI, 0, len(names))
+	for _, name := range names {
+		if parsed, ok := ContractABI(name); ok {
+			abis = append(abis, parsed)
+		}
+	}
+	return abis
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypadcontractregistry "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadContractRegistry"
+)
+
+// AddressBook lists the deployed (proxy) addresses of the Lilypad contracts.
+// A zero address means the contract is not used by this client.
+type AddressBook struct {
+	Registry        common.Address
+	Token           common.Address
+	User            common.Address
+	ModuleDirectory common.Address
+	Storage         common.Address
+	PaymentEngine   common.Address
+	Proxy           common.Address
+	Vesting         common.Address
+	// Tokenomics and Validation are not tracked by LilypadContractRegistry and
+	// have to be set explicitly.
+	Tokenomics common.Address
+	Validation common.Address
+}
+
+// LoadAddressBook reads the contract addresses from the LilypadContractRegistry
+// deployed at registry. The registry is the source of truth for the protocol
+// addresses; Tokenomics and Validation are left unset.
+func LoadAddressBook(ctx context.Context, caller bind.ContractCaller, registry common.Address) (AddressBook, error) {
+	reg, err := lilypadcontractregistry.NewLilypadContractRegistryCaller(registry, caller)
+	if err != nil {
+		return AddressBook{}, err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	book := AddressBook{Registry: registry}
+	for _, field := range []struct {
+		name string
+		dst  *common.Address
+		get  func(*bind.CallOpts) (common.Address, error)
+	}{
+		{"l2LilypadTokenAddress", &book.Token, reg.L2LilypadTokenAddress},
+		{"lilypadUserAddress", &book.User, reg.LilypadUserAddress},
+		{"lilypadModuleDirectoryAddress", &book.ModuleDirectory, reg.LilypadModuleDirectoryAddress},
+		{"lilypadStorageAddress", &book.Storage, reg.LilypadStorageAddress},
+		{"lilypadPaymentEngineAddress", &book.PaymentEngine, reg.LilypadPaymentEngineAddress},
+		{"lilypadProxyAddress", &book.Proxy, reg.LilypadProxyAddress},
+		{"lilypadVestingAddress", &book.Vesting, reg.LilypadVestingAddress},
+	} {
+		addr, err := field.get(opts)
+		if err != nil {
+			return AddressBook{}, fmt.Errorf("client: reading %s from registry: %w", field.name, err)
+		}
+		*field.dst = addr
+	}
+	return book, nil
+}
+
+// Named returns the non-zero addresses in the book keyed by contract name,
+// e.g. "LilypadStorage".
+func (b AddressBook) Named() map[string]common.Address {
+	named := make(map[string]common.Address)
+	for name, addr := range b.all() {
+		if addr != (common.Address{}) {
+			named[name] = addr
+		}
+	}
+	return named
+}
+
+// Missing returns the sorted names of the contracts whose address is not
+// set, typically LilypadTokenomics and LilypadValidation when they were not
+// passed explicitly.
+func (b AddressBook) Missing() []string {
+	var missing []string
+	for name, addr := range b.all() {
+		if addr == (common.Address{}) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func (b AddressBook) all() map[string]common.Address {
+	return map[string]common.Address{
+		"LilypadContractRegistry": b.Registry,
+		"LilypadToken":            b.Token,
+		"LilypadUser":             b.User,
+		"LilypadModuleDirectory":  b.ModuleDirectory,
+		"LilypadStorage":          b.Storage,
+		"LilypadPaymentEngine":    b.PaymentEngine,
+		"LilypadProxy":            b.Proxy,
+		"LilypadVesting":          b.Vesting,
+		"LilypadTokenomics":       b.Tokenomics,
+		"LilypadValidation":       b.Validation,
+	}
+}
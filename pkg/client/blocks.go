@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HeaderReader reads block headers; every bind.ContractBackend is one.
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// BlockAt returns the number of the last block mined at or before ts, found
+// by binary search over block timestamps. It fails if ts is before the
+// genesis block.
+func BlockAt(ctx context.Context, headers HeaderReader, ts time.Time) (uint64, error) {
+	target := uint64(ts.Unix())
+	head, err := headers.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("client: reading head: %w", err)
+	}
+	if head.Time <= target {
+		return head.Number.Uint64(), nil
+	}
+	lo, hi := uint64(0), head.Number.Uint64()
+	genesis, err := headers.HeaderByNumber(ctx, new(big.Int))
+	if err != nil {
+		return 0, fmt.Errorf("client: reading genesis: %w", err)
+	}
+	if genesis.Time > target {
+		return 0, fmt.Errorf("client: %s is before the genesis block", ts.UTC().Format(time.RFC3339))
+	}
+	// Invariant: time(lo) <= target < time(hi).
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		header, err := headers.HeaderByNumber(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, fmt.Errorf("client: reading header %d: %w", mid, err)
+		}
+		if header.Time <= target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
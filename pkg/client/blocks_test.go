@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// chainTimes serves headers whose timestamps are the slice elements; the last
+// one is the head. reads counts the headers read.
+type chainTimes struct {
+	times []uint64
+	err   error
+	reads int
+}
+
+func (c *chainTimes) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	c.reads++
+	if c.err != nil {
+		return nil, c.err
+	}
+	n := uint64(len(c.times) - 1)
+	if number != nil {
+		n = number.Uint64()
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(n), Time: c.times[n]}, nil
+}
+
+func TestBlockAt(t *testing.T) {
+	// Blocks 3 to 5 share a timestamp, and there is a gap after block 6.
+	times := []uint64{100, 110, 120, 130, 130, 130, 140, 200, 210, 220}
+	for _, tc := range []struct {
+		ts   uint64
+		want uint64
+	}{
+		{100, 0},
+		{109, 0},
+		{110, 1},
+		{125, 2},
+		{130, 5},
+		{139, 5},
+		{140, 6},
+		{199, 6},
+		{219, 8},
+		{220, 9},
+		{1000, 9},
+	} {
+		chain := &chainTimes{times: times}
+		got, err := BlockAt(context.Background(), chain, time.Unix(int64(tc.ts), 0))
+		if err != nil {
+			t.Errorf("BlockAt(%d): %v", tc.ts, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("BlockAt(%d) = %d, want %d", tc.ts, got, tc.want)
+		}
+		// The head, genesis and a binary search over the ten blocks.
+		if chain.reads > 2+4 {
+			t.Errorf("BlockAt(%d) read %d headers", tc.ts, chain.reads)
+		}
+	}
+
+	if _, err := BlockAt(context.Background(), &chainTimes{times: times}, time.Unix(99, 0)); err == nil {
+		t.Error("BlockAt before genesis succeeded")
+	}
+	errDown := errors.New("node down")
+	if _, err := BlockAt(context.Background(), &chainTimes{times: times, err: errDown}, time.Unix(150, 0)); !errors.Is(err, errDown) {
+		t.Errorf("BlockAt = %v, want %v", err, errDown)
+	}
+}
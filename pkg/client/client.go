@@ -0,0 +1,113 @@
+// Package client bundles the generated contract bindings for a deployment of
+// the Lilypad protocol behind a single value.
+package client
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	lilypadcontractregistry "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadContractRegistry"
+	lilypadmoduledirectory "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadModuleDirectory"
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadproxy "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadProxy"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	lilypadtoken "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadToken"
+	lilypadtokenomics "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadTokenomics"
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	lilypadvalidation "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadValidation"
+	lilypadvesting "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadVesting"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/poll"
+)
+
+// Client holds a binding for every contract in its AddressBook. Bindings for
+// contracts whose address is zero are nil.
+type Client struct {
+	Backend   bind.ContractBackend
+	Addresses AddressBook
+	// RPC is an optional raw connection to the same node, used for call
+	// tracing when Backend does not expose one.
+	RPC *rpc.Client
+	// Admins, if set, suggests who can grant a role when Explain decodes
+	// an AccessControl denial.
+	Admins RoleAdmins
+
+	Registry        *lilypadcontractregistry.LilypadContractRegistry
+	Token           *lilypadtoken.LilypadToken
+	User            *lilypaduser.LilypadUser
+	ModuleDirectory *lilypadmoduledirectory.LilypadModuleDirectory
+	Storage         *lilypadstorage.LilypadStorage
+	PaymentEngine   *lilypadpaymentengine.LilypadPaymentEngine
+	Proxy           *lilypadproxy.LilypadProxy
+	Vesting         *lilypadvesting.LilypadVesting
+	Tokenomics      *lilypadtokenomics.LilypadTokenomics
+	Validation      *lilypadvalidation.LilypadValidation
+}
+
+// New binds every contract in book to backend.
+func New(backend bind.ContractBackend, book AddressBook) (*Client, error) {
+	c := &Client{Backend: backend, Addresses: book}
+	var err error
+	if set(book.Registry) {
+		if c.Registry, err = lilypadcontractregistry.NewLilypadContractRegistry(book.Registry, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.Token) {
+		if c.Token, err = lilypadtoken.NewLilypadToken(book.Token, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.User) {
+		if c.User, err = lilypaduser.NewLilypadUser(book.User, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.ModuleDirectory) {
+		if c.ModuleDirectory, err = lilypadmoduledirectory.NewLilypadModuleDirectory(book.ModuleDirectory, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.Storage) {
+		if c.Storage, err = lilypadstorage.NewLilypadStorage(book.Storage, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.PaymentEngine) {
+		if c.PaymentEngine, err = lilypadpaymentengine.NewLilypadPaymentEngine(book.PaymentEngine, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.Proxy) {
+		if c.Proxy, err = lilypadproxy.NewLilypadProxy(book.Proxy, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.Vesting) {
+		if c.Vesting, err = lilypadvesting.NewLilypadVesting(book.Vesting, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.Tokenomics) {
+		if c.Tokenomics, err = lilypadtokenomics.NewLilypadTokenomics(book.Tokenomics, backend); err != nil {
+			return nil, err
+		}
+	}
+	if set(book.Validation) {
+		if c.Validation, err = lilypadvalidation.NewLilypadValidation(book.Validation, backend); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewPolling is like New but serves the bindings' Watch methods, and any
+// other log subscription made through c.Backend, by polling eth_getLogs. Use
+// it for HTTP-only endpoints; consumers need no other change.
+func NewPolling(backend bind.ContractBackend, book AddressBook, cfg poll.Config) (*Client, error) {
+	return New(poll.Wrap(backend, cfg), book)
+}
+
+func set(addr common.Address) bool {
+	return addr != (common.Address{})
+}
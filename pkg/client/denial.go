@@ -0,0 +1,290 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// unauthorized is the OpenZeppelin AccessControl error every Lilypad
+// contract reverts with when the sender lacks a role.
+const unauthorized = "AccessControlUnauthorizedAccount"
+
+// notController is the error LilypadModuleDirectory reverts with instead
+// when its caller lacks CONTROLLER_ROLE. It names neither.
+const notController = "LilypadModuleDirectory__NotController"
+
+// Admin is an account able to grant a role.
+type Admin struct {
+	Account common.Address
+	// Name is the address book name of the account, if any.
+	Name string
+}
+
+// RoleAdmins looks up who can grant a role. *access.Report implements it
+// from a role audit.
+type RoleAdmins interface {
+	// RoleAdmins returns the role administering role on the named contract
+	// and the accounts currently holding it.
+	RoleAdmins(contract string, role common.Hash) (common.Hash, []Admin)
+}
+
+// Denial is an AccessControl denial put in context: the call that was
+// denied, the account and the role it lacks, and who could grant that role.
+//
+// The account is the sender the denying contract saw. When that is not the
+// signer, the signer's call went through and a contract it reached was
+// denied on a further call: Account is then the calling contract and
+// Downstream the contracts it may lack Role on.
+type Denial struct {
+	// Contract and Method name the denied call; either is empty when
+	// unknown.
+	Contract string
+	Method   string
+	// Signer sent the call; it is zero when unknown.
+	Signer common.Address
+	// Account lacks Role. AccountName is its address book name, if any. It
+	// is zero when a downstream LilypadModuleDirectory denied a contract
+	// the client could not identify.
+	Account     common.Address
+	AccountName string
+	Role        common.Hash
+	// Downstream lists the contracts of the address book on which Account,
+	// not being the signer, lacks Role.
+	Downstream []string
+	// AdminRole administers Role and Admins hold it, on Contract or on the
+	// only Downstream contract. Both are unset when the denial was
+	// explained without RoleAdmins or with several Downstream candidates.
+	AdminRole common.Hash
+	Admins    []Admin
+	// Revert is the decoded revert and Err the error it was decoded from.
+	Revert *revert.Error
+	Err    error
+
+	suggested bool
+}
+
+// Indirect reports whether the denial was of a contract the signer's call
+// reached rather than of the signer.
+func (d *Denial) Indirect() bool {
+	return len(d.Downstream) > 0 || (d.Signer != common.Address{} && d.Account != d.Signer)
+}
+
+// Error implements the error interface.
+func (d *Denial) Error() string {
+	call := "call"
+	switch {
+	case d.Contract != "" && d.Method != "":
+		call = d.Contract + "." + d.Method
+	case d.Contract != "":
+		call = "call to " + d.Contract
+	case d.Method != "":
+		call = d.Method
+	}
+	var msg string
+	if d.Indirect() {
+		account := "the contract it calls"
+		switch {
+		case d.AccountName != "":
+			account = "contract " + d.AccountName + " " + d.Account.Hex()
+		case d.Account != common.Address{}:
+			account = "contract " + d.Account.Hex()
+		}
+		where := "a contract it calls"
+		switch len(d.Downstream) {
+		case 0:
+		case 1:
+			where = d.Downstream[0]
+		default:
+			where = "one of " + strings.Join(d.Downstream, ", ")
+		}
+		msg = fmt.Sprintf("%s denied downstream: %s lacks %s on %s", call, account, roles.String(d.Role), where)
+	} else {
+		msg = fmt.Sprintf("%s denied: signer %s lacks %s", call, d.Account.Hex(), roles.String(d.Role))
+		if d.Contract != "" {
+			msg += " on " + d.Contract
+		}
+	}
+	if !d.suggested {
+		return msg
+	}
+	if len(d.Admins) == 0 {
+		return fmt.Sprintf("%s; no account holds %s to grant it", msg, roles.String(d.AdminRole))
+	}
+	admins := make([]string, len(d.Admins))
+	for i, a := range d.Admins {
+		admins[i] = a.Account.Hex()
+		if a.Name != "" {
+			admins[i] += " (" + a.Name + ")"
+		}
+	}
+	return fmt.Sprintf("%s; a holder of %s can grant it: %s", msg, roles.String(d.AdminRole), strings.Join(admins, ", "))
+}
+
+// Unwrap returns the error the denial was decoded from.
+func (d *Denial) Unwrap() error {
+	return d.Err
+}
+
+// Explain returns err as a *Denial when it carries an
+// AccessControlUnauthorizedAccount or LilypadModuleDirectory__NotController
+// revert from method of the named contract, sent by from, and err
+// unchanged otherwise. from may be zero when the signer is unknown. When
+// admins is not nil, the denial suggests the accounts that could grant the
+// missing role. Errors already explained are returned as is.
+//
+// Explain cannot look up which contract denied an indirect call; the
+// Client method can.
+func Explain(err error, contract, method string, from common.Address, admins RoleAdmins) error {
+	return explain(err, contract, method, from, admins, nil)
+}
+
+// explain is Explain, calling locate, if set, on indirect denials before
+// suggesting admins.
+func explain(err error, contract, method string, from common.Address, admins RoleAdmins, locate func(*Denial)) error {
+	if err == nil {
+		return nil
+	}
+	var d *Denial
+	if errors.As(err, &d) {
+		return err
+	}
+	if d = decodeDenial(err, contract, method, from); d == nil {
+		return err
+	}
+	if locate != nil && d.Indirect() {
+		locate(d)
+	}
+	d.suggest(admins)
+	return d
+}
+
+// decodeDenial decodes the denial err carries, or returns nil.
+func decodeDenial(err error, contract, method string, from common.Address) *Denial {
+	decoded, ok := revert.Decode(err, denialABIs(contract)...)
+	if !ok {
+		return nil
+	}
+	d := &Denial{Contract: contract, Method: method, Signer: from, Revert: decoded, Err: err}
+	switch {
+	case decoded.Name == unauthorized && len(decoded.Args) == 2:
+		account, ok := decoded.Args[0].(common.Address)
+		if !ok {
+			return nil
+		}
+		role, ok := decoded.Args[1].([32]byte)
+		if !ok {
+			return nil
+		}
+		d.Account, d.Role = account, role
+	case decoded.Name == notController:
+		// The error names neither the caller nor the role. Called directly,
+		// the caller is the signer; otherwise it is the contract called.
+		d.Role = roles.Controller
+		if contract == "LilypadModuleDirectory" {
+			d.Account = from
+		} else {
+			d.Downstream = []string{"LilypadModuleDirectory"}
+		}
+	default:
+		return nil
+	}
+	return d
+}
+
+// suggest looks up the admins of the missing role on the contract that
+// denied it, when that contract is known.
+func (d *Denial) suggest(admins RoleAdmins) {
+	if admins == nil {
+		return
+	}
+	contract := d.Contract
+	if d.Indirect() {
+		if len(d.Downstream) != 1 {
+			return
+		}
+		contract = d.Downstream[0]
+	}
+	d.AdminRole, d.Admins = admins.RoleAdmins(contract, d.Role)
+	d.suggested = true
+}
+
+// Explain is like the package-level Explain for a call of method on the
+// contract the client has at to, suggesting admins from c.Admins. When the
+// denied account is not from, it names the account from the address book
+// and finds the contracts it lacks the role on by calling hasRole on every
+// other contract of the book.
+func (c *Client) Explain(ctx context.Context, err error, to common.Address, method string, from common.Address) error {
+	name, _, _ := c.ABI(to)
+	return explain(err, name, method, from, c.Admins, func(d *Denial) {
+		if d.Account == (common.Address{}) {
+			d.Account = to
+		}
+		book := c.Addresses.Named()
+		for n, addr := range book {
+			if addr == d.Account {
+				d.AccountName = n
+			}
+		}
+		if len(d.Downstream) == 0 {
+			d.Downstream = c.lacking(ctx, book, d.Role, d.Account, to)
+		}
+	})
+}
+
+// lacking returns the names of the contracts in book, other than skip and
+// account itself, on which account does not hold role. Contracts that fail
+// to answer hasRole are left out.
+func (c *Client) lacking(ctx context.Context, book map[string]common.Address, role common.Hash, account, skip common.Address) []string {
+	var out []string
+	for name, addr := range book {
+		if addr == skip || addr == account {
+			continue
+		}
+		parsed, ok := ContractABI(name)
+		if !ok {
+			continue
+		}
+		if _, ok := parsed.Methods["hasRole"]; !ok {
+			continue
+		}
+		var res []interface{}
+		bound := bind.NewBoundContract(addr, *parsed, c.Backend, nil, nil)
+		if err := bound.Call(&bind.CallOpts{Context: ctx}, &res, "hasRole", role, account); err != nil {
+			continue
+		}
+		if held, ok := res[0].(bool); ok && !held {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// denialABIs returns the ABIs to decode a denial from the named contract
+// with: its own first, then every other binding's, as the revert may come
+// from a contract it calls.
+func denialABIs(contract string) []*abi.ABI {
+	var abis []*abi.ABI
+	if parsed, ok := ContractABI(contract); ok {
+		abis = append(abis, parsed)
+	}
+	for _, name := range ContractNames() {
+		if name == contract {
+			continue
+		}
+		if parsed, ok := ContractABI(name); ok {
+			abis = append(abis, parsed)
+		}
+	}
+	return abis
+}
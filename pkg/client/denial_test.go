@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// revertError carries revert data the way go-ethereum's RPC errors do.
+type revertError []byte
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e) }
+
+func revertWith(t *testing.T, contract, name string, args ...interface{}) error {
+	t.Helper()
+	parsed, ok := ContractABI(contract)
+	if !ok {
+		t.Fatalf("no ABI for %s", contract)
+	}
+	abiErr := parsed.Errors[name]
+	packed, err := abiErr.Inputs.Pack(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revertError(append(abiErr.ID[:4:4], packed...))
+}
+
+// roleBackend answers hasRole from the accounts holding CONTROLLER_ROLE on
+// each contract. The other backend methods are not used.
+type roleBackend struct {
+	bind.ContractBackend
+	controllers map[common.Address]common.Address
+}
+
+func (b roleBackend) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (b roleBackend) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	parsed, _ := ContractABI("LilypadStorage")
+	method, err := parsed.MethodById(msg.Data[:4])
+	if err != nil || method.Name != "hasRole" {
+		return nil, errors.New("unexpected call")
+	}
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	role, account := common.Hash(args[0].([32]byte)), args[1].(common.Address)
+	return method.Outputs.Pack(role == roles.Controller && b.controllers[*msg.To] == account)
+}
+
+type fakeAdmins map[string][]Admin
+
+func (f fakeAdmins) RoleAdmins(contract string, role common.Hash) (common.Hash, []Admin) {
+	return roles.DefaultAdmin, f[contract]
+}
+
+func TestClientExplain(t *testing.T) {
+	signer := common.HexToAddress("0xee")
+	book := AddressBook{
+		User:            common.HexToAddress("0x03"),
+		ModuleDirectory: common.HexToAddress("0x04"),
+		Storage:         common.HexToAddress("0x05"),
+		Proxy:           common.HexToAddress("0x07"),
+	}
+	c := &Client{
+		Addresses: book,
+		// The proxy is a controller of LilypadUser and LilypadModuleDirectory
+		// but not of LilypadStorage.
+		Backend: roleBackend{controllers: map[common.Address]common.Address{book.User: book.Proxy, book.ModuleDirectory: book.Proxy}},
+		Admins:  fakeAdmins{"LilypadStorage": {{Account: common.HexToAddress("0xad"), Name: "storage admin"}}},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		err        error
+		to         common.Address
+		account    common.Address
+		downstream []string
+		want       string
+	}{
+		{
+			name:    "signer denied",
+			err:     revertWith(t, "LilypadStorage", "AccessControlUnauthorizedAccount", signer, roles.Controller),
+			to:      book.Storage,
+			account: signer,
+			want:    "LilypadStorage.saveDeal denied: signer " + signer.Hex() + " lacks CONTROLLER_ROLE on LilypadStorage",
+		},
+		{
+			name:       "proxy denied downstream",
+			err:        revertWith(t, "LilypadStorage", "AccessControlUnauthorizedAccount", book.Proxy, roles.Controller),
+			to:         book.Proxy,
+			account:    book.Proxy,
+			downstream: []string{"LilypadStorage"},
+			want:       "LilypadProxy.saveDeal denied downstream: contract LilypadProxy " + book.Proxy.Hex() + " lacks CONTROLLER_ROLE on LilypadStorage; a holder of DEFAULT_ADMIN_ROLE can grant it",
+		},
+		{
+			name:       "module directory denied downstream",
+			err:        revertWith(t, "LilypadModuleDirectory", notController),
+			to:         book.User,
+			account:    book.User,
+			downstream: []string{"LilypadModuleDirectory"},
+			want:       "LilypadUser.saveDeal denied downstream: contract LilypadUser " + book.User.Hex() + " lacks CONTROLLER_ROLE on LilypadModuleDirectory",
+		},
+		{
+			name:    "module directory denied signer",
+			err:     revertWith(t, "LilypadModuleDirectory", notController),
+			to:      book.ModuleDirectory,
+			account: signer,
+			want:    "LilypadModuleDirectory.saveDeal denied: signer " + signer.Hex() + " lacks CONTROLLER_ROLE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d *Denial
+			if !errors.As(c.Explain(ctx, tt.err, tt.to, "saveDeal", signer), &d) {
+				t.Fatal("not explained as a denial")
+			}
+			if d.Account != tt.account || strings.Join(d.Downstream, ",") != strings.Join(tt.downstream, ",") {
+				t.Errorf("account %s downstream %v, want %s %v", d.Account.Hex(), d.Downstream, tt.account.Hex(), tt.downstream)
+			}
+			if !strings.HasPrefix(d.Error(), tt.want) {
+				t.Errorf("Error() = %q, want prefix %q", d.Error(), tt.want)
+			}
+		})
+	}
+}
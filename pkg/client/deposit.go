@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/poll"
+)
+
+// ErrNoReceipts is returned when the client backend cannot wait for
+// transactions to be mined.
+var ErrNoReceipts = errors.New("client: backend cannot fetch receipts")
+
+// Receipts returns the backend to wait for transactions with, looking
+// through any polling wrappers of c.Backend.
+func (c *Client) Receipts() (bind.DeployBackend, error) {
+	receipts, ok := poll.Unwrap(c.Backend).(bind.DeployBackend)
+	if !ok {
+		return nil, ErrNoReceipts
+	}
+	return receipts, nil
+}
+
+// Deposited is the outcome of Deposit, filled in as far as it got.
+type Deposited struct {
+	// Allowance is what the payment engine was allowed to pull before.
+	Allowance *big.Int
+	// Approval is the receipt of the token approval, nil if Allowance
+	// already covered the amount.
+	Approval *types.Receipt
+	// Deposit is the receipt of the deposit.
+	Deposit *types.Receipt
+}
+
+// Deposit pays amount from opts.From into LilypadPaymentEngine escrow with
+// deposit, a LilypadProxy method such as AcceptJobPayment named method, and
+// waits for it to be mined. The proxy checks the allowance granted to the
+// payment engine, which is the contract that pulls the tokens, so Deposit
+// first approves the payment engine for amount if that allowance is short.
+// A reverted transaction is an error; a reverted approval is not followed by
+// the deposit.
+func (c *Client) Deposit(ctx context.Context, opts *bind.TransactOpts, amount *big.Int, method string, deposit func(*bind.TransactOpts, *big.Int) (*types.Transaction, error)) (*Deposited, error) {
+	d := &Deposited{}
+	receipts, err := c.Receipts()
+	if err != nil {
+		return d, err
+	}
+	sender := *opts
+	sender.Context = ctx
+
+	spender := c.Addresses.PaymentEngine
+	if d.Allowance, err = c.Token.Allowance(&bind.CallOpts{Context: ctx}, opts.From, spender); err != nil {
+		return d, fmt.Errorf("client: reading allowance: %w", err)
+	}
+	if d.Allowance.Cmp(amount) < 0 {
+		tx, err := c.Token.Approve(&sender, spender, amount)
+		if err != nil {
+			return d, fmt.Errorf("client: approving payment engine: %w", c.Explain(ctx, err, c.Addresses.Token, "approve", opts.From))
+		}
+		if d.Approval, err = waitSuccess(ctx, receipts, tx, "approve"); err != nil {
+			return d, err
+		}
+	}
+
+	tx, err := deposit(&sender, amount)
+	if err != nil {
+		return d, fmt.Errorf("client: depositing: %w", c.Explain(ctx, err, c.Addresses.Proxy, method, opts.From))
+	}
+	d.Deposit, err = waitSuccess(ctx, receipts, tx, method)
+	return d, err
+}
+
+// waitSuccess waits for tx to be mined. The receipt of a reverted
+// transaction is returned along with an error.
+func waitSuccess(ctx context.Context, receipts bind.DeployBackend, tx *types.Transaction, what string) (*types.Receipt, error) {
+	receipt, err := bind.WaitMined(ctx, receipts, tx)
+	if err != nil {
+		return nil, fmt.Errorf("client: waiting for %s: %w", what, err)
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		return receipt, fmt.Errorf("client: %s transaction %s reverted", what, tx.Hash().Hex())
+	}
+	return receipt, nil
+}
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/poll"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+)
+
+// dryRunGasLimit is set on the built transaction so that the binding does not
+// estimate gas, which would fail on a revert before it can be decoded.
+const dryRunGasLimit = 30_000_000
+
+// ErrNoTracer is returned by Trace when the backend exposes neither
+// debug_traceCall nor eth_simulateV1.
+var ErrNoTracer = errors.New("client: backend does not support call tracing")
+
+// Event is a log emitted during a dry run, decoded against the ABI of the
+// emitting contract when it is part of the client's address book.
+type Event struct {
+	Contract string
+	Name     string
+	Args     map[string]interface{}
+	Log      types.Log
+}
+
+// DryRun describes what a transaction would do if it were sent now.
+type DryRun struct {
+	// Tx is the signed transaction the binding built. It was not broadcast.
+	Tx       *types.Transaction
+	Contract string
+	Method   string
+	// Return holds the decoded return values when the call succeeded.
+	Return []interface{}
+	// Revert is set when the call reverted with a known error.
+	Revert *revert.Error
+	// Denial is set when Revert is an AccessControl denial.
+	Denial *Denial
+	// Gas is the estimated gas, zero when the call reverted.
+	Gas uint64
+	// Events lists the logs the transaction would emit. It is nil if the
+	// call reverted or the backend cannot trace calls, see TraceErr.
+	Events   []Event
+	TraceErr error
+}
+
+// DryRun builds the transaction that send makes with opts, without
+// broadcasting it, and executes it as an eth_call from the same sender at the
+// pending block. It works with any binding transactor method:
+//
+//	run, err := c.DryRun(ctx, opts, func(o *bind.TransactOpts) (*types.Transaction, error) {
+//		return c.Proxy.SetDeal(o, deal)
+//	})
+//
+// A revert with a known error is reported in DryRun.Revert rather than as an
+// error, and an AccessControl denial is also explained in DryRun.Denial.
+func (c *Client) DryRun(ctx context.Context, opts *bind.TransactOpts, send func(*bind.TransactOpts) (*types.Transaction, error)) (*DryRun, error) {
+	built := *opts
+	built.Context = ctx
+	built.NoSend = true
+	if built.GasLimit == 0 {
+		built.GasLimit = dryRunGasLimit
+	}
+	tx, err := send(&built)
+	if err != nil {
+		return nil, err
+	}
+	if tx.To() == nil {
+		return nil, errors.New("client: dry run of contract creation is not supported")
+	}
+	run := &DryRun{Tx: tx}
+	parsed := c.describe(run)
+
+	msg := ethereum.CallMsg{From: opts.From, To: tx.To(), Value: tx.Value(), Data: tx.Data()}
+	var out []byte
+	if pending, ok := poll.Unwrap(c.Backend).(bind.PendingContractCaller); ok {
+		out, err = pending.PendingCallContract(ctx, msg)
+	} else {
+		out, err = c.Backend.CallContract(ctx, msg, nil)
+	}
+	if err != nil {
+		decoded, ok := revert.Decode(err, c.ABIs()...)
+		if !ok {
+			return nil, err
+		}
+		run.Revert = decoded
+		if d, ok := c.Explain(ctx, err, *tx.To(), run.Method, opts.From).(*Denial); ok {
+			run.Denial = d
+		}
+		return run, nil
+	}
+	if parsed != nil {
+		if run.Return, err = parsed.Outputs.Unpack(out); err != nil {
+			return nil, fmt.Errorf("client: decoding %s return value: %w", run.Method, err)
+		}
+	}
+	if run.Gas, err = c.Backend.EstimateGas(ctx, msg); err != nil {
+		return nil, fmt.Errorf("client: estimating gas: %w", err)
+	}
+	logs, err := c.Trace(ctx, msg)
+	if err != nil {
+		run.TraceErr = err
+		return run, nil
+	}
+	run.Events = make([]Event, len(logs))
+	for i, log := range logs {
+		run.Events[i] = c.DecodeLog(log)
+	}
+	return run, nil
+}
+
+// describe fills in the contract and method of run and returns the method.
+func (c *Client) describe(run *DryRun) *abi.Method {
+	name, parsed, ok := c.ABI(*run.Tx.To())
+	run.Contract = name
+	if !ok || len(run.Tx.Data()) < 4 {
+		return nil
+	}
+	method, err := parsed.MethodById(run.Tx.Data()[:4])
+	if err != nil {
+		return nil
+	}
+	run.Method = method.Name
+	return method
+}
+
+// DecodeLog decodes log against the ABI of the emitting contract. Name and
+// Args are left empty when the contract is not in the address book or the
+// event is unknown.
+func (c *Client) DecodeLog(log types.Log) Event {
+	ev := Event{Log: log}
+	name, parsed, ok := c.ABI(log.Address)
+	ev.Contract = name
+	if !ok || len(log.Topics) == 0 {
+		return ev
+	}
+	event, err := parsed.EventByID(log.Topics[0])
+	if err != nil {
+		return ev
+	}
+	ev.Name = event.Name
+	args := make(map[string]interface{})
+	if len(log.Data) > 0 {
+		if err := event.Inputs.UnpackIntoMap(args, log.Data); err != nil {
+			return ev
+		}
+	}
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(args, indexed, log.Topics[1:]); err != nil {
+		return ev
+	}
+	ev.Args = args
+	return ev
+}
+
+// rpcClient is implemented by ethclient.Client.
+type rpcClient interface {
+	Client() *rpc.Client
+}
+
+// Trace returns the logs msg would emit at the pending block. It uses
+// debug_traceCall with the callTracer where the node exposes it and falls
+// back to eth_simulateV1, which the simulated backend serves.
+//
+// Tracing needs a raw RPC connection: c.RPC if set, otherwise the one behind
+// an ethclient.Client backend. The simulated backend hides its connection;
+// start it with an IPC endpoint and dial that into c.RPC:
+//
+//	sim := simulated.NewBackend(alloc, func(n *node.Config, _ *ethconfig.Config) {
+//		n.IPCPath = ipcPath
+//	})
+//	c.RPC, err = rpc.Dial(ipcPath)
+func (c *Client) Trace(ctx context.Context, msg ethereum.CallMsg) ([]types.Log, error) {
+	conn := c.RPC
+	if conn == nil {
+		if rc, ok := poll.Unwrap(c.Backend).(rpcClient); ok {
+			conn = rc.Client()
+		}
+	}
+	if conn == nil {
+		return nil, ErrNoTracer
+	}
+	args := callArgs(msg)
+
+	var frame callFrame
+	config := map[string]interface{}{"tracer": "callTracer", "tracerConfig": map[string]bool{"withLog": true}}
+	if err := conn.CallContext(ctx, &frame, "debug_traceCall", args, "pending", config); err == nil {
+		var logs []types.Log
+		frame.collect(&logs)
+		return logs, nil
+	}
+
+	var blocks []struct {
+		Calls []struct {
+			Logs  []types.Log `json:"logs"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"calls"`
+	}
+	opts := map[string]interface{}{
+		"blockStateCalls": []interface{}{map[string]interface{}{"calls": []interface{}{args}}},
+	}
+	if err := conn.CallContext(ctx, &blocks, "eth_simulateV1", opts, "pending"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoTracer, err)
+	}
+	if len(blocks) != 1 || len(blocks[0].Calls) != 1 {
+		return nil, errors.New("client: unexpected eth_simulateV1 response")
+	}
+	call := blocks[0].Calls[0]
+	if call.Error != nil {
+		return nil, fmt.Errorf("client: simulated call failed: %s", call.Error.Message)
+	}
+	return call.Logs, nil
+}
+
+func callArgs(msg ethereum.CallMsg) map[string]interface{} {
+	args := map[string]interface{}{
+		"from":  msg.From,
+		"input": hexutil.Bytes(msg.Data),
+	}
+	if msg.To != nil {
+		args["to"] = *msg.To
+	}
+	if msg.Value != nil && msg.Value.Sign() > 0 {
+		args["value"] = (*hexutil.Big)(new(big.Int).Set(msg.Value))
+	}
+	return args
+}
+
+// callFrame is the subset of the callTracer output needed to recover logs.
+type callFrame struct {
+	Error string      `json:"error"`
+	Calls []callFrame `json:"calls"`
+	Logs  []struct {
+		Address  common.Address `json:"address"`
+		Topics   []common.Hash  `json:"topics"`
+		Data     hexutil.Bytes  `json:"data"`
+		Position hexutil.Uint   `json:"position"`
+	} `json:"logs"`
+}
+
+// collect appends the logs of f and its subcalls in execution order. Each
+// log's position is the number of subcalls made before it was emitted.
+func (f *callFrame) collect(logs *[]types.Log) {
+	if f.Error != "" {
+		return
+	}
+	entries := f.Logs
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Position < entries[j].Position })
+	next := 0
+	for i := range f.Calls {
+		for next < len(entries) && int(entries[next].Position) <= i {
+			e := entries[next]
+			*logs = append(*logs, types.Log{Address: e.Address, Topics: e.Topics, Data: e.Data})
+			next++
+		}
+		f.Calls[i].collect(logs)
+	}
+	for ; next < len(entries); next++ {
+		e := entries[next]
+		*logs = append(*logs, types.Log{Address: e.Address, Topics: e.Topics, Data: e.Data})
+	}
+}
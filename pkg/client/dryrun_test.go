@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// tokenCode is a stand-in for LilypadToken, whose bytecode is not part of
+// the bindings. transfer emits Transfer(caller, to, amount) and returns
+// true; mint returns true for the minter in slot 0 and reverts with
+// AccessControlUnauthorizedAccount for anyone else.
+const tokenCode = `
+	PUSH 0
+	CALLDATALOAD
+	PUSH 0xe0
+	SHR
+	DUP1
+	PUSH 0x%x
+	EQ
+	JUMPI @transfer
+	DUP1
+	PUSH 0x%x
+	EQ
+	JUMPI @mint
+	PUSH 0
+	DUP1
+	REVERT
+transfer:
+	PUSH 0x24
+	CALLDATALOAD
+	PUSH 0
+	MSTORE
+	PUSH 0x04
+	CALLDATALOAD
+	CALLER
+	PUSH 0x%x
+	PUSH 0x20
+	PUSH 0
+	LOG3
+ok:
+	PUSH 1
+	PUSH 0
+	MSTORE
+	PUSH 0x20
+	PUSH 0
+	RETURN
+mint:
+	CALLER
+	PUSH 0
+	SLOAD
+	EQ
+	JUMPI @ok
+	PUSH 0x%x
+	PUSH 0xe0
+	SHL
+	PUSH 0
+	MSTORE
+	CALLER
+	PUSH 0x04
+	MSTORE
+	PUSH 0x%x
+	PUSH 0x24
+	MSTORE
+	PUSH 0x44
+	PUSH 0
+	REVERT
+`
+
+func compileToken(t *testing.T) []byte {
+	t.Helper()
+	parsed, ok := ContractABI("LilypadToken")
+	if !ok {
+		t.Fatal("no LilypadToken ABI")
+	}
+	denied := parsed.Errors[unauthorized].ID
+	src := fmt.Sprintf(tokenCode,
+		parsed.Methods["transfer"].ID, parsed.Methods["mint"].ID,
+		parsed.Events["Transfer"].ID, denied[:4], roles.Minter)
+	c := asm.NewCompiler(false)
+	c.Feed(asm.Lex([]byte(src), false))
+	out, errs := c.Compile()
+	if len(errs) != 0 {
+		t.Fatalf("compiling the token: %v", errs)
+	}
+	return common.FromHex(out)
+}
+
+func TestDryRunSimulated(t *testing.T) {
+	minterKey, _ := crypto.GenerateKey()
+	userKey, _ := crypto.GenerateKey()
+	minter, user := crypto.PubkeyToAddress(minterKey.PublicKey), crypto.PubkeyToAddress(userKey.PublicKey)
+	token := common.HexToAddress("0x7070")
+	funds := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+	// The IPC path must stay short, so it is not under t.TempDir.
+	dir, err := os.MkdirTemp("", "dryrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ipc := filepath.Join(dir, "sim.ipc")
+	sim := simulated.NewBackend(types.GenesisAlloc{
+		minter: {Balance: funds},
+		user:   {Balance: funds},
+		token:  {Code: compileToken(t), Storage: map[common.Hash]common.Hash{{}: common.BytesToHash(minter.Bytes())}},
+	}, func(n *node.Config, _ *ethconfig.Config) {
+		n.IPCPath = ipc
+	})
+	defer sim.Close()
+
+	c, err := New(sim.Client(), AddressBook{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RPC, err = rpc.Dial(ipc); err != nil {
+		t.Fatal(err)
+	}
+	defer c.RPC.Close()
+	ctx := context.Background()
+
+	opts, err := bind.NewKeyedTransactorWithChainID(userKey, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0xb0b")
+	run, err := c.DryRun(ctx, opts, func(o *bind.TransactOpts) (*types.Transaction, error) {
+		return c.Token.Transfer(o, to, big.NewInt(5))
+	})
+	if err != nil {
+		t.Fatalf("DryRun(transfer): %v", err)
+	}
+	if run.Contract != "LilypadToken" || run.Method != "transfer" || run.Revert != nil {
+		t.Fatalf("dry run of %s.%s reverted with %v", run.Contract, run.Method, run.Revert)
+	}
+	if len(run.Return) != 1 || run.Return[0] != true {
+		t.Errorf("Return = %v, want [true]", run.Return)
+	}
+	if run.Gas == 0 {
+		t.Error("no gas estimate")
+	}
+	if run.TraceErr != nil {
+		t.Fatalf("TraceErr: %v", run.TraceErr)
+	}
+	if len(run.Events) != 1 {
+		t.Fatalf("%d events, want the Transfer", len(run.Events))
+	}
+	ev := run.Events[0]
+	if ev.Contract != "LilypadToken" || ev.Name != "Transfer" || ev.Args["from"] != user || ev.Args["to"] != to || ev.Args["value"].(*big.Int).Int64() != 5 {
+		t.Errorf("event %s.%s %v", ev.Contract, ev.Name, ev.Args)
+	}
+
+	run, err = c.DryRun(ctx, opts, func(o *bind.TransactOpts) (*types.Transaction, error) {
+		return c.Token.Mint(o, user, big.NewInt(5))
+	})
+	if err != nil {
+		t.Fatalf("DryRun(mint): %v", err)
+	}
+	if run.Revert == nil || run.Revert.Name != unauthorized {
+		t.Fatalf("Revert = %v, want %s", run.Revert, unauthorized)
+	}
+	if d := run.Denial; d == nil || d.Account != user || d.Role != roles.Minter || d.Indirect() {
+		t.Errorf("Denial = %+v, want %s lacking MINTER_ROLE", d, user.Hex())
+	}
+	if run.Events != nil || run.Gas != 0 {
+		t.Errorf("reverted dry run has events %v and gas %d", run.Events, run.Gas)
+	}
+
+	minterOpts, err := bind.NewKeyedTransactorWithChainID(minterKey, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err = c.DryRun(ctx, minterOpts, func(o *bind.TransactOpts) (*types.Transaction, error) {
+		return c.Token.Mint(o, user, big.NewInt(5))
+	})
+	if err != nil {
+		t.Fatalf("DryRun(mint) by the minter: %v", err)
+	}
+	if run.Revert != nil {
+		t.Errorf("minter's dry run reverted with %s", run.Revert.Name)
+	}
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrNotArchive is returned by At, AtTime and the view methods of a
+	// historical client when the node has pruned the requested state.
+	ErrNotArchive = errors.New("client: node does not serve historical state, use an archive node")
+	// ErrReadOnly is returned when a historical client is used to send a
+	// transaction.
+	ErrReadOnly = errors.New("client: historical view is read-only")
+)
+
+// archiveHints are fragments of the errors nodes return for pruned state.
+var archiveHints = []string{
+	"missing trie node",
+	"historical state",
+	"state not available",
+	"state is not available",
+	"pruned",
+}
+
+// At returns a read-only view of c at block: every view method of every
+// binding reads the state as of that block, unless its CallOpts name a block
+// of their own. Log filtering is unaffected. Transactions fail with
+// ErrReadOnly.
+//
+// At checks that the node still serves the state of block and returns
+// ErrNotArchive if it does not. To answer what a resource provider's escrow
+// was when a deal was saved:
+//
+//	past, err := c.At(ctx, deal.Block)
+//	escrow, err := past.PaymentEngine.ActiveEscrow(&bind.CallOpts{Context: ctx}, deal.ResourceProvider)
+func (c *Client) At(ctx context.Context, block uint64) (*Client, error) {
+	backend := c.Backend
+	if p, ok := backend.(*pinnedBackend); ok {
+		backend = p.ContractBackend
+	}
+	header, err := backend.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+	if err != nil {
+		return nil, fmt.Errorf("client: reading header %d: %w", block, err)
+	}
+	pinned := &pinnedBackend{ContractBackend: backend, block: header.Number}
+	// Reading any account at block fails on nodes that pruned its state.
+	if _, err := pinned.CodeAt(ctx, c.Addresses.Registry, nil); err != nil {
+		return nil, err
+	}
+	view, err := New(pinned, c.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	view.RPC = c.RPC
+	return view, nil
+}
+
+// AtTime is like At for the last block mined at or before ts.
+func (c *Client) AtTime(ctx context.Context, ts time.Time) (*Client, error) {
+	block, err := BlockAt(ctx, c.Backend, ts)
+	if err != nil {
+		return nil, err
+	}
+	return c.At(ctx, block)
+}
+
+// Block returns the block a historical view reads at; ok is false for a
+// client reading the latest state.
+func (c *Client) Block() (block uint64, ok bool) {
+	if p, isPinned := c.Backend.(*pinnedBackend); isPinned {
+		return p.block.Uint64(), true
+	}
+	return 0, false
+}
+
+// pinnedBackend routes state reads without an explicit block to block.
+type pinnedBackend struct {
+	bind.ContractBackend
+	block *big.Int
+}
+
+func (p *pinnedBackend) at(blockNumber *big.Int) *big.Int {
+	if blockNumber != nil {
+		return blockNumber
+	}
+	return p.block
+}
+
+// CallContract implements bind.ContractCaller.
+func (p *pinnedBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	out, err := p.ContractBackend.CallContract(ctx, msg, p.at(blockNumber))
+	return out, archiveErr(err)
+}
+
+// CodeAt implements bind.ContractCaller.
+func (p *pinnedBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	code, err := p.ContractBackend.CodeAt(ctx, contract, p.at(blockNumber))
+	return code, archiveErr(err)
+}
+
+// PendingCallContract reads at the pinned block too: a historical view has
+// no pending state.
+func (p *pinnedBackend) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	return p.CallContract(ctx, msg, nil)
+}
+
+// PendingCodeAt implements bind.ContractTransactor.
+func (p *pinnedBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
+	return p.CodeAt(ctx, contract, nil)
+}
+
+// SendTransaction implements bind.ContractTransactor.
+func (p *pinnedBackend) SendTransaction(context.Context, *types.Transaction) error {
+	return ErrReadOnly
+}
+
+// archiveErr marks errors about pruned state with ErrNotArchive, keeping
+// the original error, and any revert data it carries, in the chain.
+func archiveErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range archiveHints {
+		if strings.Contains(msg, hint) {
+			return fmt.Errorf("%w: %w", ErrNotArchive, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadproxy "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadProxy"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	lilypadvalidation "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadValidation"
+)
+
+// abigen emits its own copy of the SharedStructs types into every binding
+// package. The lilypadstorage copies are used as the canonical types in this
+// module; the helpers below convert them to the copies the other bindings
+// expect.
+
+// ProxyDeal converts a deal to the LilypadProxy binding type.
+func ProxyDeal(d lilypadstorage.SharedStructsDeal) lilypadproxy.SharedStructsDeal {
+	return lilypadproxy.SharedStructsDeal{
+		DealId:           d.DealId,
+		JobCreator:       d.JobCreator,
+		ResourceProvider: d.ResourceProvider,
+		ModuleCreator:    d.ModuleCreator,
+		Solver:           d.Solver,
+		JobOfferCID:      d.JobOfferCID,
+		ResourceOfferCID: d.ResourceOfferCID,
+		Status:           d.Status,
+		Timestamp:        d.Timestamp,
+		PaymentStructure: lilypadproxy.SharedStructsDealPaymentStructure(d.PaymentStructure),
+	}
+}
+
+// ProxyResult converts a result to the LilypadProxy binding type.
+func ProxyResult(r lilypadstorage.SharedStructsResult) lilypadproxy.SharedStructsResult {
+	return lilypadproxy.SharedStructsResult(r)
+}
+
+// PaymentEngineDeal converts a deal to the LilypadPaymentEngine binding type.
+func PaymentEngineDeal(d lilypadstorage.SharedStructsDeal) lilypadpaymentengine.SharedStructsDeal {
+	return lilypadpaymentengine.SharedStructsDeal{
+		DealId:           d.DealId,
+		JobCreator:       d.JobCreator,
+		ResourceProvider: d.ResourceProvider,
+		ModuleCreator:    d.ModuleCreator,
+		Solver:           d.Solver,
+		JobOfferCID:      d.JobOfferCID,
+		ResourceOfferCID: d.ResourceOfferCID,
+		Status:           d.Status,
+		Timestamp:        d.Timestamp,
+		PaymentStructure: lilypadpaymentengine.SharedStructsDealPaymentStructure(d.PaymentStructure),
+	}
+}
+
+// PaymentEngineResult converts a result to the LilypadPaymentEngine binding type.
+func PaymentEngineResult(r lilypadstorage.SharedStructsResult) lilypadpaymentengine.SharedStructsResult {
+	return lilypadpaymentengine.SharedStructsResult(r)
+}
+
+// PaymentEngineValidationResult converts a validation result to the
+// LilypadPaymentEngine binding type.
+func PaymentEngineValidationResult(v lilypadstorage.SharedStructsValidationResult) lilypadpaymentengine.SharedStructsValidationResult {
+	return lilypadpaymentengine.SharedStructsValidationResult(v)
+}
+
+// ValidationDeal converts a deal to the LilypadValidation binding type.
+func ValidationDeal(d lilypadstorage.SharedStructsDeal) lilypadvalidation.SharedStructsDeal {
+	return lilypadvalidation.SharedStructsDeal{
+		DealId:           d.DealId,
+		JobCreator:       d.JobCreator,
+		ResourceProvider: d.ResourceProvider,
+		ModuleCreator:    d.ModuleCreator,
+		Solver:           d.Solver,
+		JobOfferCID:      d.JobOfferCID,
+		ResourceOfferCID: d.ResourceOfferCID,
+		Status:           d.Status,
+		Timestamp:        d.Timestamp,
+		PaymentStructure: lilypadvalidation.SharedStructsDealPaymentStructure(d.PaymentStructure),
+	}
+}
+
+// ValidationResult converts a result to the LilypadValidation binding type.
+func ValidationResult(r lilypadstorage.SharedStructsResult) lilypadvalidation.SharedStructsResult {
+	return lilypadvalidation.SharedStructsResult(r)
+}
+
+// ValidationValidationResult converts a validation result to the
+// LilypadValidation binding type.
+func ValidationValidationResult(v lilypadstorage.SharedStructsValidationResult) lilypadvalidation.SharedStructsValidationResult {
+	return lilypadvalidation.SharedStructsValidationResult(v)
+}
+
+// StorageDeal converts a deal returned by LilypadProxy.GetDeal to the
+// canonical type.
+func StorageDeal(d lilypadproxy.SharedStructsDeal) lilypadstorage.SharedStructsDeal {
+	return lilypadstorage.SharedStructsDeal{
+		DealId:           d.DealId,
+		JobCreator:       d.JobCreator,
+		ResourceProvider: d.ResourceProvider,
+		ModuleCreator:    d.ModuleCreator,
+		Solver:           d.Solver,
+		JobOfferCID:      d.JobOfferCID,
+		ResourceOfferCID: d.ResourceOfferCID,
+		Status:           d.Status,
+		Timestamp:        d.Timestamp,
+		PaymentStructure: lilypadstorage.SharedStructsDealPaymentStructure(d.PaymentStructure),
+	}
+}
@@ -0,0 +1,192 @@
+// Package controller sends the controller-only protocol actions through
+// LilypadProxy, LilypadValidation and LilypadPaymentEngine, checking each one
+// against the deal lifecycle and its CIDs before it is signed.
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/cid"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/lifecycle"
+)
+
+// ErrNotConfigured is returned when an action needs a contract the client
+// has no address for.
+var ErrNotConfigured = errors.New("controller: contract not configured")
+
+// Controller wraps a client and a lifecycle machine. Every method validates
+// the CIDs it submits, checks the transition, sends the transaction and
+// commits the transition once the transaction has been handed to the backend.
+type Controller struct {
+	client  *client.Client
+	machine *lifecycle.Machine
+	cids    *cid.Validator
+}
+
+// New returns a Controller sending through c and guarded by m. CIDs are
+// checked for syntax only until SetCIDValidator installs a strict validator.
+func New(c *client.Client, m *lifecycle.Machine) *Controller {
+	return &Controller{client: c, machine: m, cids: cid.NewValidator(nil)}
+}
+
+// SetCIDValidator replaces the validator applied to submitted CIDs.
+func (c *Controller) SetCIDValidator(v *cid.Validator) {
+	c.cids = v
+}
+
+// Machine returns the lifecycle machine guarding the controller, e.g. to feed
+// it events.
+func (c *Controller) Machine() *lifecycle.Machine {
+	return c.machine
+}
+
+// SetDeal saves a deal and locks up escrow via LilypadProxy.setDeal.
+func (c *Controller) SetDeal(opts *bind.TransactOpts, deal lilypadstorage.SharedStructsDeal) (*types.Transaction, error) {
+	if c.client.Proxy == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{Action: lifecycle.ActionSetDeal, DealID: deal.DealId}
+	cids := []fieldCID{{"jobOfferCID", deal.JobOfferCID}, {"resourceOfferCID", deal.ResourceOfferCID}}
+	return c.send(opts, c.client.Addresses.Proxy, "setDeal", t, cids, func() (*types.Transaction, error) {
+		return c.client.Proxy.SetDeal(opts, client.ProxyDeal(deal))
+	})
+}
+
+// SetResult saves a result and settles its deal via LilypadProxy.setResult.
+func (c *Controller) SetResult(opts *bind.TransactOpts, result lilypadstorage.SharedStructsResult) (*types.Transaction, error) {
+	if c.client.Proxy == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{
+		Action:   lifecycle.ActionSetResult,
+		ResultID: result.ResultId,
+		DealID:   result.DealId,
+		Status:   result.Status,
+	}
+	return c.send(opts, c.client.Addresses.Proxy, "setResult", t, []fieldCID{{"resultCID", result.ResultCID}}, func() (*types.Transaction, error) {
+		return c.client.Proxy.SetResult(opts, client.ProxyResult(result))
+	})
+}
+
+// RequestValidation asks LilypadValidation to assign a validator to an
+// accepted result.
+func (c *Controller) RequestValidation(opts *bind.TransactOpts, deal lilypadstorage.SharedStructsDeal, result lilypadstorage.SharedStructsResult, validation lilypadstorage.SharedStructsValidationResult) (*types.Transaction, error) {
+	if c.client.Validation == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{
+		Action:       lifecycle.ActionRequestValidation,
+		ValidationID: validation.ValidationResultId,
+		ResultID:     result.ResultId,
+		DealID:       deal.DealId,
+	}
+	return c.send(opts, c.client.Addresses.Validation, "requestValidation", t, []fieldCID{{"validationCID", validation.ValidationCID}}, func() (*types.Transaction, error) {
+		return c.client.Validation.RequestValidation(opts,
+			client.ValidationDeal(deal), client.ValidationResult(result), client.ValidationValidationResult(validation))
+	})
+}
+
+// ProcessValidation records the validator's verdict via
+// LilypadValidation.processValidation.
+func (c *Controller) ProcessValidation(opts *bind.TransactOpts, validation lilypadstorage.SharedStructsValidationResult) (*types.Transaction, error) {
+	if c.client.Validation == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{
+		Action:       lifecycle.ActionProcessValidation,
+		ValidationID: validation.ValidationResultId,
+		ResultID:     validation.ResultId,
+		Status:       validation.Status,
+	}
+	return c.send(opts, c.client.Addresses.Validation, "processValidation", t, []fieldCID{{"validationCID", validation.ValidationCID}}, func() (*types.Transaction, error) {
+		return c.client.Validation.ProcessValidation(opts, client.ValidationValidationResult(validation))
+	})
+}
+
+// HandleJobCompletion pays out an accepted result directly through
+// LilypadPaymentEngine. Results saved through SetResult are already settled.
+func (c *Controller) HandleJobCompletion(opts *bind.TransactOpts, result lilypadstorage.SharedStructsResult) (*types.Transaction, error) {
+	if c.client.PaymentEngine == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{Action: lifecycle.ActionHandleJobCompletion, ResultID: result.ResultId}
+	return c.send(opts, c.client.Addresses.PaymentEngine, "handleJobCompletion", t, nil, func() (*types.Transaction, error) {
+		return c.client.PaymentEngine.HandleJobCompletion(opts, client.PaymentEngineResult(result))
+	})
+}
+
+// HandleJobFailure refunds the job creator and slashes the resource provider
+// for a rejected result directly through LilypadPaymentEngine.
+func (c *Controller) HandleJobFailure(opts *bind.TransactOpts, result lilypadstorage.SharedStructsResult) (*types.Transaction, error) {
+	if c.client.PaymentEngine == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{Action: lifecycle.ActionHandleJobFailure, ResultID: result.ResultId}
+	return c.send(opts, c.client.Addresses.PaymentEngine, "handleJobFailure", t, nil, func() (*types.Transaction, error) {
+		return c.client.PaymentEngine.HandleJobFailure(opts, client.PaymentEngineResult(result))
+	})
+}
+
+// HandleValidationPassed pays the validator of an accepted validation.
+func (c *Controller) HandleValidationPassed(opts *bind.TransactOpts, validation lilypadstorage.SharedStructsValidationResult) (*types.Transaction, error) {
+	if c.client.PaymentEngine == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{Action: lifecycle.ActionHandleValidationPassed, ValidationID: validation.ValidationResultId}
+	return c.send(opts, c.client.Addresses.PaymentEngine, "handleValidationPassed", t, nil, func() (*types.Transaction, error) {
+		return c.client.PaymentEngine.HandleValidationPassed(opts, client.PaymentEngineValidationResult(validation))
+	})
+}
+
+// HandleValidationFailed pays the validator of a rejected validation and
+// penalises the resource provider of originalJobDeal.
+func (c *Controller) HandleValidationFailed(opts *bind.TransactOpts, validation lilypadstorage.SharedStructsValidationResult, originalJobDeal lilypadstorage.SharedStructsDeal) (*types.Transaction, error) {
+	if c.client.PaymentEngine == nil {
+		return nil, ErrNotConfigured
+	}
+	t := lifecycle.Transition{Action: lifecycle.ActionHandleValidationFailed, ValidationID: validation.ValidationResultId}
+	return c.send(opts, c.client.Addresses.PaymentEngine, "handleValidationFailed", t, nil, func() (*types.Transaction, error) {
+		return c.client.PaymentEngine.HandleValidationFailed(opts,
+			client.PaymentEngineValidationResult(validation), client.PaymentEngineDeal(originalJobDeal))
+	})
+}
+
+// fieldCID names a CID carried by a submission.
+type fieldCID struct {
+	field, value string
+}
+
+// send validates cids, checks t, runs transact and commits t if the
+// transaction was sent. A transact error denying method on the contract at
+// to is explained as a *client.Denial.
+func (c *Controller) send(opts *bind.TransactOpts, to common.Address, method string, t lifecycle.Transition, cids []fieldCID, transact func() (*types.Transaction, error)) (*types.Transaction, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, f := range cids {
+		if err := c.cids.Validate(ctx, f.field, f.value); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.machine.Check(ctx, t); err != nil {
+		return nil, err
+	}
+	tx, err := transact()
+	if err != nil {
+		return nil, c.client.Explain(ctx, err, to, method, opts.From)
+	}
+	// Transactions built with NoSend were never broadcast and must not move
+	// the lifecycle forward.
+	if !opts.NoSend {
+		c.machine.Commit(t)
+	}
+	return tx, nil
+}
@@ -0,0 +1,295 @@
+// Package escrow keeps a job creator's free escrow in LilypadPaymentEngine
+// topped up so that LilypadProxy.setDeal does not fail for lack of funds.
+package escrow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/backfill"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// Policy decides when and by how much the manager tops up.
+//
+// Only free escrow counts towards the threshold. Active escrow is already
+// locked in saved deals and is paid out or forfeited when they end, never
+// returned to the free balance, so it cannot fund new deals.
+type Policy struct {
+	// Threshold is the free escrow, after subtracting upcoming demand, below
+	// which a top-up is made.
+	Threshold *big.Int
+	// Target is the free escrow, after subtracting upcoming demand, a top-up
+	// brings the account back to. It must not be below Threshold.
+	Target *big.Int
+	// DailyCap limits how much is deposited per UTC day, counting every
+	// acceptJobPayment deposit of the account that day, including those
+	// made before a restart or by another process. Nil means no cap.
+	DailyCap *big.Int
+	// Interval is how often balances are checked.
+	Interval time.Duration
+}
+
+// DemandSource estimates how much escrow the account's upcoming deals will
+// lock, e.g. from the solver's queue of matched but unsaved deals.
+type DemandSource interface {
+	UpcomingDemand(ctx context.Context, account common.Address) (*big.Int, error)
+}
+
+// Report describes the outcome of a single check.
+type Report struct {
+	Time   time.Time
+	Free   *big.Int // EscrowBalances(account)
+	Active *big.Int // ActiveEscrow(account), reported but not topped up
+	Demand *big.Int
+	// TopUp is the amount deposited, zero if no deposit was made.
+	TopUp *big.Int
+	// Skipped explains why no deposit was made even though one was needed.
+	Skipped string
+}
+
+// Manager tops up the escrow of a single job creator account.
+type Manager struct {
+	client  *client.Client
+	opts    *bind.TransactOpts
+	policy  Policy
+	demand  DemandSource
+	reports func(Report)
+	now     func() time.Time
+
+	mu sync.Mutex
+	// spentDay is what the account deposited on day, read from the chain up
+	// to the block before next.
+	day      time.Time
+	next     uint64
+	spentDay *big.Int
+}
+
+// NewManager returns a Manager depositing from the account of opts. demand
+// may be nil if no demand forecast is available; reports, if not nil, is
+// called after every check.
+func NewManager(c *client.Client, opts *bind.TransactOpts, policy Policy, demand DemandSource, reports func(Report)) (*Manager, error) {
+	if c.Proxy == nil || c.PaymentEngine == nil || c.Token == nil {
+		return nil, errors.New("escrow: client needs the proxy, payment engine and token contracts")
+	}
+	if policy.Threshold == nil || policy.Target == nil || policy.Target.Cmp(policy.Threshold) < 0 {
+		return nil, errors.New("escrow: policy target must be set and not below the threshold")
+	}
+	if policy.Interval <= 0 {
+		return nil, errors.New("escrow: policy interval must be positive")
+	}
+	return &Manager{
+		client:   c,
+		opts:     opts,
+		policy:   policy,
+		demand:   demand,
+		reports:  reports,
+		now:      time.Now,
+		spentDay: new(big.Int),
+	}, nil
+}
+
+// Run checks balances every policy interval until ctx is cancelled. Errors
+// from individual checks are reported and do not stop the manager.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.policy.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Check(ctx); err != nil && m.reports != nil {
+			m.reports(Report{Time: m.now(), Skipped: err.Error()})
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Check reads the account's balances and deposits if free escrow has dropped
+// below the policy threshold.
+func (m *Manager) Check(ctx context.Context) (Report, error) {
+	account := m.opts.From
+	call := &bind.CallOpts{Context: ctx}
+	report := Report{Time: m.now(), TopUp: new(big.Int), Demand: new(big.Int)}
+
+	var err error
+	if report.Free, err = m.client.PaymentEngine.EscrowBalances(call, account); err != nil {
+		return report, fmt.Errorf("escrow: reading escrow balance: %w", err)
+	}
+	if report.Active, err = m.client.PaymentEngine.ActiveEscrow(call, account); err != nil {
+		return report, fmt.Errorf("escrow: reading active escrow: %w", err)
+	}
+	if m.demand != nil {
+		if report.Demand, err = m.demand.UpcomingDemand(ctx, account); err != nil {
+			return report, fmt.Errorf("escrow: estimating demand: %w", err)
+		}
+	}
+
+	available := new(big.Int).Sub(report.Free, report.Demand)
+	if available.Cmp(m.policy.Threshold) >= 0 {
+		m.emit(report)
+		return report, nil
+	}
+	amount := new(big.Int).Sub(m.policy.Target, available)
+
+	paused, err := m.client.Token.Paused(call)
+	if err != nil {
+		return report, fmt.Errorf("escrow: reading token pause state: %w", err)
+	}
+	if paused {
+		report.Skipped = "token is paused"
+		m.emit(report)
+		return report, nil
+	}
+
+	remaining, err := m.remainingToday(ctx, report.Time)
+	if err != nil {
+		return report, err
+	}
+	if remaining != nil {
+		if remaining.Sign() == 0 {
+			report.Skipped = "daily cap reached"
+			m.emit(report)
+			return report, nil
+		}
+		if amount.Cmp(remaining) > 0 {
+			amount = remaining
+		}
+	}
+
+	balance, err := m.client.Token.BalanceOf(call, account)
+	if err != nil {
+		return report, fmt.Errorf("escrow: reading token balance: %w", err)
+	}
+	if balance.Cmp(amount) < 0 {
+		report.Skipped = fmt.Sprintf("wallet balance %s is below the top-up of %s", balance, amount)
+		m.emit(report)
+		return report, nil
+	}
+
+	if err := m.deposit(ctx, amount); err != nil {
+		return report, err
+	}
+	report.TopUp = amount
+	m.emit(report)
+	return report, nil
+}
+
+// deposit pays amount into escrow through LilypadProxy.acceptJobPayment.
+func (m *Manager) deposit(ctx context.Context, amount *big.Int) error {
+	if _, err := m.client.Deposit(ctx, m.opts, amount, "acceptJobPayment", m.client.Proxy.AcceptJobPayment); err != nil {
+		return fmt.Errorf("escrow: %w", err)
+	}
+	return nil
+}
+
+// remainingToday returns how much may still be deposited on the UTC day of
+// now, or nil if there is no cap.
+func (m *Manager) remainingToday(ctx context.Context, now time.Time) (*big.Int, error) {
+	if m.policy.DailyCap == nil {
+		return nil, nil
+	}
+	m.mu.Lock()
+	day, next, spent := m.day, m.next, new(big.Int).Set(m.spentDay)
+	m.mu.Unlock()
+
+	if today := now.UTC().Truncate(24 * time.Hour); !today.Equal(day) {
+		first, err := m.firstBlock(ctx, today)
+		if err != nil {
+			return nil, err
+		}
+		day, next, spent = today, first, new(big.Int)
+	}
+	head, err := m.client.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("escrow: reading head: %w", err)
+	}
+	if to := head.Number.Uint64(); next <= to {
+		deposited, err := m.deposits(ctx, next, to)
+		if err != nil {
+			return nil, err
+		}
+		spent.Add(spent, deposited)
+		next = to + 1
+	}
+
+	m.mu.Lock()
+	m.day, m.next, m.spentDay = day, next, spent
+	m.mu.Unlock()
+	remaining := new(big.Int).Sub(m.policy.DailyCap, spent)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	return remaining, nil
+}
+
+// firstBlock returns the first block mined on day, or one past the head if
+// none has been yet.
+func (m *Manager) firstBlock(ctx context.Context, day time.Time) (uint64, error) {
+	genesis, err := m.client.Backend.HeaderByNumber(ctx, new(big.Int))
+	if err != nil {
+		return 0, fmt.Errorf("escrow: reading genesis: %w", err)
+	}
+	if genesis.Time >= uint64(day.Unix()) {
+		return 0, nil
+	}
+	last, err := client.BlockAt(ctx, m.client.Backend, day.Add(-time.Second))
+	if err != nil {
+		return 0, fmt.Errorf("escrow: finding the first block of %s: %w", day.Format(time.DateOnly), err)
+	}
+	return last + 1, nil
+}
+
+// jobPaymentReason is the SharedStructs.PaymentReason LilypadProxy records
+// acceptJobPayment deposits under.
+const jobPaymentReason = 1
+
+// deposits sums the account's acceptJobPayment deposits from the
+// LilypadPayment__escrowPaid events mined in blocks from to to.
+func (m *Manager) deposits(ctx context.Context, from, to uint64) (*big.Int, error) {
+	parsed, ok := client.ContractABI("LilypadPaymentEngine")
+	if !ok {
+		return nil, errors.New("escrow: no LilypadPaymentEngine ABI")
+	}
+	paid := parsed.Events["LilypadPayment__escrowPaid"].ID
+	payee := common.BytesToHash(m.opts.From.Bytes())
+	reason := common.BigToHash(big.NewInt(jobPaymentReason))
+	cfg := backfill.Config{
+		Query: ethereum.FilterQuery{
+			Addresses: []common.Address{m.client.Addresses.PaymentEngine},
+			Topics:    [][]common.Hash{{paid}, {payee}, {reason}},
+		},
+		From: from,
+		To:   to,
+	}
+	sum := new(big.Int)
+	err := backfill.Run(ctx, m.client.Backend, cfg, func(chunk backfill.Chunk) error {
+		for _, log := range chunk.Logs {
+			ev, err := m.client.PaymentEngine.ParseLilypadPaymentEscrowPaid(log)
+			if err != nil {
+				return err
+			}
+			sum.Add(sum, ev.Amount)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("escrow: reading deposits: %w", err)
+	}
+	return sum, nil
+}
+
+func (m *Manager) emit(r Report) {
+	if m.reports != nil {
+		m.reports(r)
+	}
+}
@@ -0,0 +1,299 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+var (
+	genesis = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	book    = client.AddressBook{
+		Token:         common.HexToAddress("0x02"),
+		PaymentEngine: common.HexToAddress("0x06"),
+		Proxy:         common.HexToAddress("0x07"),
+	}
+)
+
+// fakeChain mines a block every hour from genesis up to now and answers the
+// token and payment engine reads the manager makes. acceptJobPayment moves
+// the amount into free escrow and emits LilypadPayment__escrowPaid in the
+// block after the head. The other backend methods are not used.
+type fakeChain struct {
+	bind.ContractBackend
+	t   *testing.T
+	now time.Time
+
+	free, active, balance, allowance *big.Int
+	paused                           bool
+	payee                            common.Address
+	paid                             []types.Log
+
+	sent     []string
+	receipts map[common.Hash]*types.Receipt
+}
+
+func newFakeChain(t *testing.T, payee common.Address) *fakeChain {
+	return &fakeChain{
+		t:         t,
+		free:      new(big.Int),
+		active:    big.NewInt(7),
+		balance:   big.NewInt(1000),
+		allowance: new(big.Int),
+		payee:     payee,
+		receipts:  make(map[common.Hash]*types.Receipt),
+	}
+}
+
+func (f *fakeChain) abi(to common.Address) *abi.ABI {
+	name := map[common.Address]string{
+		book.Token:         "LilypadToken",
+		book.PaymentEngine: "LilypadPaymentEngine",
+		book.Proxy:         "LilypadProxy",
+	}[to]
+	parsed, ok := client.ContractABI(name)
+	if !ok {
+		f.t.Fatalf("call to unknown contract %s", to.Hex())
+	}
+	return parsed
+}
+
+func (f *fakeChain) head() uint64 {
+	return uint64(f.now.Sub(genesis) / time.Hour)
+}
+
+// pay records a deposit of amount at block.
+func (f *fakeChain) pay(block uint64, amount int64) {
+	ev := f.abi(book.PaymentEngine).Events["LilypadPayment__escrowPaid"]
+	data, err := ev.Inputs.NonIndexed().Pack(big.NewInt(amount))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.paid = append(f.paid, types.Log{
+		Address:     book.PaymentEngine,
+		Topics:      []common.Hash{ev.ID, common.BytesToHash(f.payee.Bytes()), common.BigToHash(big.NewInt(jobPaymentReason))},
+		Data:        data,
+		BlockNumber: block,
+	})
+}
+
+func (f *fakeChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	n := f.head()
+	if number != nil {
+		n = number.Uint64()
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(n), Time: uint64(genesis.Add(time.Duration(n) * time.Hour).Unix())}, nil
+}
+
+func (f *fakeChain) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (f *fakeChain) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	method, err := f.abi(*msg.To).MethodById(msg.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	switch method.Name {
+	case "escrowBalances":
+		out = f.free
+	case "activeEscrow":
+		out = f.active
+	case "paused":
+		out = f.paused
+	case "balanceOf":
+		out = f.balance
+	case "allowance":
+		out = f.allowance
+	default:
+		return nil, errors.New("unexpected call to " + method.Name)
+	}
+	return method.Outputs.Pack(out)
+}
+
+func (f *fakeChain) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var out []types.Log
+	for _, log := range f.paid {
+		if log.BlockNumber >= q.FromBlock.Uint64() && log.BlockNumber <= q.ToBlock.Uint64() {
+			out = append(out, log)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeChain) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	method, err := f.abi(*tx.To()).MethodById(tx.Data()[:4])
+	if err != nil {
+		return err
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return err
+	}
+	f.sent = append(f.sent, method.Name)
+	switch method.Name {
+	case "approve":
+		f.allowance = args[1].(*big.Int)
+	case "acceptJobPayment":
+		amount := args[0].(*big.Int)
+		f.free = new(big.Int).Add(f.free, amount)
+		f.balance = new(big.Int).Sub(f.balance, amount)
+		f.pay(f.head()+1, amount.Int64())
+	}
+	f.receipts[tx.Hash()] = &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: new(big.Int).SetUint64(f.head() + 1)}
+	return nil
+}
+
+func (f *fakeChain) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := f.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (f *fakeChain) PendingCodeAt(ctx context.Context, addr common.Address) ([]byte, error) {
+	return f.CodeAt(ctx, addr, nil)
+}
+
+func (f *fakeChain) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return uint64(len(f.sent)), nil
+}
+
+func (f *fakeChain) SuggestGasPrice(context.Context) (*big.Int, error) { return big.NewInt(1), nil }
+func (f *fakeChain) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 100000, nil
+}
+
+type fixedDemand int64
+
+func (d fixedDemand) UpcomingDemand(context.Context, common.Address) (*big.Int, error) {
+	return big.NewInt(int64(d)), nil
+}
+
+func newManager(t *testing.T, policy Policy, demand DemandSource) (*Manager, *fakeChain) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newFakeChain(t, opts.From)
+	c, err := client.New(chain, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Interval == 0 {
+		policy.Interval = time.Minute
+	}
+	m, err := NewManager(c, opts, policy, demand, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return chain.now }
+	return m, chain
+}
+
+func TestCheckTopsUpToTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		free   int64
+		demand int64
+		topUp  int64
+	}{
+		{"above threshold", 150, 30, 0},
+		{"at threshold", 130, 30, 0},
+		// 90 available after demand, topped up to the target of 200.
+		{"below threshold", 120, 30, 110},
+		{"demand beyond free escrow", 10, 50, 240},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, chain := newManager(t, Policy{Threshold: big.NewInt(100), Target: big.NewInt(200)}, fixedDemand(tt.demand))
+			chain.now = genesis.Add(10 * time.Hour)
+			chain.free = big.NewInt(tt.free)
+			report, err := m.Check(context.Background())
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if report.TopUp.Int64() != tt.topUp {
+				t.Errorf("TopUp = %s, want %d", report.TopUp, tt.topUp)
+			}
+			if report.Active.Int64() != 7 || report.Free.Int64() != tt.free || report.Demand.Int64() != tt.demand {
+				t.Errorf("report free %s active %s demand %s", report.Free, report.Active, report.Demand)
+			}
+			if tt.topUp == 0 && len(chain.sent) != 0 {
+				t.Errorf("sent %v without a top-up", chain.sent)
+			}
+			if tt.topUp != 0 && chain.free.Int64() != tt.free+tt.topUp {
+				t.Errorf("free escrow %s after the top-up", chain.free)
+			}
+		})
+	}
+}
+
+func TestCheckClampsToDailyCap(t *testing.T) {
+	m, chain := newManager(t, Policy{Threshold: big.NewInt(100), Target: big.NewInt(200), DailyCap: big.NewInt(150)}, nil)
+	ctx := context.Background()
+	// 500 deposited the day before and 100 earlier today, at 02:00.
+	chain.pay(5, 500)
+	chain.pay(26, 100)
+	chain.now = genesis.Add(42 * time.Hour)
+
+	report, err := m.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TopUp.Int64() != 50 {
+		t.Fatalf("TopUp = %s, want the 50 left of today's cap", report.TopUp)
+	}
+	if got := chain.sent; len(got) != 2 || got[0] != "approve" || got[1] != "acceptJobPayment" {
+		t.Errorf("sent %v, want approve then acceptJobPayment", got)
+	}
+
+	chain.now = chain.now.Add(time.Hour)
+	report, err = m.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TopUp.Sign() != 0 || report.Skipped != "daily cap reached" {
+		t.Fatalf("TopUp = %s, skipped %q; want the cap reached", report.TopUp, report.Skipped)
+	}
+
+	// The next UTC day starts with the whole cap.
+	chain.now = genesis.Add(49 * time.Hour)
+	report, err = m.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TopUp.Int64() != 150 {
+		t.Fatalf("TopUp = %s on the next day, want 150", report.TopUp)
+	}
+}
+
+func TestCheckSkipsWhilePaused(t *testing.T) {
+	m, chain := newManager(t, Policy{Threshold: big.NewInt(100), Target: big.NewInt(200)}, nil)
+	chain.now = genesis.Add(time.Hour)
+	chain.paused = true
+	report, err := m.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != "token is paused" || report.TopUp.Sign() != 0 || len(chain.sent) != 0 {
+		t.Errorf("skipped %q, top-up %s, sent %v; want a paused skip", report.Skipped, report.TopUp, chain.sent)
+	}
+}
@@ -0,0 +1,370 @@
+// Package export writes the protocol history held in a sqlite.Store to CSV
+// and Parquet files for data warehouses: deals, results, escrow payouts,
+// slashes, deposits and withdrawals, and token burns.
+//
+// Every dataset is partitioned by UTC day and versioned:
+//
+//	<dir>/<dataset>/v<SchemaVersion>/schema.json
+//	<dir>/<dataset>/v<SchemaVersion>/day=2024-05-01/<dataset>.csv
+//	<dir>/<dataset>/v<SchemaVersion>/day=2024-05-01/<dataset>.parquet
+//
+// Token amounts appear twice: as an exact base unit string in the _base
+// column and in tokens, as DECIMAL(38, 18) in Parquet and a decimal string
+// in CSV. Re-running an export rewrites the partitions it produces.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+// SchemaVersion is the version of the dataset layouts. It changes whenever
+// a column is added, removed or retyped, so consumers can tell partitions
+// of different layouts apart.
+const SchemaVersion = 1
+
+// Dataset names an exported dataset.
+type Dataset string
+
+// The exported datasets.
+const (
+	Deals       Dataset = "deals"
+	Results     Dataset = "results"
+	Payouts     Dataset = "payouts"
+	Slashes     Dataset = "slashes"
+	Deposits    Dataset = "deposits"
+	Withdrawals Dataset = "withdrawals"
+	Burns       Dataset = "burns"
+)
+
+// Datasets lists every dataset in export order.
+var Datasets = []Dataset{Deals, Results, Payouts, Slashes, Deposits, Withdrawals, Burns}
+
+// movementKinds maps the escrow datasets to their movement kind.
+var movementKinds = map[Dataset]sqlite.MovementKind{
+	Payouts:     sqlite.MovementPayout,
+	Slashes:     sqlite.MovementSlash,
+	Deposits:    sqlite.MovementDeposit,
+	Withdrawals: sqlite.MovementWithdrawal,
+}
+
+// Format is an output file format, named by its file extension.
+type Format string
+
+// The supported formats.
+const (
+	CSV     Format = "csv"
+	Parquet Format = "parquet"
+)
+
+// pageSize is how many records are read from the store at a time.
+const pageSize = 1000
+
+// HeaderSource provides block headers. The escrow datasets need it to
+// timestamp their rows; ethclient.Client implements it.
+type HeaderSource interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Config configures an export.
+type Config struct {
+	// Dir is the root of the output tree.
+	Dir string
+	// Formats defaults to CSV and Parquet.
+	Formats []Format
+	// Datasets defaults to all datasets, less the escrow datasets when
+	// Headers is nil.
+	Datasets []Dataset
+	// Since and Until restrict the export to rows timestamped in
+	// [Since, Until). Zero values leave the range open.
+	Since, Until time.Time
+	// Headers is required for the escrow datasets. When set, Since and
+	// Until are also resolved to block bounds so the escrow and burn
+	// datasets only read the blocks of the range.
+	Headers HeaderSource
+}
+
+// Partition is a file written by Run.
+type Partition struct {
+	Dataset Dataset
+	Day     string
+	Format  Format
+	Path    string
+	Rows    int
+}
+
+// Run exports cfg.Datasets from store and returns the files it wrote.
+func Run(ctx context.Context, store *sqlite.Store, cfg Config) ([]Partition, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("export: no output directory")
+	}
+	if len(cfg.Formats) == 0 {
+		cfg.Formats = []Format{CSV, Parquet}
+	}
+	if len(cfg.Datasets) == 0 {
+		for _, dataset := range Datasets {
+			if _, escrow := movementKinds[dataset]; !escrow || cfg.Headers != nil {
+				cfg.Datasets = append(cfg.Datasets, dataset)
+			}
+		}
+	}
+	for _, format := range cfg.Formats {
+		if format != CSV && format != Parquet {
+			return nil, fmt.Errorf("export: unknown format %q", format)
+		}
+	}
+	for _, dataset := range cfg.Datasets {
+		if _, escrow := movementKinds[dataset]; escrow && cfg.Headers == nil {
+			return nil, fmt.Errorf("export: %s need block headers for their timestamps", dataset)
+		}
+	}
+	e := &exporter{store: store, cfg: cfg, times: make(map[uint64]time.Time)}
+	for _, dataset := range cfg.Datasets {
+		var err error
+		switch dataset {
+		case Deals:
+			err = e.deals(ctx)
+		case Results:
+			err = e.results(ctx)
+		case Payouts, Slashes, Deposits, Withdrawals:
+			err = e.movements(ctx, dataset)
+		case Burns:
+			err = e.burns(ctx)
+		default:
+			err = fmt.Errorf("export: unknown dataset %q", dataset)
+		}
+		if err != nil {
+			return e.written, err
+		}
+	}
+	return e.written, nil
+}
+
+type exporter struct {
+	store   *sqlite.Store
+	cfg     Config
+	written []Partition
+	// times caches block timestamps.
+	times map[uint64]time.Time
+
+	// bounds holds the block range of [Since, Until) once resolved.
+	bounds *blockRange
+}
+
+// blockRange is an inclusive block range; a zero to leaves it open.
+type blockRange struct {
+	from, to uint64
+	// empty marks a time range in which no block was mined.
+	empty bool
+}
+
+func (e *exporter) record(p Partition) {
+	e.written = append(e.written, p)
+}
+
+// inRange reports whether ts falls into [Since, Until).
+func (e *exporter) inRange(ts time.Time) bool {
+	return (e.cfg.Since.IsZero() || !ts.Before(e.cfg.Since)) && (e.cfg.Until.IsZero() || ts.Before(e.cfg.Until))
+}
+
+func (e *exporter) deals(ctx context.Context) error {
+	out, err := newPartitions[DealRow](e.cfg.Dir, Deals, e.cfg.Formats, e.record)
+	if err != nil {
+		return err
+	}
+	defer out.abort()
+	q := indexer.Query{Since: e.cfg.Since, Until: e.cfg.Until, Limit: pageSize}
+	for ; ; q.Offset += pageSize {
+		deals, err := e.store.Deals(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, d := range deals {
+			row, err := dealRow(d)
+			if err != nil {
+				return err
+			}
+			if err := out.write(row.Timestamp, row); err != nil {
+				return err
+			}
+		}
+		if len(deals) < pageSize {
+			return out.close()
+		}
+	}
+}
+
+func (e *exporter) results(ctx context.Context) error {
+	out, err := newPartitions[ResultRow](e.cfg.Dir, Results, e.cfg.Formats, e.record)
+	if err != nil {
+		return err
+	}
+	defer out.abort()
+	q := indexer.Query{Since: e.cfg.Since, Until: e.cfg.Until, Limit: pageSize}
+	for ; ; q.Offset += pageSize {
+		results, err := e.store.Results(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			row := resultRow(r)
+			if err := out.write(row.Timestamp, row); err != nil {
+				return err
+			}
+		}
+		if len(results) < pageSize {
+			return out.close()
+		}
+	}
+}
+
+func (e *exporter) movements(ctx context.Context, dataset Dataset) error {
+	bounds, err := e.blocks(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := newPartitions[MovementRow](e.cfg.Dir, dataset, e.cfg.Formats, e.record)
+	if err != nil {
+		return err
+	}
+	defer out.abort()
+	if bounds.empty {
+		return out.close()
+	}
+	q := sqlite.MovementQuery{
+		Kinds:     []sqlite.MovementKind{movementKinds[dataset]},
+		FromBlock: bounds.from,
+		ToBlock:   bounds.to,
+		Limit:     pageSize,
+	}
+	for ; ; q.Offset += pageSize {
+		movements, err := e.store.EscrowMovements(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, m := range movements {
+			ts, err := e.blockTime(ctx, m.Block)
+			if err != nil {
+				return err
+			}
+			if !e.inRange(ts) {
+				continue
+			}
+			row, err := movementRow(m, ts)
+			if err != nil {
+				return err
+			}
+			if err := out.write(ts, row); err != nil {
+				return err
+			}
+		}
+		if len(movements) < pageSize {
+			return out.close()
+		}
+	}
+}
+
+func (e *exporter) burns(ctx context.Context) error {
+	var bounds blockRange
+	if e.cfg.Headers != nil {
+		b, err := e.blocks(ctx)
+		if err != nil {
+			return err
+		}
+		bounds = *b
+	}
+	out, err := newPartitions[BurnRow](e.cfg.Dir, Burns, e.cfg.Formats, e.record)
+	if err != nil {
+		return err
+	}
+	defer out.abort()
+	if bounds.empty {
+		return out.close()
+	}
+	q := sqlite.BurnQuery{FromBlock: bounds.from, ToBlock: bounds.to, Limit: pageSize}
+	for ; ; q.Offset += pageSize {
+		burns, err := e.store.TokenBurns(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, b := range burns {
+			row, err := burnRow(b)
+			if err != nil {
+				return err
+			}
+			if !e.inRange(row.Timestamp) {
+				continue
+			}
+			if err := out.write(row.Timestamp, row); err != nil {
+				return err
+			}
+		}
+		if len(burns) < pageSize {
+			return out.close()
+		}
+	}
+}
+
+// blockTime returns the timestamp of block. Only the latest blocks are
+// cached since movements arrive in block order.
+func (e *exporter) blockTime(ctx context.Context, block uint64) (time.Time, error) {
+	if ts, ok := e.times[block]; ok {
+		return ts, nil
+	}
+	header, err := e.cfg.Headers.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("export: reading header %d: %w", block, err)
+	}
+	if len(e.times) >= 1024 {
+		e.times = make(map[uint64]time.Time)
+	}
+	ts := time.Unix(int64(header.Time), 0).UTC()
+	e.times[block] = ts
+	return ts, nil
+}
+
+// blocks resolves [Since, Until) to the blocks mined in it.
+func (e *exporter) blocks(ctx context.Context) (*blockRange, error) {
+	if e.bounds != nil {
+		return e.bounds, nil
+	}
+	var r blockRange
+	if !e.cfg.Since.IsZero() || !e.cfg.Until.IsZero() {
+		genesis, err := e.cfg.Headers.HeaderByNumber(ctx, new(big.Int))
+		if err != nil {
+			return nil, fmt.Errorf("export: reading genesis: %w", err)
+		}
+		if !e.cfg.Since.IsZero() && genesis.Time < uint64(e.cfg.Since.Unix()) {
+			last, err := client.BlockAt(ctx, e.cfg.Headers, e.cfg.Since.Add(-time.Second))
+			if err != nil {
+				return nil, fmt.Errorf("export: finding the first block of %s: %w", e.cfg.Since.Format(time.RFC3339), err)
+			}
+			r.from = last + 1
+		}
+		if !e.cfg.Until.IsZero() {
+			if genesis.Time >= uint64(e.cfg.Until.Unix()) {
+				r.empty = true
+			} else {
+				last, err := client.BlockAt(ctx, e.cfg.Headers, e.cfg.Until.Add(-time.Second))
+				if err != nil {
+					return nil, fmt.Errorf("export: finding the last block before %s: %w", e.cfg.Until.Format(time.RFC3339), err)
+				}
+				// A zero ToBlock leaves the range open; genesis holds no
+				// events.
+				r.to = last
+				r.empty = last == 0 || last < r.from
+			}
+		}
+	}
+	e.bounds = &r
+	return e.bounds, nil
+}
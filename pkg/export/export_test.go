@@ -0,0 +1,231 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/parquet-go/parquet-go"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+// fakeHeaders mines a block every ten seconds from genesis up to head.
+type fakeHeaders struct {
+	genesis uint64
+	head    uint64
+}
+
+func (f fakeHeaders) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	n := f.head
+	if number != nil {
+		n = number.Uint64()
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(n), Time: f.genesis + 10*n}, nil
+}
+
+// at returns the timestamp of block n.
+func (f fakeHeaders) at(n uint64) time.Time {
+	return time.Unix(int64(f.genesis+10*n), 0)
+}
+
+var payments = common.HexToAddress("0x06")
+
+func openStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	s, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "lilypad.db"), client.AddressBook{PaymentEngine: payments})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestDealsByDay(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	fee, _ := new(big.Int).SetString("1500000000000000000", 10)
+	for id, ts := range map[string]string{
+		"early": "2024-05-01T00:00:00Z",
+		"late":  "2024-05-01T23:59:59Z",
+		"next":  "2024-05-02T00:00:00Z",
+	} {
+		at, _ := time.Parse(time.RFC3339, ts)
+		err := store.PutDeal(ctx, indexer.Deal{SharedStructsDeal: lilypadstorage.SharedStructsDeal{
+			DealId:    id,
+			Timestamp: big.NewInt(at.Unix()),
+			PaymentStructure: lilypadstorage.SharedStructsDealPaymentStructure{
+				JobCreatorSolverFee:   fee,
+				PriceOfJobWithoutFees: big.NewInt(1),
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	parts, err := Run(ctx, store, Config{Dir: dir, Datasets: []Dataset{Deals}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range parts {
+		got = append(got, fmt.Sprintf("%s/%s/%d", p.Day, p.Format, p.Rows))
+	}
+	want := []string{"2024-05-01/csv/2", "2024-05-01/parquet/2", "2024-05-02/csv/1", "2024-05-02/parquet/1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("partitions %v, want %v", got, want)
+	}
+	day := filepath.Join(dir, "deals", "v1", "day=2024-05-01")
+	if parts[0].Path != filepath.Join(day, "deals.csv") {
+		t.Errorf("path %s", parts[0].Path)
+	}
+
+	records := readCSV(t, parts[0].Path)
+	col := make(map[string]int)
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	row := records[1]
+	for name, want := range map[string]string{
+		"deal_id":                        "early",
+		"timestamp":                      "2024-05-01T00:00:00Z",
+		"job_creator_solver_fee_base":    "1500000000000000000",
+		"job_creator_solver_fee":         "1.500000000000000000",
+		"price_of_job_without_fees_base": "1",
+		"price_of_job_without_fees":      "0.000000000000000001",
+		"module_creator_fee_base":        "0",
+		"module_creator_fee":             "0.000000000000000000",
+	} {
+		if got := row[col[name]]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	rows, err := parquet.ReadFile[DealRow](parts[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1].DealID != "late" || rows[0].JobCreatorSolverFee.Base().Cmp(fee) != 0 || rows[0].JobCreatorSolverFeeBase != fee.String() {
+		t.Errorf("parquet rows = %+v", rows)
+	}
+}
+
+func TestNewAmount(t *testing.T) {
+	max := new(big.Int).Sub(maxAmount, big.NewInt(1))
+	a, err := NewAmount(max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.String(); got != "99999999999999999999.999999999999999999" {
+		t.Errorf("String() = %s", got)
+	}
+	for _, v := range []*big.Int{maxAmount, big.NewInt(-1)} {
+		if _, err := NewAmount(v); err == nil {
+			t.Errorf("NewAmount(%s) succeeded", v)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	headers := fakeHeaders{genesis: 1_700_000_000, head: 100}
+	for _, tc := range []struct {
+		name         string
+		since, until time.Time
+		want         blockRange
+	}{
+		{name: "open", want: blockRange{}},
+		{name: "since a block", since: headers.at(5), want: blockRange{from: 5}},
+		{name: "since between blocks", since: headers.at(5).Add(time.Second), want: blockRange{from: 6}},
+		{name: "since before genesis", since: headers.at(0).Add(-time.Hour), want: blockRange{}},
+		{name: "since after head", since: headers.at(200), want: blockRange{from: 101}},
+		{name: "until a block", until: headers.at(5), want: blockRange{to: 4}},
+		{name: "until between blocks", until: headers.at(5).Add(time.Second), want: blockRange{to: 5}},
+		{name: "until genesis", until: headers.at(0), want: blockRange{empty: true}},
+		{name: "until after genesis", until: headers.at(0).Add(time.Second), want: blockRange{empty: true}},
+		{name: "between two blocks", since: headers.at(5).Add(time.Second), until: headers.at(5).Add(9 * time.Second), want: blockRange{from: 6, to: 5, empty: true}},
+		{name: "one block", since: headers.at(5), until: headers.at(6), want: blockRange{from: 5, to: 5}},
+	} {
+		e := &exporter{cfg: Config{Since: tc.since, Until: tc.until, Headers: headers}}
+		got, err := e.blocks(context.Background())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if *got != tc.want {
+			t.Errorf("%s: blocks = %+v, want %+v", tc.name, *got, tc.want)
+		}
+	}
+}
+
+func TestDepositsInRange(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	parsed, _ := client.ContractABI("LilypadPaymentEngine")
+	ev := parsed.Events["LilypadPayment__escrowPaid"]
+	payee := common.HexToAddress("0xa1")
+	for _, block := range []uint64{3, 5, 8} {
+		topics, err := abi.MakeTopics([]interface{}{payee}, []interface{}{uint8(0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ev.Inputs.NonIndexed().Pack(new(big.Int).SetUint64(block))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log := types.Log{Address: payments, Topics: []common.Hash{ev.ID, topics[0][0], topics[1][0]}, Data: data, BlockNumber: block}
+		if err := store.InsertLog(ctx, log); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	headers := fakeHeaders{genesis: 1_700_000_000, head: 10}
+	if _, err := Run(ctx, store, Config{Dir: t.TempDir(), Datasets: []Dataset{Deposits}}); err == nil {
+		t.Error("exported deposits without headers")
+	}
+	parts, err := Run(ctx, store, Config{
+		Dir:      t.TempDir(),
+		Formats:  []Format{CSV},
+		Datasets: []Dataset{Deposits},
+		Since:    headers.at(5),
+		Until:    headers.at(8),
+		Headers:  headers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts[0].Rows != 1 {
+		t.Fatalf("partitions %+v", parts)
+	}
+	records := readCSV(t, parts[0].Path)
+	if records[1][1] != "5" || records[1][2] != "0.000000000000000005" {
+		t.Errorf("deposit row %v", records[1])
+	}
+}
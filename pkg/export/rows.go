@@ -0,0 +1,205 @@
+package export
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+// TokenDecimals is the number of decimals of LilypadToken.
+const TokenDecimals = 18
+
+// maxAmount bounds the base units an Amount can hold: DECIMAL(38, 18).
+var maxAmount = new(big.Int).Exp(big.NewInt(10), big.NewInt(38), nil)
+
+// Amount is a token amount in the Parquet DECIMAL(38, 18) encoding: the base
+// units as a big-endian two's complement integer. In CSV it is written in
+// decimal form, e.g. 1.500000000000000000.
+type Amount [16]byte
+
+// NewAmount converts base units to an Amount. It fails for negative amounts
+// and amounts of 10^20 tokens or more.
+func NewAmount(base *big.Int) (Amount, error) {
+	var a Amount
+	if base == nil {
+		return a, nil
+	}
+	if base.Sign() < 0 || base.Cmp(maxAmount) >= 0 {
+		return a, fmt.Errorf("export: amount %s out of DECIMAL(38, %d) range", base, TokenDecimals)
+	}
+	base.FillBytes(a[:])
+	return a, nil
+}
+
+// Base returns the amount in base units.
+func (a Amount) Base() *big.Int {
+	return new(big.Int).SetBytes(a[:])
+}
+
+// String formats the amount in tokens with all TokenDecimals places.
+func (a Amount) String() string {
+	digits := a.Base().String()
+	if len(digits) <= TokenDecimals {
+		digits = strings.Repeat("0", TokenDecimals-len(digits)+1) + digits
+	}
+	return digits[:len(digits)-TokenDecimals] + "." + digits[len(digits)-TokenDecimals:]
+}
+
+// DealRow is a row of the deals dataset.
+type DealRow struct {
+	DealID                        string    `parquet:"deal_id"`
+	JobCreator                    string    `parquet:"job_creator"`
+	ResourceProvider              string    `parquet:"resource_provider"`
+	ModuleCreator                 string    `parquet:"module_creator"`
+	Solver                        string    `parquet:"solver"`
+	JobOfferCID                   string    `parquet:"job_offer_cid"`
+	ResourceOfferCID              string    `parquet:"resource_offer_cid"`
+	Status                        int32     `parquet:"status"`
+	Timestamp                     time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	JobCreatorSolverFeeBase       string    `parquet:"job_creator_solver_fee_base"`
+	JobCreatorSolverFee           Amount    `parquet:"job_creator_solver_fee,decimal(18:38)"`
+	ResourceProviderSolverFeeBase string    `parquet:"resource_provider_solver_fee_base"`
+	ResourceProviderSolverFee     Amount    `parquet:"resource_provider_solver_fee,decimal(18:38)"`
+	NetworkCongestionFeeBase      string    `parquet:"network_congestion_fee_base"`
+	NetworkCongestionFee          Amount    `parquet:"network_congestion_fee,decimal(18:38)"`
+	ModuleCreatorFeeBase          string    `parquet:"module_creator_fee_base"`
+	ModuleCreatorFee              Amount    `parquet:"module_creator_fee,decimal(18:38)"`
+	PriceOfJobWithoutFeesBase     string    `parquet:"price_of_job_without_fees_base"`
+	PriceOfJobWithoutFees         Amount    `parquet:"price_of_job_without_fees,decimal(18:38)"`
+	Block                         int64     `parquet:"block"`
+	TxHash                        string    `parquet:"tx_hash"`
+}
+
+func dealRow(d indexer.Deal) (DealRow, error) {
+	row := DealRow{
+		DealID:           d.DealId,
+		JobCreator:       d.JobCreator.Hex(),
+		ResourceProvider: d.ResourceProvider.Hex(),
+		ModuleCreator:    d.ModuleCreator.Hex(),
+		Solver:           d.Solver.Hex(),
+		JobOfferCID:      d.JobOfferCID,
+		ResourceOfferCID: d.ResourceOfferCID,
+		Status:           int32(d.Status),
+		Timestamp:        unixTime(d.Timestamp),
+		Block:            int64(d.Block),
+		TxHash:           d.TxHash.Hex(),
+	}
+	p := d.PaymentStructure
+	for _, fee := range []struct {
+		v    *big.Int
+		base *string
+		dst  *Amount
+	}{
+		{p.JobCreatorSolverFee, &row.JobCreatorSolverFeeBase, &row.JobCreatorSolverFee},
+		{p.ResourceProviderSolverFee, &row.ResourceProviderSolverFeeBase, &row.ResourceProviderSolverFee},
+		{p.NetworkCongestionFee, &row.NetworkCongestionFeeBase, &row.NetworkCongestionFee},
+		{p.ModuleCreatorFee, &row.ModuleCreatorFeeBase, &row.ModuleCreatorFee},
+		{p.PriceOfJobWithoutFees, &row.PriceOfJobWithoutFeesBase, &row.PriceOfJobWithoutFees},
+	} {
+		var err error
+		if *fee.base, *fee.dst, err = amount(fee.v); err != nil {
+			return row, fmt.Errorf("deal %s: %w", d.DealId, err)
+		}
+	}
+	return row, nil
+}
+
+// ResultRow is a row of the results dataset.
+type ResultRow struct {
+	ResultID  string    `parquet:"result_id"`
+	DealID    string    `parquet:"deal_id"`
+	ResultCID string    `parquet:"result_cid"`
+	Status    int32     `parquet:"status"`
+	Timestamp time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Block     int64     `parquet:"block"`
+	TxHash    string    `parquet:"tx_hash"`
+}
+
+func resultRow(r indexer.Result) ResultRow {
+	return ResultRow{
+		ResultID:  r.ResultId,
+		DealID:    r.DealId,
+		ResultCID: r.ResultCID,
+		Status:    int32(r.Status),
+		Timestamp: unixTime(r.Timestamp),
+		Block:     int64(r.Block),
+		TxHash:    r.TxHash.Hex(),
+	}
+}
+
+// MovementRow is a row of the payouts, slashes, deposits and withdrawals
+// datasets.
+type MovementRow struct {
+	Account    string `parquet:"account"`
+	AmountBase string `parquet:"amount_base"`
+	Amount     Amount `parquet:"amount,decimal(18:38)"`
+	// Reason is the SharedStructs.PaymentReason of a deposit or the actor
+	// of a slash, null otherwise.
+	Reason    *int32    `parquet:"reason,optional"`
+	Timestamp time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Block     int64     `parquet:"block"`
+	TxHash    string    `parquet:"tx_hash"`
+	LogIndex  int32     `parquet:"log_index"`
+}
+
+func movementRow(m sqlite.Movement, ts time.Time) (MovementRow, error) {
+	row := MovementRow{
+		Account:   m.Account.Hex(),
+		Timestamp: ts,
+		Block:     int64(m.Block),
+		TxHash:    m.TxHash.Hex(),
+		LogIndex:  int32(m.LogIndex),
+	}
+	if m.Reason != nil {
+		reason := int32(*m.Reason)
+		row.Reason = &reason
+	}
+	var err error
+	if row.AmountBase, row.Amount, err = amount(m.Amount); err != nil {
+		return row, fmt.Errorf("%s in tx %s: %w", m.Kind, m.TxHash.Hex(), err)
+	}
+	return row, nil
+}
+
+// BurnRow is a row of the burns dataset.
+type BurnRow struct {
+	AmountBase string    `parquet:"amount_base"`
+	Amount     Amount    `parquet:"amount,decimal(18:38)"`
+	Timestamp  time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Block      int64     `parquet:"block"`
+	TxHash     string    `parquet:"tx_hash"`
+	LogIndex   int32     `parquet:"log_index"`
+}
+
+func burnRow(b sqlite.Burn) (BurnRow, error) {
+	row := BurnRow{
+		Timestamp: time.Unix(int64(b.BlockTimestamp), 0).UTC(),
+		Block:     int64(b.Block),
+		TxHash:    b.TxHash.Hex(),
+		LogIndex:  int32(b.LogIndex),
+	}
+	var err error
+	if row.AmountBase, row.Amount, err = amount(b.Amount); err != nil {
+		return row, fmt.Errorf("burn in tx %s: %w", b.TxHash.Hex(), err)
+	}
+	return row, nil
+}
+
+func amount(v *big.Int) (string, Amount, error) {
+	if v == nil {
+		v = new(big.Int)
+	}
+	a, err := NewAmount(v)
+	return v.String(), a, err
+}
+
+func unixTime(ts *big.Int) time.Time {
+	if ts == nil {
+		return time.Unix(0, 0).UTC()
+	}
+	return time.Unix(ts.Int64(), 0).UTC()
+}
@@ -0,0 +1,251 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Column describes a column in a dataset's schema.json.
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable,omitempty"`
+}
+
+// Schema is written as schema.json next to the partitions of a dataset.
+type Schema struct {
+	Dataset Dataset  `json:"dataset"`
+	Version int      `json:"version"`
+	Columns []Column `json:"columns"`
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	amountType = reflect.TypeOf(Amount{})
+)
+
+// schemaOf describes the columns of the row type T.
+func schemaOf[T any](dataset Dataset) Schema {
+	s := Schema{Dataset: dataset, Version: SchemaVersion}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		col := Column{Name: columnName(f)}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			col.Nullable = true
+			ft = ft.Elem()
+		}
+		switch ft {
+		case timeType:
+			col.Type = "timestamp"
+		case amountType:
+			col.Type = fmt.Sprintf("decimal(38,%d)", TokenDecimals)
+		default:
+			col.Type = ft.Kind().String()
+		}
+		s.Columns = append(s.Columns, col)
+	}
+	return s
+}
+
+func columnName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("parquet"), ",")
+	return name
+}
+
+// csvRecord formats the fields of row for CSV: timestamps in RFC 3339 UTC,
+// amounts in decimal form and null values as empty strings.
+func csvRecord(row interface{}) []string {
+	v := reflect.ValueOf(row)
+	out := make([]string, v.NumField())
+	for i := range out {
+		f := v.Field(i)
+		if f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				continue
+			}
+			f = f.Elem()
+		}
+		switch x := f.Interface().(type) {
+		case time.Time:
+			out[i] = x.UTC().Format(time.RFC3339)
+		case Amount:
+			out[i] = x.String()
+		case string:
+			out[i] = x
+		case int32:
+			out[i] = strconv.FormatInt(int64(x), 10)
+		case int64:
+			out[i] = strconv.FormatInt(x, 10)
+		default:
+			out[i] = fmt.Sprint(x)
+		}
+	}
+	return out
+}
+
+// partitions writes the rows of a dataset, which must arrive ordered by day,
+// into one file per day and format under
+// <dir>/<dataset>/v<SchemaVersion>/day=YYYY-MM-DD/. Files are written under
+// a temporary name and renamed when the day is complete, so an interrupted
+// export never leaves a truncated partition behind.
+type partitions[T any] struct {
+	dir     string
+	dataset Dataset
+	formats []Format
+	written func(Partition)
+
+	day  string
+	rows int
+	seen map[string]bool
+	open []*os.File
+	csv  *csv.Writer
+	pq   *parquet.GenericWriter[T]
+}
+
+func newPartitions[T any](dir string, dataset Dataset, formats []Format, written func(Partition)) (*partitions[T], error) {
+	p := &partitions[T]{
+		dir:     filepath.Join(dir, string(dataset), fmt.Sprintf("v%d", SchemaVersion)),
+		dataset: dataset,
+		formats: formats,
+		written: written,
+		seen:    make(map[string]bool),
+	}
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		return nil, err
+	}
+	schema, err := json.MarshalIndent(schemaOf[T](dataset), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(p.dir, "schema.json"), append(schema, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *partitions[T]) write(ts time.Time, row T) error {
+	day := ts.UTC().Format(time.DateOnly)
+	if day != p.day {
+		if err := p.close(); err != nil {
+			return err
+		}
+		if p.seen[day] {
+			return fmt.Errorf("export: %s rows for %s are not ordered by day", p.dataset, day)
+		}
+		if err := p.start(day); err != nil {
+			return err
+		}
+	}
+	if p.csv != nil {
+		if err := p.csv.Write(csvRecord(row)); err != nil {
+			return err
+		}
+	}
+	if p.pq != nil {
+		if _, err := p.pq.Write([]T{row}); err != nil {
+			return err
+		}
+	}
+	p.rows++
+	return nil
+}
+
+func (p *partitions[T]) start(day string) error {
+	dir := filepath.Join(p.dir, "day="+day)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	p.day, p.rows, p.seen[day] = day, 0, true
+	for _, format := range p.formats {
+		f, err := os.Create(filepath.Join(dir, p.file(format)+".tmp"))
+		if err != nil {
+			p.abort()
+			return err
+		}
+		p.open = append(p.open, f)
+		switch format {
+		case CSV:
+			p.csv = csv.NewWriter(f)
+			var header []string
+			for _, col := range schemaOf[T](p.dataset).Columns {
+				header = append(header, col.Name)
+			}
+			if err := p.csv.Write(header); err != nil {
+				p.abort()
+				return err
+			}
+		case Parquet:
+			p.pq = parquet.NewGenericWriter[T](f, parquet.Compression(&parquet.Zstd),
+				parquet.KeyValueMetadata("lilypad.schema_version", strconv.Itoa(SchemaVersion)))
+		}
+	}
+	return nil
+}
+
+func (p *partitions[T]) file(format Format) string {
+	return string(p.dataset) + "." + string(format)
+}
+
+// close finishes the current day, if any.
+func (p *partitions[T]) close() error {
+	if p.day == "" {
+		return nil
+	}
+	if p.csv != nil {
+		p.csv.Flush()
+		if err := p.csv.Error(); err != nil {
+			p.abort()
+			return err
+		}
+	}
+	if p.pq != nil {
+		if err := p.pq.Close(); err != nil {
+			p.abort()
+			return err
+		}
+	}
+	for _, f := range p.open {
+		if err := f.Sync(); err != nil {
+			p.abort()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			p.abort()
+			return err
+		}
+	}
+	dir := filepath.Join(p.dir, "day="+p.day)
+	for _, format := range p.formats {
+		path := filepath.Join(dir, p.file(format))
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+		p.written(Partition{Dataset: p.dataset, Day: p.day, Format: format, Path: path, Rows: p.rows})
+	}
+	p.reset()
+	return nil
+}
+
+// abort removes the temporary files of the current day.
+func (p *partitions[T]) abort() {
+	for _, f := range p.open {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	p.reset()
+}
+
+func (p *partitions[T]) reset() {
+	p.day, p.open, p.csv, p.pq = "", nil, nil, nil
+}
@@ -0,0 +1,205 @@
+// Package follower streams the logs of the Lilypad contracts block by block,
+// only once blocks have enough confirmations, and reports blocks it already
+// delivered that a reorg later removed.
+package follower
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// ErrReorgTooDeep is returned when a reorg removes more blocks than the
+// follower remembers.
+var ErrReorgTooDeep = errors.New("follower: reorg deeper than history")
+
+// Backend is the subset of an Ethereum client the follower needs.
+type Backend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// BlockRef identifies a block.
+type BlockRef struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// Update is a single entry of the follower's stream: either a newly
+// confirmed block with the logs it contains, or a rollback of a block that
+// was delivered earlier and is no longer canonical.
+type Update struct {
+	BlockRef
+	// Rollback marks a removed block. Consumers must undo whatever they
+	// applied for it. Rollbacks are delivered newest block first, before the
+	// blocks of the new chain.
+	Rollback bool
+	// Logs holds the block's logs in index order. For a rollback these are
+	// the logs delivered earlier, with Removed set, in reverse order.
+	Logs []types.Log
+}
+
+// Config configures a Follower.
+type Config struct {
+	// Addresses lists the contracts to follow, see Addresses.
+	Addresses []common.Address
+	// Confirmations is how many blocks must be built on top of a block
+	// before it is delivered. Zero delivers the head block.
+	Confirmations uint64
+	// Start is the first block to deliver when After is not set.
+	Start uint64
+	// After resumes after a block delivered by an earlier run. If that block
+	// is no longer canonical it is rolled back, without logs, and Run fails
+	// with ErrReorgTooDeep since nothing older is known; resume from an
+	// earlier block then.
+	After *BlockRef
+	// PollInterval is how often the head is polled. Defaults to 2 seconds.
+	PollInterval time.Duration
+	// History is how many delivered blocks are remembered for reorg
+	// detection. Defaults to 256.
+	History int
+}
+
+// Addresses returns the addresses in book, sorted.
+func Addresses(book client.AddressBook) []common.Address {
+	var addrs []common.Address
+	for _, addr := range book.Named() {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Cmp(addrs[j]) < 0 })
+	return addrs
+}
+
+// Follower delivers confirmed blocks and rollbacks in order.
+type Follower struct {
+	backend Backend
+	cfg     Config
+	// history holds the delivered blocks, oldest first, with their logs.
+	history []Update
+}
+
+// New returns a Follower reading from backend.
+func New(backend Backend, cfg Config) *Follower {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.History <= 0 {
+		cfg.History = 256
+	}
+	f := &Follower{backend: backend, cfg: cfg}
+	if cfg.After != nil {
+		f.history = append(f.history, Update{BlockRef: *cfg.After})
+	}
+	return f
+}
+
+// Run delivers updates to out until ctx is cancelled or the backend fails.
+func (f *Follower) Run(ctx context.Context, out chan<- Update) error {
+	ticker := time.NewTicker(f.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := f.poll(ctx, out); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// poll delivers every block that has become confirmed since the last poll.
+func (f *Follower) poll(ctx context.Context, out chan<- Update) error {
+	head, err := f.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("follower: reading head: %w", err)
+	}
+	if head.Number.Uint64() < f.cfg.Confirmations {
+		return nil
+	}
+	target := head.Number.Uint64() - f.cfg.Confirmations
+	for {
+		next := f.cfg.Start
+		if last, ok := f.last(); ok {
+			next = last.Number + 1
+		}
+		if next > target {
+			return nil
+		}
+		header, err := f.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(next))
+		if err != nil {
+			return fmt.Errorf("follower: reading block %d: %w", next, err)
+		}
+		if last, ok := f.last(); ok && header.ParentHash != last.Hash {
+			if err := f.rollback(ctx, out); err != nil {
+				return err
+			}
+			continue
+		}
+		hash := header.Hash()
+		logs, err := f.backend.FilterLogs(ctx, ethereum.FilterQuery{BlockHash: &hash, Addresses: f.cfg.Addresses})
+		if err != nil {
+			return fmt.Errorf("follower: reading logs of block %d: %w", next, err)
+		}
+		sort.Slice(logs, func(i, j int) bool { return logs[i].Index < logs[j].Index })
+		update := Update{BlockRef: BlockRef{Number: next, Hash: hash}, Logs: logs}
+		if err := send(ctx, out, update); err != nil {
+			return err
+		}
+		f.history = append(f.history, update)
+		if len(f.history) > f.cfg.History {
+			f.history = f.history[len(f.history)-f.cfg.History:]
+		}
+	}
+}
+
+// rollback removes delivered blocks from the top of the history until it
+// reaches one that is still canonical.
+func (f *Follower) rollback(ctx context.Context, out chan<- Update) error {
+	for len(f.history) > 0 {
+		top := f.history[len(f.history)-1]
+		header, err := f.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(top.Number))
+		if err != nil {
+			return fmt.Errorf("follower: reading block %d: %w", top.Number, err)
+		}
+		if header.Hash() == top.Hash {
+			return nil
+		}
+		removed := Update{BlockRef: top.BlockRef, Rollback: true, Logs: make([]types.Log, len(top.Logs))}
+		for i, log := range top.Logs {
+			log.Removed = true
+			removed.Logs[len(top.Logs)-1-i] = log
+		}
+		if err := send(ctx, out, removed); err != nil {
+			return err
+		}
+		f.history = f.history[:len(f.history)-1]
+	}
+	return ErrReorgTooDeep
+}
+
+func (f *Follower) last() (BlockRef, bool) {
+	if len(f.history) == 0 {
+		return BlockRef{}, false
+	}
+	return f.history[len(f.history)-1].BlockRef, true
+}
+
+func send(ctx context.Context, out chan<- Update, u Update) error {
+	select {
+	case out <- u:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
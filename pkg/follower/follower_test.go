@@ -0,0 +1,199 @@
+package follower
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeChain is a chain of headers with two logs per block. Blocks built on
+// different forks have different hashes.
+type fakeChain struct {
+	headers []*types.Header
+	logs    map[common.Hash][]types.Log
+}
+
+func newFakeChain(n int) *fakeChain {
+	c := &fakeChain{logs: make(map[common.Hash][]types.Log)}
+	c.extend(n, 0)
+	return c
+}
+
+// extend grows the chain to n blocks on fork.
+func (c *fakeChain) extend(n int, fork byte) {
+	for len(c.headers) < n {
+		h := &types.Header{Number: big.NewInt(int64(len(c.headers))), Extra: []byte{fork}}
+		if len(c.headers) > 0 {
+			h.ParentHash = c.headers[len(c.headers)-1].Hash()
+		}
+		c.headers = append(c.headers, h)
+		hash := h.Hash()
+		for i := uint(0); i < 2; i++ {
+			c.logs[hash] = append(c.logs[hash], types.Log{BlockNumber: h.Number.Uint64(), BlockHash: hash, Index: i})
+		}
+	}
+}
+
+// reorg replaces the blocks from block on with n blocks in total on fork.
+func (c *fakeChain) reorg(block uint64, n int, fork byte) {
+	c.headers = c.headers[:block]
+	c.extend(n, fork)
+}
+
+func (c *fakeChain) hash(n uint64) common.Hash {
+	return c.headers[n].Hash()
+}
+
+func (c *fakeChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return c.headers[len(c.headers)-1], nil
+	}
+	if number.Uint64() >= uint64(len(c.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return c.headers[number.Uint64()], nil
+}
+
+// FilterLogs returns the logs out of order, which the follower must fix.
+func (c *fakeChain) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	logs := c.logs[*q.BlockHash]
+	out := make([]types.Log, len(logs))
+	for i, log := range logs {
+		out[len(logs)-1-i] = log
+	}
+	return out, nil
+}
+
+// drain polls f once and returns the updates it delivered.
+func drain(t *testing.T, f *Follower) ([]Update, error) {
+	t.Helper()
+	out := make(chan Update, 100)
+	err := f.poll(context.Background(), out)
+	close(out)
+	var updates []Update
+	for u := range out {
+		updates = append(updates, u)
+	}
+	return updates, err
+}
+
+// summary renders updates as block numbers, negative for rollbacks.
+func summary(updates []Update) []int {
+	var s []int
+	for _, u := range updates {
+		n := int(u.Number)
+		if u.Rollback {
+			n = -n
+		}
+		s = append(s, n)
+	}
+	return s
+}
+
+func TestConfirmations(t *testing.T) {
+	chain := newFakeChain(11)
+	f := New(chain, Config{Confirmations: 2, Start: 3})
+	updates, err := drain(t, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(updates), []int{3, 4, 5, 6, 7, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("delivered %v, want %v", got, want)
+	}
+	for _, u := range updates {
+		if u.Hash != chain.hash(u.Number) || len(u.Logs) != 2 || u.Logs[0].Index != 0 || u.Logs[1].Index != 1 {
+			t.Errorf("block %d: hash %s, logs %+v", u.Number, u.Hash, u.Logs)
+		}
+	}
+
+	// Nothing new until the head moves, then only the new blocks.
+	if updates, err := drain(t, f); err != nil || len(updates) != 0 {
+		t.Errorf("second poll delivered %v, %v", summary(updates), err)
+	}
+	chain.extend(12, 0)
+	if updates, err := drain(t, f); err != nil || !reflect.DeepEqual(summary(updates), []int{9}) {
+		t.Errorf("after a new block delivered %v, %v", summary(updates), err)
+	}
+
+	// A chain shorter than the confirmation depth delivers nothing.
+	if updates, err := drain(t, New(newFakeChain(2), Config{Confirmations: 2})); err != nil || len(updates) != 0 {
+		t.Errorf("short chain delivered %v, %v", summary(updates), err)
+	}
+}
+
+func TestReorg(t *testing.T) {
+	chain := newFakeChain(10)
+	f := New(chain, Config{})
+	if _, err := drain(t, f); err != nil {
+		t.Fatal(err)
+	}
+	old8 := chain.hash(8)
+
+	chain.reorg(8, 11, 1)
+	updates, err := drain(t, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(updates), []int{-9, -8, 8, 9, 10}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("delivered %v, want %v", got, want)
+	}
+	rb := updates[1]
+	if rb.Hash != old8 || len(rb.Logs) != 2 {
+		t.Fatalf("rollback of block 8 = %+v", rb)
+	}
+	for i, log := range rb.Logs {
+		if !log.Removed || log.BlockHash != old8 || log.Index != uint(1-i) {
+			t.Errorf("rolled back log %d = %+v, want removed in reverse order", i, log)
+		}
+	}
+	for _, u := range updates[2:] {
+		if u.Hash != chain.hash(u.Number) {
+			t.Errorf("block %d delivered from the old chain", u.Number)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	chain := newFakeChain(8)
+	f := New(chain, Config{After: &BlockRef{Number: 5, Hash: chain.hash(5)}})
+	updates, err := drain(t, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(updates), []int{6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed with %v, want %v", got, want)
+	}
+
+	// A resume point that was reorged out is rolled back, without logs, and
+	// nothing older is known.
+	stale := BlockRef{Number: 5, Hash: common.HexToHash("0x5")}
+	updates, err = drain(t, New(chain, Config{After: &stale}))
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("stale resume = %v, want ErrReorgTooDeep", err)
+	}
+	if len(updates) != 1 || !updates[0].Rollback || updates[0].BlockRef != stale || len(updates[0].Logs) != 0 {
+		t.Errorf("stale resume delivered %+v", updates)
+	}
+}
+
+func TestReorgTooDeep(t *testing.T) {
+	chain := newFakeChain(10)
+	f := New(chain, Config{History: 2})
+	if _, err := drain(t, f); err != nil {
+		t.Fatal(err)
+	}
+	chain.reorg(5, 11, 1)
+	updates, err := drain(t, f)
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("poll = %v, want ErrReorgTooDeep", err)
+	}
+	if got, want := summary(updates), []int{-9, -8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package graphapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+// cacheKey carries the *requestCache of a query in its context.
+type cacheKey struct{}
+
+// requestCache projects users and modules once per query. Both are folded
+// from full event tables, so resolving the users and modules of every deal
+// of a page one by one would rescan those tables for each of them.
+type requestCache struct {
+	store *sqlite.Store
+
+	usersOnce sync.Once
+	users     map[common.Address]sqlite.User
+	usersErr  error
+
+	modulesOnce sync.Once
+	modules     map[common.Address][]sqlite.Module
+	modulesErr  error
+}
+
+// withCache gives every request handled by next its own requestCache.
+func (s *Server) withCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(s.WithCache(r.Context())))
+	})
+}
+
+// user returns the user at addr and whether it is registered.
+func (c *requestCache) user(ctx context.Context, addr common.Address) (sqlite.User, bool, error) {
+	c.usersOnce.Do(func() {
+		var users []sqlite.User
+		if users, c.usersErr = c.store.Users(ctx, sqlite.UserQuery{}); c.usersErr != nil {
+			return
+		}
+		c.users = make(map[common.Address]sqlite.User, len(users))
+		for _, u := range users {
+			c.users[u.Address] = u
+		}
+	})
+	u, ok := c.users[addr]
+	return u, ok, c.usersErr
+}
+
+// ownedModules returns the modules of owner ordered by name.
+func (c *requestCache) ownedModules(ctx context.Context, owner common.Address) ([]sqlite.Module, error) {
+	c.modulesOnce.Do(func() {
+		var modules []sqlite.Module
+		if modules, c.modulesErr = c.store.Modules(ctx, sqlite.ModuleQuery{}); c.modulesErr != nil {
+			return
+		}
+		c.modules = make(map[common.Address][]sqlite.Module)
+		for _, m := range modules {
+			c.modules[m.Owner] = append(c.modules[m.Owner], m)
+		}
+	})
+	return c.modules[owner], c.modulesErr
+}
@@ -0,0 +1,747 @@
+package graphapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+const (
+	defaultFirst = 50
+	maxFirst     = 500
+)
+
+// window turns connection arguments into an offset and a limit.
+func window(first *int32, after *string) (offset, limit int, err error) {
+	limit = defaultFirst
+	if first != nil {
+		if *first < 0 {
+			return 0, 0, errors.New("graphapi: first must not be negative")
+		}
+		limit = int(*first)
+	}
+	if limit > maxFirst {
+		limit = maxFirst
+	}
+	if after != nil {
+		if offset, err = parseCursor(*after); err != nil {
+			return 0, 0, err
+		}
+	}
+	return offset, limit, nil
+}
+
+type pageInfo struct {
+	next bool
+	end  *string
+}
+
+func (p *pageInfo) HasNextPage() bool  { return p.next }
+func (p *pageInfo) EndCursor() *string { return p.end }
+
+type connection[T any] struct {
+	nodes []T
+	info  *pageInfo
+}
+
+func (c *connection[T]) Nodes() []T          { return c.nodes }
+func (c *connection[T]) PageInfo() *pageInfo { return c.info }
+
+// connect builds a connection from records fetched with limit+1, the extra
+// record telling whether there is a next page.
+func connect[R, T any](records []R, offset, limit int, wrap func(R) T) *connection[T] {
+	c := &connection[T]{info: &pageInfo{}}
+	if len(records) > limit {
+		records = records[:limit]
+		c.info.next = true
+	}
+	c.nodes = make([]T, len(records))
+	for i, r := range records {
+		c.nodes[i] = wrap(r)
+	}
+	if len(records) > 0 {
+		end := cursor(offset + len(records) - 1)
+		c.info.end = &end
+	}
+	return c
+}
+
+func parseAddress(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("graphapi: invalid address %q", s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+func optAddress(s *string) (*common.Address, error) {
+	if s == nil {
+		return nil, nil
+	}
+	addr, err := parseAddress(*s)
+	if err != nil {
+		return nil, err
+	}
+	return &addr, nil
+}
+
+// resolver is the root resolver.
+type resolver struct {
+	s *Server
+}
+
+type recordFilter struct {
+	JobCreator       *string
+	ResourceProvider *string
+	Solver           *string
+	ModuleCreator    *string
+	Validator        *string
+	DealId           *string
+	ResultId         *string
+	Status           *int32
+	Since            *Long
+	Until            *Long
+}
+
+func (f *recordFilter) query() (indexer.Query, error) {
+	var q indexer.Query
+	if f == nil {
+		return q, nil
+	}
+	var err error
+	for _, field := range []struct {
+		src *string
+		dst **common.Address
+	}{
+		{f.JobCreator, &q.JobCreator},
+		{f.ResourceProvider, &q.ResourceProvider},
+		{f.Solver, &q.Solver},
+		{f.ModuleCreator, &q.ModuleCreator},
+		{f.Validator, &q.Validator},
+	} {
+		if *field.dst, err = optAddress(field.src); err != nil {
+			return q, err
+		}
+	}
+	if f.DealId != nil {
+		q.DealID = *f.DealId
+	}
+	if f.ResultId != nil {
+		q.ResultID = *f.ResultId
+	}
+	if f.Status != nil {
+		if *f.Status < 0 || *f.Status > 255 {
+			return q, fmt.Errorf("graphapi: invalid status %d", *f.Status)
+		}
+		status := uint8(*f.Status)
+		q.Status = &status
+	}
+	if f.Since != nil {
+		q.Since = time.Unix(int64(*f.Since), 0)
+	}
+	if f.Until != nil {
+		q.Until = time.Unix(int64(*f.Until), 0)
+	}
+	return q, nil
+}
+
+func (r *resolver) Deal(ctx context.Context, args struct{ Id string }) (*dealResolver, error) {
+	deal, err := r.s.store.Deal(ctx, args.Id)
+	if errors.Is(err, indexer.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dealResolver{r.s, deal}, nil
+}
+
+func (r *resolver) Deals(ctx context.Context, args struct {
+	Filter *recordFilter
+	First  *int32
+	After  *string
+}) (*connection[*dealResolver], error) {
+	q, err := args.Filter.query()
+	if err != nil {
+		return nil, err
+	}
+	return r.s.deals(ctx, q, args.First, args.After)
+}
+
+func (r *resolver) Result(ctx context.Context, args struct{ Id string }) (*resultResolver, error) {
+	result, err := r.s.store.Result(ctx, args.Id)
+	if errors.Is(err, indexer.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &resultResolver{r.s, result}, nil
+}
+
+func (r *resolver) Results(ctx context.Context, args struct {
+	Filter *recordFilter
+	First  *int32
+	After  *string
+}) (*connection[*resultResolver], error) {
+	q, err := args.Filter.query()
+	if err != nil {
+		return nil, err
+	}
+	return r.s.results(ctx, q, args.First, args.After)
+}
+
+func (r *resolver) ValidationResult(ctx context.Context, args struct{ Id string }) (*validationResolver, error) {
+	validation, err := r.s.store.ValidationResult(ctx, args.Id)
+	if errors.Is(err, indexer.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &validationResolver{r.s, validation}, nil
+}
+
+func (r *resolver) ValidationResults(ctx context.Context, args struct {
+	Filter *recordFilter
+	First  *int32
+	After  *string
+}) (*connection[*validationResolver], error) {
+	q, err := args.Filter.query()
+	if err != nil {
+		return nil, err
+	}
+	return r.s.validations(ctx, q, args.First, args.After)
+}
+
+type movementFilter struct {
+	Account   *string
+	Kinds     *[]string
+	DealId    *string
+	FromBlock *Long
+	ToBlock   *Long
+}
+
+func (r *resolver) EscrowMovements(ctx context.Context, args struct {
+	Filter *movementFilter
+	First  *int32
+	After  *string
+}) (*connection[*movementResolver], error) {
+	var q sqlite.MovementQuery
+	if f := args.Filter; f != nil {
+		var err error
+		if q.Account, err = optAddress(f.Account); err != nil {
+			return nil, err
+		}
+		q.Kinds = kinds(f.Kinds)
+		if f.DealId != nil {
+			q.DealID = *f.DealId
+		}
+		if f.FromBlock != nil {
+			q.FromBlock = uint64(*f.FromBlock)
+		}
+		if f.ToBlock != nil {
+			q.ToBlock = uint64(*f.ToBlock)
+		}
+	}
+	return r.s.movements(ctx, q, args.First, args.After)
+}
+
+func kinds(names *[]string) []sqlite.MovementKind {
+	if names == nil {
+		return nil
+	}
+	out := make([]sqlite.MovementKind, len(*names))
+	for i, name := range *names {
+		out[i] = sqlite.MovementKind(strings.ToLower(name))
+	}
+	return out
+}
+
+func (r *resolver) User(args struct{ Address string }) (*userResolver, error) {
+	addr, err := parseAddress(args.Address)
+	if err != nil {
+		return nil, err
+	}
+	return r.s.user(addr), nil
+}
+
+func (r *resolver) Users(ctx context.Context, args struct {
+	Role  *int32
+	First *int32
+	After *string
+}) (*connection[*userResolver], error) {
+	offset, limit, err := window(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	q := sqlite.UserQuery{Offset: offset, Limit: limit + 1}
+	if args.Role != nil {
+		if *args.Role < 0 || *args.Role > math.MaxUint8 || !roles.UserType(*args.Role).Valid() {
+			return nil, fmt.Errorf("graphapi: invalid role %d", *args.Role)
+		}
+		role := uint8(*args.Role)
+		q.Role = &role
+	}
+	users, err := r.s.store.Users(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(users, offset, limit, func(u sqlite.User) *userResolver {
+		return r.s.loadedUser(u)
+	}), nil
+}
+
+func (r *resolver) Modules(ctx context.Context, args struct {
+	Owner *string
+	Name  *string
+	First *int32
+	After *string
+}) (*connection[*moduleResolver], error) {
+	offset, limit, err := window(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	q := sqlite.ModuleQuery{Offset: offset, Limit: limit + 1}
+	if q.Owner, err = optAddress(args.Owner); err != nil {
+		return nil, err
+	}
+	if args.Name != nil {
+		q.Name = *args.Name
+	}
+	modules, err := r.s.store.Modules(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(modules, offset, limit, func(m sqlite.Module) *moduleResolver {
+		return &moduleResolver{r.s, m}
+	}), nil
+}
+
+func (r *resolver) VestingSchedules(ctx context.Context, args struct {
+	Beneficiary *string
+	First       *int32
+	After       *string
+}) (*connection[*vestingResolver], error) {
+	offset, limit, err := window(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	q := sqlite.VestingQuery{Offset: offset, Limit: limit + 1}
+	if q.Beneficiary, err = optAddress(args.Beneficiary); err != nil {
+		return nil, err
+	}
+	schedules, err := r.s.store.VestingSchedules(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(schedules, offset, limit, func(v sqlite.VestingSchedule) *vestingResolver {
+		return &vestingResolver{r.s, v}
+	}), nil
+}
+
+func (s *Server) deals(ctx context.Context, q indexer.Query, first *int32, after *string) (*connection[*dealResolver], error) {
+	offset, limit, err := window(first, after)
+	if err != nil {
+		return nil, err
+	}
+	q.Offset, q.Limit = offset, limit+1
+	deals, err := s.store.Deals(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(deals, offset, limit, func(d indexer.Deal) *dealResolver { return &dealResolver{s, d} }), nil
+}
+
+func (s *Server) results(ctx context.Context, q indexer.Query, first *int32, after *string) (*connection[*resultResolver], error) {
+	offset, limit, err := window(first, after)
+	if err != nil {
+		return nil, err
+	}
+	q.Offset, q.Limit = offset, limit+1
+	results, err := s.store.Results(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(results, offset, limit, func(r indexer.Result) *resultResolver { return &resultResolver{s, r} }), nil
+}
+
+func (s *Server) validations(ctx context.Context, q indexer.Query, first *int32, after *string) (*connection[*validationResolver], error) {
+	offset, limit, err := window(first, after)
+	if err != nil {
+		return nil, err
+	}
+	q.Offset, q.Limit = offset, limit+1
+	validations, err := s.store.ValidationResults(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(validations, offset, limit, func(v indexer.ValidationResult) *validationResolver {
+		return &validationResolver{s, v}
+	}), nil
+}
+
+func (s *Server) movements(ctx context.Context, q sqlite.MovementQuery, first *int32, after *string) (*connection[*movementResolver], error) {
+	offset, limit, err := window(first, after)
+	if err != nil {
+		return nil, err
+	}
+	q.Offset, q.Limit = offset, limit+1
+	movements, err := s.store.EscrowMovements(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return connect(movements, offset, limit, func(m sqlite.Movement) *movementResolver {
+		return &movementResolver{s, m}
+	}), nil
+}
+
+// payouts returns the payouts and slashes emitted by txs.
+func (s *Server) payouts(ctx context.Context, txs []common.Hash) ([]*movementResolver, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	movements, err := s.store.EscrowMovements(ctx, sqlite.MovementQuery{
+		Kinds:    []sqlite.MovementKind{sqlite.MovementPayout, sqlite.MovementSlash},
+		TxHashes: txs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*movementResolver, len(movements))
+	for i, m := range movements {
+		out[i] = &movementResolver{s, m}
+	}
+	return out, nil
+}
+
+type dealResolver struct {
+	s *Server
+	d indexer.Deal
+}
+
+func (r *dealResolver) ID() string                      { return r.d.DealId }
+func (r *dealResolver) JobCreator() *userResolver       { return r.s.user(r.d.JobCreator) }
+func (r *dealResolver) ResourceProvider() *userResolver { return r.s.user(r.d.ResourceProvider) }
+func (r *dealResolver) ModuleCreator() *userResolver    { return r.s.user(r.d.ModuleCreator) }
+func (r *dealResolver) Solver() *userResolver           { return r.s.user(r.d.Solver) }
+func (r *dealResolver) JobOfferCID() string             { return r.d.JobOfferCID }
+func (r *dealResolver) ResourceOfferCID() string        { return r.d.ResourceOfferCID }
+func (r *dealResolver) Status() int32                   { return int32(r.d.Status) }
+func (r *dealResolver) Timestamp() Long                 { return Long(r.d.Timestamp.Uint64()) }
+func (r *dealResolver) Block() Long                     { return Long(r.d.Block) }
+func (r *dealResolver) TxHash() string                  { return r.d.TxHash.Hex() }
+
+func (r *dealResolver) PaymentStructure() *paymentResolver {
+	p := r.d.PaymentStructure
+	return &paymentResolver{BigInt{p.JobCreatorSolverFee}, BigInt{p.ResourceProviderSolverFee},
+		BigInt{p.NetworkCongestionFee}, BigInt{p.ModuleCreatorFee}, BigInt{p.PriceOfJobWithoutFees}}
+}
+
+func (r *dealResolver) Results(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*connection[*resultResolver], error) {
+	return r.s.results(ctx, indexer.Query{DealID: r.d.DealId}, args.First, args.After)
+}
+
+func (r *dealResolver) EscrowLocks(ctx context.Context) ([]*movementResolver, error) {
+	movements, err := r.s.store.EscrowMovements(ctx, sqlite.MovementQuery{
+		Kinds:  []sqlite.MovementKind{sqlite.MovementLock},
+		DealID: r.d.DealId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*movementResolver, len(movements))
+	for i, m := range movements {
+		out[i] = &movementResolver{r.s, m}
+	}
+	return out, nil
+}
+
+type paymentResolver struct {
+	jobCreatorSolverFee, resourceProviderSolverFee, networkCongestionFee, moduleCreatorFee, priceOfJobWithoutFees BigInt
+}
+
+func (r *paymentResolver) JobCreatorSolverFee() BigInt       { return r.jobCreatorSolverFee }
+func (r *paymentResolver) ResourceProviderSolverFee() BigInt { return r.resourceProviderSolverFee }
+func (r *paymentResolver) NetworkCongestionFee() BigInt      { return r.networkCongestionFee }
+func (r *paymentResolver) ModuleCreatorFee() BigInt          { return r.moduleCreatorFee }
+func (r *paymentResolver) PriceOfJobWithoutFees() BigInt     { return r.priceOfJobWithoutFees }
+
+type resultResolver struct {
+	s *Server
+	r indexer.Result
+}
+
+func (r *resultResolver) ID() string        { return r.r.ResultId }
+func (r *resultResolver) ResultCID() string { return r.r.ResultCID }
+func (r *resultResolver) Status() int32     { return int32(r.r.Status) }
+func (r *resultResolver) Timestamp() Long   { return Long(r.r.Timestamp.Uint64()) }
+func (r *resultResolver) Block() Long       { return Long(r.r.Block) }
+func (r *resultResolver) TxHash() string    { return r.r.TxHash.Hex() }
+
+func (r *resultResolver) Deal(ctx context.Context) (*dealResolver, error) {
+	return (&resolver{r.s}).Deal(ctx, struct{ Id string }{r.r.DealId})
+}
+
+func (r *resultResolver) Validations(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*connection[*validationResolver], error) {
+	return r.s.validations(ctx, indexer.Query{ResultID: r.r.ResultId}, args.First, args.After)
+}
+
+func (r *resultResolver) Payouts(ctx context.Context) ([]*movementResolver, error) {
+	txs, err := r.s.store.ResultTxs(ctx, r.r.ResultId)
+	if err != nil {
+		return nil, err
+	}
+	return r.s.payouts(ctx, withTx(txs, r.r.TxHash))
+}
+
+type validationResolver struct {
+	s *Server
+	v indexer.ValidationResult
+}
+
+func (r *validationResolver) ID() string               { return r.v.ValidationResultId }
+func (r *validationResolver) ValidationCID() string    { return r.v.ValidationCID }
+func (r *validationResolver) Status() int32            { return int32(r.v.Status) }
+func (r *validationResolver) Timestamp() Long          { return Long(r.v.Timestamp.Uint64()) }
+func (r *validationResolver) Validator() *userResolver { return r.s.user(r.v.Validator) }
+func (r *validationResolver) Block() Long              { return Long(r.v.Block) }
+func (r *validationResolver) TxHash() string           { return r.v.TxHash.Hex() }
+
+func (r *validationResolver) Result(ctx context.Context) (*resultResolver, error) {
+	return (&resolver{r.s}).Result(ctx, struct{ Id string }{r.v.ResultId})
+}
+
+func (r *validationResolver) Payouts(ctx context.Context) ([]*movementResolver, error) {
+	txs, err := r.s.store.ValidationResultTxs(ctx, r.v.ValidationResultId)
+	if err != nil {
+		return nil, err
+	}
+	return r.s.payouts(ctx, withTx(txs, r.v.TxHash))
+}
+
+// withTx adds tx to txs unless it is already there.
+func withTx(txs []common.Hash, tx common.Hash) []common.Hash {
+	for _, t := range txs {
+		if t == tx {
+			return txs
+		}
+	}
+	return append(txs, tx)
+}
+
+type movementResolver struct {
+	s *Server
+	m sqlite.Movement
+}
+
+func (r *movementResolver) Kind() string           { return strings.ToUpper(string(r.m.Kind)) }
+func (r *movementResolver) Account() *userResolver { return r.s.user(r.m.Account) }
+func (r *movementResolver) Amount() BigInt         { return BigInt{r.m.Amount} }
+func (r *movementResolver) Block() Long            { return Long(r.m.Block) }
+func (r *movementResolver) TxHash() string         { return r.m.TxHash.Hex() }
+func (r *movementResolver) LogIndex() int32        { return int32(r.m.LogIndex) }
+
+func (r *movementResolver) Reason() *int32 {
+	if r.m.Reason == nil {
+		return nil
+	}
+	reason := int32(*r.m.Reason)
+	return &reason
+}
+
+func (r *movementResolver) Deal(ctx context.Context) (*dealResolver, error) {
+	if r.m.DealID == "" {
+		return nil, nil
+	}
+	return (&resolver{r.s}).Deal(ctx, struct{ Id string }{r.m.DealID})
+}
+
+// userResolver loads the user's LilypadUser record on first use, since most
+// queries only need the address.
+type userResolver struct {
+	s    *Server
+	addr common.Address
+
+	once sync.Once
+	user sqlite.User
+	ok   bool
+	err  error
+}
+
+func (s *Server) user(addr common.Address) *userResolver {
+	return &userResolver{s: s, addr: addr}
+}
+
+func (s *Server) loadedUser(u sqlite.User) *userResolver {
+	r := &userResolver{s: s, addr: u.Address, user: u, ok: true}
+	r.once.Do(func() {})
+	return r
+}
+
+func (r *userResolver) load(ctx context.Context) error {
+	r.once.Do(func() {
+		if c, ok := ctx.Value(cacheKey{}).(*requestCache); ok {
+			r.user, r.ok, r.err = c.user(ctx, r.addr)
+			return
+		}
+		var users []sqlite.User
+		users, r.err = r.s.store.Users(ctx, sqlite.UserQuery{Address: &r.addr})
+		if r.err == nil && len(users) > 0 {
+			r.user, r.ok = users[0], true
+		}
+	})
+	return r.err
+}
+
+func (r *userResolver) Address() string { return r.addr.Hex() }
+
+func (r *userResolver) Registered(ctx context.Context) (bool, error) {
+	err := r.load(ctx)
+	return r.ok, err
+}
+
+func (r *userResolver) MetadataId(ctx context.Context) (string, error) {
+	err := r.load(ctx)
+	return r.user.MetadataID, err
+}
+
+func (r *userResolver) URL(ctx context.Context) (string, error) {
+	err := r.load(ctx)
+	return r.user.URL, err
+}
+
+func (r *userResolver) Roles(ctx context.Context) ([]*roleResolver, error) {
+	if err := r.load(ctx); err != nil {
+		return nil, err
+	}
+	out := make([]*roleResolver, len(r.user.Roles))
+	for i, role := range r.user.Roles {
+		out[i] = &roleResolver{r.s, r.addr, roles.UserType(role)}
+	}
+	return out, nil
+}
+
+func (r *userResolver) Modules(ctx context.Context) ([]*moduleResolver, error) {
+	return r.s.ownedModules(ctx, r.addr)
+}
+
+func (r *userResolver) EscrowMovements(ctx context.Context, args struct {
+	Kinds *[]string
+	First *int32
+	After *string
+}) (*connection[*movementResolver], error) {
+	return r.s.movements(ctx, sqlite.MovementQuery{Account: &r.addr, Kinds: kinds(args.Kinds)}, args.First, args.After)
+}
+
+func (s *Server) ownedModules(ctx context.Context, owner common.Address) ([]*moduleResolver, error) {
+	var (
+		modules []sqlite.Module
+		err     error
+	)
+	if c, ok := ctx.Value(cacheKey{}).(*requestCache); ok {
+		modules, err = c.ownedModules(ctx, owner)
+	} else {
+		modules, err = s.store.Modules(ctx, sqlite.ModuleQuery{Owner: &owner})
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*moduleResolver, len(modules))
+	for i, m := range modules {
+		out[i] = &moduleResolver{s, m}
+	}
+	return out, nil
+}
+
+type roleResolver struct {
+	s    *Server
+	addr common.Address
+	role roles.UserType
+}
+
+func (r *roleResolver) Value() int32 { return int32(r.role) }
+
+func (r *roleResolver) Name() string { return r.role.String() }
+
+func (r *roleResolver) Deals(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*connection[*dealResolver], error) {
+	var q indexer.Query
+	switch r.role {
+	case roles.Solver:
+		q.Solver = &r.addr
+	case roles.ModuleCreator:
+		q.ModuleCreator = &r.addr
+	case roles.ResourceProvider:
+		q.ResourceProvider = &r.addr
+	case roles.JobCreator:
+		q.JobCreator = &r.addr
+	default:
+		return &connection[*dealResolver]{info: &pageInfo{}}, nil
+	}
+	return r.s.deals(ctx, q, args.First, args.After)
+}
+
+func (r *roleResolver) Validations(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*connection[*validationResolver], error) {
+	if r.role != roles.Validator {
+		return &connection[*validationResolver]{info: &pageInfo{}}, nil
+	}
+	return r.s.validations(ctx, indexer.Query{Validator: &r.addr}, args.First, args.After)
+}
+
+func (r *roleResolver) Modules(ctx context.Context) ([]*moduleResolver, error) {
+	if r.role != roles.ModuleCreator {
+		return nil, nil
+	}
+	return r.s.ownedModules(ctx, r.addr)
+}
+
+type moduleResolver struct {
+	s *Server
+	m sqlite.Module
+}
+
+func (r *moduleResolver) Owner() *userResolver { return r.s.user(r.m.Owner) }
+func (r *moduleResolver) Name() string         { return r.m.Name }
+func (r *moduleResolver) URL() string          { return r.m.URL }
+func (r *moduleResolver) Block() Long          { return Long(r.m.Block) }
+
+func (r *moduleResolver) ApprovedPurchaser() *userResolver {
+	if r.m.ApprovedPurchaser == nil {
+		return nil
+	}
+	return r.s.user(*r.m.ApprovedPurchaser)
+}
+
+type vestingResolver struct {
+	s *Server
+	v sqlite.VestingSchedule
+}
+
+func (r *vestingResolver) ID() BigInt                 { return BigInt{r.v.ID} }
+func (r *vestingResolver) Beneficiary() *userResolver { return r.s.user(r.v.Beneficiary) }
+func (r *vestingResolver) Amount() BigInt             { return BigInt{r.v.Amount} }
+func (r *vestingResolver) StartTime() BigInt          { return BigInt{r.v.StartTime} }
+func (r *vestingResolver) Released() BigInt           { return BigInt{r.v.Released} }
+func (r *vestingResolver) Block() Long                { return Long(r.v.Block) }
+func (r *vestingResolver) TxHash() string             { return r.v.TxHash.Hex() }
@@ -0,0 +1,108 @@
+package graphapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BigInt is the GraphQL BigInt scalar: a uint256 encoded as a decimal string.
+type BigInt struct {
+	*big.Int
+}
+
+// ImplementsGraphQLType maps BigInt to the BigInt scalar.
+func (BigInt) ImplementsGraphQLType(name string) bool { return name == "BigInt" }
+
+// UnmarshalGraphQL accepts a decimal string or an integer.
+func (b *BigInt) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case string:
+		n, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return fmt.Errorf("graphapi: invalid BigInt %q", v)
+		}
+		b.Int = n
+	case int32:
+		b.Int = big.NewInt(int64(v))
+	case int64:
+		b.Int = big.NewInt(v)
+	default:
+		return fmt.Errorf("graphapi: invalid BigInt %v", input)
+	}
+	return nil
+}
+
+// MarshalJSON encodes b as a decimal string; nil encodes as "0".
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	if b.Int == nil {
+		return []byte(`"0"`), nil
+	}
+	return json.Marshal(b.Int.String())
+}
+
+// Long is the GraphQL Long scalar: an unsigned 64-bit integer encoded as a
+// JSON number, for block numbers and timestamps.
+type Long uint64
+
+// ImplementsGraphQLType maps Long to the Long scalar.
+func (Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+// UnmarshalGraphQL accepts a non-negative integer or its decimal string.
+func (l *Long) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case int32:
+		if v < 0 {
+			return fmt.Errorf("graphapi: negative Long %d", v)
+		}
+		*l = Long(v)
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("graphapi: negative Long %d", v)
+		}
+		*l = Long(v)
+	case float64:
+		if v < 0 || v > math.MaxUint64 || v != math.Trunc(v) {
+			return fmt.Errorf("graphapi: invalid Long %v", v)
+		}
+		*l = Long(v)
+	case string:
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("graphapi: invalid Long %q", v)
+		}
+		*l = Long(n)
+	default:
+		return fmt.Errorf("graphapi: invalid Long %v", input)
+	}
+	return nil
+}
+
+// MarshalJSON encodes l as a JSON number.
+func (l Long) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(l), 10)), nil
+}
+
+const cursorPrefix = "offset:"
+
+// cursor encodes the position after the record at offset.
+func cursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// parseCursor returns the offset of the first record after c.
+func parseCursor(c string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(c)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("graphapi: invalid cursor %q", c)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("graphapi: invalid cursor %q", c)
+	}
+	return n + 1, nil
+}
@@ -0,0 +1,237 @@
+package graphapi
+
+// schema is the GraphQL schema served by Server. Token amounts and other
+// uint256 values are BigInt, a decimal string; block numbers and timestamps
+// are Long, a JSON number. Lists are connections paged with first/after.
+const schema = `
+schema {
+	query: Query
+	subscription: Subscription
+}
+
+scalar BigInt
+scalar Long
+
+type Query {
+	deal(id: String!): Deal
+	deals(filter: RecordFilter, first: Int, after: String): DealConnection!
+	result(id: String!): Result
+	results(filter: RecordFilter, first: Int, after: String): ResultConnection!
+	validationResult(id: String!): ValidationResult
+	validationResults(filter: RecordFilter, first: Int, after: String): ValidationResultConnection!
+	escrowMovements(filter: MovementFilter, first: Int, after: String): MovementConnection!
+	user(address: String!): User
+	users(role: Int, first: Int, after: String): UserConnection!
+	modules(owner: String, name: String, first: Int, after: String): ModuleConnection!
+	vestingSchedules(beneficiary: String, first: Int, after: String): VestingScheduleConnection!
+}
+
+type Subscription {
+	# blocks streams the confirmed blocks delivered by the live follower,
+	# including rollbacks of blocks removed by a reorg.
+	blocks: Block!
+	# events streams the decoded events of confirmed blocks, optionally
+	# restricted to a contract and event name.
+	events(contract: String, name: String): Event!
+}
+
+# RecordFilter selects deals, results and validation results. Participant
+# filters apply to the deal a record belongs to; validator only applies to
+# validation results. since and until bound the on-chain timestamp in unix
+# seconds; since is inclusive, until exclusive.
+input RecordFilter {
+	jobCreator: String
+	resourceProvider: String
+	solver: String
+	moduleCreator: String
+	validator: String
+	dealId: String
+	resultId: String
+	status: Int
+	since: Long
+	until: Long
+}
+
+enum MovementKind {
+	DEPOSIT
+	PAYOUT
+	SLASH
+	WITHDRAWAL
+	LOCK
+}
+
+input MovementFilter {
+	account: String
+	kinds: [MovementKind!]
+	dealId: String
+	fromBlock: Long
+	toBlock: Long
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+
+type PaymentStructure {
+	jobCreatorSolverFee: BigInt!
+	resourceProviderSolverFee: BigInt!
+	networkCongestionFee: BigInt!
+	moduleCreatorFee: BigInt!
+	priceOfJobWithoutFees: BigInt!
+}
+
+type Deal {
+	id: String!
+	jobCreator: User!
+	resourceProvider: User!
+	moduleCreator: User!
+	solver: User!
+	jobOfferCID: String!
+	resourceOfferCID: String!
+	status: Int!
+	timestamp: Long!
+	paymentStructure: PaymentStructure!
+	block: Long!
+	txHash: String!
+	results(first: Int, after: String): ResultConnection!
+	escrowLocks: [EscrowMovement!]!
+}
+
+type Result {
+	id: String!
+	deal: Deal
+	resultCID: String!
+	status: Int!
+	timestamp: Long!
+	block: Long!
+	txHash: String!
+	validations(first: Int, after: String): ValidationResultConnection!
+	# payouts lists the escrow payouts and slashes emitted by the
+	# transactions that saved the result or changed its status.
+	payouts: [EscrowMovement!]!
+}
+
+type ValidationResult {
+	id: String!
+	result: Result
+	validationCID: String!
+	status: Int!
+	timestamp: Long!
+	validator: User!
+	block: Long!
+	txHash: String!
+	# payouts lists the escrow payouts and slashes emitted by the
+	# transactions that saved the validation result or changed its status.
+	payouts: [EscrowMovement!]!
+}
+
+type EscrowMovement {
+	kind: MovementKind!
+	account: User!
+	amount: BigInt!
+	# reason is the SharedStructs.PaymentReason of a deposit or the actor of
+	# a slash.
+	reason: Int
+	deal: Deal
+	block: Long!
+	txHash: String!
+	logIndex: Int!
+}
+
+type User {
+	address: String!
+	# registered is false for addresses without LilypadUser events.
+	registered: Boolean!
+	metadataId: String!
+	url: String!
+	roles: [Role!]!
+	modules: [Module!]!
+	escrowMovements(kinds: [MovementKind!], first: Int, after: String): MovementConnection!
+}
+
+# Role is a SharedStructs.UserType the user holds, with the records the user
+# takes part in under that role.
+type Role {
+	value: Int!
+	name: String!
+	deals(first: Int, after: String): DealConnection!
+	validations(first: Int, after: String): ValidationResultConnection!
+	modules: [Module!]!
+}
+
+type Module {
+	owner: User!
+	name: String!
+	url: String!
+	approvedPurchaser: User
+	block: Long!
+}
+
+type VestingSchedule {
+	id: BigInt!
+	beneficiary: User!
+	amount: BigInt!
+	startTime: BigInt!
+	released: BigInt!
+	block: Long!
+	txHash: String!
+}
+
+type Block {
+	number: Long!
+	hash: String!
+	rollback: Boolean!
+	events: [Event!]!
+}
+
+type Event {
+	contract: String!
+	name: String!
+	args: [EventArg!]!
+	block: Long!
+	txHash: String!
+	logIndex: Int!
+	removed: Boolean!
+}
+
+type EventArg {
+	name: String!
+	value: String!
+}
+
+type DealConnection {
+	nodes: [Deal!]!
+	pageInfo: PageInfo!
+}
+
+type ResultConnection {
+	nodes: [Result!]!
+	pageInfo: PageInfo!
+}
+
+type ValidationResultConnection {
+	nodes: [ValidationResult!]!
+	pageInfo: PageInfo!
+}
+
+type MovementConnection {
+	nodes: [EscrowMovement!]!
+	pageInfo: PageInfo!
+}
+
+type UserConnection {
+	nodes: [User!]!
+	pageInfo: PageInfo!
+}
+
+type ModuleConnection {
+	nodes: [Module!]!
+	pageInfo: PageInfo!
+}
+
+type VestingScheduleConnection {
+	nodes: [VestingSchedule!]!
+	pageInfo: PageInfo!
+}
+`
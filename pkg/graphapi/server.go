@@ -0,0 +1,285 @@
+// Package graphapi serves the indexed Lilypad protocol data over a read-only
+// GraphQL API: deals, results, validations, escrow movements, users, modules
+// and vesting schedules, joined the way dashboards walk them (deal → results
+// → validations → payouts, user → roles → modules), and a subscription
+// stream of the blocks delivered by a live follower.
+//
+// Queries read a sqlite.Store kept up to date by the indexer and
+// sqlite.Store.Record; the API never writes to it.
+package graphapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/follower"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+// subscriberBuffer is how many blocks a subscriber may lag behind before it
+// is dropped.
+const subscriberBuffer = 64
+
+// Server resolves GraphQL requests against a store and fans the blocks of a
+// follower out to subscribers.
+type Server struct {
+	store  *sqlite.Store
+	client *client.Client
+	schema *graphql.Schema
+
+	mu   sync.Mutex
+	subs map[chan *blockResolver]struct{}
+}
+
+// New returns a Server reading from store. c is used to decode the logs
+// streamed to subscribers.
+func New(store *sqlite.Store, c *client.Client) (*Server, error) {
+	s := &Server{store: store, client: c, subs: make(map[chan *blockResolver]struct{})}
+	var err error
+	s.schema, err = graphql.ParseSchema(schema, &resolver{s},
+		graphql.MaxDepth(12),
+		graphql.MaxParallelism(8),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("graphapi: parsing schema: %w", err)
+	}
+	return s, nil
+}
+
+// Schema returns the parsed schema, e.g. to execute queries in process.
+// Pass the context through WithCache to resolve the users and modules of a
+// query in one pass, as Handler does.
+func (s *Server) Schema() *graphql.Schema {
+	return s.schema
+}
+
+// WithCache returns ctx with a cache that loads users and modules once for
+// the query executed with it. Use a fresh one per query; the cache does not
+// see later writes to the store.
+func (s *Server) WithCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheKey{}, &requestCache{store: s.store})
+}
+
+// Handler serves queries as JSON POST requests on /graphql and subscriptions
+// as server-sent events on /graphql/subscribe, with the request in the query,
+// operationName and variables (JSON) URL parameters.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", s.withCache(&relay.Handler{Schema: s.schema}))
+	mux.HandleFunc("/graphql/subscribe", s.serveSubscription)
+	return mux
+}
+
+func (s *Server) serveSubscription(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	params := r.URL.Query()
+	var variables map[string]interface{}
+	if v := params.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &variables); err != nil {
+			http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	responses, err := s.schema.Subscribe(r.Context(), params.Get("query"), params.Get("operationName"), variables)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for response := range responses {
+		data, err := json.Marshal(response)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// Follow runs f and publishes every update it delivers to the subscribers
+// until ctx is done or f fails. The caller configures f, e.g. to resume
+// after the block the store was last synced to.
+func (s *Server) Follow(ctx context.Context, f *follower.Follower) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := make(chan follower.Update)
+	errc := make(chan error, 1)
+	go func() { errc <- f.Run(ctx, updates) }()
+	for {
+		select {
+		case u := <-updates:
+			s.Publish(u)
+		case err := <-errc:
+			return err
+		}
+	}
+}
+
+// Publish sends u to the subscribers. Subscribers that fell more than a
+// buffer behind are dropped and their streams end.
+func (s *Server) Publish(u follower.Update) {
+	block := &blockResolver{update: u, events: make([]*eventResolver, len(u.Logs))}
+	for i, log := range u.Logs {
+		block.events[i] = &eventResolver{s.client.DecodeLog(log)}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- block:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel of published blocks that is closed when ctx
+// is done or the subscriber is dropped.
+func (s *Server) subscribe(ctx context.Context) <-chan *blockResolver {
+	ch := make(chan *blockResolver, subscriberBuffer)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+func (r *resolver) Blocks(ctx context.Context) <-chan *blockResolver {
+	return r.s.subscribe(ctx)
+}
+
+func (r *resolver) Events(ctx context.Context, args struct {
+	Contract *string
+	Name     *string
+}) <-chan *eventResolver {
+	blocks := r.s.subscribe(ctx)
+	out := make(chan *eventResolver)
+	go func() {
+		defer close(out)
+		for block := range blocks {
+			for _, ev := range block.events {
+				if args.Contract != nil && *args.Contract != ev.e.Contract {
+					continue
+				}
+				if args.Name != nil && *args.Name != ev.e.Name {
+					continue
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+type blockResolver struct {
+	update follower.Update
+	events []*eventResolver
+}
+
+func (r *blockResolver) Number() Long             { return Long(r.update.Number) }
+func (r *blockResolver) Hash() string             { return r.update.Hash.Hex() }
+func (r *blockResolver) Rollback() bool           { return r.update.Rollback }
+func (r *blockResolver) Events() []*eventResolver { return r.events }
+
+type eventResolver struct {
+	e client.Event
+}
+
+func (r *eventResolver) Contract() string { return r.e.Contract }
+func (r *eventResolver) Name() string     { return r.e.Name }
+func (r *eventResolver) Block() Long      { return Long(r.e.Log.BlockNumber) }
+func (r *eventResolver) TxHash() string   { return r.e.Log.TxHash.Hex() }
+func (r *eventResolver) LogIndex() int32  { return int32(r.e.Log.Index) }
+func (r *eventResolver) Removed() bool    { return r.e.Log.Removed }
+
+// Args returns the decoded arguments sorted by name. Logs that could not be
+// decoded report their topics and data instead.
+func (r *eventResolver) Args() []*eventArg {
+	if r.e.Args == nil {
+		return rawArgs(r.e.Log)
+	}
+	names := make([]string, 0, len(r.e.Args))
+	for name := range r.e.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]*eventArg, len(names))
+	for i, name := range names {
+		out[i] = &eventArg{name, formatArg(name, r.e.Args[name])}
+	}
+	return out
+}
+
+func rawArgs(log types.Log) []*eventArg {
+	var out []*eventArg
+	for i, topic := range log.Topics {
+		out = append(out, &eventArg{fmt.Sprintf("topic%d", i), topic.Hex()})
+	}
+	return append(out, &eventArg{"data", hexutil.Encode(log.Data)})
+}
+
+// formatArg renders a decoded ABI value: numbers in decimal, addresses,
+// hashes and bytes in hex.
+func formatArg(name string, v interface{}) string {
+	if b, ok := v.([32]byte); ok && strings.Contains(strings.ToLower(name), "role") {
+		return roles.String(b)
+	}
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case [32]byte:
+		return hexutil.Encode(v[:])
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+type eventArg struct {
+	name, value string
+}
+
+func (a *eventArg) Name() string  { return a.name }
+func (a *eventArg) Value() string { return a.value }
@@ -0,0 +1,276 @@
+package graphapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/follower"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/sqlite"
+)
+
+var (
+	book = client.AddressBook{
+		User:            common.HexToAddress("0x03"),
+		ModuleDirectory: common.HexToAddress("0x04"),
+		Storage:         common.HexToAddress("0x05"),
+	}
+	alice = common.HexToAddress("0xa1")
+	bob   = common.HexToAddress("0xb0")
+)
+
+// eventLog returns a log of the named event of contract with args in input
+// order, indexed ones moved to the topics.
+func eventLog(t *testing.T, contract string, addr common.Address, event string, block uint64, args ...interface{}) types.Log {
+	t.Helper()
+	parsed, _ := client.ContractABI(contract)
+	ev := parsed.Events[event]
+	var indexed [][]interface{}
+	var data []interface{}
+	for i, arg := range ev.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, []interface{}{args[i]})
+		} else {
+			data = append(data, args[i])
+		}
+	}
+	topics, err := abi.MakeTopics(indexed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := ev.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := types.Log{Address: addr, Topics: []common.Hash{ev.ID}, Data: packed, BlockNumber: block}
+	for _, topic := range topics {
+		log.Topics = append(log.Topics, topic[0])
+	}
+	return log
+}
+
+// newServer returns a Server over a store holding two deals of alice, a
+// result and validation of the first, alice's user record and a module she
+// transferred to bob.
+func newServer(t *testing.T) *Server {
+	t.Helper()
+	ctx := context.Background()
+	store, err := sqlite.Open(ctx, filepath.Join(t.TempDir(), "lilypad.db"), book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for i, id := range []string{"d1", "d2"} {
+		err := store.PutDeal(ctx, indexer.Deal{
+			SharedStructsDeal: lilypadstorage.SharedStructsDeal{
+				DealId: id, JobCreator: alice, ResourceProvider: bob, Status: 1, Timestamp: big.NewInt(int64(100 + i)),
+			},
+			Block: uint64(10 + i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.PutResult(ctx, indexer.Result{SharedStructsResult: lilypadstorage.SharedStructsResult{
+		ResultId: "r1", DealId: "d1", Timestamp: big.NewInt(200),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutValidationResult(ctx, indexer.ValidationResult{SharedStructsValidationResult: lilypadstorage.SharedStructsValidationResult{
+		ValidationResultId: "v1", ResultId: "r1", Validator: bob, Timestamp: big.NewInt(300),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	userEvent := "LilypadUser__UserManagementEvent"
+	moduleEvent := func(name string) string { return "LilypadModuleDirectory__" + name }
+	logs := []types.Log{
+		eventLog(t, "LilypadUser", book.User, userEvent, 1, alice, "meta", "https://alice", uint8(roles.JobCreator), uint8(roles.NewUser)),
+		eventLog(t, "LilypadUser", book.User, userEvent, 2, alice, "meta", "https://alice", uint8(roles.ModuleCreator), uint8(roles.RoleAdded)),
+		eventLog(t, "LilypadModuleDirectory", book.ModuleDirectory, moduleEvent("ModuleRegistered"), 3, alice, "cowsay", "https://a/cowsay"),
+		eventLog(t, "LilypadModuleDirectory", book.ModuleDirectory, moduleEvent("ModuleRegistered"), 4, alice, "sdxl", "https://a/sdxl"),
+		eventLog(t, "LilypadModuleDirectory", book.ModuleDirectory, moduleEvent("ModuleTransferred"), 5, bob, alice, "sdxl", "https://a/sdxl"),
+	}
+	for _, log := range logs {
+		if err := store.InsertLog(ctx, log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := client.New(nil, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(store, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+type response struct {
+	Data   json.RawMessage
+	Errors []struct{ Message string }
+}
+
+// query posts q to the handler and returns its response.
+func query(t *testing.T, s *Server, q string) response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": q})
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// equalJSON compares got with want, ignoring formatting.
+func equalJSON(t *testing.T, got json.RawMessage, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestDealWalk(t *testing.T) {
+	s := newServer(t)
+	resp := query(t, s, `{
+		deal(id: "d1") {
+			id
+			timestamp
+			jobCreator { address registered url roles { name deals { nodes { id } } } modules { name } }
+			results { nodes { id validations { nodes { id validator { address registered } } } } }
+		}
+		missing: deal(id: "nope") { id }
+	}`)
+	if len(resp.Errors) != 0 {
+		t.Fatalf("errors: %+v", resp.Errors)
+	}
+	equalJSON(t, resp.Data, `{
+		"deal": {
+			"id": "d1",
+			"timestamp": 100,
+			"jobCreator": {
+				"address": "`+alice.Hex()+`",
+				"registered": true,
+				"url": "https://alice",
+				"roles": [
+					{"name": "ModuleCreator", "deals": {"nodes": []}},
+					{"name": "JobCreator", "deals": {"nodes": [{"id": "d1"}, {"id": "d2"}]}}
+				],
+				"modules": [{"name": "cowsay"}]
+			},
+			"results": {"nodes": [{"id": "r1", "validations": {"nodes": [
+				{"id": "v1", "validator": {"address": "`+bob.Hex()+`", "registered": false}}
+			]}}]}
+		},
+		"missing": null
+	}`)
+}
+
+func TestPaging(t *testing.T) {
+	s := newServer(t)
+	resp := query(t, s, `{ deals(first: 1) { nodes { id } pageInfo { hasNextPage endCursor } } }`)
+	var page struct {
+		Deals struct {
+			Nodes    []struct{ ID string }
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   string
+			}
+		}
+	}
+	if err := json.Unmarshal(resp.Data, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Deals.Nodes) != 1 || page.Deals.Nodes[0].ID != "d1" || !page.Deals.PageInfo.HasNextPage {
+		t.Fatalf("first page = %s", resp.Data)
+	}
+	resp = query(t, s, `{ deals(first: 1, after: "`+page.Deals.PageInfo.EndCursor+`") { nodes { id } pageInfo { hasNextPage } } }`)
+	equalJSON(t, resp.Data, `{"deals": {"nodes": [{"id": "d2"}], "pageInfo": {"hasNextPage": false}}}`)
+
+	for _, q := range []string{
+		`{ deals(first: -1) { nodes { id } } }`,
+		`{ deals(after: "bm9wZQ==") { nodes { id } } }`,
+		`{ user(address: "nope") { address } }`,
+		`{ users(role: 9) { nodes { address } } }`,
+	} {
+		if resp := query(t, s, q); len(resp.Errors) == 0 {
+			t.Errorf("%s succeeded", q)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	s := newServer(t)
+	resp := query(t, s, `{ modules { nodes { owner { address } name block } } }`)
+	equalJSON(t, resp.Data, `{"modules": {"nodes": [
+		{"owner": {"address": "`+alice.Hex()+`"}, "name": "cowsay", "block": 3},
+		{"owner": {"address": "`+bob.Hex()+`"}, "name": "sdxl", "block": 5}
+	]}}`)
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	s := newServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	responses, err := s.Schema().Subscribe(ctx, `subscription { blocks { number rollback events { contract name removed } } }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.subs)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	log := eventLog(t, "LilypadModuleDirectory", book.ModuleDirectory, "LilypadModuleDirectory__ModuleRegistered", 7, alice, "m", "u")
+	log.Removed = true
+	s.Publish(follower.Update{BlockRef: follower.BlockRef{Number: 7}, Rollback: true, Logs: []types.Log{log}})
+	select {
+	case r := <-responses:
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		equalJSON(t, data, `{"data": {"blocks": {"number": 7, "rollback": true, "events": [
+			{"contract": "LilypadModuleDirectory", "name": "LilypadModuleDirectory__ModuleRegistered", "removed": true}
+		]}}}`)
+	case <-time.After(time.Second):
+		t.Fatal("no block delivered")
+	}
+}
@@ -0,0 +1,147 @@
+package ids
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+)
+
+var (
+	// ErrCollision is returned when an ID is already saved in LilypadStorage.
+	ErrCollision = errors.New("ids: id already exists in storage")
+	// ErrDuplicate is returned when an ID has already been reserved by this
+	// checker but not yet confirmed or released.
+	ErrDuplicate = errors.New("ids: id already reserved")
+	// ErrEmpty is returned for the empty ID, which storage always rejects.
+	ErrEmpty = errors.New("ids: empty id")
+)
+
+// Storage is the subset of the LilypadStorage binding the checker reads. It is
+// satisfied by *lilypadstorage.LilypadStorageCaller.
+type Storage interface {
+	GetDeal(opts *bind.CallOpts, dealId string) (lilypadstorage.SharedStructsDeal, error)
+	GetResult(opts *bind.CallOpts, resultId string) (lilypadstorage.SharedStructsResult, error)
+	GetValidationResult(opts *bind.CallOpts, validationResultId string) (lilypadstorage.SharedStructsValidationResult, error)
+}
+
+// notFoundErrors maps each kind to the custom error LilypadStorage reverts with
+// when no record exists for the ID.
+var notFoundErrors = map[Kind]string{
+	KindDeal:             "LilypadStorage__DealNotFound",
+	KindResult:           "LilypadStorage__ResultNotFound",
+	KindValidationResult: "LilypadStorage__ValidationResultNotFound",
+}
+
+type key struct {
+	kind Kind
+	id   string
+}
+
+// Checker verifies that IDs are free before they are submitted and tracks IDs
+// that are in flight, so the same ID cannot be handed out twice while its
+// transaction is still pending.
+type Checker struct {
+	storage Storage
+	abi     *abi.ABI
+	// from is the account the getters are called from. The LilypadStorage
+	// getters are restricted to CONTROLLER_ROLE, so this must be a controller.
+	from common.Address
+
+	mu       sync.Mutex
+	reserved map[key]struct{}
+}
+
+// NewChecker returns a Checker reading storage as the controller account from.
+func NewChecker(storage Storage, from common.Address) (*Checker, error) {
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{
+		storage:  storage,
+		abi:      parsed,
+		from:     from,
+		reserved: make(map[key]struct{}),
+	}, nil
+}
+
+// Exists reports whether a record with the given ID is saved in storage.
+// DealNotFound, ResultNotFound and ValidationResultNotFound reverts mean the
+// ID is free; any other error is returned as is.
+func (c *Checker) Exists(ctx context.Context, kind Kind, id string) (bool, error) {
+	if id == "" {
+		return false, ErrEmpty
+	}
+	opts := &bind.CallOpts{Context: ctx, From: c.from}
+	var err error
+	switch kind {
+	case KindDeal:
+		_, err = c.storage.GetDeal(opts, id)
+	case KindResult:
+		_, err = c.storage.GetResult(opts, id)
+	case KindValidationResult:
+		_, err = c.storage.GetValidationResult(opts, id)
+	default:
+		return false, fmt.Errorf("ids: unknown kind %d", kind)
+	}
+	if err == nil {
+		return true, nil
+	}
+	if revert.Is(err, notFoundErrors[kind], c.abi) {
+		return false, nil
+	}
+	return false, fmt.Errorf("ids: looking up %s %q: %w", kind, id, err)
+}
+
+// Check returns ErrCollision if the ID is saved in storage and ErrDuplicate if
+// it is currently reserved.
+func (c *Checker) Check(ctx context.Context, kind Kind, id string) error {
+	c.mu.Lock()
+	_, reserved := c.reserved[key{kind, id}]
+	c.mu.Unlock()
+	if reserved {
+		return fmt.Errorf("%w: %s %q", ErrDuplicate, kind, id)
+	}
+	exists, err := c.Exists(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s %q", ErrCollision, kind, id)
+	}
+	return nil
+}
+
+// Reserve checks the ID and, if it is free, marks it as in flight until
+// Release is called. Callers reserve an ID right before submitting the
+// transaction that saves it and release it once the transaction is mined or
+// has failed.
+func (c *Checker) Reserve(ctx context.Context, kind Kind, id string) error {
+	if err := c.Check(ctx, kind, id); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := key{kind, id}
+	// Another goroutine may have reserved the ID while storage was queried.
+	if _, ok := c.reserved[k]; ok {
+		return fmt.Errorf("%w: %s %q", ErrDuplicate, kind, id)
+	}
+	c.reserved[k] = struct{}{}
+	return nil
+}
+
+// Release forgets a reservation made with Reserve.
+func (c *Checker) Release(kind Kind, id string) {
+	c.mu.Lock()
+	delete(c.reserved, key{kind, id})
+	c.mu.Unlock()
+}
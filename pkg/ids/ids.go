@@ -0,0 +1,130 @@
+// Package ids derives deterministic deal, result and validation result IDs
+// and checks them against LilypadStorage before they are submitted.
+//
+// LilypadStorage keys its records by arbitrary strings and silently
+// overwrites an existing record when the same ID is saved twice, so every ID
+// the controller submits should come from this package.
+package ids
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Kind identifies which LilypadStorage mapping an ID belongs to.
+type Kind uint8
+
+const (
+	KindDeal Kind = iota
+	KindResult
+	KindValidationResult
+)
+
+// String implements fmt.Stringer.
+func (k Kind) String() string {
+	switch k {
+	case KindDeal:
+		return "deal"
+	case KindResult:
+		return "result"
+	case KindValidationResult:
+		return "validation result"
+	}
+	return fmt.Sprintf("kind(%d)", uint8(k))
+}
+
+// Domain tags keep IDs of different kinds from ever colliding with each
+// other, even when they are derived from the same inputs.
+const (
+	dealDomain             = "lilypad.deal.v1"
+	resultDomain           = "lilypad.result.v1"
+	validationResultDomain = "lilypad.validation-result.v1"
+)
+
+// Deal holds the inputs a deal ID is derived from.
+type Deal struct {
+	JobOfferCID      string
+	ResourceOfferCID string
+	JobCreator       common.Address
+	ResourceProvider common.Address
+	ModuleCreator    common.Address
+	Solver           common.Address
+	// Nonce distinguishes otherwise identical deals, e.g. a job creator
+	// re-submitting the same job offer to the same resource provider.
+	Nonce uint64
+}
+
+// Result holds the inputs a result ID is derived from.
+type Result struct {
+	DealID    string
+	ResultCID string
+	Nonce     uint64
+}
+
+// ValidationResult holds the inputs a validation result ID is derived from.
+type ValidationResult struct {
+	ResultID      string
+	ValidationCID string
+	Validator     common.Address
+	Nonce         uint64
+}
+
+var (
+	stringType, _  = abi.NewType("string", "", nil)
+	addressType, _ = abi.NewType("address", "", nil)
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+
+	dealArgs = abi.Arguments{
+		{Type: stringType}, {Type: stringType}, {Type: stringType},
+		{Type: addressType}, {Type: addressType}, {Type: addressType}, {Type: addressType},
+		{Type: uint256Type},
+	}
+	resultArgs = abi.Arguments{
+		{Type: stringType}, {Type: stringType}, {Type: stringType}, {Type: uint256Type},
+	}
+	validationResultArgs = abi.Arguments{
+		{Type: stringType}, {Type: stringType}, {Type: stringType}, {Type: addressType}, {Type: uint256Type},
+	}
+)
+
+// DealID returns the deterministic ID for a deal.
+func DealID(d Deal) string {
+	return derive(dealArgs,
+		dealDomain, d.JobOfferCID, d.ResourceOfferCID,
+		d.JobCreator, d.ResourceProvider, d.ModuleCreator, d.Solver,
+		new(big.Int).SetUint64(d.Nonce),
+	)
+}
+
+// ResultID returns the deterministic ID for a result.
+func ResultID(r Result) string {
+	return derive(resultArgs,
+		resultDomain, r.DealID, r.ResultCID,
+		new(big.Int).SetUint64(r.Nonce),
+	)
+}
+
+// ValidationResultID returns the deterministic ID for a validation result.
+func ValidationResultID(v ValidationResult) string {
+	return derive(validationResultArgs,
+		validationResultDomain, v.ResultID, v.ValidationCID, v.Validator,
+		new(big.Int).SetUint64(v.Nonce),
+	)
+}
+
+// derive hashes the ABI encoding of values. ABI encoding length-prefixes
+// strings, so unlike plain concatenation two different inputs can never
+// produce the same preimage.
+func derive(args abi.Arguments, values ...interface{}) string {
+	packed, err := args.Pack(values...)
+	if err != nil {
+		// The argument lists above are fixed, so this is a programming error.
+		panic(fmt.Sprintf("ids: packing id inputs: %v", err))
+	}
+	return hexutil.Encode(crypto.Keccak256(packed))
+}
@@ -0,0 +1,144 @@
+package ids
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+)
+
+func TestIDsAreDeterministic(t *testing.T) {
+	deal := Deal{
+		JobOfferCID:      "job",
+		ResourceOfferCID: "resource",
+		JobCreator:       common.HexToAddress("0x01"),
+		ResourceProvider: common.HexToAddress("0x02"),
+		ModuleCreator:    common.HexToAddress("0x03"),
+		Solver:           common.HexToAddress("0x04"),
+	}
+	id := DealID(deal)
+	if id != DealID(deal) {
+		t.Fatal("DealID differs between calls")
+	}
+	if len(id) != 66 {
+		t.Errorf("DealID = %q, want a 32 byte hex hash", id)
+	}
+	// Changing any input changes the ID.
+	for name, d := range map[string]Deal{
+		"nonce":     func() Deal { d := deal; d.Nonce = 1; return d }(),
+		"solver":    func() Deal { d := deal; d.Solver = common.HexToAddress("0x05"); return d }(),
+		"split cid": func() Deal { d := deal; d.JobOfferCID, d.ResourceOfferCID = "jobr", "esource"; return d }(),
+	} {
+		if DealID(d) == id {
+			t.Errorf("DealID unchanged with a different %s", name)
+		}
+	}
+	// The domain tags keep kinds apart even with the same inputs.
+	if ResultID(Result{DealID: "a", ResultCID: "b"}) == ValidationResultID(ValidationResult{ResultID: "a", ValidationCID: "b"}) {
+		t.Error("result and validation result IDs collide")
+	}
+}
+
+// revertError carries revert data the way go-ethereum's RPC errors do.
+type revertError []byte
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e) }
+
+// storageRevert returns a LilypadStorage revert with the error name and an
+// id argument.
+func storageRevert(t *testing.T, name string) error {
+	t.Helper()
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	abiErr, ok := parsed.Errors[name]
+	if !ok {
+		t.Fatalf("no error %s", name)
+	}
+	packed, err := abiErr.Inputs.Pack("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revertError(append(abiErr.ID[:4:4], packed...))
+}
+
+// fakeStorage answers every getter with err.
+type fakeStorage struct{ err error }
+
+func (f fakeStorage) GetDeal(*bind.CallOpts, string) (lilypadstorage.SharedStructsDeal, error) {
+	return lilypadstorage.SharedStructsDeal{}, f.err
+}
+
+func (f fakeStorage) GetResult(*bind.CallOpts, string) (lilypadstorage.SharedStructsResult, error) {
+	return lilypadstorage.SharedStructsResult{}, f.err
+}
+
+func (f fakeStorage) GetValidationResult(*bind.CallOpts, string) (lilypadstorage.SharedStructsValidationResult, error) {
+	return lilypadstorage.SharedStructsValidationResult{}, f.err
+}
+
+func TestCheckerExists(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    Kind
+		err     error
+		exists  bool
+		wantErr bool
+	}{
+		{"saved deal", KindDeal, nil, true, false},
+		{"free deal", KindDeal, storageRevert(t, "LilypadStorage__DealNotFound"), false, false},
+		{"free result", KindResult, storageRevert(t, "LilypadStorage__ResultNotFound"), false, false},
+		{"free validation result", KindValidationResult, storageRevert(t, "LilypadStorage__ValidationResultNotFound"), false, false},
+		// Only the not found error of the kind looked up means free.
+		{"deal with result revert", KindDeal, storageRevert(t, "LilypadStorage__ResultNotFound"), false, true},
+		{"other error", KindDeal, errors.New("connection refused"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewChecker(fakeStorage{tt.err}, common.Address{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exists, err := c.Exists(context.Background(), tt.kind, "id")
+			if exists != tt.exists || (err != nil) != tt.wantErr {
+				t.Errorf("Exists = %v, %v; want %v, error %v", exists, err, tt.exists, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckerReserve(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewChecker(fakeStorage{storageRevert(t, "LilypadStorage__DealNotFound")}, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reserve(ctx, KindDeal, "id"); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := c.Reserve(ctx, KindDeal, "id"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("second Reserve = %v, want ErrDuplicate", err)
+	}
+	c.Release(KindDeal, "id")
+	if err := c.Reserve(ctx, KindDeal, "id"); err != nil {
+		t.Errorf("Reserve after Release: %v", err)
+	}
+	if err := c.Check(ctx, KindDeal, ""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Check of the empty id = %v, want ErrEmpty", err)
+	}
+
+	saved, err := NewChecker(fakeStorage{}, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.Reserve(ctx, KindDeal, "id"); !errors.Is(err, ErrCollision) {
+		t.Errorf("Reserve of a saved id = %v, want ErrCollision", err)
+	}
+}
@@ -0,0 +1,440 @@
+// Package indexer builds a queryable index of the deals, results and
+// validation results in LilypadStorage from its events. LilypadStorage keeps
+// them in private mappings keyed by ID, so the index is the only way to list
+// them by participant, status or time.
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/preimage"
+)
+
+// Names of the LilypadStorage events the indexer consumes.
+const (
+	EventDealSaved                     = "LilypadStorage__DealSaved"
+	EventDealStatusChanged             = "LilypadStorage__DealStatusChanged"
+	EventResultSaved                   = "LilypadStorage__ResultSaved"
+	EventResultStatusChanged           = "LilypadStorage__ResultStatusChanged"
+	EventValidationResultSaved         = "LilypadStorage__ValidationResultSaved"
+	EventValidationResultStatusChanged = "LilypadStorage__ValidationResultStatusChanged"
+)
+
+var events = []string{
+	EventDealSaved,
+	EventDealStatusChanged,
+	EventResultSaved,
+	EventResultStatusChanged,
+	EventValidationResultSaved,
+	EventValidationResultStatusChanged,
+}
+
+// Indexer feeds LilypadStorage logs into a Store, hydrating saved records
+// with the storage getters.
+type Indexer struct {
+	address  common.Address
+	backend  bind.ContractBackend
+	storage  *lilypadstorage.LilypadStorage
+	resolver *preimage.Resolver
+	store    Store
+	from     common.Address
+	ids      map[common.Hash]string
+}
+
+// New returns an Indexer for the storage contract of c. The storage getters
+// require CONTROLLER_ROLE, so from must hold it; it is only used as the
+// sender of eth_calls. resolver recovers the IDs behind indexed topics.
+func New(c *client.Client, store Store, resolver *preimage.Resolver, from common.Address) (*Indexer, error) {
+	if c.Storage == nil {
+		return nil, errors.New("indexer: client has no storage contract")
+	}
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[common.Hash]string, len(events))
+	for _, name := range events {
+		ids[parsed.Events[name].ID] = name
+	}
+	return &Indexer{
+		address:  c.Addresses.Storage,
+		backend:  c.Backend,
+		storage:  c.Storage,
+		resolver: resolver,
+		store:    store,
+		from:     from,
+		ids:      ids,
+	}, nil
+}
+
+// Store returns the store the indexer writes to.
+func (ix *Indexer) Store() Store {
+	return ix.store
+}
+
+// FilterQuery returns the log filter selecting the indexed events between
+// from and to, inclusive. A nil to means the latest block.
+func (ix *Indexer) FilterQuery(from uint64, to *uint64) ethereum.FilterQuery {
+	topics := make([]common.Hash, 0, len(ix.ids))
+	for id := range ix.ids {
+		topics = append(topics, id)
+	}
+	q := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		Addresses: []common.Address{ix.address},
+		Topics:    [][]common.Hash{topics},
+	}
+	if to != nil {
+		q.ToBlock = new(big.Int).SetUint64(*to)
+	}
+	return q
+}
+
+// CheckpointKey is the key under which the indexer keeps its checkpoint in
+// a BlockStore.
+const CheckpointKey = "indexer:LilypadStorage"
+
+// Sync indexes the events between from and to, inclusive. With a BlockStore
+// each block is applied atomically and blocks at or below the checkpoint are
+// skipped, so overlapping ranges do not index anything twice.
+func (ix *Indexer) Sync(ctx context.Context, from, to uint64) error {
+	logs, err := ix.backend.FilterLogs(ctx, ix.FilterQuery(from, &to))
+	if err != nil {
+		return fmt.Errorf("indexer: filtering logs: %w", err)
+	}
+	if err := ix.IndexLogs(ctx, logs); err != nil {
+		return err
+	}
+	bs, ok := ix.store.(BlockStore)
+	if !ok {
+		return nil
+	}
+	// Advance the checkpoint over the blocks without events.
+	return bs.ApplyBlock(ctx, CheckpointKey, to, func(context.Context) error { return nil })
+}
+
+// IndexLogs indexes logs fetched by the caller, e.g. from a backfill.Run
+// chunk, which must be ordered by block. With a BlockStore each block is
+// applied atomically, as in Sync, but the checkpoint only advances to the
+// last block with logs. Removed logs are handled on their own, between
+// blocks.
+func (ix *Indexer) IndexLogs(ctx context.Context, logs []types.Log) error {
+	bs, ok := ix.store.(BlockStore)
+	if !ok {
+		for _, log := range logs {
+			if err := ix.HandleLog(ctx, log); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for start := 0; start < len(logs); {
+		if logs[start].Removed {
+			if err := ix.HandleLog(ctx, logs[start]); err != nil {
+				return err
+			}
+			start++
+			continue
+		}
+		end := start
+		for end < len(logs) && logs[end].BlockNumber == logs[start].BlockNumber && !logs[end].Removed {
+			end++
+		}
+		if err := ix.apply(ctx, bs, logs[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// Resume syncs up to to, starting after the store's checkpoint or at start
+// if there is none. The store must be a BlockStore.
+func (ix *Indexer) Resume(ctx context.Context, start, to uint64) error {
+	bs, ok := ix.store.(BlockStore)
+	if !ok {
+		return errors.New("indexer: store does not keep checkpoints")
+	}
+	done, ok, err := bs.Checkpoint(ctx, CheckpointKey)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if done >= to {
+			return nil
+		}
+		start = done + 1
+	}
+	return ix.Sync(ctx, start, to)
+}
+
+// apply indexes the logs of a single block in one BlockStore transaction.
+func (ix *Indexer) apply(ctx context.Context, bs BlockStore, logs []types.Log) error {
+	return bs.ApplyBlock(ctx, CheckpointKey, logs[0].BlockNumber, func(ctx context.Context) error {
+		for _, log := range logs {
+			if err := ix.HandleLog(ctx, log); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flushInterval is how often Watch checks whether the head has moved past
+// its pending block.
+var flushInterval = 2 * time.Second
+
+// Watch indexes new events as they arrive until ctx is cancelled or the
+// subscription fails. With a BlockStore the logs of a block are buffered
+// and applied once a log from a later block arrives or the head moves past
+// the block, whichever is first; when ctx is cancelled before that, the
+// block is left for Resume. Logs removed by a reorg are rolled back as
+// HandleLog describes.
+func (ix *Indexer) Watch(ctx context.Context) error {
+	logs := make(chan types.Log)
+	q := ix.FilterQuery(0, nil)
+	q.FromBlock = nil
+	sub, err := ix.backend.SubscribeFilterLogs(ctx, q, logs)
+	if err != nil {
+		return fmt.Errorf("indexer: subscribing to logs: %w", err)
+	}
+	defer sub.Unsubscribe()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	bs, batched := ix.store.(BlockStore)
+	var pending []types.Log
+	flush := func(ctx context.Context) error {
+		if len(pending) == 0 {
+			return nil
+		}
+		head, err := ix.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("indexer: reading head: %w", err)
+		}
+		// Logs of the head block may still be in flight.
+		if head.Number.Uint64() <= pending[0].BlockNumber {
+			return nil
+		}
+		if err := ix.apply(ctx, bs, pending); err != nil {
+			return err
+		}
+		pending = pending[:0]
+		return nil
+	}
+	for {
+		select {
+		case log := <-logs:
+			switch {
+			case !batched:
+				if err := ix.HandleLog(ctx, log); err != nil {
+					return err
+				}
+			case log.Removed:
+				if len(pending) > 0 && pending[0].BlockNumber >= log.BlockNumber {
+					pending = pending[:0]
+				}
+				if err := ix.HandleLog(ctx, log); err != nil {
+					return err
+				}
+			default:
+				if len(pending) > 0 && pending[0].BlockNumber != log.BlockNumber {
+					if err := ix.apply(ctx, bs, pending); err != nil {
+						return err
+					}
+					pending = pending[:0]
+				}
+				pending = append(pending, log)
+			}
+		case <-ticker.C:
+			if err := flush(ctx); err != nil {
+				return err
+			}
+		case err := <-sub.Err():
+			return err
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushInterval)
+			err := flush(fctx)
+			cancel()
+			if err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// HandleLog indexes a single log. Logs of other events are ignored. A log
+// removed by a reorg rolls back its block and everything after it if the
+// store is a RewindStore, and is ignored otherwise; it must then not be
+// handled inside ApplyBlock.
+func (ix *Indexer) HandleLog(ctx context.Context, log types.Log) error {
+	if len(log.Topics) == 0 || log.Address != ix.address {
+		return nil
+	}
+	if log.Removed {
+		rs, ok := ix.store.(RewindStore)
+		if !ok {
+			return nil
+		}
+		if err := rs.Rollback(ctx, log.BlockNumber); err != nil {
+			return fmt.Errorf("indexer: rolling back block %d: %w", log.BlockNumber, err)
+		}
+		return nil
+	}
+	var err error
+	switch ix.ids[log.Topics[0]] {
+	case EventDealSaved:
+		err = ix.dealSaved(ctx, log)
+	case EventDealStatusChanged:
+		err = ix.dealStatusChanged(ctx, log)
+	case EventResultSaved:
+		err = ix.resultSaved(ctx, log)
+	case EventResultStatusChanged:
+		err = ix.resultStatusChanged(ctx, log)
+	case EventValidationResultSaved:
+		err = ix.validationResultSaved(ctx, log)
+	case EventValidationResultStatusChanged:
+		err = ix.validationResultStatusChanged(ctx, log)
+	}
+	if err != nil {
+		return fmt.Errorf("indexer: block %d tx %s log %d: %w", log.BlockNumber, log.TxHash, log.Index, err)
+	}
+	return nil
+}
+
+// callOpts reads storage at the block of log, so that a record indexed
+// from history holds the status and timestamps it had then rather than
+// today's. Historical syncs therefore need a node that keeps state for the
+// synced range.
+func (ix *Indexer) callOpts(ctx context.Context, log types.Log) *bind.CallOpts {
+	return &bind.CallOpts{Context: ctx, From: ix.from, BlockNumber: new(big.Int).SetUint64(log.BlockNumber)}
+}
+
+func (ix *Indexer) dealSaved(ctx context.Context, log types.Log) error {
+	ev, err := ix.storage.ParseLilypadStorageDealSaved(log)
+	if err != nil {
+		return err
+	}
+	id, err := ix.resolver.Resolve(ctx, ev.DealId, log.TxHash)
+	if err != nil {
+		return err
+	}
+	return ix.hydrateDeal(ctx, id, log)
+}
+
+func (ix *Indexer) hydrateDeal(ctx context.Context, id string, log types.Log) error {
+	deal, err := ix.storage.GetDeal(ix.callOpts(ctx, log), id)
+	if err != nil {
+		return fmt.Errorf("hydrating deal %q: %w", id, err)
+	}
+	return ix.store.PutDeal(ctx, Deal{SharedStructsDeal: deal, Block: log.BlockNumber, TxHash: log.TxHash})
+}
+
+func (ix *Indexer) dealStatusChanged(ctx context.Context, log types.Log) error {
+	ev, err := ix.storage.ParseLilypadStorageDealStatusChanged(log)
+	if err != nil {
+		return err
+	}
+	id, err := ix.resolver.Resolve(ctx, ev.DealId, log.TxHash)
+	if err != nil {
+		return err
+	}
+	err = ix.store.SetDealStatus(ctx, id, ev.Status)
+	if errors.Is(err, ErrNotFound) {
+		return ix.hydrateDeal(ctx, id, log)
+	}
+	return err
+}
+
+func (ix *Indexer) resultSaved(ctx context.Context, log types.Log) error {
+	ev, err := ix.storage.ParseLilypadStorageResultSaved(log)
+	if err != nil {
+		return err
+	}
+	if err := ix.resolver.Learn(ctx, ev.DealId); err != nil {
+		return err
+	}
+	id, err := ix.resolver.Resolve(ctx, ev.ResultId, log.TxHash)
+	if err != nil {
+		return err
+	}
+	return ix.hydrateResult(ctx, id, log)
+}
+
+func (ix *Indexer) hydrateResult(ctx context.Context, id string, log types.Log) error {
+	result, err := ix.storage.GetResult(ix.callOpts(ctx, log), id)
+	if err != nil {
+		return fmt.Errorf("hydrating result %q: %w", id, err)
+	}
+	return ix.store.PutResult(ctx, Result{SharedStructsResult: result, Block: log.BlockNumber, TxHash: log.TxHash})
+}
+
+func (ix *Indexer) resultStatusChanged(ctx context.Context, log types.Log) error {
+	ev, err := ix.storage.ParseLilypadStorageResultStatusChanged(log)
+	if err != nil {
+		return err
+	}
+	id, err := ix.resolver.Resolve(ctx, ev.ResultId, log.TxHash)
+	if err != nil {
+		return err
+	}
+	err = ix.store.SetResultStatus(ctx, id, ev.Status)
+	if errors.Is(err, ErrNotFound) {
+		return ix.hydrateResult(ctx, id, log)
+	}
+	return err
+}
+
+func (ix *Indexer) validationResultSaved(ctx context.Context, log types.Log) error {
+	ev, err := ix.storage.ParseLilypadStorageValidationResultSaved(log)
+	if err != nil {
+		return err
+	}
+	if err := ix.resolver.Learn(ctx, ev.ResultId); err != nil {
+		return err
+	}
+	id, err := ix.resolver.Resolve(ctx, ev.ValidationResultId, log.TxHash)
+	if err != nil {
+		return err
+	}
+	return ix.hydrateValidationResult(ctx, id, log)
+}
+
+func (ix *Indexer) hydrateValidationResult(ctx context.Context, id string, log types.Log) error {
+	validation, err := ix.storage.GetValidationResult(ix.callOpts(ctx, log), id)
+	if err != nil {
+		return fmt.Errorf("hydrating validation result %q: %w", id, err)
+	}
+	return ix.store.PutValidationResult(ctx, ValidationResult{
+		SharedStructsValidationResult: validation,
+		Block:                         log.BlockNumber,
+		TxHash:                        log.TxHash,
+	})
+}
+
+func (ix *Indexer) validationResultStatusChanged(ctx context.Context, log types.Log) error {
+	ev, err := ix.storage.ParseLilypadStorageValidationResultStatusChanged(log)
+	if err != nil {
+		return err
+	}
+	id, err := ix.resolver.Resolve(ctx, ev.ValidationResultId, log.TxHash)
+	if err != nil {
+		return err
+	}
+	err = ix.store.SetValidationResultStatus(ctx, id, ev.Status)
+	if errors.Is(err, ErrNotFound) {
+		return ix.hydrateValidationResult(ctx, id, log)
+	}
+	return err
+}
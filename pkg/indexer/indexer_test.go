@@ -0,0 +1,416 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/preimage"
+)
+
+var storageAddr = common.HexToAddress("0x05")
+
+// fakeChain serves the storage getters from its records and the log
+// subscription from a channel the test writes to.
+type fakeChain struct {
+	bind.ContractBackend
+	abi *abi.ABI
+
+	deals       map[string]lilypadstorage.SharedStructsDeal
+	results     map[string]lilypadstorage.SharedStructsResult
+	validations map[string]lilypadstorage.SharedStructsValidationResult
+
+	mu    sync.Mutex
+	head  uint64
+	calls []uint64 // block of every getter call
+	sub   chan chan<- types.Log
+}
+
+func newFakeChain(t *testing.T) *fakeChain {
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeChain{
+		abi:         parsed,
+		deals:       make(map[string]lilypadstorage.SharedStructsDeal),
+		results:     make(map[string]lilypadstorage.SharedStructsResult),
+		validations: make(map[string]lilypadstorage.SharedStructsValidationResult),
+		sub:         make(chan chan<- types.Log, 1),
+	}
+}
+
+func (f *fakeChain) CallContract(_ context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	method, err := f.abi.MethodById(call.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, block.Uint64())
+	f.mu.Unlock()
+	id := args[0].(string)
+	var record interface{}
+	var ok bool
+	switch method.Name {
+	case "getDeal":
+		record, ok = f.deals[id]
+	case "getResult":
+		record, ok = f.results[id]
+	case "getValidationResult":
+		record, ok = f.validations[id]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s(%q): not found", method.Name, id)
+	}
+	return method.Outputs.Pack(record)
+}
+
+func (f *fakeChain) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &types.Header{Number: new(big.Int).SetUint64(f.head)}, nil
+}
+
+func (f *fakeChain) setHead(n uint64) {
+	f.mu.Lock()
+	f.head = n
+	f.mu.Unlock()
+}
+
+func (f *fakeChain) SubscribeFilterLogs(_ context.Context, _ ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	f.sub <- ch
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	}), nil
+}
+
+// log returns a log of the storage event name with id as its indexed ID and
+// args as the non-indexed inputs.
+func (f *fakeChain) log(t *testing.T, block uint64, index uint, name, id string, args ...interface{}) types.Log {
+	t.Helper()
+	ev := f.abi.Events[name]
+	data, err := ev.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := []common.Hash{ev.ID, crypto.Keccak256Hash([]byte(id))}
+	if name == EventDealSaved {
+		deal := f.deals[id]
+		topics = append(topics, common.BytesToHash(deal.JobCreator.Bytes()), common.BytesToHash(deal.ResourceProvider.Bytes()))
+	}
+	return types.Log{
+		Address:     storageAddr,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: block,
+		Index:       index,
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(block)),
+	}
+}
+
+// blockStore is a MemoryStore that records the blocks applied and rolled
+// back. Rollback only rewinds the checkpoint.
+type blockStore struct {
+	*MemoryStore
+
+	mu        sync.Mutex
+	done      uint64
+	ok        bool
+	applied   []uint64
+	rollbacks []uint64
+}
+
+func (s *blockStore) Checkpoint(context.Context, string) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done, s.ok, nil
+}
+
+func (s *blockStore) ApplyBlock(ctx context.Context, _ string, block uint64, fn func(context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ok && block <= s.done {
+		return nil
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	s.done, s.ok = block, true
+	s.applied = append(s.applied, block)
+	return nil
+}
+
+func (s *blockStore) Rollback(_ context.Context, block uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollbacks = append(s.rollbacks, block)
+	if s.ok && s.done >= block {
+		s.done = block - 1
+	}
+	return nil
+}
+
+func (s *blockStore) state() (applied, rollbacks []uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64(nil), s.applied...), append([]uint64(nil), s.rollbacks...)
+}
+
+func newIndexer(t *testing.T, chain *fakeChain, store Store, ids ...string) *Indexer {
+	t.Helper()
+	c, err := client.New(chain, client.AddressBook{Storage: storageAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := preimage.NewResolver(nil)
+	if err := resolver.Learn(context.Background(), ids...); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := New(c, store, resolver, common.HexToAddress("0xc0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+func seed(chain *fakeChain) {
+	chain.deals["d1"] = lilypadstorage.SharedStructsDeal{
+		DealId:           "d1",
+		JobCreator:       common.HexToAddress("0xa1"),
+		ResourceProvider: common.HexToAddress("0xa2"),
+		Timestamp:        big.NewInt(100),
+		PaymentStructure: lilypadstorage.SharedStructsDealPaymentStructure{
+			JobCreatorSolverFee:       new(big.Int),
+			ResourceProviderSolverFee: new(big.Int),
+			NetworkCongestionFee:      new(big.Int),
+			ModuleCreatorFee:          new(big.Int),
+			PriceOfJobWithoutFees:     new(big.Int),
+		},
+	}
+	chain.results["r1"] = lilypadstorage.SharedStructsResult{ResultId: "r1", DealId: "d1", Status: 1, Timestamp: big.NewInt(200)}
+	chain.validations["v1"] = lilypadstorage.SharedStructsValidationResult{ValidationResultId: "v1", ResultId: "r1", Status: 1, Timestamp: big.NewInt(300)}
+}
+
+func TestIndexLogs(t *testing.T) {
+	ctx := context.Background()
+	chain := newFakeChain(t)
+	seed(chain)
+	store := NewMemoryStore()
+	ix := newIndexer(t, chain, store, "d1", "r1", "v1")
+
+	other := chain.log(t, 2, 0, EventDealStatusChanged, "d1", uint8(3))
+	other.Address = common.HexToAddress("0x99")
+	logs := []types.Log{
+		other,
+		chain.log(t, 3, 0, EventDealSaved, "d1"),
+		chain.log(t, 4, 0, EventResultSaved, "r1", "d1"),
+		chain.log(t, 5, 0, EventDealStatusChanged, "d1", uint8(2)),
+		// v1 was never saved in the range, so its status change hydrates it.
+		chain.log(t, 6, 0, EventValidationResultStatusChanged, "v1", uint8(2)),
+	}
+	chain.validations["v1"] = lilypadstorage.SharedStructsValidationResult{ValidationResultId: "v1", ResultId: "r1", Status: 2, Timestamp: big.NewInt(300)}
+	if err := ix.IndexLogs(ctx, logs); err != nil {
+		t.Fatal(err)
+	}
+
+	deal, err := store.Deal(ctx, "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deal.Status != 2 || deal.Block != 3 || deal.JobCreator != common.HexToAddress("0xa1") {
+		t.Errorf("deal = status %d block %d creator %s", deal.Status, deal.Block, deal.JobCreator.Hex())
+	}
+	if result, err := store.Result(ctx, "r1"); err != nil || result.Block != 4 {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+	if v, err := store.ValidationResult(ctx, "v1"); err != nil || v.Status != 2 || v.Block != 6 {
+		t.Errorf("validation = %+v, %v", v, err)
+	}
+	// Records are hydrated at the block of their log.
+	if want := []uint64{3, 4, 6}; !reflect.DeepEqual(chain.calls, want) {
+		t.Errorf("getters called at blocks %v, want %v", chain.calls, want)
+	}
+}
+
+func TestIndexLogsRollsBackRemoved(t *testing.T) {
+	ctx := context.Background()
+	chain := newFakeChain(t)
+	seed(chain)
+	store := &blockStore{MemoryStore: NewMemoryStore()}
+	ix := newIndexer(t, chain, store, "d1", "r1")
+
+	saved := chain.log(t, 3, 0, EventDealSaved, "d1")
+	removed := saved
+	removed.Removed = true
+	logs := []types.Log{
+		saved,
+		chain.log(t, 3, 1, EventDealStatusChanged, "d1", uint8(1)),
+		removed,
+		chain.log(t, 3, 0, EventDealSaved, "d1"),
+		chain.log(t, 4, 0, EventResultSaved, "r1", "d1"),
+	}
+	if err := ix.IndexLogs(ctx, logs); err != nil {
+		t.Fatal(err)
+	}
+	applied, rollbacks := store.state()
+	if want := []uint64{3, 3, 4}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied %v, want %v", applied, want)
+	}
+	if want := []uint64{3}; !reflect.DeepEqual(rollbacks, want) {
+		t.Errorf("rolled back %v, want %v", rollbacks, want)
+	}
+}
+
+func TestHandleLogIgnoresRemovedWithoutRewind(t *testing.T) {
+	chain := newFakeChain(t)
+	seed(chain)
+	ix := newIndexer(t, chain, NewMemoryStore(), "d1")
+	log := chain.log(t, 3, 0, EventDealSaved, "d1")
+	log.Removed = true
+	if err := ix.HandleLog(context.Background(), log); err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.calls) != 0 {
+		t.Errorf("removed log hydrated a record")
+	}
+}
+
+// watch starts ix.Watch, checking the head every interval, and returns the subscription channel and a function
+// cancelling the watch and returning its error.
+func watch(t *testing.T, chain *fakeChain, ix *Indexer, interval time.Duration) (chan<- types.Log, func() error) {
+	t.Helper()
+	saved := flushInterval
+	flushInterval = interval
+	t.Cleanup(func() { flushInterval = saved })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ix.Watch(ctx) }()
+	var logs chan<- types.Log
+	select {
+	case logs = <-chain.sub:
+	case err := <-done:
+		t.Fatalf("Watch: %v", err)
+	}
+	return logs, func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func waitApplied(t *testing.T, store *blockStore, want []uint64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		applied, _ := store.state()
+		if reflect.DeepEqual(applied, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("applied %v, want %v", applied, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchFlushesWhenHeadAdvances(t *testing.T) {
+	chain := newFakeChain(t)
+	seed(chain)
+	chain.setHead(5)
+	store := &blockStore{MemoryStore: NewMemoryStore()}
+	ix := newIndexer(t, chain, store, "d1")
+	logs, stop := watch(t, chain, ix, 5*time.Millisecond)
+
+	logs <- chain.log(t, 5, 0, EventDealSaved, "d1")
+	logs <- chain.log(t, 5, 1, EventDealStatusChanged, "d1", uint8(1))
+	time.Sleep(30 * time.Millisecond)
+	if applied, _ := store.state(); len(applied) != 0 {
+		t.Fatalf("head block applied before the head moved: %v", applied)
+	}
+	chain.setHead(6)
+	waitApplied(t, store, []uint64{5})
+	if deal, err := store.Deal(context.Background(), "d1"); err != nil || deal.Status != 1 {
+		t.Errorf("deal = %+v, %v", deal, err)
+	}
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch returned %v", err)
+	}
+}
+
+func TestWatchFlushesOnCancel(t *testing.T) {
+	for _, tc := range []struct {
+		head uint64
+		want []uint64
+	}{
+		{head: 7, want: nil},
+		{head: 8, want: []uint64{7}},
+	} {
+		chain := newFakeChain(t)
+		seed(chain)
+		chain.setHead(tc.head)
+		store := &blockStore{MemoryStore: NewMemoryStore()}
+		ix := newIndexer(t, chain, store, "d1")
+		// A long interval keeps the ticker out of the way.
+		logs, stop := watch(t, chain, ix, time.Hour)
+		logs <- chain.log(t, 7, 0, EventDealSaved, "d1")
+		if err := stop(); !errors.Is(err, context.Canceled) {
+			t.Errorf("head %d: Watch returned %v", tc.head, err)
+		}
+		if applied, _ := store.state(); !reflect.DeepEqual(applied, tc.want) {
+			t.Errorf("head %d: applied %v, want %v", tc.head, applied, tc.want)
+		}
+	}
+}
+
+func TestWatchRollsBackRemoved(t *testing.T) {
+	chain := newFakeChain(t)
+	seed(chain)
+	chain.setHead(6)
+	store := &blockStore{MemoryStore: NewMemoryStore()}
+	ix := newIndexer(t, chain, store, "d1", "r1")
+	logs, stop := watch(t, chain, ix, 5*time.Millisecond)
+
+	saved := chain.log(t, 5, 0, EventDealSaved, "d1")
+	result := chain.log(t, 6, 0, EventResultSaved, "r1", "d1")
+	logs <- saved
+	logs <- result
+	waitApplied(t, store, []uint64{5})
+
+	// A reorg replaces blocks 5 and 6: the pending block 6 is dropped and
+	// block 5 is rolled back before the new chain is applied.
+	for _, log := range []types.Log{result, saved} {
+		log.Removed = true
+		logs <- log
+	}
+	logs <- chain.log(t, 5, 0, EventDealSaved, "d1")
+	chain.setHead(7)
+	waitApplied(t, store, []uint64{5, 5})
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch returned %v", err)
+	}
+	if _, rollbacks := store.state(); !reflect.DeepEqual(rollbacks, []uint64{6, 5}) {
+		t.Errorf("rolled back %v, want [6 5]", rollbacks)
+	}
+	if _, err := store.Result(context.Background(), "r1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("result of the removed block 6 was indexed: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store held in memory.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	deals       map[string]Deal
+	results     map[string]Result
+	validations map[string]ValidationResult
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		deals:       make(map[string]Deal),
+		results:     make(map[string]Result),
+		validations: make(map[string]ValidationResult),
+	}
+}
+
+// PutDeal implements Store.
+func (s *MemoryStore) PutDeal(_ context.Context, deal Deal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deals[deal.DealId] = deal
+	return nil
+}
+
+// PutResult implements Store.
+func (s *MemoryStore) PutResult(_ context.Context, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[result.ResultId] = result
+	return nil
+}
+
+// PutValidationResult implements Store.
+func (s *MemoryStore) PutValidationResult(_ context.Context, validation ValidationResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validations[validation.ValidationResultId] = validation
+	return nil
+}
+
+// SetDealStatus implements Store.
+func (s *MemoryStore) SetDealStatus(_ context.Context, id string, status uint8) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deal, ok := s.deals[id]
+	if !ok {
+		return ErrNotFound
+	}
+	deal.Status = status
+	s.deals[id] = deal
+	return nil
+}
+
+// SetResultStatus implements Store.
+func (s *MemoryStore) SetResultStatus(_ context.Context, id string, status uint8) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[id]
+	if !ok {
+		return ErrNotFound
+	}
+	result.Status = status
+	s.results[id] = result
+	return nil
+}
+
+// SetValidationResultStatus implements Store.
+func (s *MemoryStore) SetValidationResultStatus(_ context.Context, id string, status uint8) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	validation, ok := s.validations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	validation.Status = status
+	s.validations[id] = validation
+	return nil
+}
+
+// Deal implements Store.
+func (s *MemoryStore) Deal(_ context.Context, id string) (Deal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	deal, ok := s.deals[id]
+	if !ok {
+		return Deal{}, ErrNotFound
+	}
+	return deal, nil
+}
+
+// Result implements Store.
+func (s *MemoryStore) Result(_ context.Context, id string) (Result, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result, ok := s.results[id]
+	if !ok {
+		return Result{}, ErrNotFound
+	}
+	return result, nil
+}
+
+// ValidationResult implements Store.
+func (s *MemoryStore) ValidationResult(_ context.Context, id string) (ValidationResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	validation, ok := s.validations[id]
+	if !ok {
+		return ValidationResult{}, ErrNotFound
+	}
+	return validation, nil
+}
+
+// Deals implements Store.
+func (s *MemoryStore) Deals(_ context.Context, q Query) ([]Deal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Deal
+	for _, deal := range s.deals {
+		if (q.DealID == "" || q.DealID == deal.DealId) && q.matchDeal(deal.SharedStructsDeal) &&
+			q.matchStatus(deal.Status) && q.matchTime(deal.Timestamp) {
+			out = append(out, deal)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return before(out[i].Timestamp, out[j].Timestamp, out[i].DealId, out[j].DealId)
+	})
+	return page(out, q.Offset, q.Limit), nil
+}
+
+// Results implements Store.
+func (s *MemoryStore) Results(_ context.Context, q Query) ([]Result, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Result
+	for _, result := range s.results {
+		if (q.ResultID == "" || q.ResultID == result.ResultId) && s.resultMatches(q, result) &&
+			q.matchStatus(result.Status) && q.matchTime(result.Timestamp) {
+			out = append(out, result)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return before(out[i].Timestamp, out[j].Timestamp, out[i].ResultId, out[j].ResultId)
+	})
+	return page(out, q.Offset, q.Limit), nil
+}
+
+// ValidationResults implements Store.
+func (s *MemoryStore) ValidationResults(_ context.Context, q Query) ([]ValidationResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []ValidationResult
+	for _, validation := range s.validations {
+		if !addrMatch(q.Validator, validation.Validator) || !q.matchStatus(validation.Status) ||
+			!q.matchTime(validation.Timestamp) {
+			continue
+		}
+		if q.ResultID != "" && q.ResultID != validation.ResultId {
+			continue
+		}
+		if q.DealID != "" || q.participants() {
+			result, ok := s.results[validation.ResultId]
+			if !ok || !s.resultMatches(q, result) {
+				continue
+			}
+		}
+		out = append(out, validation)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return before(out[i].Timestamp, out[j].Timestamp, out[i].ValidationResultId, out[j].ValidationResultId)
+	})
+	return page(out, q.Offset, q.Limit), nil
+}
+
+// resultMatches applies the deal filters of q to the deal of result.
+func (s *MemoryStore) resultMatches(q Query, result Result) bool {
+	if q.DealID != "" && q.DealID != result.DealId {
+		return false
+	}
+	if !q.participants() {
+		return true
+	}
+	deal, ok := s.deals[result.DealId]
+	return ok && q.matchDeal(deal.SharedStructsDeal)
+}
+
+func before(a, b *big.Int, idA, idB string) bool {
+	if c := cmpTimestamp(a, b); c != 0 {
+		return c < 0
+	}
+	return idA < idB
+}
+
+func cmpTimestamp(a, b *big.Int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Cmp(b)
+}
+
+func page[T any](records []T, offset, limit int) []T {
+	if offset > 0 {
+		if offset >= len(records) {
+			return nil
+		}
+		records = records[offset:]
+	}
+	if limit > 0 && len(records) > limit {
+		return records[:limit]
+	}
+	return records
+}
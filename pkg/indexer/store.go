@@ -0,0 +1,137 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+)
+
+// ErrNotFound is returned by Store lookups for unknown IDs.
+var ErrNotFound = errors.New("indexer: not found")
+
+// Deal is a hydrated deal together with the log that saved it.
+type Deal struct {
+	lilypadstorage.SharedStructsDeal
+	Block  uint64
+	TxHash common.Hash
+}
+
+// Result is a hydrated result together with the log that saved it.
+type Result struct {
+	lilypadstorage.SharedStructsResult
+	Block  uint64
+	TxHash common.Hash
+}
+
+// ValidationResult is a hydrated validation result together with the log
+// that saved it.
+type ValidationResult struct {
+	lilypadstorage.SharedStructsValidationResult
+	Block  uint64
+	TxHash common.Hash
+}
+
+// Query selects records. Zero fields match everything. Participant filters
+// apply to the deal a result or validation result belongs to; Validator only
+// applies to validation results.
+type Query struct {
+	JobCreator       *common.Address
+	ResourceProvider *common.Address
+	Solver           *common.Address
+	ModuleCreator    *common.Address
+	Validator        *common.Address
+
+	DealID   string
+	ResultID string
+	Status   *uint8
+
+	// Since and Until bound the on-chain timestamp of the record; Since is
+	// inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+
+	// Offset skips that many matching records, for paging.
+	Offset int
+	// Limit caps the number of records returned, zero means no limit.
+	Limit int
+}
+
+// matchDeal reports whether deal satisfies the participant filters of q.
+func (q Query) matchDeal(deal lilypadstorage.SharedStructsDeal) bool {
+	return addrMatch(q.JobCreator, deal.JobCreator) &&
+		addrMatch(q.ResourceProvider, deal.ResourceProvider) &&
+		addrMatch(q.Solver, deal.Solver) &&
+		addrMatch(q.ModuleCreator, deal.ModuleCreator)
+}
+
+func (q Query) participants() bool {
+	return q.JobCreator != nil || q.ResourceProvider != nil || q.Solver != nil || q.ModuleCreator != nil
+}
+
+func (q Query) matchStatus(status uint8) bool {
+	return q.Status == nil || *q.Status == status
+}
+
+func (q Query) matchTime(ts *big.Int) bool {
+	if ts == nil {
+		return q.Since.IsZero() && q.Until.IsZero()
+	}
+	if !q.Since.IsZero() && ts.Cmp(big.NewInt(q.Since.Unix())) < 0 {
+		return false
+	}
+	if !q.Until.IsZero() && ts.Cmp(big.NewInt(q.Until.Unix())) >= 0 {
+		return false
+	}
+	return true
+}
+
+func addrMatch(want *common.Address, got common.Address) bool {
+	return want == nil || *want == got
+}
+
+// Store persists indexed records. Put methods replace existing records;
+// Set*Status methods return ErrNotFound for unknown IDs. List methods return
+// records ordered by timestamp, then ID.
+type Store interface {
+	PutDeal(ctx context.Context, deal Deal) error
+	PutResult(ctx context.Context, result Result) error
+	PutValidationResult(ctx context.Context, validation ValidationResult) error
+
+	SetDealStatus(ctx context.Context, id string, status uint8) error
+	SetResultStatus(ctx context.Context, id string, status uint8) error
+	SetValidationResultStatus(ctx context.Context, id string, status uint8) error
+
+	Deal(ctx context.Context, id string) (Deal, error)
+	Result(ctx context.Context, id string) (Result, error)
+	ValidationResult(ctx context.Context, id string) (ValidationResult, error)
+
+	Deals(ctx context.Context, q Query) ([]Deal, error)
+	Results(ctx context.Context, q Query) ([]Result, error)
+	ValidationResults(ctx context.Context, q Query) ([]ValidationResult, error)
+}
+
+// BlockStore is a Store that applies the writes of a block atomically and
+// remembers the last block applied under a key.
+type BlockStore interface {
+	Store
+	// Checkpoint returns the last block applied under key.
+	Checkpoint(ctx context.Context, key string) (block uint64, ok bool, err error)
+	// ApplyBlock runs fn in a transaction and records block as the checkpoint
+	// for key. Store calls made with the context passed to fn join the
+	// transaction. Blocks at or below the checkpoint are skipped.
+	ApplyBlock(ctx context.Context, key string, block uint64, fn func(ctx context.Context) error) error
+}
+
+// RewindStore is a BlockStore that can undo the blocks a reorg removed.
+type RewindStore interface {
+	BlockStore
+	// Rollback deletes what was applied from block on and rewinds the
+	// checkpoints to the block before it. It must not be called inside
+	// ApplyBlock.
+	Rollback(ctx context.Context, block uint64) error
+}
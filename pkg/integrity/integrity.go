@@ -0,0 +1,326 @@
+// Package integrity checks the indexed LilypadStorage records for the
+// inconsistencies the contracts do not prevent.
+//
+// LilypadStorage.saveResult does not verify that the referenced deal exists,
+// saveValidationResult does not verify its result, and neither checks that
+// the parties are registered LilypadUser accounts. The Checker walks every
+// indexed record and reports orphans, records timestamped before the record
+// they refer to, and parties missing the role they act in.
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/ids"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Kind classifies an anomaly.
+type Kind string
+
+const (
+	// OrphanResult is a result whose deal does not exist.
+	OrphanResult Kind = "orphan_result"
+	// OrphanValidation is a validation result whose result does not exist.
+	OrphanValidation Kind = "orphan_validation"
+	// IndexGap is a referenced record that is missing from the index but
+	// exists in LilypadStorage; the index is incomplete, not the chain.
+	IndexGap Kind = "index_gap"
+	// TimestampOrder is a record timestamped before the record it refers to.
+	TimestampOrder Kind = "timestamp_order"
+	// Unregistered is a party that is not a LilypadUser account.
+	Unregistered Kind = "unregistered"
+	// MissingRole is a registered party without the role it acts in.
+	MissingRole Kind = "missing_role"
+)
+
+// Anomaly is a single finding.
+type Anomaly struct {
+	Kind Kind `json:"kind"`
+	// Record and ID identify the record the finding is about.
+	Record string `json:"record"`
+	ID     string `json:"id"`
+	// Ref is the ID of the referenced record for orphans, index gaps and
+	// timestamp order findings.
+	Ref string `json:"ref,omitempty"`
+	// Party and Role are set for role findings.
+	Party *common.Address `json:"party,omitempty"`
+	Role  string          `json:"role,omitempty"`
+	// Detail is a human readable description.
+	Detail string `json:"detail"`
+}
+
+// Report is the outcome of a check.
+type Report struct {
+	Deals       int       `json:"deals"`
+	Results     int       `json:"results"`
+	Validations int       `json:"validations"`
+	Anomalies   []Anomaly `json:"anomalies"`
+}
+
+// Count returns the number of anomalies of kind.
+func (r *Report) Count(kind Kind) int {
+	n := 0
+	for _, a := range r.Anomalies {
+		if a.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes a summary line per anomaly kind followed by the
+// anomalies, one per line.
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "checked %d deals, %d results, %d validation results: %d anomalies\n",
+		r.Deals, r.Results, r.Validations, len(r.Anomalies)); err != nil {
+		return err
+	}
+	for _, kind := range []Kind{OrphanResult, OrphanValidation, IndexGap, TimestampOrder, Unregistered, MissingRole} {
+		if n := r.Count(kind); n > 0 {
+			if _, err := fmt.Fprintf(w, "  %-18s %d\n", kind, n); err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range r.Anomalies {
+		if _, err := fmt.Fprintf(w, "%s\t%s %q\t%s\n", a.Kind, a.Record, a.ID, a.Detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Users is the subset of the LilypadUser binding the checker reads. It is
+// satisfied by *lilypaduser.LilypadUserCaller.
+type Users interface {
+	HasRole0(opts *bind.CallOpts, walletAddress common.Address, role uint8) (bool, error)
+}
+
+// Checker checks the records of an indexer.Store.
+type Checker struct {
+	store indexer.Store
+	users Users
+	abi   *abi.ABI
+	// storage, if set, confirms orphans against LilypadStorage.
+	storage *ids.Checker
+	// AtRecordBlock checks roles at the block each record was saved in
+	// rather than at the latest block. It needs an archive node.
+	AtRecordBlock bool
+
+	roles map[roleKey]roleState
+}
+
+type roleKey struct {
+	party common.Address
+	role  roles.UserType
+	block uint64
+}
+
+type roleState uint8
+
+const (
+	roleHeld roleState = iota
+	roleMissing
+	notRegistered
+)
+
+// pageSize is how many records are read from the store at a time.
+const pageSize = 1000
+
+// NewChecker returns a Checker for the records in store. users checks party
+// roles; if nil, role checks are skipped. storage, if not nil, tells orphans
+// apart from records the index missed.
+func NewChecker(store indexer.Store, users Users, storage *ids.Checker) (*Checker, error) {
+	parsed, err := lilypaduser.LilypadUserMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{store: store, users: users, abi: parsed, storage: storage}, nil
+}
+
+// Run walks every indexed record and returns the report.
+func (c *Checker) Run(ctx context.Context) (*Report, error) {
+	c.roles = make(map[roleKey]roleState)
+	report := &Report{}
+
+	deals := make(map[string]*big.Int)
+	q := indexer.Query{Limit: pageSize}
+	for ; ; q.Offset += pageSize {
+		page, err := c.store.Deals(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range page {
+			deals[d.DealId] = d.Timestamp
+			for _, party := range []struct {
+				addr common.Address
+				role roles.UserType
+			}{
+				{d.JobCreator, roles.JobCreator},
+				{d.ResourceProvider, roles.ResourceProvider},
+				{d.ModuleCreator, roles.ModuleCreator},
+				{d.Solver, roles.Solver},
+			} {
+				if err := c.checkRole(ctx, report, ids.KindDeal, d.DealId, party.addr, party.role, d.Block); err != nil {
+					return nil, err
+				}
+			}
+		}
+		report.Deals += len(page)
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	results := make(map[string]*big.Int)
+	q = indexer.Query{Limit: pageSize}
+	for ; ; q.Offset += pageSize {
+		page, err := c.store.Results(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page {
+			results[r.ResultId] = r.Timestamp
+			ts, ok := deals[r.DealId]
+			if !ok {
+				if err := c.missing(ctx, report, ids.KindResult, r.ResultId, ids.KindDeal, r.DealId); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			c.checkOrder(report, ids.KindResult, r.ResultId, r.Timestamp, ids.KindDeal, r.DealId, ts)
+		}
+		report.Results += len(page)
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	q = indexer.Query{Limit: pageSize}
+	for ; ; q.Offset += pageSize {
+		page, err := c.store.ValidationResults(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page {
+			if err := c.checkRole(ctx, report, ids.KindValidationResult, v.ValidationResultId, v.Validator, roles.Validator, v.Block); err != nil {
+				return nil, err
+			}
+			ts, ok := results[v.ResultId]
+			if !ok {
+				if err := c.missing(ctx, report, ids.KindValidationResult, v.ValidationResultId, ids.KindResult, v.ResultId); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			c.checkOrder(report, ids.KindValidationResult, v.ValidationResultId, v.Timestamp, ids.KindResult, v.ResultId, ts)
+		}
+		report.Validations += len(page)
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	sort.SliceStable(report.Anomalies, func(i, j int) bool { return report.Anomalies[i].Kind < report.Anomalies[j].Kind })
+	return report, nil
+}
+
+// missing reports a reference to a record that is not in the index.
+func (c *Checker) missing(ctx context.Context, report *Report, kind ids.Kind, id string, refKind ids.Kind, ref string) error {
+	a := Anomaly{Record: kind.String(), ID: id, Ref: ref}
+	if refKind == ids.KindDeal {
+		a.Kind = OrphanResult
+	} else {
+		a.Kind = OrphanValidation
+	}
+	a.Detail = fmt.Sprintf("%s %q does not exist", refKind, ref)
+	if c.storage != nil {
+		exists, err := c.storage.Exists(ctx, refKind, ref)
+		if err != nil {
+			return fmt.Errorf("integrity: %w", err)
+		}
+		if exists {
+			a.Kind = IndexGap
+			a.Detail = fmt.Sprintf("%s %q exists in storage but is not indexed", refKind, ref)
+		}
+	}
+	report.Anomalies = append(report.Anomalies, a)
+	return nil
+}
+
+// checkOrder reports a record timestamped before the record it refers to.
+func (c *Checker) checkOrder(report *Report, kind ids.Kind, id string, ts *big.Int, refKind ids.Kind, ref string, refTS *big.Int) {
+	if ts == nil || refTS == nil || ts.Cmp(refTS) >= 0 {
+		return
+	}
+	report.Anomalies = append(report.Anomalies, Anomaly{
+		Kind:   TimestampOrder,
+		Record: kind.String(),
+		ID:     id,
+		Ref:    ref,
+		Detail: fmt.Sprintf("timestamp %s is before %s %q timestamp %s", ts, refKind, ref, refTS),
+	})
+}
+
+// checkRole reports a party that is not registered or lacks role.
+func (c *Checker) checkRole(ctx context.Context, report *Report, kind ids.Kind, id string, party common.Address, role roles.UserType, block uint64) error {
+	if c.users == nil {
+		return nil
+	}
+	key := roleKey{party: party, role: role}
+	if c.AtRecordBlock {
+		key.block = block
+	}
+	state, ok := c.roles[key]
+	if !ok {
+		opts := &bind.CallOpts{Context: ctx}
+		if c.AtRecordBlock {
+			opts.BlockNumber = new(big.Int).SetUint64(block)
+		}
+		held, err := c.users.HasRole0(opts, party, uint8(role))
+		switch {
+		case err == nil && held:
+			state = roleHeld
+		case err == nil:
+			state = roleMissing
+		case revert.Is(err, "LilypadUser__UserNotFound", c.abi):
+			state = notRegistered
+		default:
+			return fmt.Errorf("integrity: checking %s role of %s: %w", role, party.Hex(), err)
+		}
+		c.roles[key] = state
+	}
+	if state == roleHeld {
+		return nil
+	}
+	a := Anomaly{Record: kind.String(), ID: id, Party: &party, Role: role.String()}
+	if state == notRegistered {
+		a.Kind = Unregistered
+		a.Detail = fmt.Sprintf("%s %s is not a registered user", role, party.Hex())
+	} else {
+		a.Kind = MissingRole
+		a.Detail = fmt.Sprintf("%s does not hold the %s role", party.Hex(), role)
+	}
+	report.Anomalies = append(report.Anomalies, a)
+	return nil
+}
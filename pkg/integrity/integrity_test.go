@@ -0,0 +1,244 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/ids"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+var (
+	alice = common.HexToAddress("0xa1")
+	bob   = common.HexToAddress("0xb0")
+	carol = common.HexToAddress("0xc0")
+)
+
+// revertError carries revert data the way go-ethereum's RPC errors do.
+type revertError []byte
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e) }
+
+// fakeUsers holds the roles of registered users; other addresses revert with
+// LilypadUser__UserNotFound.
+type fakeUsers struct {
+	roles  map[common.Address][]roles.UserType
+	err    error
+	blocks []*big.Int
+}
+
+func (f *fakeUsers) HasRole0(opts *bind.CallOpts, wallet common.Address, role uint8) (bool, error) {
+	f.blocks = append(f.blocks, opts.BlockNumber)
+	if f.err != nil {
+		return false, f.err
+	}
+	held, ok := f.roles[wallet]
+	if !ok {
+		parsed, err := lilypaduser.LilypadUserMetaData.GetAbi()
+		if err != nil {
+			return false, err
+		}
+		id := parsed.Errors["LilypadUser__UserNotFound"].ID
+		return false, revertError(id[:4])
+	}
+	for _, r := range held {
+		if uint8(r) == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeStorage holds the deals and results saved in LilypadStorage.
+type fakeStorage struct {
+	deals, results map[string]bool
+}
+
+func notFound(name string) error {
+	parsed, _ := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	abiErr := parsed.Errors[name]
+	packed, _ := abiErr.Inputs.Pack("id")
+	id := abiErr.ID
+	return revertError(append(id[:4:4], packed...))
+}
+
+func (f fakeStorage) GetDeal(_ *bind.CallOpts, id string) (lilypadstorage.SharedStructsDeal, error) {
+	if !f.deals[id] {
+		return lilypadstorage.SharedStructsDeal{}, notFound("LilypadStorage__DealNotFound")
+	}
+	return lilypadstorage.SharedStructsDeal{DealId: id}, nil
+}
+
+func (f fakeStorage) GetResult(_ *bind.CallOpts, id string) (lilypadstorage.SharedStructsResult, error) {
+	if !f.results[id] {
+		return lilypadstorage.SharedStructsResult{}, notFound("LilypadStorage__ResultNotFound")
+	}
+	return lilypadstorage.SharedStructsResult{ResultId: id}, nil
+}
+
+func (f fakeStorage) GetValidationResult(_ *bind.CallOpts, id string) (lilypadstorage.SharedStructsValidationResult, error) {
+	return lilypadstorage.SharedStructsValidationResult{}, notFound("LilypadStorage__ValidationResultNotFound")
+}
+
+// newStore returns a store holding a deal d1 and, referring to it or to
+// records that are not indexed:
+//   - r1, timestamped before d1;
+//   - r2 of deal "gone" and r3 of deal "unindexed";
+//   - v1 of r1 and v2 of result "lost".
+func newStore(t *testing.T) *indexer.MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	store := indexer.NewMemoryStore()
+	deal := indexer.Deal{SharedStructsDeal: lilypadstorage.SharedStructsDeal{
+		DealId:           "d1",
+		JobCreator:       alice,
+		ResourceProvider: bob,
+		ModuleCreator:    alice,
+		Solver:           carol,
+		Timestamp:        big.NewInt(100),
+	}, Block: 7}
+	if err := store.PutDeal(ctx, deal); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []lilypadstorage.SharedStructsResult{
+		{ResultId: "r1", DealId: "d1", Timestamp: big.NewInt(50)},
+		{ResultId: "r2", DealId: "gone", Timestamp: big.NewInt(100)},
+		{ResultId: "r3", DealId: "unindexed", Timestamp: big.NewInt(100)},
+	} {
+		if err := store.PutResult(ctx, indexer.Result{SharedStructsResult: r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []lilypadstorage.SharedStructsValidationResult{
+		{ValidationResultId: "v1", ResultId: "r1", Validator: alice, Timestamp: big.NewInt(200)},
+		{ValidationResultId: "v2", ResultId: "lost", Validator: alice, Timestamp: big.NewInt(200)},
+	} {
+		err := store.PutValidationResult(ctx, indexer.ValidationResult{SharedStructsValidationResult: v, Block: 9})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+type finding struct {
+	kind Kind
+	id   string
+	ref  string
+	role string
+}
+
+func findings(r *Report) []finding {
+	var got []finding
+	for _, a := range r.Anomalies {
+		got = append(got, finding{a.Kind, a.ID, a.Ref, a.Role})
+	}
+	return got
+}
+
+func TestRun(t *testing.T) {
+	users := &fakeUsers{roles: map[common.Address][]roles.UserType{
+		alice: {roles.JobCreator, roles.ModuleCreator, roles.Validator},
+		bob:   {roles.JobCreator},
+	}}
+	storage, err := ids.NewChecker(fakeStorage{deals: map[string]bool{"unindexed": true}}, common.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewChecker(newStore(t), users, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deals != 1 || report.Results != 3 || report.Validations != 2 {
+		t.Errorf("checked %d deals, %d results, %d validations", report.Deals, report.Results, report.Validations)
+	}
+	want := []finding{
+		{IndexGap, "r3", "unindexed", ""},
+		{MissingRole, "d1", "", "ResourceProvider"},
+		{OrphanResult, "r2", "gone", ""},
+		{OrphanValidation, "v2", "lost", ""},
+		{TimestampOrder, "r1", "d1", ""},
+		{Unregistered, "d1", "", "Solver"},
+	}
+	if got := findings(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("anomalies %+v, want %+v", got, want)
+	}
+	// alice's Validator role is read once for both validations, at the
+	// latest block.
+	if len(users.blocks) != 5 {
+		t.Errorf("%d role reads, want 5", len(users.blocks))
+	}
+	for _, block := range users.blocks {
+		if block != nil {
+			t.Errorf("role read at block %s", block)
+		}
+	}
+}
+
+func TestRunWithoutStorageOrUsers(t *testing.T) {
+	c, err := NewChecker(newStore(t), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []finding{
+		{OrphanResult, "r2", "gone", ""},
+		{OrphanResult, "r3", "unindexed", ""},
+		{OrphanValidation, "v2", "lost", ""},
+		{TimestampOrder, "r1", "d1", ""},
+	}
+	if got := findings(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("anomalies %+v, want %+v", got, want)
+	}
+}
+
+func TestAtRecordBlock(t *testing.T) {
+	users := &fakeUsers{roles: map[common.Address][]roles.UserType{
+		alice: {roles.JobCreator, roles.ModuleCreator, roles.Validator},
+		bob:   {roles.ResourceProvider},
+		carol: {roles.Solver},
+	}}
+	c, err := NewChecker(newStore(t), users, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AtRecordBlock = true
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var got []uint64
+	for _, block := range users.blocks {
+		got = append(got, block.Uint64())
+	}
+	if want := []uint64{7, 7, 7, 7, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("roles read at blocks %v, want %v", got, want)
+	}
+
+	// Errors other than UserNotFound end the check.
+	users = &fakeUsers{err: errors.New("node down")}
+	c, err = NewChecker(newStore(t), users, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run(context.Background()); !errors.Is(err, users.err) {
+		t.Errorf("Run = %v, want %v", err, users.err)
+	}
+}
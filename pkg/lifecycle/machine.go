@@ -0,0 +1,502 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+)
+
+// Action is a state-changing controller call that moves a record through the
+// lifecycle.
+type Action string
+
+const (
+	// LilypadProxy
+	ActionSetDeal   Action = "setDeal"
+	ActionSetResult Action = "setResult"
+	// LilypadValidation
+	ActionRequestValidation Action = "requestValidation"
+	ActionProcessValidation Action = "processValidation"
+	// LilypadPaymentEngine
+	ActionHandleJobCompletion    Action = "handleJobCompletion"
+	ActionHandleJobFailure       Action = "handleJobFailure"
+	ActionHandleValidationPassed Action = "handleValidationPassed"
+	ActionHandleValidationFailed Action = "handleValidationFailed"
+)
+
+// ErrIllegalTransition is wrapped by every TransitionError.
+var ErrIllegalTransition = errors.New("lifecycle: illegal transition")
+
+// TransitionError describes why an action was rejected.
+type TransitionError struct {
+	Action Action
+	ID     string
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("lifecycle: %s %q: %s", e.Action, e.ID, e.Reason)
+}
+
+// Unwrap returns ErrIllegalTransition.
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// Transition describes a controller action and the records it touches. Only
+// the fields relevant to Action need to be set:
+//
+//	setDeal                 DealID
+//	setResult               ResultID, DealID, Status
+//	handleJobCompletion     ResultID
+//	handleJobFailure        ResultID
+//	requestValidation       ValidationID, ResultID, DealID
+//	processValidation       ValidationID, ResultID, Status
+//	handleValidationPassed  ValidationID
+//	handleValidationFailed  ValidationID
+type Transition struct {
+	Action       Action
+	DealID       string
+	ResultID     string
+	ValidationID string
+	// Status is the SharedStructs enum value the record is saved with.
+	Status uint8
+}
+
+// Source loads the current state of a record, typically from LilypadStorage.
+// A record that does not exist is reported with the Unknown state and a nil
+// error.
+type Source interface {
+	Deal(ctx context.Context, id string) (DealState, error)
+	Result(ctx context.Context, id string) (state ResultState, dealID string, err error)
+	Validation(ctx context.Context, id string) (state ValidationState, resultID string, err error)
+}
+
+type dealRecord struct {
+	state DealState
+	// settled is set once a result for the deal has been paid out or
+	// refunded. Storage does not record this, so it is only learned from
+	// events and from actions committed through this machine.
+	settled bool
+}
+
+type resultRecord struct {
+	state   ResultState
+	dealID  string
+	settled bool
+	// known is false when an event told us the result was (re)saved but not
+	// with which status, so it has to be reloaded from the source.
+	known bool
+}
+
+type validationRecord struct {
+	state    ValidationState
+	resultID string
+	settled  bool
+	known    bool
+}
+
+// Machine tracks the lifecycle state of deals, results and validation
+// results. Records are keyed by the keccak256 hash of their ID so that events
+// which only carry the hashed, indexed ID can update them.
+type Machine struct {
+	source Source
+
+	mu          sync.Mutex
+	deals       map[common.Hash]*dealRecord
+	results     map[common.Hash]*resultRecord
+	validations map[common.Hash]*validationRecord
+}
+
+// NewMachine returns a Machine that loads records it has not seen from
+// source. A nil source treats every unseen record as not saved.
+func NewMachine(source Source) *Machine {
+	return &Machine{
+		source:      source,
+		deals:       make(map[common.Hash]*dealRecord),
+		results:     make(map[common.Hash]*resultRecord),
+		validations: make(map[common.Hash]*validationRecord),
+	}
+}
+
+func idHash(id string) common.Hash {
+	return crypto.Keccak256Hash([]byte(id))
+}
+
+// Check reports whether t is a legal transition from the current state. It
+// returns a *TransitionError for illegal transitions and passes through
+// errors from the Source.
+func (m *Machine) Check(ctx context.Context, t Transition) error {
+	illegal := func(id, format string, args ...interface{}) error {
+		return &TransitionError{Action: t.Action, ID: id, Reason: fmt.Sprintf(format, args...)}
+	}
+	switch t.Action {
+	case ActionSetDeal:
+		deal, err := m.deal(ctx, t.DealID)
+		if err != nil {
+			return err
+		}
+		if deal.state != DealUnknown {
+			return illegal(t.DealID, "deal already exists")
+		}
+
+	case ActionSetResult:
+		if _, err := ResultStateOf(t.Status); err != nil {
+			return illegal(t.ResultID, "%v", err)
+		}
+		deal, err := m.deal(ctx, t.DealID)
+		if err != nil {
+			return err
+		}
+		if deal.state != DealCreated {
+			return illegal(t.ResultID, "deal %q does not exist", t.DealID)
+		}
+		if deal.settled {
+			return illegal(t.ResultID, "deal %q has already been settled", t.DealID)
+		}
+		result, err := m.result(ctx, t.ResultID)
+		if err != nil {
+			return err
+		}
+		if result.state != ResultUnknown {
+			return illegal(t.ResultID, "result already exists (%s)", result.state)
+		}
+
+	case ActionHandleJobCompletion, ActionHandleJobFailure:
+		want := ResultAccepted
+		if t.Action == ActionHandleJobFailure {
+			want = ResultRejected
+		}
+		result, err := m.result(ctx, t.ResultID)
+		if err != nil {
+			return err
+		}
+		if result.state != want {
+			return illegal(t.ResultID, "result is %s, want %s", result.state, want)
+		}
+		if result.settled {
+			return illegal(t.ResultID, "result has already been settled")
+		}
+		deal, err := m.deal(ctx, result.dealID)
+		if err != nil {
+			return err
+		}
+		if deal.settled {
+			return illegal(t.ResultID, "deal %q has already been settled", result.dealID)
+		}
+
+	case ActionRequestValidation:
+		deal, err := m.deal(ctx, t.DealID)
+		if err != nil {
+			return err
+		}
+		if deal.state != DealCreated {
+			return illegal(t.ValidationID, "deal %q does not exist", t.DealID)
+		}
+		result, err := m.result(ctx, t.ResultID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case result.state == ResultUnknown:
+			return illegal(t.ValidationID, "result %q does not exist", t.ResultID)
+		case result.state == ResultRejected:
+			return illegal(t.ValidationID, "result %q was rejected", t.ResultID)
+		case result.dealID != t.DealID:
+			return illegal(t.ValidationID, "result %q belongs to deal %q, not %q", t.ResultID, result.dealID, t.DealID)
+		}
+		validation, err := m.validation(ctx, t.ValidationID)
+		if err != nil {
+			return err
+		}
+		if validation.state != ValidationUnknown {
+			return illegal(t.ValidationID, "validation already exists (%s)", validation.state)
+		}
+
+	case ActionProcessValidation:
+		status, err := ValidationStateOf(t.Status)
+		if err != nil {
+			return illegal(t.ValidationID, "%v", err)
+		}
+		if status == ValidationPending {
+			return illegal(t.ValidationID, "validation must be processed as accepted or rejected")
+		}
+		validation, err := m.validation(ctx, t.ValidationID)
+		if err != nil {
+			return err
+		}
+		if validation.state != ValidationPending {
+			return illegal(t.ValidationID, "validation is %s, want %s", validation.state, ValidationPending)
+		}
+		if t.ResultID != "" && validation.resultID != "" && validation.resultID != t.ResultID {
+			return illegal(t.ValidationID, "validation belongs to result %q, not %q", validation.resultID, t.ResultID)
+		}
+
+	case ActionHandleValidationPassed, ActionHandleValidationFailed:
+		want := ValidationAccepted
+		if t.Action == ActionHandleValidationFailed {
+			want = ValidationRejected
+		}
+		validation, err := m.validation(ctx, t.ValidationID)
+		if err != nil {
+			return err
+		}
+		if validation.state != want {
+			return illegal(t.ValidationID, "validation is %s, want %s", validation.state, want)
+		}
+		if validation.settled {
+			return illegal(t.ValidationID, "validation has already been settled")
+		}
+
+	default:
+		return fmt.Errorf("lifecycle: unknown action %q", t.Action)
+	}
+	return nil
+}
+
+// Commit applies t to the tracked state. It is called once the transaction
+// for t has been sent; if the transaction later fails, Invalidate the IDs it
+// touched so they are reloaded.
+func (m *Machine) Commit(t Transition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch t.Action {
+	case ActionSetDeal:
+		m.dealRecord(idHash(t.DealID)).state = DealCreated
+
+	case ActionSetResult:
+		// LilypadProxy.setResult saves the result and settles the deal in
+		// the same transaction.
+		state, _ := ResultStateOf(t.Status)
+		r := m.resultRecord(idHash(t.ResultID))
+		r.state, r.dealID, r.settled, r.known = state, t.DealID, true, true
+		m.dealRecord(idHash(t.DealID)).settled = true
+
+	case ActionHandleJobCompletion, ActionHandleJobFailure:
+		r := m.resultRecord(idHash(t.ResultID))
+		r.settled = true
+		if r.dealID != "" {
+			m.dealRecord(idHash(r.dealID)).settled = true
+		}
+
+	case ActionRequestValidation:
+		v := m.validationRecord(idHash(t.ValidationID))
+		v.state, v.resultID, v.known = ValidationPending, t.ResultID, true
+
+	case ActionProcessValidation:
+		state, _ := ValidationStateOf(t.Status)
+		v := m.validationRecord(idHash(t.ValidationID))
+		v.state, v.known = state, true
+		if t.ResultID != "" {
+			v.resultID = t.ResultID
+		}
+
+	case ActionHandleValidationPassed, ActionHandleValidationFailed:
+		m.validationRecord(idHash(t.ValidationID)).settled = true
+	}
+}
+
+// Invalidate drops everything known about the given IDs so that the next
+// check reloads them from the source. Settlement flags are dropped too.
+func (m *Machine) Invalidate(ids ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		h := idHash(id)
+		delete(m.deals, h)
+		delete(m.results, h)
+		delete(m.validations, h)
+	}
+}
+
+// ObserveDealSaved records a LilypadStorage__DealSaved event.
+func (m *Machine) ObserveDealSaved(ev *lilypadstorage.LilypadStorageLilypadStorageDealSaved) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dealRecord(ev.DealId).state = DealCreated
+}
+
+// ObserveDealStatusChanged records a LilypadStorage__DealStatusChanged event.
+func (m *Machine) ObserveDealStatusChanged(ev *lilypadstorage.LilypadStorageLilypadStorageDealStatusChanged) {
+	state, err := DealStateOf(ev.Status)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dealRecord(ev.DealId).state = state
+}
+
+// ObserveResultSaved records a LilypadStorage__ResultSaved event. The event
+// does not carry the status, so the result is reloaded on the next check.
+func (m *Machine) ObserveResultSaved(ev *lilypadstorage.LilypadStorageLilypadStorageResultSaved) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.resultRecord(ev.ResultId)
+	r.dealID, r.known = ev.DealId, false
+}
+
+// ObserveResultStatusChanged records a LilypadStorage__ResultStatusChanged event.
+func (m *Machine) ObserveResultStatusChanged(ev *lilypadstorage.LilypadStorageLilypadStorageResultStatusChanged) {
+	state, err := ResultStateOf(ev.Status)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.resultRecord(ev.ResultId)
+	r.state = state
+	// The deal is still needed for settlement checks, so only mark the
+	// record as known if it was already linked to one.
+	r.known = r.dealID != ""
+}
+
+// ObserveValidationResultSaved records a LilypadStorage__ValidationResultSaved
+// event. The event does not carry the status, so the validation is reloaded on
+// the next check.
+func (m *Machine) ObserveValidationResultSaved(ev *lilypadstorage.LilypadStorageLilypadStorageValidationResultSaved) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.validationRecord(ev.ValidationResultId)
+	v.resultID, v.known = ev.ResultId, false
+}
+
+// ObserveValidationResultStatusChanged records a
+// LilypadStorage__ValidationResultStatusChanged event.
+func (m *Machine) ObserveValidationResultStatusChanged(ev *lilypadstorage.LilypadStorageLilypadStorageValidationResultStatusChanged) {
+	state, err := ValidationStateOf(ev.Status)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.validationRecord(ev.ValidationResultId)
+	v.state, v.known = state, true
+}
+
+// ObserveJobCompleted records a LilypadPayment__JobCompleted event, which
+// settles the deal.
+func (m *Machine) ObserveJobCompleted(ev *lilypadpaymentengine.LilypadPaymentEngineLilypadPaymentJobCompleted) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dealRecord(idHash(ev.DealId)).settled = true
+}
+
+// ObserveJobFailed records a LilypadPayment__JobFailed event, which settles
+// the result and its deal.
+func (m *Machine) ObserveJobFailed(ev *lilypadpaymentengine.LilypadPaymentEngineLilypadPaymentJobFailed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.resultRecord(idHash(ev.ResultId))
+	r.settled = true
+	if r.dealID != "" {
+		m.dealRecord(idHash(r.dealID)).settled = true
+	}
+}
+
+func (m *Machine) dealRecord(h common.Hash) *dealRecord {
+	d, ok := m.deals[h]
+	if !ok {
+		d = new(dealRecord)
+		m.deals[h] = d
+	}
+	return d
+}
+
+func (m *Machine) resultRecord(h common.Hash) *resultRecord {
+	r, ok := m.results[h]
+	if !ok {
+		r = new(resultRecord)
+		m.results[h] = r
+	}
+	return r
+}
+
+func (m *Machine) validationRecord(h common.Hash) *validationRecord {
+	v, ok := m.validations[h]
+	if !ok {
+		v = new(validationRecord)
+		m.validations[h] = v
+	}
+	return v
+}
+
+// deal returns a snapshot of the deal record, loading it from the source if
+// it is not known to exist yet.
+func (m *Machine) deal(ctx context.Context, id string) (dealRecord, error) {
+	h := idHash(id)
+	m.mu.Lock()
+	d := m.dealRecord(h)
+	snapshot := *d
+	m.mu.Unlock()
+	if snapshot.state != DealUnknown || m.source == nil {
+		return snapshot, nil
+	}
+	state, err := m.source.Deal(ctx, id)
+	if err != nil {
+		return dealRecord{}, fmt.Errorf("lifecycle: loading deal %q: %w", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d = m.dealRecord(h)
+	if d.state == DealUnknown {
+		d.state = state
+	}
+	return *d, nil
+}
+
+// result returns a snapshot of the result record, loading it from the source
+// if its status is not known.
+func (m *Machine) result(ctx context.Context, id string) (resultRecord, error) {
+	h := idHash(id)
+	m.mu.Lock()
+	r := m.resultRecord(h)
+	snapshot := *r
+	m.mu.Unlock()
+	if snapshot.known || m.source == nil {
+		return snapshot, nil
+	}
+	state, dealID, err := m.source.Result(ctx, id)
+	if err != nil {
+		return resultRecord{}, fmt.Errorf("lifecycle: loading result %q: %w", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r = m.resultRecord(h)
+	if !r.known && state != ResultUnknown {
+		r.state, r.dealID, r.known = state, dealID, true
+	}
+	return *r, nil
+}
+
+// validation returns a snapshot of the validation record, loading it from the
+// source if its status is not known.
+func (m *Machine) validation(ctx context.Context, id string) (validationRecord, error) {
+	h := idHash(id)
+	m.mu.Lock()
+	v := m.validationRecord(h)
+	snapshot := *v
+	m.mu.Unlock()
+	if snapshot.known || m.source == nil {
+		return snapshot, nil
+	}
+	state, resultID, err := m.source.Validation(ctx, id)
+	if err != nil {
+		return validationRecord{}, fmt.Errorf("lifecycle: loading validation %q: %w", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v = m.validationRecord(h)
+	if !v.known && state != ValidationUnknown {
+		v.state, v.resultID, v.known = state, resultID, true
+	}
+	return *v, nil
+}
@@ -0,0 +1,225 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+)
+
+// fakeSource serves records from maps; a missing record is not saved.
+type fakeSource struct {
+	deals       map[string]DealState
+	results     map[string]ResultState
+	resultDeals map[string]string
+	validations map[string]ValidationState
+	loads       int
+}
+
+func (s *fakeSource) Deal(ctx context.Context, id string) (DealState, error) {
+	s.loads++
+	return s.deals[id], nil
+}
+
+func (s *fakeSource) Result(ctx context.Context, id string) (ResultState, string, error) {
+	s.loads++
+	return s.results[id], s.resultDeals[id], nil
+}
+
+func (s *fakeSource) Validation(ctx context.Context, id string) (ValidationState, string, error) {
+	s.loads++
+	return s.validations[id], "", nil
+}
+
+type step struct {
+	t     Transition
+	legal bool
+}
+
+var (
+	setDeal       = Transition{Action: ActionSetDeal, DealID: "d1"}
+	acceptResult  = Transition{Action: ActionSetResult, ResultID: "r1", DealID: "d1", Status: statusResultsAccepted}
+	rejectResult  = Transition{Action: ActionSetResult, ResultID: "r1", DealID: "d1", Status: statusResultsRejected}
+	requestValid  = Transition{Action: ActionRequestValidation, ValidationID: "v1", ResultID: "r1", DealID: "d1"}
+	acceptValid   = Transition{Action: ActionProcessValidation, ValidationID: "v1", ResultID: "r1", Status: statusValidationAccepted}
+	rejectValid   = Transition{Action: ActionProcessValidation, ValidationID: "v1", ResultID: "r1", Status: statusValidationRejected}
+	validPassed   = Transition{Action: ActionHandleValidationPassed, ValidationID: "v1"}
+	validFailed   = Transition{Action: ActionHandleValidationFailed, ValidationID: "v1"}
+	jobCompletion = Transition{Action: ActionHandleJobCompletion, ResultID: "r1"}
+	jobFailure    = Transition{Action: ActionHandleJobFailure, ResultID: "r1"}
+)
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"deal, accepted result, passed validation", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{requestValid, true},
+			{acceptValid, true},
+			{validPassed, true},
+			{validPassed, false},
+		}},
+		{"rejected validation", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{requestValid, true},
+			{rejectValid, true},
+			{validPassed, false},
+			{validFailed, true},
+		}},
+		{"deal saved twice", []step{
+			{setDeal, true},
+			{setDeal, false},
+		}},
+		{"result without deal", []step{
+			{acceptResult, false},
+		}},
+		{"result with an invalid status", []step{
+			{setDeal, true},
+			{Transition{Action: ActionSetResult, ResultID: "r1", DealID: "d1", Status: 7}, false},
+		}},
+		{"second result for a settled deal", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{Transition{Action: ActionSetResult, ResultID: "r2", DealID: "d1"}, false},
+		}},
+		{"validation of a rejected result", []step{
+			{setDeal, true},
+			{rejectResult, true},
+			{requestValid, false},
+		}},
+		{"validation of a result of another deal", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{Transition{Action: ActionSetDeal, DealID: "d2"}, true},
+			{Transition{Action: ActionRequestValidation, ValidationID: "v1", ResultID: "r1", DealID: "d2"}, false},
+		}},
+		{"validation requested twice", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{requestValid, true},
+			{requestValid, false},
+		}},
+		{"processing as pending", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{requestValid, true},
+			{Transition{Action: ActionProcessValidation, ValidationID: "v1", Status: statusValidationPending}, false},
+		}},
+		{"processing an unrequested validation", []step{
+			{acceptValid, false},
+		}},
+		{"processing twice", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{requestValid, true},
+			{acceptValid, true},
+			{rejectValid, false},
+		}},
+		{"processing against another result", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{requestValid, true},
+			{Transition{Action: ActionProcessValidation, ValidationID: "v1", ResultID: "r2", Status: statusValidationAccepted}, false},
+		}},
+		{"payout of a result already settled by setResult", []step{
+			{setDeal, true},
+			{acceptResult, true},
+			{jobCompletion, false},
+		}},
+		{"unknown action", []step{
+			{Transition{Action: "withdraw"}, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine(nil)
+			for i, s := range tt.steps {
+				err := m.Check(context.Background(), s.t)
+				if s.legal {
+					if err != nil {
+						t.Fatalf("step %d %s: %v", i, s.t.Action, err)
+					}
+					m.Commit(s.t)
+					continue
+				}
+				if err == nil {
+					t.Fatalf("step %d %s: accepted, want rejected", i, s.t.Action)
+				}
+				if s.t.Action != "withdraw" && !errors.Is(err, ErrIllegalTransition) {
+					t.Fatalf("step %d %s: %v, want ErrIllegalTransition", i, s.t.Action, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDirectSettlement(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{
+		deals:       map[string]DealState{"d1": DealCreated},
+		results:     map[string]ResultState{"r1": ResultAccepted, "r2": ResultRejected},
+		resultDeals: map[string]string{"r1": "d1", "r2": "d1"},
+	}
+	m := NewMachine(src)
+	if err := m.Check(ctx, jobFailure); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("failure of an accepted result: %v, want ErrIllegalTransition", err)
+	}
+	if err := m.Check(ctx, jobCompletion); err != nil {
+		t.Fatalf("completion loaded from the source: %v", err)
+	}
+	m.Commit(jobCompletion)
+	if err := m.Check(ctx, jobCompletion); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("second completion: %v, want ErrIllegalTransition", err)
+	}
+	// r2 belongs to the deal r1 just settled.
+	if err := m.Check(ctx, Transition{Action: ActionHandleJobFailure, ResultID: "r2"}); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("failure of a result of a settled deal: %v, want ErrIllegalTransition", err)
+	}
+
+	m.Invalidate("r1", "d1")
+	if err := m.Check(ctx, jobCompletion); err != nil {
+		t.Fatalf("completion after Invalidate: %v", err)
+	}
+}
+
+func TestSourceLoadedOnce(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{deals: map[string]DealState{"d1": DealCreated}}
+	m := NewMachine(src)
+	for i := 0; i < 3; i++ {
+		if err := m.Check(ctx, setDeal); !errors.Is(err, ErrIllegalTransition) {
+			t.Fatalf("setDeal of a stored deal: %v, want ErrIllegalTransition", err)
+		}
+	}
+	if src.loads != 1 {
+		t.Errorf("source loaded %d times, want 1", src.loads)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	ctx := context.Background()
+	m := NewMachine(nil)
+	m.ObserveDealSaved(&lilypadstorage.LilypadStorageLilypadStorageDealSaved{DealId: idHash("d1")})
+	if err := m.Check(ctx, setDeal); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("setDeal after DealSaved: %v, want ErrIllegalTransition", err)
+	}
+	if err := m.Check(ctx, acceptResult); err != nil {
+		t.Fatalf("setResult after DealSaved: %v", err)
+	}
+
+	m.ObserveResultStatusChanged(&lilypadstorage.LilypadStorageLilypadStorageResultStatusChanged{ResultId: idHash("r1"), Status: statusResultsRejected})
+	if err := m.Check(ctx, acceptResult); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("setResult after ResultStatusChanged: %v, want ErrIllegalTransition", err)
+	}
+
+	m.ObserveJobCompleted(&lilypadpaymentengine.LilypadPaymentEngineLilypadPaymentJobCompleted{DealId: "d1"})
+	if err := m.Check(ctx, Transition{Action: ActionSetResult, ResultID: "r2", DealID: "d1"}); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("setResult after JobCompleted: %v, want ErrIllegalTransition", err)
+	}
+}
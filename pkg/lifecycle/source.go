@@ -0,0 +1,67 @@
+package lifecycle
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+)
+
+// StorageSource loads records from LilypadStorage.
+type StorageSource struct {
+	storage *lilypadstorage.LilypadStorageCaller
+	abi     *abi.ABI
+	// from must hold CONTROLLER_ROLE on storage, which guards the getters.
+	from common.Address
+}
+
+// NewStorageSource returns a Source reading storage as the controller from.
+func NewStorageSource(storage *lilypadstorage.LilypadStorageCaller, from common.Address) (*StorageSource, error) {
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return &StorageSource{storage: storage, abi: parsed, from: from}, nil
+}
+
+// Deal implements Source.
+func (s *StorageSource) Deal(ctx context.Context, id string) (DealState, error) {
+	deal, err := s.storage.GetDeal(&bind.CallOpts{Context: ctx, From: s.from}, id)
+	if revert.Is(err, "LilypadStorage__DealNotFound", s.abi) {
+		return DealUnknown, nil
+	}
+	if err != nil {
+		return DealUnknown, err
+	}
+	return DealStateOf(deal.Status)
+}
+
+// Result implements Source.
+func (s *StorageSource) Result(ctx context.Context, id string) (ResultState, string, error) {
+	result, err := s.storage.GetResult(&bind.CallOpts{Context: ctx, From: s.from}, id)
+	if revert.Is(err, "LilypadStorage__ResultNotFound", s.abi) {
+		return ResultUnknown, "", nil
+	}
+	if err != nil {
+		return ResultUnknown, "", err
+	}
+	state, err := ResultStateOf(result.Status)
+	return state, result.DealId, err
+}
+
+// Validation implements Source.
+func (s *StorageSource) Validation(ctx context.Context, id string) (ValidationState, string, error) {
+	validation, err := s.storage.GetValidationResult(&bind.CallOpts{Context: ctx, From: s.from}, id)
+	if revert.Is(err, "LilypadStorage__ValidationResultNotFound", s.abi) {
+		return ValidationUnknown, "", nil
+	}
+	if err != nil {
+		return ValidationUnknown, "", err
+	}
+	state, err := ValidationStateOf(validation.Status)
+	return state, validation.ResultId, err
+}
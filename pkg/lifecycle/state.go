@@ -0,0 +1,123 @@
+// Package lifecycle models the deal → result → validation lifecycle of the
+// Lilypad protocol and rejects controller actions that would break it.
+//
+// The contracts only check that referenced records are non-empty; nothing on
+// chain stops a controller from saving a result for a deal that was never
+// saved, paying out a result twice or validating a rejected result. The
+// Machine in this package tracks every record's state from storage reads and
+// events and is consulted before a transaction is signed.
+package lifecycle
+
+import "fmt"
+
+// DealState is the state of a deal as far as the lifecycle is concerned.
+type DealState uint8
+
+const (
+	DealUnknown DealState = iota
+	DealCreated
+)
+
+// String implements fmt.Stringer.
+func (s DealState) String() string {
+	switch s {
+	case DealUnknown:
+		return "unknown"
+	case DealCreated:
+		return "created"
+	}
+	return fmt.Sprintf("DealState(%d)", uint8(s))
+}
+
+// ResultState is the state of a result.
+type ResultState uint8
+
+const (
+	ResultUnknown ResultState = iota
+	ResultAccepted
+	ResultRejected
+)
+
+// String implements fmt.Stringer.
+func (s ResultState) String() string {
+	switch s {
+	case ResultUnknown:
+		return "unknown"
+	case ResultAccepted:
+		return "accepted"
+	case ResultRejected:
+		return "rejected"
+	}
+	return fmt.Sprintf("ResultState(%d)", uint8(s))
+}
+
+// ValidationState is the state of a validation result.
+type ValidationState uint8
+
+const (
+	ValidationUnknown ValidationState = iota
+	ValidationPending
+	ValidationAccepted
+	ValidationRejected
+)
+
+// String implements fmt.Stringer.
+func (s ValidationState) String() string {
+	switch s {
+	case ValidationUnknown:
+		return "unknown"
+	case ValidationPending:
+		return "pending"
+	case ValidationAccepted:
+		return "accepted"
+	case ValidationRejected:
+		return "rejected"
+	}
+	return fmt.Sprintf("ValidationState(%d)", uint8(s))
+}
+
+// The on-chain enums in SharedStructs. The lifecycle states are offset by one
+// so that the zero value means "not saved".
+const (
+	statusDealCreated = 0
+
+	statusResultsAccepted = 0
+	statusResultsRejected = 1
+
+	statusValidationPending  = 0
+	statusValidationAccepted = 1
+	statusValidationRejected = 2
+)
+
+// DealStateOf maps a SharedStructs.DealStatusEnum value to a DealState.
+func DealStateOf(status uint8) (DealState, error) {
+	if status == statusDealCreated {
+		return DealCreated, nil
+	}
+	return DealUnknown, fmt.Errorf("lifecycle: invalid deal status %d", status)
+}
+
+// ResultStateOf maps a SharedStructs.ResultStatusEnum value to a ResultState.
+func ResultStateOf(status uint8) (ResultState, error) {
+	switch status {
+	case statusResultsAccepted:
+		return ResultAccepted, nil
+	case statusResultsRejected:
+		return ResultRejected, nil
+	}
+	return ResultUnknown, fmt.Errorf("lifecycle: invalid result status %d", status)
+}
+
+// ValidationStateOf maps a SharedStructs.ValidationResultStatusEnum value to a
+// ValidationState.
+func ValidationStateOf(status uint8) (ValidationState, error) {
+	switch status {
+	case statusValidationPending:
+		return ValidationPending, nil
+	case statusValidationAccepted:
+		return ValidationAccepted, nil
+	case statusValidationRejected:
+		return ValidationRejected, nil
+	}
+	return ValidationUnknown, fmt.Errorf("lifecycle: invalid validation status %d", status)
+}
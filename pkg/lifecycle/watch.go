@@ -0,0 +1,99 @@
+package lifecycle
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/event"
+
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+)
+
+// Watch subscribes to the storage and payment engine events that drive the
+// machine and feeds them in until ctx is cancelled or a subscription fails.
+func (m *Machine) Watch(ctx context.Context, storage *lilypadstorage.LilypadStorageFilterer, payments *lilypadpaymentengine.LilypadPaymentEngineFilterer) error {
+	opts := &bind.WatchOpts{Context: ctx}
+
+	dealSaved := make(chan *lilypadstorage.LilypadStorageLilypadStorageDealSaved)
+	dealStatus := make(chan *lilypadstorage.LilypadStorageLilypadStorageDealStatusChanged)
+	resultSaved := make(chan *lilypadstorage.LilypadStorageLilypadStorageResultSaved)
+	resultStatus := make(chan *lilypadstorage.LilypadStorageLilypadStorageResultStatusChanged)
+	validationSaved := make(chan *lilypadstorage.LilypadStorageLilypadStorageValidationResultSaved)
+	validationStatus := make(chan *lilypadstorage.LilypadStorageLilypadStorageValidationResultStatusChanged)
+	jobCompleted := make(chan *lilypadpaymentengine.LilypadPaymentEngineLilypadPaymentJobCompleted)
+	jobFailed := make(chan *lilypadpaymentengine.LilypadPaymentEngineLilypadPaymentJobFailed)
+
+	var subs []event.Subscription
+	defer func() {
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+	}()
+	for _, watch := range []func() (event.Subscription, error){
+		func() (event.Subscription, error) {
+			return storage.WatchLilypadStorageDealSaved(opts, dealSaved, nil, nil, nil)
+		},
+		func() (event.Subscription, error) {
+			return storage.WatchLilypadStorageDealStatusChanged(opts, dealStatus, nil)
+		},
+		func() (event.Subscription, error) {
+			return storage.WatchLilypadStorageResultSaved(opts, resultSaved, nil)
+		},
+		func() (event.Subscription, error) {
+			return storage.WatchLilypadStorageResultStatusChanged(opts, resultStatus, nil)
+		},
+		func() (event.Subscription, error) {
+			return storage.WatchLilypadStorageValidationResultSaved(opts, validationSaved, nil)
+		},
+		func() (event.Subscription, error) {
+			return storage.WatchLilypadStorageValidationResultStatusChanged(opts, validationStatus, nil)
+		},
+		func() (event.Subscription, error) {
+			return payments.WatchLilypadPaymentJobCompleted(opts, jobCompleted, nil, nil)
+		},
+		func() (event.Subscription, error) {
+			return payments.WatchLilypadPaymentJobFailed(opts, jobFailed, nil, nil)
+		},
+	} {
+		sub, err := watch()
+		if err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+	}
+
+	errc := make(chan error, len(subs))
+	for _, sub := range subs {
+		go func(sub event.Subscription) {
+			if err, ok := <-sub.Err(); ok {
+				errc <- err
+			}
+		}(sub)
+	}
+
+	for {
+		select {
+		case ev := <-dealSaved:
+			m.ObserveDealSaved(ev)
+		case ev := <-dealStatus:
+			m.ObserveDealStatusChanged(ev)
+		case ev := <-resultSaved:
+			m.ObserveResultSaved(ev)
+		case ev := <-resultStatus:
+			m.ObserveResultStatusChanged(ev)
+		case ev := <-validationSaved:
+			m.ObserveValidationResultSaved(ev)
+		case ev := <-validationStatus:
+			m.ObserveValidationResultStatusChanged(ev)
+		case ev := <-jobCompleted:
+			m.ObserveJobCompleted(ev)
+		case ev := <-jobFailed:
+			m.ObserveJobFailed(ev)
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
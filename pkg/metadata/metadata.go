@@ -0,0 +1,298 @@
+// Package metadata fetches and validates the off-chain documents LilypadUser
+// accounts and LilypadModuleDirectory modules point to.
+//
+// SharedStructs.User carries a metadataID, usually the CID of a JSON
+// document, and a url; SharedStructs.Module carries a moduleUrl. The
+// contracts store whatever they are given. A Loader resolves these
+// references through a chain of Resolvers (HTTP, IPFS gateway, local
+// directory), caps the document size, checks the content against the CID it
+// claims to be and validates it against the JSON schema of its kind.
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	lilypadmoduledirectory "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadModuleDirectory"
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+)
+
+var (
+	// ErrUnsupported is returned by a Resolver for references it does not
+	// handle.
+	ErrUnsupported = errors.New("metadata: unsupported reference")
+	// ErrNotFound is returned when the referenced document does not exist.
+	ErrNotFound = errors.New("metadata: document not found")
+	// ErrTooLarge is returned for documents over the size limit.
+	ErrTooLarge = errors.New("metadata: document too large")
+	// ErrHashMismatch is returned when content does not match its CID.
+	ErrHashMismatch = errors.New("metadata: content hash mismatch")
+	// ErrUnverifiable is returned when the content hash cannot be checked.
+	ErrUnverifiable = errors.New("metadata: content hash cannot be verified")
+	// ErrInvalid is wrapped by schema validation failures.
+	ErrInvalid = errors.New("metadata: invalid document")
+	// ErrForbiddenAddress is returned by PublicClient for hosts that resolve
+	// to loopback, private, link-local or other non-public addresses.
+	ErrForbiddenAddress = errors.New("metadata: non-public address")
+)
+
+// Kind is the kind of a metadata document; each has its own JSON schema.
+type Kind string
+
+// The document kinds.
+const (
+	ResourceProvider Kind = "resource-provider"
+	JobCreator       Kind = "job-creator"
+	Module           Kind = "module"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// Document is a fetched and validated metadata document.
+type Document struct {
+	Kind Kind
+	// Ref is the reference the document was fetched from.
+	Ref     string
+	Content []byte
+	Fields  map[string]interface{}
+	// Verified is set when the content was checked against its CID.
+	Verified bool
+}
+
+// Config configures a Loader.
+type Config struct {
+	// Resolvers are tried in order for every reference.
+	Resolvers []Resolver
+	// MaxSize caps documents, 1 MiB if zero.
+	MaxSize int64
+	// CacheSize bounds the number of cached documents, 1024 if zero.
+	// Negative disables the cache.
+	CacheSize int
+	// CacheTTL is how long documents are cached, ten minutes if zero.
+	// Content-addressed documents never change and are cached until
+	// evicted.
+	CacheTTL time.Duration
+	// RequireVerified rejects documents without a CID to check them
+	// against.
+	RequireVerified bool
+}
+
+// Loader fetches metadata documents. It is safe for concurrent use.
+type Loader struct {
+	cfg     Config
+	schemas map[Kind]*jsonschema.Schema
+
+	mu    sync.Mutex
+	cache map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	kind    Kind
+	id, ref string
+}
+
+type cacheEntry struct {
+	doc     *Document
+	expires time.Time
+}
+
+// NewLoader returns a Loader for cfg.
+func NewLoader(cfg Config) (*Loader, error) {
+	if len(cfg.Resolvers) == 0 {
+		return nil, errors.New("metadata: no resolvers")
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = 1 << 20
+	}
+	if cfg.CacheSize == 0 {
+		cfg.CacheSize = 1024
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 10 * time.Minute
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	schemas := make(map[Kind]*jsonschema.Schema)
+	for _, kind := range []Kind{ResourceProvider, JobCreator, Module} {
+		name := "schemas/" + string(kind) + ".json"
+		raw, err := schemaFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := compiler.AddResource(name, bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("metadata: %s: %w", name, err)
+		}
+		if schemas[kind], err = compiler.Compile(name); err != nil {
+			return nil, fmt.Errorf("metadata: %s: %w", name, err)
+		}
+	}
+	return &Loader{cfg: cfg, schemas: schemas, cache: make(map[cacheKey]cacheEntry)}, nil
+}
+
+// Schema returns the JSON schema documents of kind are validated against.
+func Schema(kind Kind) ([]byte, error) {
+	return schemaFiles.ReadFile("schemas/" + string(kind) + ".json")
+}
+
+// User loads the metadata of a LilypadUser account acting as kind.
+func (l *Loader) User(ctx context.Context, kind Kind, u lilypaduser.SharedStructsUser) (*Document, error) {
+	return l.Load(ctx, kind, u.MetadataID, u.Url)
+}
+
+// Module loads the metadata a module's URL points to. Modules have no
+// metadata ID; the content is verified only when the URL is an ipfs:// URL
+// or a bare CID.
+func (l *Loader) Module(ctx context.Context, m lilypadmoduledirectory.SharedStructsModule) (*Document, error) {
+	return l.Load(ctx, Module, "", m.ModuleUrl)
+}
+
+// Load fetches the document of kind identified by id and ref. id is
+// usually a CID and ref a URL; either may be empty. When id is a CID the
+// document is fetched by CID first and by ref otherwise, and must hash to id
+// either way. A content-addressed ref stands in for an empty id.
+func (l *Loader) Load(ctx context.Context, kind Kind, id, ref string) (*Document, error) {
+	schema, ok := l.schemas[kind]
+	if !ok {
+		return nil, fmt.Errorf("metadata: unknown kind %q", kind)
+	}
+	if _, isCID := contentID(id); !isCID {
+		if c, ok := contentID(ref); ok {
+			id = c
+		}
+	}
+	key := cacheKey{kind: kind, id: id, ref: ref}
+	if doc := l.cached(key); doc != nil {
+		return doc, nil
+	}
+
+	var refs []string
+	_, verifiable := contentID(id)
+	if id != "" {
+		refs = append(refs, id)
+	}
+	if ref != "" && ref != id {
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("%w: no metadata reference", ErrNotFound)
+	}
+	if !verifiable && l.cfg.RequireVerified {
+		return nil, fmt.Errorf("%w: %q is not a CID", ErrUnverifiable, id)
+	}
+
+	var errs []error
+	for _, r := range refs {
+		content, err := l.fetch(ctx, r)
+		if err == nil && verifiable {
+			err = Verify(id, content)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrUnsupported) {
+				errs = append(errs, fmt.Errorf("%s: %w", r, err))
+			}
+			continue
+		}
+		doc := &Document{Kind: kind, Ref: r, Content: content, Verified: verifiable}
+		if err := validate(schema, doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", r, err)
+		}
+		l.store(key, doc, verifiable)
+		return doc, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%w: no resolver for %q", ErrUnsupported, refs)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetch reads ref from the first resolver that has it.
+func (l *Loader) fetch(ctx context.Context, ref string) ([]byte, error) {
+	missing := ErrUnsupported
+	for _, r := range l.cfg.Resolvers {
+		rc, err := r.Resolve(ctx, ref)
+		if errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		if errors.Is(err, ErrNotFound) {
+			missing = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, l.cfg.MaxSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(content)) > l.cfg.MaxSize {
+			return nil, fmt.Errorf("%w: over %d bytes", ErrTooLarge, l.cfg.MaxSize)
+		}
+		return content, nil
+	}
+	return nil, missing
+}
+
+func validate(schema *jsonschema.Schema, doc *Document) error {
+	var v interface{}
+	if err := json.Unmarshal(doc.Content, &v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := schema.Validate(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	doc.Fields, _ = v.(map[string]interface{})
+	return nil
+}
+
+func (l *Loader) cached(key cacheKey) *Document {
+	if l.cfg.CacheSize < 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.cache[key]
+	if !ok {
+		return nil
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(l.cache, key)
+		return nil
+	}
+	return e.doc
+}
+
+func (l *Loader) store(key cacheKey, doc *Document, permanent bool) {
+	if l.cfg.CacheSize < 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.cache) >= l.cfg.CacheSize {
+		// Drop expired entries first and everything if that is not enough.
+		now := time.Now()
+		for k, e := range l.cache {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(l.cache, k)
+			}
+		}
+		if len(l.cache) >= l.cfg.CacheSize {
+			l.cache = make(map[cacheKey]cacheEntry)
+		}
+	}
+	e := cacheEntry{doc: doc}
+	if !permanent {
+		e.expires = time.Now().Add(l.cfg.CacheTTL)
+	}
+	l.cache[key] = e
+}
@@ -0,0 +1,234 @@
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// jsonCID returns the CIDv1 of content with the json codec and a sha2-256
+// multihash, in base32.
+func jsonCID(content []byte) string {
+	digest := sha256.Sum256(content)
+	raw := append([]byte{0x01, 0x80, 0x04, 0x12, 0x20}, digest[:]...)
+	return "b" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))
+}
+
+func TestVerify(t *testing.T) {
+	hello := []byte("hello world\n")
+	for _, tc := range []struct {
+		name    string
+		cid     string
+		content []byte
+		err     error
+	}{
+		// ipfs add of an empty file and of "hello world\n", and the CIDv1
+		// of the latter.
+		{"dag-pb v0 empty", "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH", nil, nil},
+		{"dag-pb v0", "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", hello, nil},
+		{"dag-pb v1", "bafybeicg2rebjoofv4kbyovkw7af3rpiitvnl6i7ckcywaq6xjcxnc2mby", hello, nil},
+		// ipfs add --cid-version=1 of "hello world".
+		{"raw", "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e", []byte("hello world"), nil},
+		{"json", jsonCID([]byte(`{"name":"x"}`)), []byte(`{"name":"x"}`), nil},
+		{"dag-pb mismatch", "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", []byte("hello world"), ErrHashMismatch},
+		{"raw mismatch", "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e", hello, ErrHashMismatch},
+		{"json mismatch", jsonCID([]byte(`{"name":"x"}`)), []byte(`{"name": "x"}`), ErrHashMismatch},
+		// dag-cbor is not a codec Verify understands.
+		{"dag-cbor", "bafyreigbtj4x7ip5legnfznufuopl4sg4knzc2cof6duas4b3q2fy6swua", hello, ErrUnverifiable},
+	} {
+		err := Verify(tc.cid, tc.content)
+		if !errors.Is(err, tc.err) || (tc.err == nil) != (err == nil) {
+			t.Errorf("%s: Verify = %v, want %v", tc.name, err, tc.err)
+		}
+	}
+	if err := Verify("not a cid", hello); err == nil {
+		t.Error("Verify of an invalid CID succeeded")
+	}
+}
+
+// staticResolver serves documents by reference and counts the lookups.
+type staticResolver struct {
+	docs  map[string]string
+	calls int
+}
+
+func (r *staticResolver) Resolve(_ context.Context, ref string) (io.ReadCloser, error) {
+	r.calls++
+	doc, ok := r.docs[ref]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(strings.NewReader(doc)), nil
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	valid := `{"name":"alice"}`
+	id := jsonCID([]byte(valid))
+	resolver := &staticResolver{docs: map[string]string{
+		id:                      valid,
+		"https://a/ok":          valid,
+		"https://a/bad":         `{"name":""}`,
+		"https://a/extra-field": `{"name":"alice","website":"not a uri"}`,
+		"https://a/not-json":    `name: alice`,
+		"https://a/limit":       `{"name":"` + strings.Repeat("a", 53) + `"}`,
+		"https://a/large":       `{"name":"` + strings.Repeat("a", 54) + `"}`,
+		"https://a/forged":      `{"name":"mallory"}`,
+	}}
+	l, err := NewLoader(Config{Resolvers: []Resolver{resolver}, MaxSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := l.Load(ctx, JobCreator, id, "https://a/forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Verified || doc.Ref != id || doc.Fields["name"] != "alice" {
+		t.Errorf("Load = %+v", doc)
+	}
+	// Content-addressed documents are cached.
+	calls := resolver.calls
+	if _, err := l.Load(ctx, JobCreator, id, "https://a/forged"); err != nil || resolver.calls != calls {
+		t.Errorf("cached Load = %v after %d lookups", err, resolver.calls-calls)
+	}
+	// The URL is only used when the CID is not found, and must hash to it.
+	delete(resolver.docs, id)
+	if _, err := l.Load(ctx, ResourceProvider, id, "https://a/forged"); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("Load of a forged document = %v, want %v", err, ErrHashMismatch)
+	}
+
+	for _, tc := range []struct {
+		ref string
+		err error
+	}{
+		{"https://a/ok", nil},
+		{"https://a/bad", ErrInvalid},
+		{"https://a/extra-field", ErrInvalid},
+		{"https://a/not-json", ErrInvalid},
+		{"https://a/limit", nil},
+		{"https://a/large", ErrTooLarge},
+		{"https://a/missing", ErrNotFound},
+	} {
+		_, err := l.Load(ctx, JobCreator, "", tc.ref)
+		if !errors.Is(err, tc.err) || (tc.err == nil) != (err == nil) {
+			t.Errorf("Load(%s) = %v, want %v", tc.ref, err, tc.err)
+		}
+	}
+
+	strict, err := NewLoader(Config{Resolvers: []Resolver{resolver}, RequireVerified: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.Load(ctx, JobCreator, "meta", "https://a/ok"); !errors.Is(err, ErrUnverifiable) {
+		t.Errorf("strict Load of a URL = %v, want %v", err, ErrUnverifiable)
+	}
+}
+
+func TestDirResolver(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "pinned")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const id = "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"
+	for name, content := range map[string]string{
+		filepath.Join(dir, id+".json"):    "pinned",
+		filepath.Join(dir, "doc.json"):    "doc",
+		filepath.Join(root, "secret"):     "secret",
+		filepath.Join(root, "pinned-not"): "sibling",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewDirResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		ref  string
+		want string
+		err  error
+	}{
+		{id, "pinned", nil},
+		{"ipfs://" + id, "pinned", nil},
+		{"file://" + filepath.Join(dir, "doc.json"), "doc", nil},
+		{"file://" + filepath.Join(dir, "nope.json"), "", ErrNotFound},
+		{"bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e", "", ErrNotFound},
+		{"https://a/doc.json", "", ErrUnsupported},
+	} {
+		rc, err := r.Resolve(context.Background(), tc.ref)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("Resolve(%s) = %v, want %v", tc.ref, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", tc.ref, err)
+			continue
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != tc.want {
+			t.Errorf("Resolve(%s) = %q, want %q", tc.ref, got, tc.want)
+		}
+	}
+	for _, ref := range []string{
+		"file://" + filepath.Join(root, "secret"),
+		"file://" + dir + "/../secret",
+		"file://" + filepath.Join(root, "pinned-not"),
+		"file:///etc/passwd",
+	} {
+		if rc, err := r.Resolve(context.Background(), ref); err == nil || errors.Is(err, ErrNotFound) {
+			if rc != nil {
+				rc.Close()
+			}
+			t.Errorf("Resolve(%s) = %v, want an error for a path outside the directory", ref, err)
+		}
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr   string
+		public bool
+	}{
+		{"1.1.1.1", true},
+		{"100.63.255.255", true},
+		{"2606:4700:4700::1111", true},
+		{"::ffff:1.1.1.1", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.255", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+	} {
+		if got := publicAddr(netip.MustParseAddr(tc.addr)); got != tc.public {
+			t.Errorf("publicAddr(%s) = %t, want %t", tc.addr, got, tc.public)
+		}
+	}
+}
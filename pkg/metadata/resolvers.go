@@ -0,0 +1,222 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/cid"
+)
+
+// Resolver opens the document a metadata reference points to. References
+// are URLs ("https://…", "ipfs://<cid>") or bare CIDs. A resolver returns
+// ErrUnsupported for references it does not handle and ErrNotFound when the
+// document does not exist.
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (io.ReadCloser, error)
+}
+
+// contentID returns the CID a reference addresses: a bare CID or an
+// ipfs://<cid> URL without a path.
+func contentID(ref string) (string, bool) {
+	raw := strings.TrimPrefix(ref, "ipfs://")
+	if cid.Valid(raw) {
+		return raw, true
+	}
+	return "", false
+}
+
+// HTTPResolver fetches http and https URLs.
+type HTTPResolver struct {
+	client *http.Client
+}
+
+// NewHTTPResolver returns a resolver using client. A nil client uses
+// PublicClient, since the URLs come from on-chain records anyone can write.
+func NewHTTPResolver(client *http.Client) *HTTPResolver {
+	if client == nil {
+		client = PublicClient()
+	}
+	return &HTTPResolver{client: client}
+}
+
+// maxRedirects caps the redirects PublicClient follows.
+const maxRedirects = 5
+
+// cgnat is the shared address space of RFC 6598, which netip does not
+// count as private.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicClient returns an HTTP client that only connects to public unicast
+// addresses. The address is checked when dialing, after name resolution
+// and for every redirect, so neither a hostname resolving to an internal
+// address nor a redirect to one reaches it. It uses no proxy and follows at
+// most 5 redirects, all to http or https URLs.
+func PublicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addr) {
+				return fmt.Errorf("%w %s", ErrForbiddenAddress, addr)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("metadata: stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("metadata: redirect to %s URL", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// publicAddr reports whether addr is a public unicast address.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	switch {
+	case !addr.IsValid(), addr.IsUnspecified(), addr.IsLoopback(), addr.IsPrivate(),
+		addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast(), addr.IsInterfaceLocalMulticast(),
+		addr.IsMulticast(), cgnat.Contains(addr):
+		return false
+	case addr.Is4():
+		// 0.0.0.0/8 ("this network") and 240.0.0.0/4 (reserved, including
+		// broadcast).
+		return addr.As4()[0] != 0 && addr.As4()[0] < 240
+	}
+	return true
+}
+
+// Resolve implements Resolver.
+func (r *HTTPResolver) Resolve(ctx context.Context, ref string) (io.ReadCloser, error) {
+	u, err := url.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, ErrUnsupported
+	}
+	return get(ctx, r.client, u.String())
+}
+
+// GatewayResolver fetches CIDs and ipfs:// URLs through an IPFS HTTP
+// gateway.
+type GatewayResolver struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewGatewayResolver returns a resolver asking the gateway at base, e.g.
+// "https://ipfs.io". A nil client uses http.DefaultClient.
+func NewGatewayResolver(base string, client *http.Client) (*GatewayResolver, error) {
+	u, err := url.Parse(strings.TrimSuffix(base, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("metadata: gateway url: %w", err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &GatewayResolver{base: u, client: client}, nil
+}
+
+// Resolve implements Resolver. ipfs:// URLs may carry a path below the CID.
+func (r *GatewayResolver) Resolve(ctx context.Context, ref string) (io.ReadCloser, error) {
+	path, ok := strings.CutPrefix(ref, "ipfs://")
+	if !ok {
+		if _, ok := contentID(ref); !ok {
+			return nil, ErrUnsupported
+		}
+	}
+	return get(ctx, r.client, r.base.JoinPath("ipfs", path).String())
+}
+
+func get(ctx context.Context, client *http.Client, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode < 300:
+		return resp.Body, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("metadata: %s returned %s", u, resp.Status)
+}
+
+// DirResolver reads documents from a local directory, e.g. a pinned copy of
+// the metadata. Content-addressed references are looked up as <dir>/<cid>
+// or <dir>/<cid>.json; file:// URLs must point into the directory.
+type DirResolver struct {
+	dir string
+}
+
+// NewDirResolver returns a resolver reading from dir.
+func NewDirResolver(dir string) (*DirResolver, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &DirResolver{dir: abs}, nil
+}
+
+// Resolve implements Resolver.
+func (r *DirResolver) Resolve(_ context.Context, ref string) (io.ReadCloser, error) {
+	if c, ok := contentID(ref); ok {
+		for _, name := range []string{c, c + ".json"} {
+			f, err := os.Open(filepath.Join(r.dir, name))
+			if err == nil {
+				return f, nil
+			}
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		return nil, ErrNotFound
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "file" {
+		return nil, ErrUnsupported
+	}
+	path := filepath.Clean(u.Path)
+	if rel, err := filepath.Rel(r.dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("metadata: %s is outside %s", path, r.dir)
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/cid"
+)
+
+// Multicodec codes of the content codecs Verify understands.
+const (
+	codecRaw   = 0x55
+	codecDagPB = 0x70
+	codecJSON  = 0x0200
+)
+
+// Verify checks that content is the document the CID id addresses.
+//
+// Raw and json CIDs hash the content itself. dag-pb CIDs, which includes
+// every CIDv0, hash the UnixFS node wrapping the content; only documents
+// that fit into a single block (256 KiB as added by default) can be checked.
+func Verify(id string, content []byte) error {
+	c, err := cid.Parse(id)
+	if err != nil {
+		return err
+	}
+	block := content
+	switch c.Codec {
+	case codecRaw, codecJSON:
+	case codecDagPB:
+		block = unixfsFile(content)
+	default:
+		return fmt.Errorf("%w: cannot verify %s content", ErrUnverifiable, c.CodecName())
+	}
+	digest, err := sum(c.Hash.Code, block)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, c.Hash.Digest) {
+		return fmt.Errorf("%w: content does not hash to %s", ErrHashMismatch, id)
+	}
+	return nil
+}
+
+func sum(code uint64, data []byte) ([]byte, error) {
+	switch code {
+	case 0x00:
+		return data, nil
+	case 0x12:
+		d := sha256.Sum256(data)
+		return d[:], nil
+	case 0x13:
+		d := sha512.Sum512(data)
+		return d[:], nil
+	case 0x16:
+		d := sha3.Sum256(data)
+		return d[:], nil
+	case 0x1b:
+		return crypto.Keccak256(data), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported multihash 0x%x", ErrUnverifiable, code)
+}
+
+// unixfsFile encodes content as the single dag-pb block `ipfs add` produces
+// for a small file: a PBNode without links whose Data is a UnixFS File
+// message holding the content.
+func unixfsFile(content []byte) []byte {
+	var data []byte
+	data = protoVarint(data, 1, 2) // Type: File
+	if len(content) > 0 {
+		data = protoBytes(data, 2, content)
+	}
+	data = protoVarint(data, 3, uint64(len(content))) // filesize
+	return protoBytes(nil, 1, data)
+}
+
+func protoVarint(buf []byte, field int, v uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3)
+	return binary.AppendUvarint(buf, v)
+}
+
+func protoBytes(buf []byte, field int, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
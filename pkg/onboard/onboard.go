@@ -0,0 +1,491 @@
+// Package onboard brings a resource provider online.
+//
+// A new resource provider needs a LilypadUser account with its metadata and
+// the ResourceProvider role, optionally the Validator role, and collateral
+// in LilypadPaymentEngine escrow paid through
+// LilypadProxy.acceptResourceProviderCollateral after approving the payment
+// engine to pull the tokens. Run performs each of these steps unless the
+// chain shows it is already done, so it can be rerun after a failure, and
+// ends with a readiness report.
+package onboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/metadata"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Config describes the resource provider to onboard.
+type Config struct {
+	// Provider signs the token approval and the collateral deposit; its
+	// From is the resource provider account.
+	Provider *bind.TransactOpts
+	// Controller signs insertUser, updateUserMetadata and addRole and must
+	// hold CONTROLLER_ROLE on LilypadUser. Without one those steps are
+	// skipped; the first deposit then registers the provider with empty
+	// metadata.
+	Controller *bind.TransactOpts
+	// MetadataID and URL are stored on the LilypadUser account.
+	MetadataID string
+	URL        string
+	// Validator also assigns the Validator role.
+	Validator bool
+	// Collateral is the escrow the provider should hold, the proxy's
+	// minimum if nil.
+	Collateral *big.Int
+	// Metadata, if set, loads and validates the metadata document before
+	// anything is sent and again for the readiness report.
+	Metadata *metadata.Loader
+}
+
+// Status is the outcome of a step.
+type Status string
+
+const (
+	// Sent is a step whose transaction was mined.
+	Sent Status = "sent"
+	// AlreadyDone is a step the chain shows needs no transaction.
+	AlreadyDone Status = "done"
+	// Skipped is a step that could not be taken, see Step.Detail.
+	Skipped Status = "skipped"
+)
+
+// Step is a step of the onboarding sequence.
+type Step struct {
+	Name   string       `json:"name"`
+	Status Status       `json:"status"`
+	Tx     *common.Hash `json:"tx,omitempty"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// Readiness is the state of a resource provider account.
+type Readiness struct {
+	Block            uint64         `json:"block"`
+	Account          common.Address `json:"account"`
+	Registered       bool           `json:"registered"`
+	ResourceProvider bool           `json:"resource_provider"`
+	Validator        bool           `json:"validator"`
+	MetadataID       string         `json:"metadata_id"`
+	URL              string         `json:"url"`
+	// MetadataValid is set when a Loader validated the metadata document.
+	MetadataValid bool     `json:"metadata_valid"`
+	Escrow        *big.Int `json:"escrow"`
+	ActiveEscrow  *big.Int `json:"active_escrow"`
+	Collateral    *big.Int `json:"collateral"`
+	// LockedUntil is when the escrow becomes withdrawable; every deposit
+	// extends it by the collateral lock duration.
+	LockedUntil time.Time `json:"locked_until"`
+	Problems    []string  `json:"problems"`
+}
+
+// Ready reports whether no problems were found.
+func (r *Readiness) Ready() bool {
+	return len(r.Problems) == 0
+}
+
+// WriteText writes the readiness report.
+func (r *Readiness) WriteText(w io.Writer) error {
+	yes := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	lines := []string{
+		fmt.Sprintf("account %s at block %d", r.Account.Hex(), r.Block),
+		fmt.Sprintf("  registered         %s", yes(r.Registered)),
+		fmt.Sprintf("  resource provider  %s", yes(r.ResourceProvider)),
+		fmt.Sprintf("  validator          %s", yes(r.Validator)),
+		fmt.Sprintf("  metadata           %q %q (validated: %s)", r.MetadataID, r.URL, yes(r.MetadataValid)),
+		fmt.Sprintf("  escrow             %s of %s wanted, %s active", r.Escrow, r.Collateral, r.ActiveEscrow),
+	}
+	if r.LockedUntil.IsZero() {
+		lines = append(lines, "  locked until       never deposited")
+	} else {
+		lines = append(lines, fmt.Sprintf("  locked until       %s", r.LockedUntil.UTC().Format(time.RFC3339)))
+	}
+	if r.Ready() {
+		lines = append(lines, "ready")
+	} else {
+		lines = append(lines, "not ready:")
+		for _, p := range r.Problems {
+			lines = append(lines, "  - "+p)
+		}
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the readiness report as indented JSON.
+func (r *Readiness) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Report is the outcome of Run.
+type Report struct {
+	Steps     []Step     `json:"steps"`
+	Readiness *Readiness `json:"readiness"`
+}
+
+// WriteText writes the steps and the readiness report.
+func (r *Report) WriteText(w io.Writer) error {
+	for _, s := range r.Steps {
+		line := fmt.Sprintf("%-8s %s", s.Status, s.Name)
+		if s.Tx != nil {
+			line += " " + s.Tx.Hex()
+		}
+		if s.Detail != "" {
+			line += ": " + s.Detail
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	if r.Readiness == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	return r.Readiness.WriteText(w)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type onboarder struct {
+	c        *client.Client
+	cfg      Config
+	receipts bind.DeployBackend
+	userABI  *abi.ABI
+	report   *Report
+}
+
+// Run onboards the resource provider of cfg. It returns the report so far
+// along with any error; steps after a failure are not attempted.
+func Run(ctx context.Context, c *client.Client, cfg Config) (*Report, error) {
+	if c.User == nil || c.Proxy == nil || c.PaymentEngine == nil || c.Token == nil {
+		return nil, errors.New("onboard: client needs the user, proxy, payment engine and token contracts")
+	}
+	if cfg.Provider == nil {
+		return nil, errors.New("onboard: no provider account")
+	}
+	receipts, err := c.Receipts()
+	if err != nil {
+		return nil, err
+	}
+	userABI, err := lilypaduser.LilypadUserMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	o := &onboarder{c: c, cfg: cfg, receipts: receipts, userABI: userABI, report: &Report{}}
+	if err := o.run(ctx); err != nil {
+		return o.report, err
+	}
+	o.report.Readiness, err = Check(ctx, c, cfg.Provider.From, cfg)
+	return o.report, err
+}
+
+func (o *onboarder) run(ctx context.Context) error {
+	account := o.cfg.Provider.From
+	call := &bind.CallOpts{Context: ctx}
+
+	if o.cfg.Metadata != nil && (o.cfg.MetadataID != "" || o.cfg.URL != "") {
+		if _, err := o.cfg.Metadata.Load(ctx, metadata.ResourceProvider, o.cfg.MetadataID, o.cfg.URL); err != nil {
+			return fmt.Errorf("onboard: metadata: %w", err)
+		}
+		o.step(Step{Name: "validate metadata", Status: AlreadyDone})
+	}
+
+	if o.cfg.Controller != nil {
+		held, err := o.c.User.HasRole(call, roles.Controller, o.cfg.Controller.From)
+		if err != nil {
+			return fmt.Errorf("onboard: checking controller: %w", err)
+		}
+		if !held {
+			return fmt.Errorf("onboard: %s lacks %s on LilypadUser", o.cfg.Controller.From.Hex(), roles.ControllerName)
+		}
+	}
+
+	// Registration.
+	user, err := o.c.User.GetUser(call, account)
+	registered := err == nil
+	if err != nil && !revert.Is(err, "LilypadUser__UserNotFound", o.userABI) {
+		return fmt.Errorf("onboard: reading user: %w", err)
+	}
+	switch {
+	case registered:
+		o.step(Step{Name: "insert user", Status: AlreadyDone})
+	case o.cfg.Controller == nil:
+		o.step(Step{Name: "insert user", Status: Skipped, Detail: "no controller; the deposit registers the provider without metadata"})
+	default:
+		if err := o.send(ctx, "insert user", o.c.Addresses.User, "insertUser", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return o.c.User.InsertUser(opts, account, o.cfg.MetadataID, o.cfg.URL, uint8(roles.ResourceProvider))
+		}, o.cfg.Controller); err != nil {
+			return err
+		}
+		registered = true
+		user.MetadataID, user.Url = o.cfg.MetadataID, o.cfg.URL
+	}
+
+	if registered {
+		switch {
+		case o.cfg.MetadataID == "" && o.cfg.URL == "":
+			// Keep whatever metadata the account has.
+		case user.MetadataID == o.cfg.MetadataID && user.Url == o.cfg.URL:
+			o.step(Step{Name: "update metadata", Status: AlreadyDone})
+		case o.cfg.Controller == nil:
+			o.step(Step{Name: "update metadata", Status: Skipped, Detail: "no controller"})
+		default:
+			if err := o.send(ctx, "update metadata", o.c.Addresses.User, "updateUserMetadata", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return o.c.User.UpdateUserMetadata(opts, account, o.cfg.MetadataID, o.cfg.URL)
+			}, o.cfg.Controller); err != nil {
+				return err
+			}
+		}
+	}
+
+	wanted := []roles.UserType{roles.ResourceProvider}
+	if o.cfg.Validator {
+		wanted = append(wanted, roles.Validator)
+	}
+	for _, role := range wanted {
+		name := "add " + role.String() + " role"
+		if !registered {
+			if role == roles.ResourceProvider {
+				// The deposit registers the provider with this role.
+				continue
+			}
+			o.step(Step{Name: name, Status: Skipped, Detail: "account not registered"})
+			continue
+		}
+		held, err := o.c.User.HasRole0(call, account, uint8(role))
+		if err != nil {
+			return fmt.Errorf("onboard: checking %s role: %w", role, err)
+		}
+		if held {
+			o.step(Step{Name: name, Status: AlreadyDone})
+			continue
+		}
+		if role == roles.ResourceProvider {
+			jobCreator, err := o.c.User.HasRole0(call, account, uint8(roles.JobCreator))
+			if err != nil {
+				return fmt.Errorf("onboard: checking %s role: %w", roles.JobCreator, err)
+			}
+			if jobCreator {
+				return fmt.Errorf("onboard: %s is a job creator and cannot become a resource provider", account.Hex())
+			}
+		}
+		if o.cfg.Controller == nil {
+			if role == roles.ResourceProvider {
+				// The proxy refuses collateral from registered accounts
+				// without the role.
+				return fmt.Errorf("onboard: %s lacks the %s role and there is no controller to add it", account.Hex(), role)
+			}
+			o.step(Step{Name: name, Status: Skipped, Detail: "no controller"})
+			continue
+		}
+		if err := o.send(ctx, name, o.c.Addresses.User, "addRole", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return o.c.User.AddRole(opts, account, uint8(role))
+		}, o.cfg.Controller); err != nil {
+			return err
+		}
+	}
+
+	// Collateral.
+	collateral, err := o.collateral(call)
+	if err != nil {
+		return err
+	}
+	escrow, err := o.c.PaymentEngine.EscrowBalances(call, account)
+	if err != nil {
+		return fmt.Errorf("onboard: reading escrow balance: %w", err)
+	}
+	if escrow.Cmp(collateral) >= 0 {
+		o.step(Step{Name: "approve collateral", Status: AlreadyDone})
+		o.step(Step{Name: "deposit collateral", Status: AlreadyDone, Detail: fmt.Sprintf("escrow %s", escrow)})
+		return nil
+	}
+	// Each deposit of a resource provider must meet the minimum on its own.
+	amount := new(big.Int).Sub(collateral, escrow)
+	minimum, err := o.c.Proxy.GetMinimumResourceProviderCollateralAmount(call)
+	if err != nil {
+		return fmt.Errorf("onboard: reading minimum collateral: %w", err)
+	}
+	if amount.Cmp(minimum) < 0 {
+		amount.Set(minimum)
+	}
+	balance, err := o.c.Token.BalanceOf(call, account)
+	if err != nil {
+		return fmt.Errorf("onboard: reading token balance: %w", err)
+	}
+	if balance.Cmp(amount) < 0 {
+		return fmt.Errorf("onboard: wallet balance %s is below the deposit of %s", balance, amount)
+	}
+
+	d, err := o.c.Deposit(ctx, o.cfg.Provider, amount, "acceptResourceProviderCollateral", o.c.Proxy.AcceptResourceProviderCollateral)
+	switch {
+	case d.Approval != nil:
+		o.mined("approve collateral", d.Approval)
+	case d.Allowance != nil && d.Allowance.Cmp(amount) >= 0:
+		o.step(Step{Name: "approve collateral", Status: AlreadyDone})
+	}
+	if d.Deposit != nil {
+		o.mined("deposit collateral", d.Deposit)
+	}
+	if err != nil {
+		return fmt.Errorf("onboard: deposit collateral: %w", err)
+	}
+	return nil
+}
+
+func (o *onboarder) collateral(call *bind.CallOpts) (*big.Int, error) {
+	if o.cfg.Collateral != nil {
+		return o.cfg.Collateral, nil
+	}
+	minimum, err := o.c.Proxy.GetMinimumResourceProviderCollateralAmount(call)
+	if err != nil {
+		return nil, fmt.Errorf("onboard: reading minimum collateral: %w", err)
+	}
+	return minimum, nil
+}
+
+func (o *onboarder) step(s Step) {
+	o.report.Steps = append(o.report.Steps, s)
+}
+
+// send sends a transaction from signer and waits for it to be mined. An
+// error denying method on the contract at to is explained as a
+// *client.Denial.
+func (o *onboarder) send(ctx context.Context, name string, to common.Address, method string, transact func(*bind.TransactOpts) (*types.Transaction, error), signer *bind.TransactOpts) error {
+	opts := *signer
+	opts.Context = ctx
+	tx, err := transact(&opts)
+	if err != nil {
+		return fmt.Errorf("onboard: %s: %w", name, o.c.Explain(ctx, err, to, method, signer.From))
+	}
+	receipt, err := bind.WaitMined(ctx, o.receipts, tx)
+	if err != nil {
+		return fmt.Errorf("onboard: waiting for %s: %w", name, err)
+	}
+	o.mined(name, receipt)
+	if receipt.Status == types.ReceiptStatusFailed {
+		return fmt.Errorf("onboard: %s transaction %s reverted", name, tx.Hash().Hex())
+	}
+	return nil
+}
+
+// mined records the step name as sent, or as skipped if its transaction
+// reverted.
+func (o *onboarder) mined(name string, receipt *types.Receipt) {
+	hash := receipt.TxHash
+	if receipt.Status == types.ReceiptStatusFailed {
+		o.step(Step{Name: name, Status: Skipped, Tx: &hash, Detail: "reverted"})
+		return
+	}
+	o.step(Step{Name: name, Status: Sent, Tx: &hash})
+}
+
+// Check reports the readiness of account against cfg; only cfg's metadata,
+// Validator, Collateral and Metadata fields are used.
+func Check(ctx context.Context, c *client.Client, account common.Address, cfg Config) (*Readiness, error) {
+	head, err := c.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("onboard: reading head: %w", err)
+	}
+	call := &bind.CallOpts{Context: ctx, BlockNumber: head.Number}
+	r := &Readiness{Block: head.Number.Uint64(), Account: account}
+	problem := func(format string, args ...interface{}) {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+
+	userABI, err := lilypaduser.LilypadUserMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	user, err := c.User.GetUser(call, account)
+	switch {
+	case err == nil:
+		r.Registered = true
+		r.MetadataID, r.URL = user.MetadataID, user.Url
+	case revert.Is(err, "LilypadUser__UserNotFound", userABI):
+		problem("not registered in LilypadUser")
+	default:
+		return nil, fmt.Errorf("onboard: reading user: %w", err)
+	}
+	if r.Registered {
+		if r.ResourceProvider, err = c.User.HasRole0(call, account, uint8(roles.ResourceProvider)); err != nil {
+			return nil, fmt.Errorf("onboard: checking %s role: %w", roles.ResourceProvider, err)
+		}
+		if r.Validator, err = c.User.HasRole0(call, account, uint8(roles.Validator)); err != nil {
+			return nil, fmt.Errorf("onboard: checking %s role: %w", roles.Validator, err)
+		}
+		if !r.ResourceProvider {
+			problem("lacks the %s role", roles.ResourceProvider)
+		}
+		if cfg.Validator && !r.Validator {
+			problem("lacks the %s role", roles.Validator)
+		}
+		if (cfg.MetadataID != "" || cfg.URL != "") && (r.MetadataID != cfg.MetadataID || r.URL != cfg.URL) {
+			problem("metadata is %q %q, want %q %q", r.MetadataID, r.URL, cfg.MetadataID, cfg.URL)
+		}
+		switch {
+		case r.MetadataID == "" && r.URL == "":
+			problem("no metadata")
+		case cfg.Metadata != nil:
+			if _, err := cfg.Metadata.Load(ctx, metadata.ResourceProvider, r.MetadataID, r.URL); err != nil {
+				problem("metadata: %v", err)
+			} else {
+				r.MetadataValid = true
+			}
+		}
+	}
+
+	if r.Escrow, err = c.PaymentEngine.EscrowBalances(call, account); err != nil {
+		return nil, fmt.Errorf("onboard: reading escrow balance: %w", err)
+	}
+	if r.ActiveEscrow, err = c.PaymentEngine.ActiveEscrow(call, account); err != nil {
+		return nil, fmt.Errorf("onboard: reading active escrow: %w", err)
+	}
+	if r.Collateral = cfg.Collateral; r.Collateral == nil {
+		if r.Collateral, err = c.Proxy.GetMinimumResourceProviderCollateralAmount(call); err != nil {
+			return nil, fmt.Errorf("onboard: reading minimum collateral: %w", err)
+		}
+	}
+	if r.Escrow.Cmp(r.Collateral) < 0 {
+		problem("escrow %s is below the collateral of %s", r.Escrow, r.Collateral)
+	}
+	unlock, err := c.PaymentEngine.DepositTimestamps(call, account)
+	if err != nil {
+		return nil, fmt.Errorf("onboard: reading deposit lock: %w", err)
+	}
+	if unlock.Sign() > 0 {
+		r.LockedUntil = time.Unix(unlock.Int64(), 0)
+	}
+	return r, nil
+}
@@ -0,0 +1,316 @@
+package onboard
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+var book = client.AddressBook{
+	Token:         common.HexToAddress("0x02"),
+	User:          common.HexToAddress("0x03"),
+	PaymentEngine: common.HexToAddress("0x06"),
+	Proxy:         common.HexToAddress("0x07"),
+}
+
+// revertError carries revert data the way go-ethereum's RPC errors do.
+type revertError []byte
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e) }
+
+// fakeChain holds the LilypadUser accounts, escrow and token state of the
+// calls Run makes and applies the transactions it sends. A transaction of
+// the method fail is rejected.
+type fakeChain struct {
+	bind.ContractBackend
+	t *testing.T
+
+	controller common.Address
+	users      map[common.Address]lilypaduser.SharedStructsUser
+	roles      map[common.Address]map[uint8]bool
+	escrow     *big.Int
+	balance    *big.Int
+	allowance  *big.Int
+	minimum    *big.Int
+	unlock     *big.Int
+
+	fail     string
+	sent     []string
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (f *fakeChain) abi(to common.Address) *abi.ABI {
+	name := map[common.Address]string{
+		book.Token:         "LilypadToken",
+		book.User:          "LilypadUser",
+		book.PaymentEngine: "LilypadPaymentEngine",
+		book.Proxy:         "LilypadProxy",
+	}[to]
+	parsed, ok := client.ContractABI(name)
+	if !ok {
+		f.t.Fatalf("call to unknown contract %s", to.Hex())
+	}
+	return parsed
+}
+
+func (f *fakeChain) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(int64(len(f.sent)))}, nil
+}
+
+func (f *fakeChain) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (f *fakeChain) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	parsed := f.abi(*msg.To)
+	method, err := parsed.MethodById(msg.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	switch method.Name {
+	case "hasRole":
+		out = common.Hash(args[0].([32]byte)) == roles.Controller && args[1].(common.Address) == f.controller
+	case "getUser":
+		user, ok := f.users[args[0].(common.Address)]
+		if !ok {
+			id := parsed.Errors["LilypadUser__UserNotFound"].ID
+			return nil, revertError(id[:4])
+		}
+		out = user
+	case "hasRole0":
+		out = f.roles[args[0].(common.Address)][args[1].(uint8)]
+	case "getMinimumResourceProviderCollateralAmount":
+		out = f.minimum
+	case "escrowBalances":
+		out = f.escrow
+	case "activeEscrow":
+		out = new(big.Int)
+	case "depositTimestamps":
+		out = f.unlock
+	case "balanceOf":
+		out = f.balance
+	case "allowance":
+		out = f.allowance
+	default:
+		return nil, errors.New("unexpected call to " + method.Name)
+	}
+	return method.Outputs.Pack(out)
+}
+
+func (f *fakeChain) insert(account common.Address, metadataID, url string, role roles.UserType) {
+	f.users[account] = lilypaduser.SharedStructsUser{UserAddress: account, MetadataID: metadataID, Url: url}
+	f.roles[account] = map[uint8]bool{uint8(role): true}
+}
+
+func (f *fakeChain) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	method, err := f.abi(*tx.To()).MethodById(tx.Data()[:4])
+	if err != nil {
+		return err
+	}
+	if method.Name == f.fail {
+		return errors.New("connection reset")
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return err
+	}
+	f.sent = append(f.sent, method.Name)
+	switch method.Name {
+	case "insertUser":
+		f.insert(args[0].(common.Address), args[1].(string), args[2].(string), roles.UserType(args[3].(uint8)))
+	case "updateUserMetadata":
+		account := args[0].(common.Address)
+		f.users[account] = lilypaduser.SharedStructsUser{UserAddress: account, MetadataID: args[1].(string), Url: args[2].(string)}
+	case "addRole":
+		f.roles[args[0].(common.Address)][args[1].(uint8)] = true
+	case "approve":
+		f.allowance = args[1].(*big.Int)
+	case "acceptResourceProviderCollateral":
+		amount := args[0].(*big.Int)
+		f.escrow = new(big.Int).Add(f.escrow, amount)
+		f.balance = new(big.Int).Sub(f.balance, amount)
+		f.allowance = new(big.Int).Sub(f.allowance, amount)
+		f.unlock = big.NewInt(1_800_000_000)
+	}
+	f.receipts[tx.Hash()] = &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: big.NewInt(int64(len(f.sent)))}
+	return nil
+}
+
+func (f *fakeChain) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := f.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (f *fakeChain) PendingCodeAt(ctx context.Context, addr common.Address) ([]byte, error) {
+	return f.CodeAt(ctx, addr, nil)
+}
+
+func (f *fakeChain) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return uint64(len(f.sent)), nil
+}
+
+func (f *fakeChain) SuggestGasPrice(context.Context) (*big.Int, error) { return big.NewInt(1), nil }
+func (f *fakeChain) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 100000, nil
+}
+
+func transactor(t *testing.T) *bind.TransactOpts {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+// setup returns an unregistered provider with enough tokens for the
+// minimum collateral of 100, to be onboarded as a validator too.
+func setup(t *testing.T) (*fakeChain, *client.Client, Config) {
+	cfg := Config{Provider: transactor(t), Controller: transactor(t), MetadataID: "meta", URL: "https://rp", Validator: true}
+	chain := &fakeChain{
+		t:          t,
+		controller: cfg.Controller.From,
+		users:      make(map[common.Address]lilypaduser.SharedStructsUser),
+		roles:      make(map[common.Address]map[uint8]bool),
+		escrow:     new(big.Int),
+		balance:    big.NewInt(1000),
+		allowance:  new(big.Int),
+		minimum:    big.NewInt(100),
+		unlock:     new(big.Int),
+		receipts:   make(map[common.Hash]*types.Receipt),
+	}
+	c, err := client.New(chain, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain, c, cfg
+}
+
+type step struct {
+	name   string
+	status Status
+}
+
+func steps(r *Report) []step {
+	var out []step
+	for _, s := range r.Steps {
+		out = append(out, step{s.Name, s.Status})
+	}
+	return out
+}
+
+func TestRunResumes(t *testing.T) {
+	ctx := context.Background()
+	// The transactions Run sends and the steps they belong to.
+	sends := []struct{ method, step string }{
+		{"insertUser", "insert user"},
+		{"addRole", "add Validator role"},
+		{"approve", "approve collateral"},
+		{"acceptResourceProviderCollateral", "deposit collateral"},
+	}
+	for i, failed := range sends {
+		chain, c, cfg := setup(t)
+		chain.fail = failed.method
+		if _, err := Run(ctx, c, cfg); err == nil {
+			t.Fatalf("%s: Run succeeded", failed.method)
+		}
+		var sent []string
+		for _, s := range sends[:i] {
+			sent = append(sent, s.method)
+		}
+		if !reflect.DeepEqual(chain.sent, sent) {
+			t.Fatalf("%s: sent %v before failing, want %v", failed.method, chain.sent, sent)
+		}
+
+		// A rerun takes up where the failed run stopped.
+		chain.fail = ""
+		report, err := Run(ctx, c, cfg)
+		if err != nil {
+			t.Fatalf("%s: rerun: %v", failed.method, err)
+		}
+		status := func(j int) Status {
+			if j < i {
+				return AlreadyDone
+			}
+			return Sent
+		}
+		want := []step{
+			{"insert user", status(0)},
+			{"update metadata", AlreadyDone},
+			{"add ResourceProvider role", AlreadyDone},
+			{"add Validator role", status(1)},
+			{"approve collateral", status(2)},
+			{"deposit collateral", status(3)},
+		}
+		if got := steps(report); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: rerun steps\n%v\nwant\n%v", failed.method, got, want)
+		}
+		if len(chain.sent) != len(sends) || !report.Readiness.Ready() {
+			t.Errorf("%s: rerun sent %v, problems %v", failed.method, chain.sent, report.Readiness.Problems)
+		}
+
+		// Once onboarded, every step is done and nothing is sent.
+		report, err = Run(ctx, c, cfg)
+		if err != nil {
+			t.Fatalf("%s: third run: %v", failed.method, err)
+		}
+		for _, s := range report.Steps {
+			if s.Status != AlreadyDone {
+				t.Errorf("%s: third run %s %s, want %s", failed.method, s.Name, s.Status, AlreadyDone)
+			}
+		}
+		if len(chain.sent) != len(sends) {
+			t.Errorf("%s: third run sent %v", failed.method, chain.sent[len(sends):])
+		}
+	}
+}
+
+func TestRunUpdatesMetadata(t *testing.T) {
+	chain, c, cfg := setup(t)
+	chain.insert(cfg.Provider.From, "old", "https://old", roles.ResourceProvider)
+	chain.allowance = big.NewInt(100)
+	cfg.Validator = false
+	report, err := Run(context.Background(), c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []step{
+		{"insert user", AlreadyDone},
+		{"update metadata", Sent},
+		{"add ResourceProvider role", AlreadyDone},
+		{"approve collateral", AlreadyDone},
+		{"deposit collateral", Sent},
+	}
+	if got := steps(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps\n%v\nwant\n%v", got, want)
+	}
+	if user := chain.users[cfg.Provider.From]; user.MetadataID != "meta" || user.Url != "https://rp" {
+		t.Errorf("metadata %q %q after the update", user.MetadataID, user.Url)
+	}
+}
@@ -0,0 +1,191 @@
+// Package poll serves log subscriptions by polling eth_getLogs, so the
+// generated Watch methods work against HTTP-only RPC endpoints.
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Config tunes the polling loop. Zero fields take their defaults.
+type Config struct {
+	// MinInterval is the polling interval while logs keep arriving.
+	// Defaults to 1 second.
+	MinInterval time.Duration
+	// MaxInterval caps the interval, which doubles after every poll that
+	// finds no new block or fails. Defaults to 15 seconds.
+	MaxInterval time.Duration
+	// MaxRange caps the number of blocks requested per eth_getLogs call.
+	// Defaults to 1000.
+	MaxRange uint64
+	// MaxErrors is how many consecutive failed polls end the subscription
+	// with an error. Defaults to 5.
+	MaxErrors int
+}
+
+func (c Config) withDefaults() Config {
+	if c.MinInterval <= 0 {
+		c.MinInterval = time.Second
+	}
+	if c.MaxInterval < c.MinInterval {
+		c.MaxInterval = 15 * time.Second
+		if c.MaxInterval < c.MinInterval {
+			c.MaxInterval = c.MinInterval
+		}
+	}
+	if c.MaxRange == 0 {
+		c.MaxRange = 1000
+	}
+	if c.MaxErrors <= 0 {
+		c.MaxErrors = 5
+	}
+	return c
+}
+
+// Backend wraps a contract backend and replaces its SubscribeFilterLogs with
+// polling. All other calls go to the wrapped backend. Backend only has the
+// methods of bind.ContractBackend, so optional interfaces of the wrapped
+// backend, such as bind.DeployBackend or bind.PendingContractCaller, are
+// asserted on Unwrap(backend) instead.
+type Backend struct {
+	bind.ContractBackend
+	cfg Config
+}
+
+// Wrap returns backend with polled log subscriptions.
+func Wrap(backend bind.ContractBackend, cfg Config) *Backend {
+	return &Backend{ContractBackend: backend, cfg: cfg.withDefaults()}
+}
+
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() bind.ContractBackend {
+	return b.ContractBackend
+}
+
+// Unwrap returns the backend behind any polling wrappers of backend, or
+// backend itself if it is not wrapped.
+func Unwrap(backend bind.ContractBackend) bind.ContractBackend {
+	for {
+		w, ok := backend.(interface{ Unwrap() bind.ContractBackend })
+		if !ok {
+			return backend
+		}
+		backend = w.Unwrap()
+	}
+}
+
+// SubscribeFilterLogs polls for logs matching q and sends them to ch. Without
+// q.FromBlock, only logs in blocks after the current head are delivered, as
+// with a websocket subscription. With q.ToBlock, the subscription ends once
+// that block has been delivered. Logs are delivered once per block number;
+// use the follower package where reorgs matter.
+func (b *Backend) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	if q.BlockHash != nil {
+		return nil, errors.New("poll: cannot subscribe to a single block")
+	}
+	var next uint64
+	if q.FromBlock != nil {
+		next = q.FromBlock.Uint64()
+	} else {
+		head, err := b.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		next = head.Number.Uint64() + 1
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		return b.loop(q, next, ch, quit)
+	}), nil
+}
+
+func (b *Backend) loop(q ethereum.FilterQuery, next uint64, ch chan<- types.Log, quit <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-quit
+		cancel()
+	}()
+
+	interval := b.cfg.MinInterval
+	failures := 0
+	for {
+		delivered, done, err := b.poll(ctx, q, &next, ch)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil
+		case err != nil:
+			failures++
+			if failures >= b.cfg.MaxErrors {
+				return fmt.Errorf("poll: %d consecutive failures: %w", failures, err)
+			}
+			interval = b.slower(interval)
+		case done:
+			return nil
+		case delivered:
+			failures = 0
+			interval = b.cfg.MinInterval
+			// More blocks may be waiting behind MaxRange; poll again at once.
+			continue
+		default:
+			failures = 0
+			interval = b.slower(interval)
+		}
+		select {
+		case <-time.After(interval):
+		case <-quit:
+			return nil
+		}
+	}
+}
+
+func (b *Backend) slower(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval > b.cfg.MaxInterval {
+		interval = b.cfg.MaxInterval
+	}
+	return interval
+}
+
+// poll fetches and delivers the logs of the blocks from *next up to the head,
+// at most MaxRange blocks, and advances *next. It reports whether any blocks
+// were covered and whether q.ToBlock has been reached.
+func (b *Backend) poll(ctx context.Context, q ethereum.FilterQuery, next *uint64, ch chan<- types.Log) (bool, bool, error) {
+	head, err := b.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return false, false, err
+	}
+	last := head.Number.Uint64()
+	if q.ToBlock != nil && q.ToBlock.Uint64() < last {
+		last = q.ToBlock.Uint64()
+	}
+	if *next > last {
+		return false, q.ToBlock != nil && *next > q.ToBlock.Uint64(), nil
+	}
+	if last-*next+1 > b.cfg.MaxRange {
+		last = *next + b.cfg.MaxRange - 1
+	}
+	query := q
+	query.FromBlock = new(big.Int).SetUint64(*next)
+	query.ToBlock = new(big.Int).SetUint64(last)
+	logs, err := b.FilterLogs(ctx, query)
+	if err != nil {
+		return false, false, err
+	}
+	for _, log := range logs {
+		select {
+		case ch <- log:
+		case <-ctx.Done():
+			return false, false, ctx.Err()
+		}
+	}
+	*next = last + 1
+	return true, q.ToBlock != nil && *next > q.ToBlock.Uint64(), nil
+}
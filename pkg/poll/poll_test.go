@@ -0,0 +1,213 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeChain has one log per block up to its head. fail lists, per poll,
+// whether reading the head fails; polls past the list succeed.
+type fakeChain struct {
+	bind.ContractBackend
+
+	mu    sync.Mutex
+	head  uint64
+	fail  []bool
+	polls int
+	spans [][2]uint64
+}
+
+var errDown = errors.New("node down")
+
+func (f *fakeChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.polls++
+	if f.polls <= len(f.fail) && f.fail[f.polls-1] {
+		return nil, errDown
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(f.head)}, nil
+}
+
+func (f *fakeChain) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	f.spans = append(f.spans, [2]uint64{from, to})
+	var logs []types.Log
+	for b := from; b <= to; b++ {
+		logs = append(logs, types.Log{BlockNumber: b})
+	}
+	return logs, nil
+}
+
+func (f *fakeChain) setHead(n uint64) {
+	f.mu.Lock()
+	f.head = n
+	f.mu.Unlock()
+}
+
+func (f *fakeChain) requested() [][2]uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][2]uint64(nil), f.spans...)
+}
+
+var fast = Config{MinInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+
+// receive collects the block numbers of the logs sent to ch until n have
+// arrived.
+func receive(t *testing.T, ch <-chan types.Log, n int) []uint64 {
+	t.Helper()
+	var blocks []uint64
+	for len(blocks) < n {
+		select {
+		case log := <-ch:
+			blocks = append(blocks, log.BlockNumber)
+		case <-time.After(time.Second):
+			t.Fatalf("received blocks %v, want %d", blocks, n)
+		}
+	}
+	return blocks
+}
+
+func ended(t *testing.T, sub ethereum.Subscription) error {
+	t.Helper()
+	select {
+	case err := <-sub.Err():
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("subscription did not end")
+		return nil
+	}
+}
+
+func span(from, to uint64) []uint64 {
+	var s []uint64
+	for b := from; b <= to; b++ {
+		s = append(s, b)
+	}
+	return s
+}
+
+func TestSubscribeRange(t *testing.T) {
+	chain := &fakeChain{head: 10}
+	cfg := fast
+	cfg.MaxRange = 3
+	b := Wrap(chain, cfg)
+	ch := make(chan types.Log)
+	q := ethereum.FilterQuery{FromBlock: big.NewInt(2), ToBlock: big.NewInt(12)}
+	sub, err := b.SubscribeFilterLogs(context.Background(), q, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	if got := receive(t, ch, 9); !reflect.DeepEqual(got, span(2, 10)) {
+		t.Fatalf("received %v", got)
+	}
+	want := [][2]uint64{{2, 4}, {5, 7}, {8, 10}}
+	if got := chain.requested(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requested %v, want %v", got, want)
+	}
+	// The subscription waits for the head to reach ToBlock, then ends.
+	chain.setHead(20)
+	if got := receive(t, ch, 2); !reflect.DeepEqual(got, span(11, 12)) {
+		t.Fatalf("received %v", got)
+	}
+	if err := ended(t, sub); err != nil {
+		t.Errorf("subscription ended with %v", err)
+	}
+	if got := chain.requested(); !reflect.DeepEqual(got[len(got)-1], [2]uint64{11, 12}) {
+		t.Errorf("last request %v, want [11 12]", got[len(got)-1])
+	}
+}
+
+func TestSubscribeFromHead(t *testing.T) {
+	chain := &fakeChain{head: 10}
+	b := Wrap(chain, fast)
+	ch := make(chan types.Log)
+	sub, err := b.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{}, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	chain.setHead(12)
+	if got := receive(t, ch, 2); !reflect.DeepEqual(got, span(11, 12)) {
+		t.Errorf("received %v, want the blocks after the head", got)
+	}
+
+	hash := common.HexToHash("0x01")
+	if _, err := b.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{BlockHash: &hash}, ch); err == nil {
+		t.Error("subscribed to a single block")
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	// Failures below MaxErrors are retried, and a success resets the count.
+	chain := &fakeChain{head: 5, fail: []bool{true, true, false, true, true}}
+	cfg := fast
+	cfg.MaxErrors = 3
+	ch := make(chan types.Log)
+	sub, err := Wrap(chain, cfg).SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(5), ToBlock: big.NewInt(6)}, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, ch, 1); !reflect.DeepEqual(got, []uint64{5}) {
+		t.Fatalf("received %v", got)
+	}
+	chain.setHead(6)
+	receive(t, ch, 1)
+	if err := ended(t, sub); err != nil {
+		t.Errorf("subscription ended with %v", err)
+	}
+
+	chain = &fakeChain{fail: []bool{true, true, true, true}}
+	sub, err = Wrap(chain, cfg).SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: big.NewInt(0)}, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ended(t, sub); !errors.Is(err, errDown) {
+		t.Errorf("subscription ended with %v, want %v", err, errDown)
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	if chain.polls != 3 {
+		t.Errorf("%d polls, want 3", chain.polls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Wrap(nil, Config{MinInterval: time.Second, MaxInterval: 5 * time.Second})
+	var got []time.Duration
+	for interval := b.cfg.MinInterval; len(got) < 4; {
+		interval = b.slower(interval)
+		got = append(got, interval)
+	}
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("intervals %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		cfg, want Config
+	}{
+		{Config{}, Config{MinInterval: time.Second, MaxInterval: 15 * time.Second, MaxRange: 1000, MaxErrors: 5}},
+		{Config{MinInterval: time.Minute}, Config{MinInterval: time.Minute, MaxInterval: time.Minute, MaxRange: 1000, MaxErrors: 5}},
+		{Config{MinInterval: time.Millisecond, MaxInterval: time.Second, MaxRange: 10, MaxErrors: 1}, Config{MinInterval: time.Millisecond, MaxInterval: time.Second, MaxRange: 10, MaxErrors: 1}},
+	} {
+		if got := tc.cfg.withDefaults(); got != tc.want {
+			t.Errorf("%+v.withDefaults() = %+v, want %+v", tc.cfg, got, tc.want)
+		}
+	}
+}
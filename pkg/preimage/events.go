@@ -0,0 +1,378 @@
+package preimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	lilypadpaymentengine "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadPaymentEngine"
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	lilypadvalidation "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadValidation"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// ErrUnknownEvent is returned by Decode for logs it does not handle.
+var ErrUnknownEvent = errors.New("preimage: not an event with an indexed ID")
+
+// DealSaved is LilypadStorage__DealSaved with its plain deal ID.
+type DealSaved struct {
+	DealID           string
+	JobCreator       common.Address
+	ResourceProvider common.Address
+	Raw              types.Log
+}
+
+// DealStatusChanged is LilypadStorage__DealStatusChanged with its plain deal
+// ID.
+type DealStatusChanged struct {
+	DealID string
+	Status uint8
+	Raw    types.Log
+}
+
+// ResultSaved is LilypadStorage__ResultSaved with its plain result ID.
+type ResultSaved struct {
+	ResultID string
+	DealID   string
+	Raw      types.Log
+}
+
+// ResultStatusChanged is LilypadStorage__ResultStatusChanged with its plain
+// result ID.
+type ResultStatusChanged struct {
+	ResultID string
+	Status   uint8
+	Raw      types.Log
+}
+
+// ValidationResultSaved is LilypadStorage__ValidationResultSaved with its
+// plain validation result ID.
+type ValidationResultSaved struct {
+	ValidationResultID string
+	ResultID           string
+	Validator          common.Address
+	Raw                types.Log
+}
+
+// ValidationResultStatusChanged is
+// LilypadStorage__ValidationResultStatusChanged with its plain validation
+// result ID.
+type ValidationResultStatusChanged struct {
+	ValidationResultID string
+	Status             uint8
+	Raw                types.Log
+}
+
+// ActiveEscrowLockedForJob is LilypadPayment__ActiveEscrowLockedForJob with
+// its plain deal ID.
+type ActiveEscrowLockedForJob struct {
+	JobCreator       common.Address
+	ResourceProvider common.Address
+	DealID           string
+	Cost             *big.Int
+	Raw              types.Log
+}
+
+// Decoder turns the events that index string IDs into the types above.
+type Decoder struct {
+	resolver *Resolver
+	backend  ethereum.LogFilterer
+
+	storageAddr common.Address
+	storage     *lilypadstorage.LilypadStorageFilterer
+	storageIDs  map[common.Hash]string
+
+	paymentsAddr common.Address
+	payments     *lilypadpaymentengine.LilypadPaymentEngineFilterer
+	escrowLocked common.Hash
+	jobCompleted common.Hash
+	jobFailed    common.Hash
+
+	validationAddr      common.Address
+	validation          *lilypadvalidation.LilypadValidationFilterer
+	validationRequested common.Hash
+	validationProcessed common.Hash
+}
+
+// NewDecoder returns a Decoder for the storage, payment engine and
+// validation contracts of c, resolving IDs with resolver.
+func NewDecoder(c *client.Client, resolver *Resolver) (*Decoder, error) {
+	d := &Decoder{
+		resolver:       resolver,
+		backend:        c.Backend,
+		storageAddr:    c.Addresses.Storage,
+		paymentsAddr:   c.Addresses.PaymentEngine,
+		validationAddr: c.Addresses.Validation,
+		storageIDs:     make(map[common.Hash]string),
+	}
+	if c.Storage != nil {
+		d.storage = &c.Storage.LilypadStorageFilterer
+		parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{
+			"LilypadStorage__DealSaved",
+			"LilypadStorage__DealStatusChanged",
+			"LilypadStorage__ResultSaved",
+			"LilypadStorage__ResultStatusChanged",
+			"LilypadStorage__ValidationResultSaved",
+			"LilypadStorage__ValidationResultStatusChanged",
+		} {
+			d.storageIDs[parsed.Events[name].ID] = name
+		}
+	}
+	if c.PaymentEngine != nil {
+		d.payments = &c.PaymentEngine.LilypadPaymentEngineFilterer
+		parsed, err := lilypadpaymentengine.LilypadPaymentEngineMetaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		d.escrowLocked = parsed.Events["LilypadPayment__ActiveEscrowLockedForJob"].ID
+		d.jobCompleted = parsed.Events["LilypadPayment__JobCompleted"].ID
+		d.jobFailed = parsed.Events["LilypadPayment__JobFailed"].ID
+	}
+	if c.Validation != nil {
+		d.validation = &c.Validation.LilypadValidationFilterer
+		parsed, err := lilypadvalidation.LilypadValidationMetaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		d.validationRequested = parsed.Events["ValidationRequested"].ID
+		d.validationProcessed = parsed.Events["ValidationProcessed"].ID
+	}
+	return d, nil
+}
+
+// Learn learns the plain IDs that log carries in its data rather than as
+// hashed topics: the deal and result IDs of ResultSaved,
+// ValidationResultSaved, JobCompleted, JobFailed, ValidationRequested and
+// ValidationProcessed. Feeding every log of a range through Learn before
+// decoding it lets later events indexing those IDs resolve without
+// calldata. Other logs are ignored.
+func (d *Decoder) Learn(ctx context.Context, log types.Log) error {
+	ids, err := d.plainIDs(log)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return d.resolver.Learn(ctx, ids...)
+}
+
+// plainIDs returns the IDs log carries unhashed.
+func (d *Decoder) plainIDs(log types.Log) ([]string, error) {
+	if len(log.Topics) == 0 || log.Removed {
+		return nil, nil
+	}
+	switch {
+	case d.storage != nil && log.Address == d.storageAddr:
+		switch d.storageIDs[log.Topics[0]] {
+		case "LilypadStorage__ResultSaved":
+			ev, err := d.storage.ParseLilypadStorageResultSaved(log)
+			if err != nil {
+				return nil, err
+			}
+			return []string{ev.DealId}, nil
+		case "LilypadStorage__ValidationResultSaved":
+			ev, err := d.storage.ParseLilypadStorageValidationResultSaved(log)
+			if err != nil {
+				return nil, err
+			}
+			return []string{ev.ResultId}, nil
+		}
+	case d.payments != nil && log.Address == d.paymentsAddr:
+		switch log.Topics[0] {
+		case d.jobCompleted:
+			ev, err := d.payments.ParseLilypadPaymentJobCompleted(log)
+			if err != nil {
+				return nil, err
+			}
+			return []string{ev.DealId}, nil
+		case d.jobFailed:
+			ev, err := d.payments.ParseLilypadPaymentJobFailed(log)
+			if err != nil {
+				return nil, err
+			}
+			return []string{ev.ResultId}, nil
+		}
+	case d.validation != nil && log.Address == d.validationAddr:
+		switch log.Topics[0] {
+		case d.validationRequested:
+			ev, err := d.validation.ParseValidationRequested(log)
+			if err != nil {
+				return nil, err
+			}
+			return []string{ev.DealId, ev.ResultId}, nil
+		case d.validationProcessed:
+			ev, err := d.validation.ParseValidationProcessed(log)
+			if err != nil {
+				return nil, err
+			}
+			return []string{ev.ValidationResultId}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Decode returns the typed event for log, one of the pointer types declared
+// in this package, resolving its ID through the resolver.
+func (d *Decoder) Decode(ctx context.Context, log types.Log) (interface{}, error) {
+	if len(log.Topics) == 0 {
+		return nil, ErrUnknownEvent
+	}
+	if err := d.Learn(ctx, log); err != nil {
+		return nil, err
+	}
+	if d.payments != nil && log.Address == d.paymentsAddr && log.Topics[0] == d.escrowLocked {
+		ev, err := d.payments.ParseLilypadPaymentActiveEscrowLockedForJob(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.DealId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &ActiveEscrowLockedForJob{
+			JobCreator:       ev.JobCreator,
+			ResourceProvider: ev.ResourceProvider,
+			DealID:           id,
+			Cost:             ev.Cost,
+			Raw:              log,
+		}, nil
+	}
+	if d.storage == nil || log.Address != d.storageAddr {
+		return nil, ErrUnknownEvent
+	}
+	switch d.storageIDs[log.Topics[0]] {
+	case "LilypadStorage__DealSaved":
+		ev, err := d.storage.ParseLilypadStorageDealSaved(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.DealId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &DealSaved{DealID: id, JobCreator: ev.JobCreator, ResourceProvider: ev.ResourceProvider, Raw: log}, nil
+	case "LilypadStorage__DealStatusChanged":
+		ev, err := d.storage.ParseLilypadStorageDealStatusChanged(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.DealId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &DealStatusChanged{DealID: id, Status: ev.Status, Raw: log}, nil
+	case "LilypadStorage__ResultSaved":
+		ev, err := d.storage.ParseLilypadStorageResultSaved(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.ResultId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &ResultSaved{ResultID: id, DealID: ev.DealId, Raw: log}, nil
+	case "LilypadStorage__ResultStatusChanged":
+		ev, err := d.storage.ParseLilypadStorageResultStatusChanged(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.ResultId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &ResultStatusChanged{ResultID: id, Status: ev.Status, Raw: log}, nil
+	case "LilypadStorage__ValidationResultSaved":
+		ev, err := d.storage.ParseLilypadStorageValidationResultSaved(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.ValidationResultId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &ValidationResultSaved{ValidationResultID: id, ResultID: ev.ResultId, Validator: ev.Validator, Raw: log}, nil
+	case "LilypadStorage__ValidationResultStatusChanged":
+		ev, err := d.storage.ParseLilypadStorageValidationResultStatusChanged(log)
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.resolver.Resolve(ctx, ev.ValidationResultId, log.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &ValidationResultStatusChanged{ValidationResultID: id, Status: ev.Status, Raw: log}, nil
+	}
+	return nil, ErrUnknownEvent
+}
+
+// FilterIDs returns the decoded events between from and to, inclusive,
+// whose indexed ID is one of ids, ordered by block and log index. A nil to
+// means the latest block. The IDs may be deal, result or validation result
+// IDs; each is learned so the matching events resolve without calldata.
+func (d *Decoder) FilterIDs(ctx context.Context, from uint64, to *uint64, ids ...string) ([]interface{}, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := d.resolver.Learn(ctx, ids...); err != nil {
+		return nil, err
+	}
+	hashes := make([]common.Hash, len(ids))
+	for i, id := range ids {
+		hashes[i] = crypto.Keccak256Hash([]byte(id))
+	}
+	base := ethereum.FilterQuery{FromBlock: new(big.Int).SetUint64(from)}
+	if to != nil {
+		base.ToBlock = new(big.Int).SetUint64(*to)
+	}
+
+	var logs []types.Log
+	if d.storage != nil {
+		var topics []common.Hash
+		for id := range d.storageIDs {
+			topics = append(topics, id)
+		}
+		q := base
+		q.Addresses = []common.Address{d.storageAddr}
+		q.Topics = [][]common.Hash{topics, hashes}
+		found, err := d.backend.FilterLogs(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("preimage: filtering storage logs: %w", err)
+		}
+		logs = append(logs, found...)
+	}
+	if d.payments != nil {
+		q := base
+		q.Addresses = []common.Address{d.paymentsAddr}
+		q.Topics = [][]common.Hash{{d.escrowLocked}, nil, nil, hashes}
+		found, err := d.backend.FilterLogs(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("preimage: filtering payment engine logs: %w", err)
+		}
+		logs = append(logs, found...)
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+
+	events := make([]interface{}, 0, len(logs))
+	for _, log := range logs {
+		ev, err := d.Decode(ctx, log)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
@@ -0,0 +1,237 @@
+// Package preimage recovers the plain-text deal, result and validation IDs
+// behind the keccak256 hashes that indexed string event parameters carry.
+package preimage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrUnresolved is returned when no known string hashes to a topic.
+var ErrUnresolved = errors.New("preimage: unresolved topic")
+
+// maxIDLength bounds the strings picked up when scanning raw calldata.
+const maxIDLength = 256
+
+// Resolver maps topic hashes to the strings they were derived from. It
+// learns pairs from strings it is told about and from the calldata of the
+// transactions that emitted the events, and writes them through to its
+// Store if it has one.
+type Resolver struct {
+	txs   ethereum.TransactionReader
+	abis  []*abi.ABI
+	store Store
+
+	mu  sync.RWMutex
+	ids map[common.Hash]string
+}
+
+// NewResolver returns an in-memory Resolver reading transactions from txs,
+// which may be nil to resolve only learned strings. Calldata is decoded
+// against abis; if none match, it is scanned for anything that looks like an
+// ABI-encoded string, which also covers calls wrapped by multisigs.
+func NewResolver(txs ethereum.TransactionReader, abis ...*abi.ABI) *Resolver {
+	return &Resolver{txs: txs, abis: abis, ids: make(map[common.Hash]string)}
+}
+
+// OpenResolver is like NewResolver but loads the pairs already in store and
+// persists every pair it learns.
+func OpenResolver(ctx context.Context, store Store, txs ethereum.TransactionReader, abis ...*abi.ABI) (*Resolver, error) {
+	ids, err := store.LoadPreimages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("preimage: loading: %w", err)
+	}
+	r := NewResolver(txs, abis...)
+	r.store = store
+	for hash, id := range ids {
+		r.ids[hash] = id
+	}
+	return r, nil
+}
+
+// Learn records the hashes of ids. With a Store, new pairs are saved before
+// they are resolvable, so a failed save is retried the next time the IDs
+// are learned; if the store saved them in a transaction that rolls back,
+// they are forgotten again.
+func (r *Resolver) Learn(ctx context.Context, ids ...string) error {
+	fresh := make(map[common.Hash]string)
+	r.mu.RLock()
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		hash := crypto.Keccak256Hash([]byte(id))
+		if _, ok := r.ids[hash]; !ok {
+			fresh[hash] = id
+		}
+	}
+	r.mu.RUnlock()
+	if len(fresh) == 0 {
+		return nil
+	}
+	if r.store != nil {
+		save := make([]string, 0, len(fresh))
+		for _, id := range fresh {
+			save = append(save, id)
+		}
+		sort.Strings(save)
+		if err := r.store.SavePreimages(ctx, save); err != nil {
+			return fmt.Errorf("preimage: saving: %w", err)
+		}
+		if rb, ok := r.store.(RollbackNotifier); ok {
+			rb.OnRollback(ctx, func() { r.forget(fresh) })
+		}
+	}
+	r.mu.Lock()
+	for hash, id := range fresh {
+		r.ids[hash] = id
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// forget drops pairs whose save was rolled back.
+func (r *Resolver) forget(pairs map[common.Hash]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash := range pairs {
+		delete(r.ids, hash)
+	}
+}
+
+// Lookup returns the learned string hashing to hash.
+func (r *Resolver) Lookup(hash common.Hash) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.ids[hash]
+	return id, ok
+}
+
+// Resolve returns the string hashing to hash, learning from the calldata of
+// transaction txHash if it is not known yet.
+func (r *Resolver) Resolve(ctx context.Context, hash, txHash common.Hash) (string, error) {
+	if id, ok := r.Lookup(hash); ok {
+		return id, nil
+	}
+	if r.txs == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnresolved, hash)
+	}
+	tx, _, err := r.txs.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return "", fmt.Errorf("preimage: fetching transaction %s: %w", txHash, err)
+	}
+	if err := r.LearnCalldata(ctx, tx.Data()); err != nil {
+		return "", err
+	}
+	if id, ok := r.Lookup(hash); ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnresolved, hash)
+}
+
+// LearnCalldata learns every string argument in data.
+func (r *Resolver) LearnCalldata(ctx context.Context, data []byte) error {
+	if found := r.decode(data); len(found) > 0 {
+		return r.Learn(ctx, found...)
+	}
+	return r.Learn(ctx, scan(data)...)
+}
+
+func (r *Resolver) decode(data []byte) []string {
+	if len(data) < 4 {
+		return nil
+	}
+	for _, parsed := range r.abis {
+		method, err := parsed.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+		args, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+		var found []string
+		for _, arg := range args {
+			collect(reflect.ValueOf(arg), &found)
+		}
+		return found
+	}
+	return nil
+}
+
+// collect appends every string reachable from v, which holds values as
+// returned by abi.Arguments.Unpack.
+func collect(v reflect.Value, found *[]string) {
+	switch v.Kind() {
+	case reflect.String:
+		*found = append(*found, v.String())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			collect(v.Field(i), found)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			collect(v.Index(i), found)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			collect(v.Elem(), found)
+		}
+	}
+}
+
+// scan returns every printable string in data that is laid out like an ABI
+// string: a 32 byte length word followed by that many bytes.
+func scan(data []byte) []string {
+	var found []string
+	for i := 0; i+32 < len(data); i++ {
+		word := data[i : i+32]
+		if !zero(word[:24]) {
+			continue
+		}
+		n := binary.BigEndian.Uint64(word[24:])
+		if n == 0 || n > maxIDLength || uint64(len(data)-i-32) < n {
+			continue
+		}
+		s := data[i+32 : i+32+int(n)]
+		if printable(s) {
+			found = append(found, string(s))
+		}
+	}
+	return found
+}
+
+func zero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,273 @@
+package preimage
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// fakeTxs serves transactions carrying the given calldata.
+type fakeTxs map[common.Hash][]byte
+
+func (f fakeTxs) TransactionByHash(_ context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	data, ok := f[hash]
+	if !ok {
+		return nil, false, errors.New("not found")
+	}
+	return types.NewTx(&types.LegacyTx{Data: data}), false, nil
+}
+
+func (f fakeTxs) TransactionReceipt(context.Context, common.Hash) (*types.Receipt, error) {
+	return nil, errors.New("not supported")
+}
+
+// memStore is a Store that can fail saves and records the resolver's view
+// of the IDs being saved.
+type memStore struct {
+	resolver *Resolver
+	err      error
+	saved    []string
+	// resolvable records, for every saved ID, whether the resolver already
+	// resolved it while it was being saved.
+	resolvable []bool
+	rollbacks  []func()
+}
+
+func (s *memStore) LoadPreimages(context.Context) (map[common.Hash]string, error) {
+	ids := make(map[common.Hash]string)
+	for _, id := range s.saved {
+		ids[crypto.Keccak256Hash([]byte(id))] = id
+	}
+	return ids, nil
+}
+
+func (s *memStore) SavePreimages(_ context.Context, ids []string) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, id := range ids {
+		_, ok := s.resolver.Lookup(crypto.Keccak256Hash([]byte(id)))
+		s.resolvable = append(s.resolvable, ok)
+	}
+	s.saved = append(s.saved, ids...)
+	return nil
+}
+
+func (s *memStore) OnRollback(_ context.Context, fn func()) {
+	s.rollbacks = append(s.rollbacks, fn)
+}
+
+func storageABI(t *testing.T) *abi.ABI {
+	t.Helper()
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestResolveFromCalldata(t *testing.T) {
+	ctx := context.Background()
+	parsed := storageABI(t)
+	decoded, err := parsed.Pack("getDeal", "deal-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A call of an unknown contract, e.g. a multisig, wrapping the
+	// storage call as bytes.
+	wrapper, err := abi.NewType("bytes", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := parsed.Pack("getResult", "result-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := abi.Arguments{{Type: wrapper}}.Pack(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped = append([]byte{0xde, 0xad, 0xbe, 0xef}, wrapped...)
+
+	txs := fakeTxs{
+		common.HexToHash("0x01"): decoded,
+		common.HexToHash("0x02"): wrapped,
+	}
+	r := NewResolver(txs, parsed)
+	for _, tc := range []struct {
+		id string
+		tx common.Hash
+	}{
+		{"deal-1", common.HexToHash("0x01")},
+		{"result-1", common.HexToHash("0x02")},
+	} {
+		got, err := r.Resolve(ctx, crypto.Keccak256Hash([]byte(tc.id)), tc.tx)
+		if err != nil || got != tc.id {
+			t.Errorf("Resolve(%s) = %q, %v", tc.id, got, err)
+		}
+		// Once learned, the ID resolves without its transaction.
+		if got, ok := r.Lookup(crypto.Keccak256Hash([]byte(tc.id))); !ok || got != tc.id {
+			t.Errorf("Lookup(%s) = %q, %v", tc.id, got, ok)
+		}
+	}
+	_, err = r.Resolve(ctx, crypto.Keccak256Hash([]byte("other")), common.HexToHash("0x01"))
+	if !errors.Is(err, ErrUnresolved) {
+		t.Errorf("Resolve(other) = %v, want ErrUnresolved", err)
+	}
+	if _, err := NewResolver(nil).Resolve(ctx, crypto.Keccak256Hash([]byte("deal-1")), common.Hash{}); !errors.Is(err, ErrUnresolved) {
+		t.Errorf("Resolve without transactions = %v, want ErrUnresolved", err)
+	}
+}
+
+func TestDecoderResolvesIndexedIDs(t *testing.T) {
+	ctx := context.Background()
+	parsed := storageABI(t)
+	storage := common.HexToAddress("0x05")
+	c, err := client.New(nil, client.AddressBook{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(c, NewResolver(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := func(name, id string, args ...interface{}) types.Log {
+		ev := parsed.Events[name]
+		data, err := ev.Inputs.NonIndexed().Pack(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return types.Log{Address: storage, Topics: []common.Hash{ev.ID, crypto.Keccak256Hash([]byte(id))}, Data: data}
+	}
+
+	// The deal ID is hashed in DealStatusChanged and only resolves once
+	// ResultSaved has carried it in plain.
+	status := log("LilypadStorage__DealStatusChanged", "deal-1", uint8(2))
+	if _, err := d.Decode(ctx, status); !errors.Is(err, ErrUnresolved) {
+		t.Fatalf("Decode before learning = %v, want ErrUnresolved", err)
+	}
+	if err := d.Learn(ctx, log("LilypadStorage__ResultSaved", "result-1", "deal-1")); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := d.Decode(ctx, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := ev.(*DealStatusChanged); !ok || got.DealID != "deal-1" || got.Status != 2 {
+		t.Errorf("Decode = %#v", ev)
+	}
+
+	if _, err := d.Decode(ctx, types.Log{Address: common.HexToAddress("0x06"), Topics: []common.Hash{{}}}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Decode of another contract = %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestLearnPersistsBeforeResolving(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{}
+	r, err := OpenResolver(ctx, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.resolver = r
+	hash := crypto.Keccak256Hash([]byte("deal-1"))
+
+	store.err = errors.New("disk full")
+	if err := r.Learn(ctx, "deal-1"); !errors.Is(err, store.err) {
+		t.Fatalf("Learn = %v, want the save error", err)
+	}
+	if _, ok := r.Lookup(hash); ok {
+		t.Fatal("unsaved ID resolves")
+	}
+
+	// The failed save is retried when the ID is learned again.
+	store.err = nil
+	if err := r.Learn(ctx, "deal-1", "", "deal-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.saved, []string{"deal-1"}) || !reflect.DeepEqual(store.resolvable, []bool{false}) {
+		t.Errorf("saved %v, resolvable while saving %v", store.saved, store.resolvable)
+	}
+	if _, ok := r.Lookup(hash); !ok {
+		t.Fatal("saved ID does not resolve")
+	}
+	// Known IDs are not saved again.
+	if err := r.Learn(ctx, "deal-1"); err != nil || len(store.saved) != 1 {
+		t.Errorf("relearning saved %v, %v", store.saved, err)
+	}
+
+	// A save in a transaction that rolls back is forgotten.
+	if err := r.Learn(ctx, "deal-2"); err != nil {
+		t.Fatal(err)
+	}
+	store.rollbacks[len(store.rollbacks)-1]()
+	if _, ok := r.Lookup(crypto.Keccak256Hash([]byte("deal-2"))); ok {
+		t.Error("rolled back ID still resolves")
+	}
+
+	// A new resolver starts from the store.
+	reopened, err := OpenResolver(ctx, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := reopened.Lookup(hash); !ok || id != "deal-1" {
+		t.Errorf("reopened Lookup = %q, %v", id, ok)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "preimages.jsonl")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePreimages(ctx, []string{"deal-1", "result-1"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// A forged entry and a line torn by a crash are skipped, and the next
+	// open terminates the torn line.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := `{"hash":"` + common.BigToHash(big.NewInt(1)).Hex() + `","id":"forged"}` + "\n"
+	if _, err := f.WriteString(forged + `{"hash":"0x`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SavePreimages(ctx, []string{"deal-2"}); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := s.LoadPreimages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[common.Hash]string)
+	for _, id := range []string{"deal-1", "result-1", "deal-2"} {
+		want[crypto.Keccak256Hash([]byte(id))] = id
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("LoadPreimages = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,112 @@
+package preimage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Store persists learned preimages.
+type Store interface {
+	LoadPreimages(ctx context.Context) (map[common.Hash]string, error)
+	SavePreimages(ctx context.Context, ids []string) error
+}
+
+// RollbackNotifier is implemented by stores whose SavePreimages joins a
+// transaction carried by ctx, such as the block transactions of
+// sqlite.Store. The Resolver uses it to forget pairs that were never
+// committed.
+type RollbackNotifier interface {
+	// OnRollback calls fn if the transaction carried by ctx rolls back. It
+	// does nothing when ctx carries no transaction.
+	OnRollback(ctx context.Context, fn func())
+}
+
+// FileStore is a Store appending one JSON object per preimage to a file.
+type FileStore struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+type fileEntry struct {
+	Hash common.Hash `json:"hash"`
+	ID   string      `json:"id"`
+}
+
+// OpenFileStore opens or creates the preimage file at path.
+func OpenFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Terminate a line torn by a crash so the next entry starts cleanly.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return &FileStore{path: path, file: f}, nil
+}
+
+// LoadPreimages implements Store. Entries whose hash does not match their ID
+// and a torn final line left by a crash are skipped.
+func (s *FileStore) LoadPreimages(_ context.Context) (map[common.Hash]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ids := make(map[common.Hash]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry fileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if crypto.Keccak256Hash([]byte(entry.ID)) == entry.Hash {
+			ids[entry.Hash] = entry.ID
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	return ids, nil
+}
+
+// SavePreimages implements Store.
+func (s *FileStore) SavePreimages(_ context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf []byte
+	for _, id := range ids {
+		line, err := json.Marshal(fileEntry{Hash: crypto.Keccak256Hash([]byte(id)), ID: id})
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := s.file.Write(buf); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
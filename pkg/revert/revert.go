@@ -0,0 +1,136 @@
+// Package revert decodes the revert data returned by the Lilypad contracts
+// into their named Solidity custom errors.
+package revert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// errorStringID is the selector of the builtin Error(string) revert reason.
+var errorStringID = [4]byte{0x08, 0xc3, 0x79, 0xa0}
+
+// Error is a revert whose data matched a known custom error.
+type Error struct {
+	// Name is the Solidity name of the error, e.g. LilypadStorage__DealNotFound.
+	Name string
+	// Args holds the decoded error arguments in declaration order.
+	Args []interface{}
+	// Params holds the names of the arguments, where the ABI declares them.
+	Params []string
+	// Data is the raw revert data including the selector.
+	Data []byte
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if len(e.Args) == 0 {
+		return "execution reverted: " + e.Name + "()"
+	}
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		if b, ok := arg.([32]byte); ok {
+			// Role arguments, e.g. the neededRole of
+			// AccessControlUnauthorizedAccount, are named; other bytes32
+			// values print as hex.
+			if i < len(e.Params) && strings.Contains(strings.ToLower(e.Params[i]), "role") {
+				args[i] = roles.String(b)
+			} else {
+				args[i] = common.Hash(b).Hex()
+			}
+			continue
+		}
+		args[i] = fmt.Sprint(arg)
+	}
+	return fmt.Sprintf("execution reverted: %s(%s)", e.Name, strings.Join(args, ", "))
+}
+
+// dataError mirrors rpc.DataError without importing the rpc package.
+type dataError interface {
+	ErrorData() interface{}
+}
+
+// Data extracts the raw revert data carried by err, if any. It understands the
+// JSON-RPC error data returned by nodes and the simulated backend.
+func Data(err error) ([]byte, bool) {
+	var de dataError
+	if !errors.As(err, &de) {
+		return nil, false
+	}
+	switch data := de.ErrorData().(type) {
+	case string:
+		raw, err := hexutil.Decode(data)
+		if err != nil {
+			return nil, false
+		}
+		return raw, len(raw) >= 4
+	case []byte:
+		return data, len(data) >= 4
+	}
+	return nil, false
+}
+
+// Decode matches the revert data in err against the errors declared in the
+// given ABIs. The builtin Error(string) reason is reported with the name
+// "Error". It returns false when err carries no revert data or the selector
+// is unknown.
+func Decode(err error, abis ...*abi.ABI) (*Error, bool) {
+	data, ok := Data(err)
+	if !ok {
+		return nil, false
+	}
+	return DecodeData(data, abis...)
+}
+
+// DecodeData is like Decode but works on raw revert data.
+func DecodeData(data []byte, abis ...*abi.ABI) (*Error, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	if bytes.Equal(data[:4], errorStringID[:]) {
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			return nil, false
+		}
+		return &Error{Name: "Error", Args: []interface{}{reason}, Params: []string{"reason"}, Data: common.CopyBytes(data)}, true
+	}
+	var id [4]byte
+	copy(id[:], data[:4])
+	for _, parsed := range abis {
+		if parsed == nil {
+			continue
+		}
+		abiErr, err := parsed.ErrorByID(id)
+		if err != nil {
+			continue
+		}
+		args, err := abiErr.Inputs.Unpack(data[4:])
+		if err != nil {
+			return nil, false
+		}
+		params := make([]string, len(abiErr.Inputs))
+		for i, input := range abiErr.Inputs {
+			params[i] = input.Name
+		}
+		return &Error{Name: abiErr.Name, Args: args, Params: params, Data: common.CopyBytes(data)}, true
+	}
+	return nil, false
+}
+
+// Is reports whether err is a revert with the named custom error.
+func Is(err error, name string, abis ...*abi.ABI) bool {
+	var decoded *Error
+	if errors.As(err, &decoded) {
+		return decoded.Name == name
+	}
+	decoded, ok := Decode(err, abis...)
+	return ok && decoded.Name == name
+}
@@ -0,0 +1,47 @@
+package revert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+const testABI = `[
+	{"type":"error","name":"AccessControlUnauthorizedAccount","inputs":[{"name":"account","type":"address"},{"name":"neededRole","type":"bytes32"}]},
+	{"type":"error","name":"DigestMismatch","inputs":[{"name":"digest","type":"bytes32"}]}
+]`
+
+func TestErrorFormatsRoles(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := common.HexToAddress("0x1234")
+	tests := []struct {
+		name string
+		args []interface{}
+		want string
+	}{
+		{"AccessControlUnauthorizedAccount", []interface{}{account, roles.Controller}, "(" + account.Hex() + ", CONTROLLER_ROLE)"},
+		// A bytes32 that happens to equal a role hash is not a role.
+		{"DigestMismatch", []interface{}{roles.Controller}, "(" + roles.ControllerHex + ")"},
+	}
+	for _, tt := range tests {
+		abiErr := parsed.Errors[tt.name]
+		packed, err := abiErr.Inputs.Pack(tt.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, ok := DecodeData(append(abiErr.ID[:4:4], packed...), &parsed)
+		if !ok {
+			t.Fatalf("DecodeData(%s) failed", tt.name)
+		}
+		if want := "execution reverted: " + tt.name + tt.want; decoded.Error() != want {
+			t.Errorf("Error() = %q, want %q", decoded.Error(), want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package roles names the role identifiers of the Lilypad contracts without
+// a round trip to a node: the AccessControl roles every contract checks and
+// the SharedStructs.UserType values LilypadUser assigns to accounts.
+//
+// The AccessControl roles are keccak256 hashes of their names, declared in
+// SharedStructs as e.g. keccak256("CONTROLLER_ROLE"); DEFAULT_ADMIN_ROLE is
+// the zero hash. Name turns a bytes32 role back into its name for event,
+// revert and audit output.
+package roles
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Names of the AccessControl roles.
+const (
+	DefaultAdminName = "DEFAULT_ADMIN_ROLE"
+	ControllerName   = "CONTROLLER_ROLE"
+	MinterName       = "MINTER_ROLE"
+	PauserName       = "PAUSER_ROLE"
+	VestingName      = "VESTING_ROLE"
+)
+
+// Hex encoded AccessControl role identifiers: keccak256 of the name, except
+// for DEFAULT_ADMIN_ROLE.
+const (
+	DefaultAdminHex = "0x0000000000000000000000000000000000000000000000000000000000000000"
+	ControllerHex   = "0x7b765e0e932d348852a6f810bfa1ab891e259123f02db8cdcde614c570223357"
+	MinterHex       = "0x9f2df0fed2c77648de5860a4cc508cd0818c85b8b8a1ab4ceeef8d981c8956a6"
+	PauserHex       = "0x65d7a28e3265b37a6474929f336521b332c1681b933f6cb9f3376673440d862a"
+	VestingHex      = "0x6343452265350cc926492d9bfc7710ca06328d7c328cdb091fde925c1441e7a8"
+)
+
+// The AccessControl role identifiers.
+var (
+	DefaultAdmin = common.HexToHash(DefaultAdminHex)
+	Controller   = common.HexToHash(ControllerHex)
+	Minter       = common.HexToHash(MinterHex)
+	Pauser       = common.HexToHash(PauserHex)
+	Vesting      = common.HexToHash(VestingHex)
+)
+
+// All lists the AccessControl roles.
+var All = []common.Hash{DefaultAdmin, Controller, Minter, Pauser, Vesting}
+
+var names = map[common.Hash]string{
+	DefaultAdmin: DefaultAdminName,
+	Controller:   ControllerName,
+	Minter:       MinterName,
+	Pauser:       PauserName,
+	Vesting:      VestingName,
+}
+
+// Name returns the name of a known AccessControl role.
+func Name(role common.Hash) (string, bool) {
+	name, ok := names[role]
+	return name, ok
+}
+
+// String returns the name of a known role and the hex hash of any other.
+func String(role common.Hash) string {
+	if name, ok := names[role]; ok {
+		return name
+	}
+	return role.Hex()
+}
+
+// Parse accepts a role name such as "CONTROLLER_ROLE", case-insensitively,
+// or a 32 byte hex hash.
+func Parse(s string) (common.Hash, error) {
+	for role, name := range names {
+		if strings.EqualFold(s, name) {
+			return role, nil
+		}
+	}
+	if b, err := hexutil.Decode(s); err == nil && len(b) == common.HashLength {
+		return common.BytesToHash(b), nil
+	}
+	return common.Hash{}, fmt.Errorf("roles: unknown role %q", s)
+}
+
+// UserType is a SharedStructs.UserType, a role LilypadUser assigns.
+type UserType uint8
+
+// The SharedStructs.UserType values.
+const (
+	Solver UserType = iota
+	Validator
+	ModuleCreator
+	ResourceProvider
+	JobCreator
+	Admin
+)
+
+// UserTypes lists the user types in value order.
+var UserTypes = []UserType{Solver, Validator, ModuleCreator, ResourceProvider, JobCreator, Admin}
+
+var userTypeNames = []string{"Solver", "Validator", "ModuleCreator", "ResourceProvider", "JobCreator", "Admin"}
+
+// String implements fmt.Stringer.
+func (t UserType) String() string {
+	if int(t) < len(userTypeNames) {
+		return userTypeNames[t]
+	}
+	return fmt.Sprintf("UserType(%d)", uint8(t))
+}
+
+// Valid reports whether t is a declared user type.
+func (t UserType) Valid() bool {
+	return int(t) < len(userTypeNames)
+}
+
+// ParseUserType accepts a user type name such as "ResourceProvider",
+// case-insensitively.
+func ParseUserType(s string) (UserType, error) {
+	for i, name := range userTypeNames {
+		if strings.EqualFold(s, name) {
+			return UserType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("roles: unknown user type %q", s)
+}
+
+// UserOperation is a SharedStructs.UserOperation, the change a
+// LilypadUser__UserManagementEvent records.
+type UserOperation uint8
+
+// The SharedStructs.UserOperation values.
+const (
+	NewUser UserOperation = iota
+	UpdateUser
+	RoleAdded
+	RoleRemoved
+)
+
+var userOperationNames = []string{"NewUser", "UpdateUser", "RoleAdded", "RoleRemoved"}
+
+// String implements fmt.Stringer.
+func (op UserOperation) String() string {
+	if int(op) < len(userOperationNames) {
+		return userOperationNames[op]
+	}
+	return fmt.Sprintf("UserOperation(%d)", uint8(op))
+}
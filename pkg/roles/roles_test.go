@@ -0,0 +1,49 @@
+package roles
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRoleHashes(t *testing.T) {
+	tests := []struct {
+		name string
+		role common.Hash
+	}{
+		{ControllerName, Controller},
+		{MinterName, Minter},
+		{PauserName, Pauser},
+		{VestingName, Vesting},
+	}
+	for _, tt := range tests {
+		if got := crypto.Keccak256Hash([]byte(tt.name)); got != tt.role {
+			t.Errorf("keccak256(%q) = %s, want %s", tt.name, got.Hex(), tt.role.Hex())
+		}
+	}
+	if DefaultAdmin != (common.Hash{}) {
+		t.Errorf("%s = %s, want the zero hash", DefaultAdminName, DefaultAdmin.Hex())
+	}
+	if len(All) != len(tests)+1 {
+		t.Errorf("All has %d roles, the test covers %d", len(All), len(tests)+1)
+	}
+}
+
+func TestNames(t *testing.T) {
+	for _, role := range All {
+		name, ok := Name(role)
+		if !ok {
+			t.Errorf("Name(%s) unknown", role.Hex())
+			continue
+		}
+		parsed, err := Parse(name)
+		if err != nil || parsed != role {
+			t.Errorf("Parse(%q) = %s, %v, want %s", name, parsed.Hex(), err, role.Hex())
+		}
+	}
+	other := common.HexToHash("0x01")
+	if s := String(other); s != other.Hex() {
+		t.Errorf("String of an unknown role = %q, want its hex", s)
+	}
+}
@@ -0,0 +1,227 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// RecordKey returns the checkpoint key under which Record tracks contract.
+func RecordKey(contract string) string {
+	return "events:" + contract
+}
+
+// Record copies the events of every contract in the address book into the
+// event tables, from the block after each contract's checkpoint, or from
+// start if it has none, up to to. Each block is applied in one transaction
+// together with the contract's checkpoint.
+func (s *Store) Record(ctx context.Context, logs ethereum.LogFilterer, start, to uint64) error {
+	for addr, contract := range s.contracts {
+		from := start
+		done, ok, err := s.Checkpoint(ctx, RecordKey(contract))
+		if err != nil {
+			return err
+		}
+		if ok {
+			if done >= to {
+				continue
+			}
+			from = done + 1
+		}
+		found, err := logs.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{addr},
+		})
+		if err != nil {
+			return fmt.Errorf("sqlite: filtering %s logs: %w", contract, err)
+		}
+		if err := s.RecordLogs(ctx, RecordKey(contract), found); err != nil {
+			return err
+		}
+		if err := s.ApplyBlock(ctx, RecordKey(contract), to, func(context.Context) error { return nil }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordLogs inserts logs, which must be ordered by block, applying each
+// block in one transaction under the checkpoint key.
+func (s *Store) RecordLogs(ctx context.Context, key string, logs []types.Log) error {
+	for start := 0; start < len(logs); {
+		end := start
+		for end < len(logs) && logs[end].BlockNumber == logs[start].BlockNumber {
+			end++
+		}
+		block := logs[start:end]
+		err := s.ApplyBlock(ctx, key, block[0].BlockNumber, func(ctx context.Context) error {
+			for _, log := range block {
+				if err := s.InsertLog(ctx, log); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// Rollback implements indexer.RewindStore. It undoes every block from block
+// on after a reorg, e.g. for a follower.Update with Rollback set: it
+// deletes their events and the deals, results and validation results saved
+// in them, and rewinds the checkpoints past block so the new chain is
+// applied from there. Status changes of older records are not reverted
+// until the indexer replays the blocks.
+func (s *Store) Rollback(ctx context.Context, block uint64) error {
+	if _, nested := ctx.Value(txKey{}).(*blockTx); nested {
+		return errors.New("sqlite: Rollback called inside a block transaction")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	tables := []string{"deals", "results", "validation_results"}
+	for _, t := range s.events {
+		tables = append(tables, t.name)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE block >= ?", table), int64(block)); err != nil {
+			return fmt.Errorf("sqlite: rolling back %s: %w", table, err)
+		}
+	}
+	if block == 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM checkpoints`)
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE checkpoints SET block = ? WHERE block >= ?`, int64(block-1), int64(block))
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertLog decodes log and inserts it into its event table. Logs of unknown
+// contracts or events are ignored. A log replaces the row at its block and
+// index, so a block re-applied from a new chain after a reorg overwrites the
+// old one, and a log removed by a reorg deletes its row.
+func (s *Store) InsertLog(ctx context.Context, log types.Log) error {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	contract, ok := s.contracts[log.Address]
+	if !ok {
+		return nil
+	}
+	table, ok := s.events[eventKey{contract, log.Topics[0]}]
+	if !ok {
+		return nil
+	}
+	if log.Removed {
+		_, err := s.conn(ctx).ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE block = ? AND log_index = ? AND block_hash = ?", table.name),
+			int64(log.BlockNumber), int64(log.Index), log.BlockHash.Hex())
+		return err
+	}
+	values := make(map[string]interface{})
+	if len(log.Data) > 0 {
+		if err := table.event.Inputs.UnpackIntoMap(values, log.Data); err != nil {
+			return fmt.Errorf("sqlite: decoding %s: %w", table.event.Name, err)
+		}
+	}
+	var indexed abi.Arguments
+	for _, arg := range table.event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(values, indexed, log.Topics[1:]); err != nil {
+		return fmt.Errorf("sqlite: decoding %s topics: %w", table.event.Name, err)
+	}
+
+	cols := []string{"block", "block_hash", "tx_hash", "tx_index", "log_index"}
+	args := []interface{}{int64(log.BlockNumber), log.BlockHash.Hex(), log.TxHash.Hex(), int64(log.TxIndex), int64(log.Index)}
+	for _, col := range table.columns {
+		value, err := s.columnValue(ctx, col, values[col.arg.Name])
+		if err != nil {
+			return fmt.Errorf("sqlite: %s.%s: %w", table.name, col.name, err)
+		}
+		cols = append(cols, fmt.Sprintf("%q", col.name))
+		args = append(args, value)
+	}
+	set := make([]string, 0, len(cols)-2)
+	for _, col := range cols {
+		if col != "block" && col != "log_index" {
+			set = append(set, col+" = excluded."+col)
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s) ON CONFLICT (block, log_index) DO UPDATE SET %s",
+		table.name, strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)-1), strings.Join(set, ", "))
+	_, err := s.conn(ctx).ExecContext(ctx, query, args...)
+	return err
+}
+
+// columnValue converts a decoded event value to its column representation.
+func (s *Store) columnValue(ctx context.Context, col eventColumn, v interface{}) (interface{}, error) {
+	if col.hashed || col.preimage {
+		hash, ok := v.(common.Hash)
+		if !ok {
+			return nil, fmt.Errorf("indexed value is %T, not a topic hash", v)
+		}
+		if col.hashed {
+			return hash.Bytes(), nil
+		}
+		id, ok, err := s.preimage(ctx, hash)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return id, nil
+	}
+	switch col.arg.Type.T {
+	case abi.AddressTy:
+		return v.(common.Address).Hex(), nil
+	case abi.IntTy, abi.UintTy:
+		if b, ok := v.(*big.Int); ok {
+			return b.String(), nil
+		}
+		rv := reflect.ValueOf(v)
+		if col.arg.Type.T == abi.IntTy {
+			if col.arg.Type.Size < 64 {
+				return rv.Int(), nil
+			}
+			return fmt.Sprint(rv.Int()), nil
+		}
+		if col.arg.Type.Size < 64 {
+			return int64(rv.Uint()), nil
+		}
+		return fmt.Sprint(rv.Uint()), nil
+	case abi.BoolTy, abi.StringTy, abi.BytesTy:
+		return v, nil
+	case abi.FixedBytesTy:
+		rv := reflect.ValueOf(v)
+		out := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(out), rv)
+		return out, nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/preimage"
+)
+
+var _ preimage.Store = (*Store)(nil)
+
+// LoadPreimages implements preimage.Store.
+func (s *Store) LoadPreimages(ctx context.Context) (map[common.Hash]string, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT hash, id FROM preimages`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[common.Hash]string)
+	for rows.Next() {
+		var (
+			hash []byte
+			id   string
+		)
+		if err := rows.Scan(&hash, &id); err != nil {
+			return nil, err
+		}
+		ids[common.BytesToHash(hash)] = id
+	}
+	return ids, rows.Err()
+}
+
+// SavePreimages implements preimage.Store. Called with the context of
+// ApplyBlock, the preimages commit together with the block. Event rows
+// recorded before a preimage was known get their preimage column filled.
+func (s *Store) SavePreimages(ctx context.Context, ids []string) error {
+	conn := s.conn(ctx)
+	for _, id := range ids {
+		hash := crypto.Keccak256([]byte(id))
+		res, err := conn.ExecContext(ctx, `INSERT OR IGNORE INTO preimages (hash, id) VALUES (?, ?)`, hash, id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+		if err := s.fillPreimage(ctx, conn, hash, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillPreimage sets the preimage columns still empty for hash to id.
+func (s *Store) fillPreimage(ctx context.Context, conn querier, hash []byte, id string) error {
+	for _, table := range s.events {
+		for _, col := range table.columns {
+			if !col.preimage {
+				continue
+			}
+			query := fmt.Sprintf("UPDATE %s SET %q = ? WHERE %q = ? AND %q IS NULL", table.name, col.name, col.name+"_hash", col.name)
+			if _, err := conn.ExecContext(ctx, query, id, hash); err != nil {
+				return fmt.Errorf("sqlite: filling %s.%s: %w", table.name, col.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// preimage returns the stored ID hashing to hash.
+func (s *Store) preimage(ctx context.Context, hash common.Hash) (string, bool, error) {
+	var id string
+	err := s.conn(ctx).QueryRowContext(ctx, `SELECT id FROM preimages WHERE hash = ?`, hash.Bytes()).Scan(&id)
+	if errNoRows(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return id, true, nil
+}
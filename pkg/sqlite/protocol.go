@@ -0,0 +1,610 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// The read models below are projected from the event tables filled by
+// Record. They only see events of contracts in the store's address book.
+
+// table returns the name of the table of the named event of contract.
+func (s *Store) table(contract, event string) (string, error) {
+	for key, t := range s.events {
+		if key.contract == contract && t.event.Name == event {
+			return t.name, nil
+		}
+	}
+	return "", fmt.Errorf("sqlite: no table for %s.%s", contract, event)
+}
+
+// MovementKind classifies an escrow movement.
+type MovementKind string
+
+// Escrow movement kinds and the LilypadPaymentEngine events they come from.
+const (
+	MovementDeposit    MovementKind = "deposit"    // LilypadPayment__escrowPaid
+	MovementPayout     MovementKind = "payout"     // LilypadPayment__escrowPayout
+	MovementSlash      MovementKind = "slash"      // LilypadPayment__escrowSlashed
+	MovementWithdrawal MovementKind = "withdrawal" // LilypadPayment__escrowWithdrawn
+	MovementLock       MovementKind = "lock"       // LilypadPayment__ActiveEscrowLockedForJob
+)
+
+// Movement is a single escrow movement.
+type Movement struct {
+	Kind    MovementKind
+	Account common.Address
+	Amount  *big.Int
+	// Reason is the payment reason of a deposit or the actor of a slash.
+	Reason *uint8
+	// DealID is set for locks whose deal ID preimage is known.
+	DealID   string
+	Block    uint64
+	TxHash   common.Hash
+	LogIndex uint
+}
+
+// MovementQuery selects escrow movements. Zero fields match everything.
+type MovementQuery struct {
+	Account   *common.Address
+	Kinds     []MovementKind
+	DealID    string
+	TxHashes  []common.Hash
+	FromBlock uint64
+	ToBlock   uint64 // inclusive, zero for no bound
+	Offset    int
+	Limit     int
+}
+
+// EscrowMovements returns the escrow movements matching q in chain order.
+func (s *Store) EscrowMovements(ctx context.Context, q MovementQuery) ([]Movement, error) {
+	const contract = "LilypadPaymentEngine"
+	var parts []string
+	for _, src := range []struct {
+		kind              MovementKind
+		event             string
+		account, amount   string
+		reason, dealIDCol string
+	}{
+		{MovementDeposit, "LilypadPayment__escrowPaid", "payee", "amount", "payment_reason", "NULL"},
+		{MovementPayout, "LilypadPayment__escrowPayout", `"to"`, "amount", "NULL", "NULL"},
+		{MovementSlash, "LilypadPayment__escrowSlashed", "account", "amount", "actor", "NULL"},
+		{MovementWithdrawal, "LilypadPayment__escrowWithdrawn", "withdrawer", "amount", "NULL", "NULL"},
+		{MovementLock, "LilypadPayment__ActiveEscrowLockedForJob", "job_creator", "cost", "NULL", "deal_id"},
+	} {
+		table, err := s.table(contract, src.event)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, fmt.Sprintf(
+			`SELECT '%s' AS kind, %s AS account, %s AS amount, %s AS reason, %s AS deal_id, block, tx_hash, log_index FROM %s`,
+			src.kind, src.account, src.amount, src.reason, src.dealIDCol, table))
+	}
+	var w where
+	if q.Account != nil {
+		w.add("account = ?", q.Account.Hex())
+	}
+	if len(q.Kinds) > 0 {
+		args := make([]interface{}, len(q.Kinds))
+		for i, k := range q.Kinds {
+			args[i] = string(k)
+		}
+		w.add("kind IN ("+placeholders(len(args))+")", args...)
+	}
+	if q.DealID != "" {
+		w.add("deal_id = ?", q.DealID)
+	}
+	if len(q.TxHashes) > 0 {
+		args := make([]interface{}, len(q.TxHashes))
+		for i, h := range q.TxHashes {
+			args[i] = h.Hex()
+		}
+		w.add("tx_hash IN ("+placeholders(len(args))+")", args...)
+	}
+	if q.FromBlock > 0 {
+		w.add("block >= ?", int64(q.FromBlock))
+	}
+	if q.ToBlock > 0 {
+		w.add("block <= ?", int64(q.ToBlock))
+	}
+	query := `SELECT kind, account, amount, reason, deal_id, block, tx_hash, log_index FROM (` +
+		strings.Join(parts, " UNION ALL ") + `)` + w.sql() + ` ORDER BY block, log_index` + pageSQL(q.Offset, q.Limit)
+	rows, err := s.conn(ctx).QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Movement
+	for rows.Next() {
+		var (
+			m                     Movement
+			kind, account, amount string
+			reason                *int64
+			dealID                *string
+			block, index          int64
+			tx                    string
+		)
+		if err := rows.Scan(&kind, &account, &amount, &reason, &dealID, &block, &tx, &index); err != nil {
+			return nil, err
+		}
+		m.Kind, m.Account, m.Amount = MovementKind(kind), common.HexToAddress(account), parseDecimal(amount)
+		if reason != nil {
+			r := uint8(*reason)
+			m.Reason = &r
+		}
+		if dealID != nil {
+			m.DealID = *dealID
+		}
+		m.Block, m.TxHash, m.LogIndex = uint64(block), common.HexToHash(tx), uint(index)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// ResultTxs returns the hashes of the transactions that saved the result id
+// or changed its status, in chain order. Events recorded before the ID's
+// preimage was known are not matched.
+func (s *Store) ResultTxs(ctx context.Context, id string) ([]common.Hash, error) {
+	return s.recordTxs(ctx, id, "result_id", "LilypadStorage__ResultSaved", "LilypadStorage__ResultStatusChanged")
+}
+
+// ValidationResultTxs is like ResultTxs for validation results.
+func (s *Store) ValidationResultTxs(ctx context.Context, id string) ([]common.Hash, error) {
+	return s.recordTxs(ctx, id, "validation_result_id",
+		"LilypadStorage__ValidationResultSaved", "LilypadStorage__ValidationResultStatusChanged")
+}
+
+func (s *Store) recordTxs(ctx context.Context, id, column string, events ...string) ([]common.Hash, error) {
+	var parts []string
+	var args []interface{}
+	for _, event := range events {
+		table, err := s.table("LilypadStorage", event)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, `SELECT tx_hash, block, log_index FROM `+table+` WHERE `+column+` = ?`)
+		args = append(args, id)
+	}
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT tx_hash FROM (`+strings.Join(parts, " UNION ALL ")+`)
+		GROUP BY tx_hash ORDER BY MIN(block), MIN(log_index)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []common.Hash
+	for rows.Next() {
+		var tx string
+		if err := rows.Scan(&tx); err != nil {
+			return nil, err
+		}
+		out = append(out, common.HexToHash(tx))
+	}
+	return out, rows.Err()
+}
+
+// Burn is a token burn reported by LilypadPaymentEngine.
+type Burn struct {
+	// BlockTimestamp is the unix time the contract reported for the burn.
+	BlockTimestamp uint64
+	Amount         *big.Int
+	Block          uint64
+	TxHash         common.Hash
+	LogIndex       uint
+}
+
+// BurnQuery selects token burns. Zero fields match everything.
+type BurnQuery struct {
+	FromBlock uint64
+	ToBlock   uint64 // inclusive, zero for no bound
+	Offset    int
+	Limit     int
+}
+
+// TokenBurns returns the token burns matching q in chain order.
+func (s *Store) TokenBurns(ctx context.Context, q BurnQuery) ([]Burn, error) {
+	table, err := s.table("LilypadPaymentEngine", "LilypadPayment__TokensBurned")
+	if err != nil {
+		return nil, err
+	}
+	var w where
+	if q.FromBlock > 0 {
+		w.add("block >= ?", int64(q.FromBlock))
+	}
+	if q.ToBlock > 0 {
+		w.add("block <= ?", int64(q.ToBlock))
+	}
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT block_timestamp, amount_burnt, block, tx_hash, log_index FROM `+table+
+		w.sql()+` ORDER BY block, log_index`+pageSQL(q.Offset, q.Limit), w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Burn
+	for rows.Next() {
+		var (
+			ts, amount, tx string
+			block, index   int64
+		)
+		if err := rows.Scan(&ts, &amount, &block, &tx, &index); err != nil {
+			return nil, err
+		}
+		out = append(out, Burn{
+			BlockTimestamp: parseDecimal(ts).Uint64(),
+			Amount:         parseDecimal(amount),
+			Block:          uint64(block),
+			TxHash:         common.HexToHash(tx),
+			LogIndex:       uint(index),
+		})
+	}
+	return out, rows.Err()
+}
+
+// User is a LilypadUser account as projected from its management events.
+type User struct {
+	Address    common.Address
+	MetadataID string
+	URL        string
+	// Roles holds the SharedStructs.UserType values the user holds, sorted.
+	Roles []uint8
+	// Block is the block of the user's latest management event.
+	Block uint64
+}
+
+// UserQuery selects users. Zero fields match everything.
+type UserQuery struct {
+	Address *common.Address
+	Role    *uint8
+	Offset  int
+	Limit   int
+}
+
+// Users returns the users matching q ordered by address.
+func (s *Store) Users(ctx context.Context, q UserQuery) ([]User, error) {
+	table, err := s.table("LilypadUser", "LilypadUser__UserManagementEvent")
+	if err != nil {
+		return nil, err
+	}
+	var w where
+	if q.Address != nil {
+		w.add("wallet_address = ?", q.Address.Hex())
+	}
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT wallet_address, metadata_id, url, role, operation, block
+		FROM `+table+w.sql()+` ORDER BY block, log_index`, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := make(map[common.Address]*User)
+	for rows.Next() {
+		var (
+			wallet, metadataID, url string
+			role, op, block         int64
+		)
+		if err := rows.Scan(&wallet, &metadataID, &url, &role, &op, &block); err != nil {
+			return nil, err
+		}
+		addr := common.HexToAddress(wallet)
+		u, ok := users[addr]
+		if !ok {
+			u = &User{Address: addr}
+			users[addr] = u
+		}
+		u.MetadataID, u.URL, u.Block = metadataID, url, uint64(block)
+		switch roles.UserOperation(op) {
+		case roles.NewUser, roles.RoleAdded:
+			u.Roles = addRole(u.Roles, uint8(role))
+		case roles.RoleRemoved:
+			u.Roles = removeRole(u.Roles, uint8(role))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]User, 0, len(users))
+	for _, u := range users {
+		if q.Role == nil || hasRole(u.Roles, *q.Role) {
+			out = append(out, *u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address.Cmp(out[j].Address) < 0 })
+	return pageSlice(out, q.Offset, q.Limit), nil
+}
+
+func addRole(roles []uint8, role uint8) []uint8 {
+	if hasRole(roles, role) {
+		return roles
+	}
+	roles = append(roles, role)
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles
+}
+
+func removeRole(roles []uint8, role uint8) []uint8 {
+	out := roles[:0]
+	for _, r := range roles {
+		if r != role {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func hasRole(roles []uint8, role uint8) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Module is a LilypadModuleDirectory module as projected from its events.
+type Module struct {
+	Owner common.Address
+	Name  string
+	URL   string
+	// ApprovedPurchaser is the account approved to take over the module, if
+	// any.
+	ApprovedPurchaser *common.Address
+	// Block is the block of the module's latest event.
+	Block uint64
+}
+
+// ModuleQuery selects modules. Zero fields match everything.
+type ModuleQuery struct {
+	Owner  *common.Address
+	Name   string
+	Offset int
+	Limit  int
+}
+
+type moduleKey struct {
+	owner common.Address
+	name  string
+}
+
+// moduleEvent is a row of one of the module directory event tables.
+type moduleEvent struct {
+	event        string
+	block, index int64
+	values       []string
+}
+
+// Modules returns the modules matching q ordered by owner and name.
+func (s *Store) Modules(ctx context.Context, q ModuleQuery) ([]Module, error) {
+	const contract = "LilypadModuleDirectory"
+	var events []moduleEvent
+	for _, src := range []struct {
+		event string
+		cols  string
+	}{
+		{"LilypadModuleDirectory__ModuleRegistered", "owner, module_name, module_url"},
+		{"LilypadModuleDirectory__ModuleNameUpdated", "owner, old_module_name, new_module_name"},
+		{"LilypadModuleDirectory__ModuleUrlUpdated", "owner, module_name, new_module_url"},
+		{"LilypadModuleDirectory__ModuleTransferApproved", "owner, purchaser, module_name"},
+		{"LilypadModuleDirectory__ModuleTransferRevoked", "owner, revoked_from, module_name"},
+		{"LilypadModuleDirectory__ModuleTransferred", "new_owner, previous_owner, module_name, module_url"},
+	} {
+		table, err := s.table(contract, src.event)
+		if err != nil {
+			return nil, err
+		}
+		found, err := s.moduleEvents(ctx, table, src.event, src.cols)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].block != events[j].block {
+			return events[i].block < events[j].block
+		}
+		return events[i].index < events[j].index
+	})
+
+	modules := make(map[moduleKey]*Module)
+	for _, ev := range events {
+		v := ev.values
+		switch ev.event {
+		case "LilypadModuleDirectory__ModuleRegistered":
+			owner := common.HexToAddress(v[0])
+			modules[moduleKey{owner, v[1]}] = &Module{Owner: owner, Name: v[1], URL: v[2]}
+		case "LilypadModuleDirectory__ModuleNameUpdated":
+			key := moduleKey{common.HexToAddress(v[0]), v[1]}
+			if m, ok := modules[key]; ok {
+				delete(modules, key)
+				m.Name = v[2]
+				modules[moduleKey{m.Owner, m.Name}] = m
+			}
+		case "LilypadModuleDirectory__ModuleUrlUpdated":
+			if m, ok := modules[moduleKey{common.HexToAddress(v[0]), v[1]}]; ok {
+				m.URL = v[2]
+			}
+		case "LilypadModuleDirectory__ModuleTransferApproved":
+			if m, ok := modules[moduleKey{common.HexToAddress(v[0]), v[2]}]; ok {
+				purchaser := common.HexToAddress(v[1])
+				m.ApprovedPurchaser = &purchaser
+			}
+		case "LilypadModuleDirectory__ModuleTransferRevoked":
+			if m, ok := modules[moduleKey{common.HexToAddress(v[0]), v[2]}]; ok {
+				m.ApprovedPurchaser = nil
+			}
+		case "LilypadModuleDirectory__ModuleTransferred":
+			key := moduleKey{common.HexToAddress(v[1]), v[2]}
+			m, ok := modules[key]
+			if !ok {
+				m = &Module{Name: v[2]}
+			}
+			delete(modules, key)
+			m.Owner, m.URL, m.ApprovedPurchaser = common.HexToAddress(v[0]), v[3], nil
+			modules[moduleKey{m.Owner, m.Name}] = m
+		}
+		if m, ok := modules[moduleKeyOf(ev)]; ok {
+			m.Block = uint64(ev.block)
+		}
+	}
+
+	var out []Module
+	for _, m := range modules {
+		if q.Owner != nil && *q.Owner != m.Owner {
+			continue
+		}
+		if q.Name != "" && q.Name != m.Name {
+			continue
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if c := out[i].Owner.Cmp(out[j].Owner); c != 0 {
+			return c < 0
+		}
+		return out[i].Name < out[j].Name
+	})
+	return pageSlice(out, q.Offset, q.Limit), nil
+}
+
+// moduleKeyOf returns the key of the module an event leaves behind.
+func moduleKeyOf(ev moduleEvent) moduleKey {
+	v := ev.values
+	switch ev.event {
+	case "LilypadModuleDirectory__ModuleNameUpdated":
+		return moduleKey{common.HexToAddress(v[0]), v[2]}
+	case "LilypadModuleDirectory__ModuleTransferApproved", "LilypadModuleDirectory__ModuleTransferRevoked":
+		return moduleKey{common.HexToAddress(v[0]), v[2]}
+	case "LilypadModuleDirectory__ModuleTransferred":
+		return moduleKey{common.HexToAddress(v[0]), v[2]}
+	}
+	return moduleKey{common.HexToAddress(v[0]), v[1]}
+}
+
+func (s *Store) moduleEvents(ctx context.Context, table, event, cols string) ([]moduleEvent, error) {
+	n := len(strings.Split(cols, ","))
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT block, log_index, `+cols+` FROM `+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []moduleEvent
+	for rows.Next() {
+		ev := moduleEvent{event: event, values: make([]string, n)}
+		dest := []interface{}{&ev.block, &ev.index}
+		for i := range ev.values {
+			dest = append(dest, &ev.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// VestingSchedule is a LilypadVesting schedule as projected from its
+// events.
+type VestingSchedule struct {
+	ID          *big.Int
+	Beneficiary common.Address
+	Amount      *big.Int
+	StartTime   *big.Int
+	Released    *big.Int
+	Block       uint64
+	TxHash      common.Hash
+}
+
+// VestingQuery selects vesting schedules. Zero fields match everything.
+type VestingQuery struct {
+	Beneficiary *common.Address
+	Offset      int
+	Limit       int
+}
+
+// VestingSchedules returns the schedules matching q ordered by ID.
+func (s *Store) VestingSchedules(ctx context.Context, q VestingQuery) ([]VestingSchedule, error) {
+	created, err := s.table("LilypadVesting", "LilypadVesting__VestingScheduleCreated")
+	if err != nil {
+		return nil, err
+	}
+	released, err := s.table("LilypadVesting", "LilypadVesting__l2TokensReleased")
+	if err != nil {
+		return nil, err
+	}
+	var w where
+	if q.Beneficiary != nil {
+		w.add("c.beneficiary = ?", q.Beneficiary.Hex())
+	}
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT c.schedule_id, c.beneficiary, c.amount, c.start_time, c.block, c.tx_hash
+		FROM `+created+` c`+w.sql()+` ORDER BY c.block, c.log_index`, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []VestingSchedule
+	index := make(map[string]int)
+	for rows.Next() {
+		var (
+			id, beneficiary, amount, start, tx string
+			block                              int64
+		)
+		if err := rows.Scan(&id, &beneficiary, &amount, &start, &block, &tx); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[id] = len(out)
+		out = append(out, VestingSchedule{
+			ID:          parseDecimal(id),
+			Beneficiary: common.HexToAddress(beneficiary),
+			Amount:      parseDecimal(amount),
+			StartTime:   parseDecimal(start),
+			Released:    new(big.Int),
+			Block:       uint64(block),
+			TxHash:      common.HexToHash(tx),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.conn(ctx).QueryContext(ctx, `SELECT schedule_id, amount FROM `+released)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, amount string
+		if err := rows.Scan(&id, &amount); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			out[i].Released.Add(out[i].Released, parseDecimal(amount))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID.Cmp(out[j].ID) < 0 })
+	return pageSlice(out, q.Offset, q.Limit), nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func pageSlice[T any](records []T, offset, limit int) []T {
+	if offset > 0 {
+		if offset >= len(records) {
+			return nil
+		}
+		records = records[offset:]
+	}
+	if limit > 0 && len(records) > limit {
+		return records[:limit]
+	}
+	return records
+}
@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// eventLog returns a log of the named event of contract with args in input
+// order, indexed ones moved to the topics.
+func eventLog(t *testing.T, contract string, addr common.Address, event string, block uint64, index uint, args ...interface{}) types.Log {
+	t.Helper()
+	parsed, ok := client.ContractABI(contract)
+	if !ok {
+		t.Fatalf("no ABI for %s", contract)
+	}
+	ev, ok := parsed.Events[event]
+	if !ok {
+		t.Fatalf("no event %s.%s", contract, event)
+	}
+	var indexed [][]interface{}
+	var data []interface{}
+	for i, arg := range ev.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, []interface{}{args[i]})
+		} else {
+			data = append(data, args[i])
+		}
+	}
+	topics, err := abi.MakeTopics(indexed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := ev.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := types.Log{Address: addr, Topics: []common.Hash{ev.ID}, Data: packed, BlockNumber: block, Index: index}
+	for _, topic := range topics {
+		log.Topics = append(log.Topics, topic[0])
+	}
+	return log
+}
+
+func TestModules(t *testing.T) {
+	ctx := context.Background()
+	directory := common.HexToAddress("0x04")
+	s, err := Open(ctx, filepath.Join(t.TempDir(), "lilypad.db"), client.AddressBook{ModuleDirectory: directory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	alice, bob, carol := common.HexToAddress("0xa1"), common.HexToAddress("0xb0"), common.HexToAddress("0xc0")
+	event := func(name string, block uint64, args ...interface{}) types.Log {
+		return eventLog(t, "LilypadModuleDirectory", directory, "LilypadModuleDirectory__"+name, block, 0, args...)
+	}
+	logs := []types.Log{
+		event("ModuleRegistered", 1, alice, "cowsay", "https://a/cowsay"),
+		event("ModuleRegistered", 2, alice, "sdxl", "https://a/sdxl"),
+		event("ModuleNameUpdated", 3, alice, "cowsay", "cowsay2"),
+		event("ModuleUrlUpdated", 4, alice, "cowsay2", "https://a/cowsay2"),
+		event("ModuleTransferApproved", 5, alice, bob, "cowsay2", "https://a/cowsay2"),
+		event("ModuleTransferred", 6, bob, alice, "cowsay2", "https://a/cowsay2"),
+		event("ModuleTransferApproved", 7, alice, carol, "sdxl", "https://a/sdxl"),
+		event("ModuleTransferApproved", 8, alice, bob, "sdxl", "https://a/sdxl"),
+		event("ModuleTransferRevoked", 9, alice, bob, "sdxl"),
+	}
+	if err := s.RecordLogs(ctx, RecordKey("LilypadModuleDirectory"), logs); err != nil {
+		t.Fatal(err)
+	}
+
+	modules, err := s.Modules(ctx, ModuleQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Module{
+		{Owner: alice, Name: "sdxl", URL: "https://a/sdxl", Block: 9},
+		{Owner: bob, Name: "cowsay2", URL: "https://a/cowsay2", Block: 6},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("Modules = %+v, want %+v", modules, want)
+	}
+
+	// An approval before the transfer is still pending.
+	logs = logs[:5]
+	s2, err := Open(ctx, filepath.Join(t.TempDir(), "lilypad.db"), client.AddressBook{ModuleDirectory: directory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	if err := s2.RecordLogs(ctx, RecordKey("LilypadModuleDirectory"), logs); err != nil {
+		t.Fatal(err)
+	}
+	modules, err = s2.Modules(ctx, ModuleQuery{Owner: &alice, Name: "cowsay2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 || modules[0].ApprovedPurchaser == nil || *modules[0].ApprovedPurchaser != bob || modules[0].Block != 5 {
+		t.Errorf("Modules before the transfer = %+v", modules)
+	}
+}
@@ -0,0 +1,360 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+)
+
+const (
+	dealColumns = `d.deal_id, d.job_creator, d.resource_provider, d.module_creator, d.solver,
+		d.job_offer_cid, d.resource_offer_cid, d.status, d.timestamp,
+		d.job_creator_solver_fee, d.resource_provider_solver_fee, d.network_congestion_fee,
+		d.module_creator_fee, d.price_of_job_without_fees, d.block, d.tx_hash`
+	resultColumns     = `r.result_id, r.deal_id, r.result_cid, r.status, r.timestamp, r.block, r.tx_hash`
+	validationColumns = `v.validation_result_id, v.result_id, v.validation_cid, v.status, v.timestamp,
+		v.validator, v.block, v.tx_hash`
+)
+
+// PutDeal implements indexer.Store.
+func (s *Store) PutDeal(ctx context.Context, deal indexer.Deal) error {
+	p := deal.PaymentStructure
+	_, err := s.conn(ctx).ExecContext(ctx, `INSERT OR REPLACE INTO deals (
+			deal_id, job_creator, resource_provider, module_creator, solver,
+			job_offer_cid, resource_offer_cid, status, timestamp,
+			job_creator_solver_fee, resource_provider_solver_fee, network_congestion_fee,
+			module_creator_fee, price_of_job_without_fees, block, tx_hash
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		deal.DealId, deal.JobCreator.Hex(), deal.ResourceProvider.Hex(), deal.ModuleCreator.Hex(), deal.Solver.Hex(),
+		deal.JobOfferCID, deal.ResourceOfferCID, deal.Status, unix(deal.Timestamp),
+		decimal(p.JobCreatorSolverFee), decimal(p.ResourceProviderSolverFee), decimal(p.NetworkCongestionFee),
+		decimal(p.ModuleCreatorFee), decimal(p.PriceOfJobWithoutFees), int64(deal.Block), deal.TxHash.Hex())
+	return err
+}
+
+// PutResult implements indexer.Store.
+func (s *Store) PutResult(ctx context.Context, result indexer.Result) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `INSERT OR REPLACE INTO results (
+			result_id, deal_id, result_cid, status, timestamp, block, tx_hash
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		result.ResultId, result.DealId, result.ResultCID, result.Status, unix(result.Timestamp),
+		int64(result.Block), result.TxHash.Hex())
+	return err
+}
+
+// PutValidationResult implements indexer.Store.
+func (s *Store) PutValidationResult(ctx context.Context, validation indexer.ValidationResult) error {
+	_, err := s.conn(ctx).ExecContext(ctx, `INSERT OR REPLACE INTO validation_results (
+			validation_result_id, result_id, validation_cid, status, timestamp, validator, block, tx_hash
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		validation.ValidationResultId, validation.ResultId, validation.ValidationCID, validation.Status,
+		unix(validation.Timestamp), validation.Validator.Hex(), int64(validation.Block), validation.TxHash.Hex())
+	return err
+}
+
+// SetDealStatus implements indexer.Store.
+func (s *Store) SetDealStatus(ctx context.Context, id string, status uint8) error {
+	return s.setStatus(ctx, `UPDATE deals SET status = ? WHERE deal_id = ?`, id, status)
+}
+
+// SetResultStatus implements indexer.Store.
+func (s *Store) SetResultStatus(ctx context.Context, id string, status uint8) error {
+	return s.setStatus(ctx, `UPDATE results SET status = ? WHERE result_id = ?`, id, status)
+}
+
+// SetValidationResultStatus implements indexer.Store.
+func (s *Store) SetValidationResultStatus(ctx context.Context, id string, status uint8) error {
+	return s.setStatus(ctx, `UPDATE validation_results SET status = ? WHERE validation_result_id = ?`, id, status)
+}
+
+func (s *Store) setStatus(ctx context.Context, query, id string, status uint8) error {
+	res, err := s.conn(ctx).ExecContext(ctx, query, status, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return indexer.ErrNotFound
+	}
+	return nil
+}
+
+// Deal implements indexer.Store.
+func (s *Store) Deal(ctx context.Context, id string) (indexer.Deal, error) {
+	deals, err := s.Deals(ctx, indexer.Query{DealID: id, Limit: 1})
+	if err != nil {
+		return indexer.Deal{}, err
+	}
+	if len(deals) == 0 {
+		return indexer.Deal{}, indexer.ErrNotFound
+	}
+	return deals[0], nil
+}
+
+// Result implements indexer.Store.
+func (s *Store) Result(ctx context.Context, id string) (indexer.Result, error) {
+	results, err := s.Results(ctx, indexer.Query{ResultID: id, Limit: 1})
+	if err != nil {
+		return indexer.Result{}, err
+	}
+	if len(results) == 0 {
+		return indexer.Result{}, indexer.ErrNotFound
+	}
+	return results[0], nil
+}
+
+// ValidationResult implements indexer.Store.
+func (s *Store) ValidationResult(ctx context.Context, id string) (indexer.ValidationResult, error) {
+	var v indexer.ValidationResult
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT `+validationColumns+` FROM validation_results v WHERE v.validation_result_id = ?`, id)
+	if err != nil {
+		return v, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return v, err
+		}
+		return v, indexer.ErrNotFound
+	}
+	return scanValidation(rows)
+}
+
+// Deals implements indexer.Store.
+func (s *Store) Deals(ctx context.Context, q indexer.Query) ([]indexer.Deal, error) {
+	var w where
+	w.deal(q)
+	if q.DealID != "" {
+		w.add("d.deal_id = ?", q.DealID)
+	}
+	w.common(q, "d")
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT `+dealColumns+` FROM deals d`+w.sql()+` ORDER BY d.timestamp, d.deal_id`+limit(q), w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deals []indexer.Deal
+	for rows.Next() {
+		var (
+			d                               indexer.Deal
+			jc, rp, mc, solver, tx          string
+			jcFee, rpFee, congestion, mcFee string
+			price                           string
+			timestamp, block                int64
+		)
+		if err := rows.Scan(&d.DealId, &jc, &rp, &mc, &solver, &d.JobOfferCID, &d.ResourceOfferCID, &d.Status,
+			&timestamp, &jcFee, &rpFee, &congestion, &mcFee, &price, &block, &tx); err != nil {
+			return nil, err
+		}
+		d.JobCreator, d.ResourceProvider = common.HexToAddress(jc), common.HexToAddress(rp)
+		d.ModuleCreator, d.Solver = common.HexToAddress(mc), common.HexToAddress(solver)
+		d.Timestamp = big.NewInt(timestamp)
+		d.PaymentStructure = lilypadstorage.SharedStructsDealPaymentStructure{
+			JobCreatorSolverFee:       parseDecimal(jcFee),
+			ResourceProviderSolverFee: parseDecimal(rpFee),
+			NetworkCongestionFee:      parseDecimal(congestion),
+			ModuleCreatorFee:          parseDecimal(mcFee),
+			PriceOfJobWithoutFees:     parseDecimal(price),
+		}
+		d.Block, d.TxHash = uint64(block), common.HexToHash(tx)
+		deals = append(deals, d)
+	}
+	return deals, rows.Err()
+}
+
+// Results implements indexer.Store.
+func (s *Store) Results(ctx context.Context, q indexer.Query) ([]indexer.Result, error) {
+	var w where
+	join := ""
+	if w.deal(q) {
+		join = ` JOIN deals d ON d.deal_id = r.deal_id`
+	}
+	if q.DealID != "" {
+		w.add("r.deal_id = ?", q.DealID)
+	}
+	if q.ResultID != "" {
+		w.add("r.result_id = ?", q.ResultID)
+	}
+	w.common(q, "r")
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT `+resultColumns+` FROM results r`+join+w.sql()+` ORDER BY r.timestamp, r.result_id`+limit(q), w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []indexer.Result
+	for rows.Next() {
+		var (
+			r                indexer.Result
+			tx               string
+			timestamp, block int64
+		)
+		if err := rows.Scan(&r.ResultId, &r.DealId, &r.ResultCID, &r.Status, &timestamp, &block, &tx); err != nil {
+			return nil, err
+		}
+		r.Timestamp, r.Block, r.TxHash = big.NewInt(timestamp), uint64(block), common.HexToHash(tx)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// ValidationResults implements indexer.Store.
+func (s *Store) ValidationResults(ctx context.Context, q indexer.Query) ([]indexer.ValidationResult, error) {
+	var w where
+	join := ""
+	if w.deal(q) || q.DealID != "" {
+		join = ` JOIN results r ON r.result_id = v.result_id JOIN deals d ON d.deal_id = r.deal_id`
+	}
+	if q.DealID != "" {
+		w.add("r.deal_id = ?", q.DealID)
+	}
+	if q.ResultID != "" {
+		w.add("v.result_id = ?", q.ResultID)
+	}
+	if q.Validator != nil {
+		w.add("v.validator = ?", q.Validator.Hex())
+	}
+	w.common(q, "v")
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		`SELECT `+validationColumns+` FROM validation_results v`+join+w.sql()+
+			` ORDER BY v.timestamp, v.validation_result_id`+limit(q), w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var validations []indexer.ValidationResult
+	for rows.Next() {
+		v, err := scanValidation(rows)
+		if err != nil {
+			return nil, err
+		}
+		validations = append(validations, v)
+	}
+	return validations, rows.Err()
+}
+
+func scanValidation(rows *sql.Rows) (indexer.ValidationResult, error) {
+	var (
+		v                indexer.ValidationResult
+		validator, tx    string
+		timestamp, block int64
+	)
+	if err := rows.Scan(&v.ValidationResultId, &v.ResultId, &v.ValidationCID, &v.Status, &timestamp,
+		&validator, &block, &tx); err != nil {
+		return v, err
+	}
+	v.Timestamp, v.Validator = big.NewInt(timestamp), common.HexToAddress(validator)
+	v.Block, v.TxHash = uint64(block), common.HexToHash(tx)
+	return v, nil
+}
+
+// where accumulates the conditions of a query.
+type where struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *where) add(cond string, args ...interface{}) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+// deal adds the participant filters of q on the deals table aliased d and
+// reports whether there were any.
+func (w *where) deal(q indexer.Query) bool {
+	n := len(w.conds)
+	for _, f := range []struct {
+		col  string
+		addr *common.Address
+	}{
+		{"d.job_creator", q.JobCreator},
+		{"d.resource_provider", q.ResourceProvider},
+		{"d.solver", q.Solver},
+		{"d.module_creator", q.ModuleCreator},
+	} {
+		if f.addr != nil {
+			w.add(f.col+" = ?", f.addr.Hex())
+		}
+	}
+	return len(w.conds) > n
+}
+
+// common adds the status and time range filters on the table aliased alias.
+func (w *where) common(q indexer.Query, alias string) {
+	if q.Status != nil {
+		w.add(alias+".status = ?", *q.Status)
+	}
+	if !q.Since.IsZero() {
+		w.add(alias+".timestamp >= ?", q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		w.add(alias+".timestamp < ?", q.Until.Unix())
+	}
+}
+
+func (w *where) sql() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+func limit(q indexer.Query) string {
+	return pageSQL(q.Offset, q.Limit)
+}
+
+// pageSQL returns the LIMIT clause for a page; SQLite needs a LIMIT for an
+// OFFSET, -1 meaning none.
+func pageSQL(offset, limit int) string {
+	switch {
+	case offset > 0 && limit > 0:
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	case offset > 0:
+		return fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	case limit > 0:
+		return fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return ""
+}
+
+func unix(ts *big.Int) int64 {
+	if ts == nil {
+		return 0
+	}
+	return ts.Int64()
+}
+
+func decimal(v *big.Int) string {
+	if v == nil {
+		return "0"
+	}
+	return v.String()
+}
+
+func parseDecimal(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return v
+}
+
+var _ indexer.RewindStore = (*Store)(nil)
+
+// errNoRows reports whether err is sql.ErrNoRows.
+func errNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
@@ -0,0 +1,222 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+const recordSchema = `
+CREATE TABLE IF NOT EXISTS checkpoints (
+	key   TEXT PRIMARY KEY,
+	block INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS preimages (
+	hash BLOB PRIMARY KEY,
+	id   TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS deals (
+	deal_id                      TEXT PRIMARY KEY,
+	job_creator                  TEXT NOT NULL,
+	resource_provider            TEXT NOT NULL,
+	module_creator               TEXT NOT NULL,
+	solver                       TEXT NOT NULL,
+	job_offer_cid                TEXT NOT NULL,
+	resource_offer_cid           TEXT NOT NULL,
+	status                       INTEGER NOT NULL,
+	timestamp                    INTEGER NOT NULL,
+	job_creator_solver_fee       TEXT NOT NULL,
+	resource_provider_solver_fee TEXT NOT NULL,
+	network_congestion_fee       TEXT NOT NULL,
+	module_creator_fee           TEXT NOT NULL,
+	price_of_job_without_fees    TEXT NOT NULL,
+	block                        INTEGER NOT NULL,
+	tx_hash                      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS deals_job_creator ON deals (job_creator, timestamp);
+CREATE INDEX IF NOT EXISTS deals_resource_provider ON deals (resource_provider, timestamp);
+CREATE INDEX IF NOT EXISTS deals_solver ON deals (solver, timestamp);
+CREATE INDEX IF NOT EXISTS deals_module_creator ON deals (module_creator, timestamp);
+CREATE INDEX IF NOT EXISTS deals_timestamp ON deals (timestamp);
+
+CREATE TABLE IF NOT EXISTS results (
+	result_id  TEXT PRIMARY KEY,
+	deal_id    TEXT NOT NULL,
+	result_cid TEXT NOT NULL,
+	status     INTEGER NOT NULL,
+	timestamp  INTEGER NOT NULL,
+	block      INTEGER NOT NULL,
+	tx_hash    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_deal ON results (deal_id);
+CREATE INDEX IF NOT EXISTS results_timestamp ON results (timestamp);
+
+CREATE TABLE IF NOT EXISTS validation_results (
+	validation_result_id TEXT PRIMARY KEY,
+	result_id            TEXT NOT NULL,
+	validation_cid       TEXT NOT NULL,
+	status               INTEGER NOT NULL,
+	timestamp            INTEGER NOT NULL,
+	validator            TEXT NOT NULL,
+	block                INTEGER NOT NULL,
+	tx_hash              TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS validation_results_result ON validation_results (result_id);
+CREATE INDEX IF NOT EXISTS validation_results_validator ON validation_results (validator, timestamp);
+`
+
+// eventKey identifies an event of a contract.
+type eventKey struct {
+	contract string
+	id       common.Hash
+}
+
+// eventTable maps an event to its table. Every table has the log position
+// columns followed by one column per event input; indexed dynamic inputs
+// only carry their topic hash, in a column suffixed _hash, and indexed
+// strings additionally get a column with the resolved preimage, if known.
+type eventTable struct {
+	name    string
+	event   abi.Event
+	columns []eventColumn
+}
+
+type eventColumn struct {
+	name string
+	arg  abi.Argument
+	// hashed marks an indexed dynamic input stored as its topic hash.
+	hashed bool
+	// preimage marks the text column holding the resolved indexed string.
+	preimage bool
+}
+
+// eventTables builds the table of every event of every Lilypad contract.
+func eventTables() (map[eventKey]*eventTable, error) {
+	tables := make(map[eventKey]*eventTable)
+	for _, contract := range client.ContractNames() {
+		parsed, ok := client.ContractABI(contract)
+		if !ok {
+			return nil, fmt.Errorf("sqlite: no ABI for %s", contract)
+		}
+		for _, event := range parsed.Events {
+			short := event.Name
+			if i := strings.LastIndex(short, "__"); i >= 0 {
+				short = short[i+2:]
+			}
+			table := &eventTable{name: "ev_" + snake(contract) + "_" + snake(short), event: event}
+			for i, arg := range event.Inputs {
+				name := snake(arg.Name)
+				if name == "" {
+					name = fmt.Sprintf("arg%d", i)
+				}
+				if arg.Indexed && dynamic(arg.Type) {
+					table.columns = append(table.columns, eventColumn{name: name + "_hash", arg: arg, hashed: true})
+					if arg.Type.T == abi.StringTy {
+						table.columns = append(table.columns, eventColumn{name: name, arg: arg, preimage: true})
+					}
+					continue
+				}
+				table.columns = append(table.columns, eventColumn{name: name, arg: arg})
+			}
+			tables[eventKey{contract, event.ID}] = table
+		}
+	}
+	return tables, nil
+}
+
+func (t *eventTable) ddl() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n", t.name)
+	b.WriteString("\tblock INTEGER NOT NULL,\n\tblock_hash TEXT NOT NULL,\n\ttx_hash TEXT NOT NULL,\n\ttx_index INTEGER NOT NULL,\n\tlog_index INTEGER NOT NULL,\n")
+	for _, col := range t.columns {
+		fmt.Fprintf(&b, "\t%q %s,\n", col.name, col.sqlType())
+	}
+	b.WriteString("\tPRIMARY KEY (block, log_index)\n);")
+	// SavePreimages fills the preimage columns by their hash.
+	for _, col := range t.columns {
+		if col.preimage {
+			fmt.Fprintf(&b, "\nCREATE INDEX IF NOT EXISTS %s_%s_hash ON %s (%q);", t.name, col.name, t.name, col.name+"_hash")
+		}
+	}
+	return b.String()
+}
+
+func (c eventColumn) sqlType() string {
+	switch {
+	case c.hashed:
+		return "BLOB NOT NULL"
+	case c.preimage:
+		return "TEXT"
+	}
+	switch c.arg.Type.T {
+	case abi.IntTy, abi.UintTy:
+		if c.arg.Type.Size < 64 {
+			return "INTEGER NOT NULL"
+		}
+		return "TEXT NOT NULL"
+	case abi.BoolTy:
+		return "INTEGER NOT NULL"
+	case abi.BytesTy, abi.FixedBytesTy:
+		return "BLOB NOT NULL"
+	}
+	return "TEXT NOT NULL"
+}
+
+func dynamic(t abi.Type) bool {
+	switch t.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return true
+	}
+	return false
+}
+
+func (s *Store) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, recordSchema); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(s.events))
+	byName := make(map[string]*eventTable, len(s.events))
+	for _, t := range s.events {
+		names = append(names, t.name)
+		byName[t.name] = t
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := s.db.ExecContext(ctx, byName[name].ddl()); err != nil {
+			return fmt.Errorf("creating %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// snake converts a Solidity identifier such as jobOfferCID to job_offer_cid.
+func snake(s string) string {
+	s = strings.TrimLeft(s, "_")
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		if r == '_' && i > 0 && runes[i-1] == '_' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+// Package sqlite persists indexed Lilypad protocol data in an embedded SQLite
+// database, so tools resume from their checkpoints instead of rescanning the
+// chain from genesis on every start.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+)
+
+// Store is a SQLite database holding the hydrated deals, results and
+// validation results, a table per Lilypad event, ID preimages and per-key
+// block checkpoints. It implements indexer.BlockStore, preimage.Store and
+// preimage.RollbackNotifier.
+type Store struct {
+	db        *sql.DB
+	contracts map[common.Address]string
+	events    map[eventKey]*eventTable
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txKey carries the *blockTx of ApplyBlock in a context.
+type txKey struct{}
+
+// blockTx is the transaction of ApplyBlock and the functions to call if it
+// does not commit.
+type blockTx struct {
+	tx        *sql.Tx
+	rollbacks []func()
+}
+
+// Open opens or creates the database at path and migrates its schema. book
+// tells the store which contract emitted a log.
+func Open(ctx context.Context, path string, book client.AddressBook) (*Store, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, contracts: make(map[common.Address]string)}
+	for name, addr := range book.Named() {
+		s.contracts[addr] = name
+	}
+	if s.events, err = eventTables(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: migrating %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the underlying database for ad-hoc queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// conn returns the transaction carried by ctx, or the database.
+func (s *Store) conn(ctx context.Context) querier {
+	if btx, ok := ctx.Value(txKey{}).(*blockTx); ok {
+		return btx.tx
+	}
+	return s.db
+}
+
+// OnRollback implements preimage.RollbackNotifier: fn is called if the block
+// transaction carried by ctx does not commit.
+func (s *Store) OnRollback(ctx context.Context, fn func()) {
+	if btx, ok := ctx.Value(txKey{}).(*blockTx); ok {
+		btx.rollbacks = append(btx.rollbacks, fn)
+	}
+}
+
+// Checkpoint implements indexer.BlockStore.
+func (s *Store) Checkpoint(ctx context.Context, key string) (uint64, bool, error) {
+	var block int64
+	err := s.conn(ctx).QueryRowContext(ctx, `SELECT block FROM checkpoints WHERE key = ?`, key).Scan(&block)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint64(block), true, nil
+}
+
+// ApplyBlock implements indexer.BlockStore. The checkpoint is written in the
+// same transaction as the block's data, so after a crash a block is either
+// fully applied and skipped on resume, or not applied at all.
+func (s *Store) ApplyBlock(ctx context.Context, key string, block uint64, fn func(ctx context.Context) error) error {
+	if _, nested := ctx.Value(txKey{}).(*blockTx); nested {
+		return errors.New("sqlite: ApplyBlock called inside a block transaction")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	btx := &blockTx{tx: tx}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		tx.Rollback()
+		for i := len(btx.rollbacks) - 1; i >= 0; i-- {
+			btx.rollbacks[i]()
+		}
+	}()
+	txCtx := context.WithValue(ctx, txKey{}, btx)
+
+	done, ok, err := s.Checkpoint(txCtx, key)
+	if err != nil {
+		return err
+	}
+	if ok && block <= done {
+		return nil
+	}
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO checkpoints (key, block) VALUES (?, ?)
+		 ON CONFLICT (key) DO UPDATE SET block = excluded.block`, key, int64(block)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
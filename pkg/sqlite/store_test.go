@@ -0,0 +1,267 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	lilypadstorage "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadStorage"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/indexer"
+)
+
+var storageAddr = common.HexToAddress("0x05")
+
+func openStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(context.Background(), filepath.Join(t.TempDir(), "lilypad.db"), client.AddressBook{Storage: storageAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func deal(id string, block uint64) indexer.Deal {
+	return indexer.Deal{
+		SharedStructsDeal: lilypadstorage.SharedStructsDeal{DealId: id, Timestamp: big.NewInt(int64(block))},
+		Block:             block,
+	}
+}
+
+func checkpoint(t *testing.T, s *Store, key string) (uint64, bool) {
+	t.Helper()
+	block, ok, err := s.Checkpoint(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block, ok
+}
+
+func TestApplyBlock(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t)
+	if _, ok := checkpoint(t, s, "k"); ok {
+		t.Fatal("new store has a checkpoint")
+	}
+	if err := s.ApplyBlock(ctx, "k", 5, func(ctx context.Context) error {
+		return s.PutDeal(ctx, deal("d5", 5))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if block, ok := checkpoint(t, s, "k"); !ok || block != 5 {
+		t.Fatalf("checkpoint = %d, %v, want 5", block, ok)
+	}
+
+	// Blocks at or below the checkpoint are skipped.
+	for _, block := range []uint64{4, 5} {
+		called := false
+		if err := s.ApplyBlock(ctx, "k", block, func(context.Context) error {
+			called = true
+			return nil
+		}); err != nil || called {
+			t.Errorf("ApplyBlock(%d) at checkpoint 5: called %v, %v", block, called, err)
+		}
+	}
+
+	// A failed block leaves neither its writes nor a new checkpoint, and
+	// runs the rollback hooks.
+	fail := errors.New("boom")
+	rolledBack := false
+	err := s.ApplyBlock(ctx, "k", 6, func(ctx context.Context) error {
+		if err := s.PutDeal(ctx, deal("d6", 6)); err != nil {
+			return err
+		}
+		s.OnRollback(ctx, func() { rolledBack = true })
+		return fail
+	})
+	if !errors.Is(err, fail) {
+		t.Fatalf("ApplyBlock = %v, want %v", err, fail)
+	}
+	if block, _ := checkpoint(t, s, "k"); block != 5 {
+		t.Errorf("checkpoint after a failed block = %d, want 5", block)
+	}
+	if _, err := s.Deal(ctx, "d6"); !errors.Is(err, indexer.ErrNotFound) {
+		t.Errorf("deal of the failed block: %v", err)
+	}
+	if !rolledBack {
+		t.Error("rollback hook not called")
+	}
+
+	// Keys are independent, and blocks do not nest.
+	err = s.ApplyBlock(ctx, "other", 1, func(ctx context.Context) error {
+		return s.ApplyBlock(ctx, "k", 7, func(context.Context) error { return nil })
+	})
+	if err == nil {
+		t.Error("nested ApplyBlock succeeded")
+	}
+	if _, ok := checkpoint(t, s, "other"); ok {
+		t.Error("failed outer block wrote its checkpoint")
+	}
+}
+
+// statusLog returns a LilypadStorage__DealStatusChanged log.
+func statusLog(t *testing.T, block uint64, index uint, blockHash common.Hash, id string, status uint8) types.Log {
+	t.Helper()
+	parsed, err := lilypadstorage.LilypadStorageMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := parsed.Events[indexer.EventDealStatusChanged]
+	data, err := ev.Inputs.NonIndexed().Pack(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Log{
+		Address:     storageAddr,
+		Topics:      []common.Hash{ev.ID, crypto.Keccak256Hash([]byte(id))},
+		Data:        data,
+		BlockNumber: block,
+		BlockHash:   blockHash,
+		Index:       index,
+	}
+}
+
+type statusRow struct {
+	block  int64
+	hash   string
+	dealID *string
+	status int64
+}
+
+func statusRows(t *testing.T, s *Store) []statusRow {
+	t.Helper()
+	rows, err := s.DB().Query(`SELECT block, block_hash, deal_id, status FROM ev_lilypad_storage_deal_status_changed ORDER BY block, log_index`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []statusRow
+	for rows.Next() {
+		var r statusRow
+		if err := rows.Scan(&r.block, &r.hash, &r.dealID, &r.status); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestInsertLog(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t)
+	if err := s.SavePreimages(ctx, []string{"d1"}); err != nil {
+		t.Fatal(err)
+	}
+	oldChain, newChain := common.HexToHash("0xaa"), common.HexToHash("0xbb")
+
+	old := statusLog(t, 3, 0, oldChain, "d1", 1)
+	if err := s.InsertLog(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	other := old
+	other.Address = common.HexToAddress("0x99")
+	if err := s.InsertLog(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	rows := statusRows(t, s)
+	if len(rows) != 1 || rows[0].dealID == nil || *rows[0].dealID != "d1" || rows[0].status != 1 {
+		t.Fatalf("rows = %+v", rows)
+	}
+
+	// The same position on a new chain replaces the row.
+	if err := s.InsertLog(ctx, statusLog(t, 3, 0, newChain, "d1", 2)); err != nil {
+		t.Fatal(err)
+	}
+	rows = statusRows(t, s)
+	if len(rows) != 1 || rows[0].hash != newChain.Hex() || rows[0].status != 2 {
+		t.Fatalf("rows after upsert = %+v", rows)
+	}
+
+	// Removing the old chain's log leaves the new row, removing the new
+	// one deletes it.
+	old.Removed = true
+	if err := s.InsertLog(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	if rows := statusRows(t, s); len(rows) != 1 {
+		t.Fatalf("removing a replaced log deleted %+v", rows)
+	}
+	removed := statusLog(t, 3, 0, newChain, "d1", 2)
+	removed.Removed = true
+	if err := s.InsertLog(ctx, removed); err != nil {
+		t.Fatal(err)
+	}
+	if rows := statusRows(t, s); len(rows) != 0 {
+		t.Errorf("rows after removal = %+v", rows)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t)
+	for _, block := range []uint64{3, 5} {
+		err := s.ApplyBlock(ctx, "indexer", block, func(ctx context.Context) error {
+			if err := s.PutDeal(ctx, deal(fmt.Sprintf("d%d", block), block)); err != nil {
+				return err
+			}
+			return s.InsertLog(ctx, statusLog(t, block, 0, common.Hash{}, "d3", 1))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ApplyBlock(ctx, "behind", 2, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.ApplyBlock(ctx, "indexer", 6, func(ctx context.Context) error { return s.Rollback(ctx, 4) })
+	if err == nil {
+		t.Error("Rollback inside ApplyBlock succeeded")
+	}
+	if err := s.Rollback(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Deal(ctx, "d3"); err != nil {
+		t.Errorf("deal before the rollback: %v", err)
+	}
+	if _, err := s.Deal(ctx, "d5"); !errors.Is(err, indexer.ErrNotFound) {
+		t.Errorf("deal of a rolled back block: %v", err)
+	}
+	if rows := statusRows(t, s); len(rows) != 1 || rows[0].block != 3 {
+		t.Errorf("events after rollback = %+v", rows)
+	}
+	if block, _ := checkpoint(t, s, "indexer"); block != 3 {
+		t.Errorf("indexer checkpoint = %d, want 3", block)
+	}
+	if block, _ := checkpoint(t, s, "behind"); block != 2 {
+		t.Errorf("checkpoint below the rollback moved to %d", block)
+	}
+
+	// The next block of the new chain is applied again.
+	if err := s.ApplyBlock(ctx, "indexer", 4, func(ctx context.Context) error {
+		return s.PutDeal(ctx, deal("d4", 4))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Deal(ctx, "d4"); err != nil {
+		t.Errorf("deal of the new chain: %v", err)
+	}
+
+	if err := s.Rollback(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := checkpoint(t, s, "indexer"); ok {
+		t.Error("rolling back to genesis kept the checkpoint")
+	}
+}
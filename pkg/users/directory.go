@@ -0,0 +1,222 @@
+// Package users keeps a directory of LilypadUser accounts.
+//
+// LilypadUser stores each account's roles as a private bitmask and only
+// enumerates validators. The Directory in this package replays
+// LilypadUser__UserManagementEvent to hold every account's metadata and full
+// role set, so questions such as "which accounts are resource providers" can
+// be answered without a contract call per address. Verify spot checks the
+// directory against LilypadUser.hasRole.
+package users
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Roles is a role set in the encoding LilypadUser uses: bit n is set when
+// the account holds SharedStructs.UserType n.
+type Roles uint8
+
+// Has reports whether role is in the set.
+func (r Roles) Has(role roles.UserType) bool {
+	return role.Valid() && r&(1<<role) != 0
+}
+
+// List returns the roles in the set in ascending order.
+func (r Roles) List() []roles.UserType {
+	var out []roles.UserType
+	for _, role := range roles.UserTypes {
+		if r.Has(role) {
+			out = append(out, role)
+		}
+	}
+	return out
+}
+
+func (r Roles) with(role roles.UserType) Roles {
+	return r | 1<<role
+}
+
+func (r Roles) without(role roles.UserType) Roles {
+	return r &^ (1 << role)
+}
+
+// User is an account as known to the directory.
+type User struct {
+	Address    common.Address
+	MetadataID string
+	URL        string
+	Roles      Roles
+	// Block is the block of the account's latest management event.
+	Block uint64
+}
+
+// position orders events.
+type position struct {
+	block uint64
+	index uint
+}
+
+func (p position) after(q position) bool {
+	return p.block > q.block || p.block == q.block && p.index > q.index
+}
+
+// Directory is the set of LilypadUser accounts as projected from their
+// management events. It is safe for concurrent use.
+//
+// Events must be observed in chain order. Events at or before the last one
+// observed are ignored, so Load and Watch may overlap. Removed logs are not
+// reverted; feed the directory from confirmed blocks only.
+type Directory struct {
+	mu    sync.RWMutex
+	users map[common.Address]*User
+	last  position
+	seen  bool
+}
+
+// NewDirectory returns an empty directory.
+func NewDirectory() *Directory {
+	return &Directory{users: make(map[common.Address]*User)}
+}
+
+// Observe applies a management event.
+func (d *Directory) Observe(ev *lilypaduser.LilypadUserLilypadUserUserManagementEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pos := position{block: ev.Raw.BlockNumber, index: ev.Raw.Index}
+	if d.seen && !pos.after(d.last) {
+		return
+	}
+	d.last, d.seen = pos, true
+
+	u, ok := d.users[ev.WalletAddress]
+	if !ok {
+		u = &User{Address: ev.WalletAddress}
+		d.users[ev.WalletAddress] = u
+	}
+	u.MetadataID, u.URL, u.Block = ev.MetadataID, ev.Url, ev.Raw.BlockNumber
+	role := roles.UserType(ev.Role)
+	switch roles.UserOperation(ev.Operation) {
+	case roles.NewUser:
+		// insertUser overwrites the role set.
+		u.Roles = Roles(0).with(role)
+	case roles.RoleAdded:
+		u.Roles = u.Roles.with(role)
+	case roles.RoleRemoved:
+		u.Roles = u.Roles.without(role)
+	}
+}
+
+// Load observes the management events in blocks [from, to]; a nil to reads
+// up to the latest block.
+func (d *Directory) Load(ctx context.Context, filterer *lilypaduser.LilypadUserFilterer, from uint64, to *uint64) error {
+	it, err := filterer.FilterLilypadUserUserManagementEvent(&bind.FilterOpts{Context: ctx, Start: from, End: to}, nil)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		d.Observe(it.Event)
+	}
+	return it.Error()
+}
+
+// Watch observes new management events until ctx is cancelled or the
+// subscription fails.
+func (d *Directory) Watch(ctx context.Context, filterer *lilypaduser.LilypadUserFilterer) error {
+	events := make(chan *lilypaduser.LilypadUserLilypadUserUserManagementEvent)
+	sub, err := filterer.WatchLilypadUserUserManagementEvent(&bind.WatchOpts{Context: ctx}, events, nil)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case ev := <-events:
+			d.Observe(ev)
+		case err := <-sub.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Block returns the block of the last event observed.
+func (d *Directory) Block() uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.last.block
+}
+
+// Get returns the account at addr.
+func (d *Directory) Get(addr common.Address) (User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	u, ok := d.users[addr]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// Users returns every account ordered by address.
+func (d *Directory) Users() []User {
+	return d.filter(func(*User) bool { return true })
+}
+
+// WithRole returns the accounts holding role ordered by address.
+func (d *Directory) WithRole(role roles.UserType) []User {
+	return d.filter(func(u *User) bool { return u.Roles.Has(role) })
+}
+
+// ResourceProviders returns every resource provider.
+func (d *Directory) ResourceProviders() []User {
+	return d.WithRole(roles.ResourceProvider)
+}
+
+// ModuleCreators returns every module creator.
+func (d *Directory) ModuleCreators() []User {
+	return d.WithRole(roles.ModuleCreator)
+}
+
+// JobCreators returns every job creator.
+func (d *Directory) JobCreators() []User {
+	return d.WithRole(roles.JobCreator)
+}
+
+// Solvers returns every solver.
+func (d *Directory) Solvers() []User {
+	return d.WithRole(roles.Solver)
+}
+
+// Validators returns every validator. The set matches
+// LilypadUser.getValidators, which returns it in storage order.
+func (d *Directory) Validators() []User {
+	return d.WithRole(roles.Validator)
+}
+
+// Admins returns every account holding the Admin user type.
+func (d *Directory) Admins() []User {
+	return d.WithRole(roles.Admin)
+}
+
+func (d *Directory) filter(keep func(*User) bool) []User {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var out []User
+	for _, u := range d.users {
+		if keep(u) {
+			out = append(out, *u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address.Cmp(out[j].Address) < 0 })
+	return out
+}
@@ -0,0 +1,106 @@
+package users
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+var (
+	alice = common.HexToAddress("0xa1")
+	bob   = common.HexToAddress("0xb0")
+	carol = common.HexToAddress("0xc0")
+)
+
+// event returns a management event at block with the log index 0.
+func event(block uint64, addr common.Address, url string, role roles.UserType, op roles.UserOperation) *lilypaduser.LilypadUserLilypadUserUserManagementEvent {
+	return &lilypaduser.LilypadUserLilypadUserUserManagementEvent{
+		WalletAddress: addr,
+		MetadataID:    "meta-" + url,
+		Url:           url,
+		Role:          uint8(role),
+		Operation:     uint8(op),
+		Raw:           types.Log{BlockNumber: block},
+	}
+}
+
+func TestObserve(t *testing.T) {
+	d := NewDirectory()
+	for _, ev := range []*lilypaduser.LilypadUserLilypadUserUserManagementEvent{
+		event(1, alice, "a1", roles.ResourceProvider, roles.NewUser),
+		event(2, alice, "a1", roles.Solver, roles.RoleAdded),
+		// updateUserMetadata always emits JobCreator, which alice does not
+		// hold and does not gain.
+		event(3, alice, "a2", roles.JobCreator, roles.UpdateUser),
+		event(4, bob, "b1", roles.JobCreator, roles.NewUser),
+		event(5, bob, "b1", roles.Validator, roles.RoleAdded),
+		event(6, bob, "b1", roles.JobCreator, roles.RoleRemoved),
+		// insertUser over an existing account overwrites its roles.
+		event(7, carol, "c1", roles.Validator, roles.NewUser),
+		event(8, carol, "c1", roles.Solver, roles.RoleAdded),
+		event(9, carol, "c2", roles.ModuleCreator, roles.NewUser),
+		// Events at or before the last one observed are ignored.
+		event(9, carol, "c3", roles.Admin, roles.RoleAdded),
+		event(2, bob, "b0", roles.Admin, roles.RoleAdded),
+	} {
+		d.Observe(ev)
+	}
+
+	want := []User{
+		{Address: alice, MetadataID: "meta-a2", URL: "a2", Roles: rolesOf(roles.ResourceProvider, roles.Solver), Block: 3},
+		{Address: bob, MetadataID: "meta-b1", URL: "b1", Roles: rolesOf(roles.Validator), Block: 6},
+		{Address: carol, MetadataID: "meta-c2", URL: "c2", Roles: rolesOf(roles.ModuleCreator), Block: 9},
+	}
+	if got := d.Users(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Users = %+v, want %+v", got, want)
+	}
+	if d.Block() != 9 {
+		t.Errorf("Block = %d, want 9", d.Block())
+	}
+	for _, tc := range []struct {
+		name string
+		got  []User
+		want []common.Address
+	}{
+		{"ResourceProviders", d.ResourceProviders(), []common.Address{alice}},
+		{"Solvers", d.Solvers(), []common.Address{alice}},
+		{"Validators", d.Validators(), []common.Address{bob}},
+		{"ModuleCreators", d.ModuleCreators(), []common.Address{carol}},
+		{"JobCreators", d.JobCreators(), nil},
+		{"Admins", d.Admins(), nil},
+	} {
+		var got []common.Address
+		for _, u := range tc.got {
+			got = append(got, u.Address)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Events in one block are ordered by log index.
+	d = NewDirectory()
+	first, second := event(1, alice, "a1", roles.JobCreator, roles.NewUser), event(1, alice, "a1", roles.Solver, roles.RoleAdded)
+	second.Raw.Index = 1
+	d.Observe(first)
+	d.Observe(second)
+	d.Observe(first)
+	if u, _ := d.Get(alice); u.Roles != rolesOf(roles.JobCreator, roles.Solver) {
+		t.Errorf("roles %v, want JobCreator and Solver", u.Roles.List())
+	}
+}
+
+func TestRoles(t *testing.T) {
+	r := rolesOf(roles.Solver, roles.Admin)
+	if got, want := r.List(), []roles.UserType{roles.Solver, roles.Admin}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if r.Has(roles.Validator) || !r.Has(roles.Admin) || Roles(0xff).Has(roles.UserType(7)) {
+		t.Error("Has reports the wrong roles")
+	}
+}
@@ -0,0 +1,439 @@
+package users
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/client"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Record is an account as the off-chain source wants it.
+type Record struct {
+	// Row is the line of a CSV file or the 1-based index in a JSON array.
+	Row        int
+	Address    common.Address
+	MetadataID string
+	URL        string
+	Roles      Roles
+	// Err is set when the row could not be parsed or breaks a contract
+	// rule; such rows are reported and not imported.
+	Err error
+}
+
+// jsonRecord is the JSON form of a record.
+type jsonRecord struct {
+	Address    string   `json:"address"`
+	MetadataID string   `json:"metadata_id"`
+	URL        string   `json:"url"`
+	Roles      []string `json:"roles"`
+}
+
+// ReadCSV reads records from CSV with a header naming the columns address,
+// metadata_id, url and roles. Roles are SharedStructs.UserType names
+// separated by semicolons, e.g. "ResourceProvider;Validator".
+func ReadCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("users: reading header: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"address", "metadata_id", "url", "roles"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("users: no %s column", name)
+		}
+	}
+	var out []Record
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("users: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i := cols[name]; i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		var names []string
+		if s := field("roles"); s != "" {
+			names = strings.Split(s, ";")
+		}
+		out = append(out, newRecord(line, field("address"), field("metadata_id"), field("url"), names))
+	}
+}
+
+// ReadJSON reads records from a JSON array of objects with the fields
+// address, metadata_id, url and roles, a list of SharedStructs.UserType
+// names.
+func ReadJSON(r io.Reader) ([]Record, error) {
+	var rows []jsonRecord
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("users: %w", err)
+	}
+	out := make([]Record, len(rows))
+	for i, row := range rows {
+		out[i] = newRecord(i+1, row.Address, row.MetadataID, row.URL, row.Roles)
+	}
+	return out, nil
+}
+
+func newRecord(row int, address, metadataID, url string, names []string) Record {
+	rec := Record{Row: row, MetadataID: metadataID, URL: url}
+	if !common.IsHexAddress(address) {
+		rec.Err = fmt.Errorf("invalid address %q", address)
+		return rec
+	}
+	rec.Address = common.HexToAddress(address)
+	for _, name := range names {
+		role, err := roles.ParseUserType(strings.TrimSpace(name))
+		if err != nil {
+			rec.Err = err
+			return rec
+		}
+		rec.Roles = rec.Roles.with(role)
+	}
+	switch {
+	case rec.Roles == 0:
+		rec.Err = errors.New("no roles")
+	case rec.Roles.Has(roles.JobCreator) && rec.Roles.Has(roles.ResourceProvider):
+		// LilypadUser.addRole rejects this combination.
+		rec.Err = fmt.Errorf("%s and %s are exclusive", roles.JobCreator, roles.ResourceProvider)
+	}
+	return rec
+}
+
+// Op is a single LilypadUser call of an import.
+type Op struct {
+	// Method is insertUser, updateUserMetadata, addRole or removeRole.
+	Method     string
+	Address    common.Address
+	MetadataID string
+	URL        string
+	Role       roles.UserType
+}
+
+func (op Op) String() string {
+	switch op.Method {
+	case "insertUser":
+		return fmt.Sprintf("insertUser(%s)", op.Role)
+	case "updateUserMetadata":
+		return fmt.Sprintf("updateUserMetadata(%q, %q)", op.MetadataID, op.URL)
+	}
+	return fmt.Sprintf("%s(%s)", op.Method, op.Role)
+}
+
+// DefaultManaged are the roles an import manages unless its Scope says
+// otherwise: the ones accounts sign up for.
+var DefaultManaged = []roles.UserType{roles.JobCreator, roles.ResourceProvider}
+
+// Scope limits the roles a Diff may change. Roles such as Solver,
+// Validator or Admin are usually granted by hand, and a source listing
+// only job creators and resource providers must not strip them.
+type Scope struct {
+	// Managed are the roles the source is authoritative for, DefaultManaged
+	// if empty. Diff only adds and removes these.
+	Managed []roles.UserType
+	// RemoveUnmanaged also removes roles outside Managed that a record
+	// does not list.
+	RemoveUnmanaged bool
+}
+
+func (s Scope) managed() Roles {
+	list := s.Managed
+	if len(list) == 0 {
+		list = DefaultManaged
+	}
+	var r Roles
+	for _, role := range list {
+		r = r.with(role)
+	}
+	return r
+}
+
+// Diff returns the calls that bring the account of rec in line with it
+// within scope, in the order they must be sent. Roles are removed before
+// they are added, so a job creator can become a resource provider and
+// vice versa. Roles of rec outside the managed set are not added.
+func (d *Directory) Diff(rec Record, scope Scope) []Op {
+	managed := scope.managed()
+	u, known := d.Get(rec.Address)
+	var ops []Op
+	op := func(method string, role roles.UserType) {
+		ops = append(ops, Op{Method: method, Address: rec.Address, MetadataID: rec.MetadataID, URL: rec.URL, Role: role})
+	}
+	if !known {
+		want := (rec.Roles & managed).List()
+		if len(want) == 0 {
+			return nil
+		}
+		op("insertUser", want[0])
+		for _, role := range want[1:] {
+			op("addRole", role)
+		}
+		return ops
+	}
+	if u.MetadataID != rec.MetadataID || u.URL != rec.URL {
+		op("updateUserMetadata", 0)
+	}
+	for _, role := range u.Roles.List() {
+		if !rec.Roles.Has(role) && (managed.Has(role) || scope.RemoveUnmanaged) {
+			op("removeRole", role)
+		}
+	}
+	for _, role := range (rec.Roles & managed).List() {
+		if !u.Roles.Has(role) {
+			op("addRole", role)
+		}
+	}
+	return ops
+}
+
+func joinRoles(r Roles) string {
+	var names []string
+	for _, role := range r.List() {
+		names = append(names, role.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Transactor is the subset of the LilypadUser binding an import sends
+// through. It is satisfied by *lilypaduser.LilypadUserTransactor.
+type Transactor interface {
+	InsertUser(opts *bind.TransactOpts, walletAddress common.Address, metadataID string, url string, role uint8) (*types.Transaction, error)
+	UpdateUserMetadata(opts *bind.TransactOpts, walletAddress common.Address, metadataID string, url string) (*types.Transaction, error)
+	AddRole(opts *bind.TransactOpts, walletAddress common.Address, role uint8) (*types.Transaction, error)
+	RemoveRole(opts *bind.TransactOpts, walletAddress common.Address, role uint8) (*types.Transaction, error)
+}
+
+// Backend assigns nonces and fetches receipts for an import.
+type Backend interface {
+	bind.DeployBackend
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// Result statuses.
+const (
+	// Unchanged rows already match the chain.
+	Unchanged = "unchanged"
+	// Planned rows have calls that were not sent, as in a dry run.
+	Planned = "planned"
+	// Applied rows had all their calls mined.
+	Applied = "applied"
+	// Failed rows had a call fail; later calls of the row were not sent.
+	Failed = "failed"
+	// Invalid rows could not be parsed or break a contract rule.
+	Invalid = "invalid"
+)
+
+// Result is the outcome of a row.
+type Result struct {
+	Row     int
+	Address common.Address
+	Status  string
+	Ops     []Op
+	Txs     []common.Hash
+	Err     error
+}
+
+// Importer mirrors records into LilypadUser. The sender must hold
+// CONTROLLER_ROLE on LilypadUser.
+//
+// Calls are sent in waves: the first call of every row, then the second,
+// and so on, so a row's calls are only sent once its previous call was
+// mined. Within a wave, calls go out in batches of BatchSize transactions
+// with consecutive nonces; the importer waits for a batch to be mined and
+// then for Interval before sending the next.
+type Importer struct {
+	dir        *Directory
+	transactor Transactor
+	backend    Backend
+	opts       *bind.TransactOpts
+	// BatchSize is the number of transactions in flight, 20 if zero.
+	BatchSize int
+	// Interval is the pause between batches.
+	Interval time.Duration
+	// DryRun only plans the calls.
+	DryRun bool
+	// Admins, if set, suggests who can grant CONTROLLER_ROLE when a call
+	// is denied.
+	Admins client.RoleAdmins
+	// Scope limits the roles the import changes.
+	Scope Scope
+}
+
+// NewImporter returns an Importer diffing against dir and sending from
+// opts.
+func NewImporter(dir *Directory, transactor Transactor, backend Backend, opts *bind.TransactOpts) *Importer {
+	return &Importer{dir: dir, transactor: transactor, backend: backend, opts: opts}
+}
+
+// Import diffs records against the directory and sends the calls. It
+// returns a result per record, in the order given; on a context or nonce
+// error it returns the results so far along with the error. Records
+// repeating an address or listing a role outside the Scope are invalid.
+func (im *Importer) Import(ctx context.Context, records []Record) ([]Result, error) {
+	results := make([]Result, len(records))
+	seen := make(map[common.Address]int)
+	managed := im.Scope.managed()
+	var pending []int
+	for i, rec := range records {
+		res := Result{Row: rec.Row, Address: rec.Address, Err: rec.Err}
+		if rec.Err == nil {
+			if row, dup := seen[rec.Address]; dup {
+				res.Err = fmt.Errorf("address repeats row %d", row)
+			} else if extra := rec.Roles &^ managed; extra != 0 {
+				res.Err = fmt.Errorf("%s not managed by this import", joinRoles(extra))
+			}
+			seen[rec.Address] = rec.Row
+		}
+		switch {
+		case res.Err != nil:
+			res.Status = Invalid
+		default:
+			res.Ops = im.dir.Diff(rec, im.Scope)
+			if len(res.Ops) == 0 {
+				res.Status = Unchanged
+			} else {
+				res.Status = Planned
+				pending = append(pending, i)
+			}
+		}
+		results[i] = res
+	}
+	if im.DryRun || len(pending) == 0 {
+		return results, nil
+	}
+
+	nonce, err := im.backend.PendingNonceAt(ctx, im.opts.From)
+	if err != nil {
+		return results, fmt.Errorf("users: reading nonce: %w", err)
+	}
+	batch := im.BatchSize
+	if batch <= 0 {
+		batch = 20
+	}
+	first := true
+	for wave := 0; len(pending) > 0; wave++ {
+		var next []int
+		for start := 0; start < len(pending); start += batch {
+			if !first && im.Interval > 0 {
+				select {
+				case <-time.After(im.Interval):
+				case <-ctx.Done():
+					return results, ctx.Err()
+				}
+			}
+			first = false
+			end := start + batch
+			if end > len(pending) {
+				end = len(pending)
+			}
+			sent := make(map[int]*types.Transaction)
+			for _, i := range pending[start:end] {
+				opts := *im.opts
+				opts.Context = ctx
+				opts.Nonce = new(big.Int).SetUint64(nonce)
+				tx, err := im.send(&opts, results[i].Ops[wave])
+				if err != nil {
+					err = client.Explain(err, "LilypadUser", results[i].Ops[wave].Method, opts.From, im.Admins)
+					results[i].Status, results[i].Err = Failed, fmt.Errorf("%s: %w", results[i].Ops[wave], err)
+					continue
+				}
+				nonce++
+				results[i].Txs = append(results[i].Txs, tx.Hash())
+				sent[i] = tx
+			}
+			for _, i := range pending[start:end] {
+				tx, ok := sent[i]
+				if !ok {
+					continue
+				}
+				receipt, err := bind.WaitMined(ctx, im.backend, tx)
+				if err != nil {
+					return results, fmt.Errorf("users: waiting for %s: %w", tx.Hash().Hex(), err)
+				}
+				if receipt.Status == types.ReceiptStatusFailed {
+					results[i].Status, results[i].Err = Failed, fmt.Errorf("%s: transaction reverted", results[i].Ops[wave])
+					continue
+				}
+				if wave+1 < len(results[i].Ops) {
+					next = append(next, i)
+				} else {
+					results[i].Status = Applied
+				}
+			}
+		}
+		pending = next
+	}
+	return results, nil
+}
+
+func (im *Importer) send(opts *bind.TransactOpts, op Op) (*types.Transaction, error) {
+	switch op.Method {
+	case "insertUser":
+		return im.transactor.InsertUser(opts, op.Address, op.MetadataID, op.URL, uint8(op.Role))
+	case "updateUserMetadata":
+		return im.transactor.UpdateUserMetadata(opts, op.Address, op.MetadataID, op.URL)
+	case "addRole":
+		return im.transactor.AddRole(opts, op.Address, uint8(op.Role))
+	case "removeRole":
+		return im.transactor.RemoveRole(opts, op.Address, uint8(op.Role))
+	}
+	return nil, fmt.Errorf("unknown method %q", op.Method)
+}
+
+// WriteResults writes results as CSV with the columns row, address, status,
+// calls, transactions and error, ordered by row.
+func WriteResults(w io.Writer, results []Result) error {
+	sorted := append([]Result(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Row < sorted[j].Row })
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"row", "address", "status", "calls", "transactions", "error"}); err != nil {
+		return err
+	}
+	for _, res := range sorted {
+		calls := make([]string, len(res.Ops))
+		for i, op := range res.Ops {
+			calls[i] = op.String()
+		}
+		txs := make([]string, len(res.Txs))
+		for i, tx := range res.Txs {
+			txs[i] = tx.Hex()
+		}
+		var msg string
+		if res.Err != nil {
+			msg = res.Err.Error()
+		}
+		address := res.Address.Hex()
+		if res.Address == (common.Address{}) {
+			address = ""
+		}
+		if err := cw.Write([]string{fmt.Sprint(res.Row), address, res.Status, strings.Join(calls, ";"), strings.Join(txs, ";"), msg}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
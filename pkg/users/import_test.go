@@ -0,0 +1,184 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+func rolesOf(types ...roles.UserType) Roles {
+	var r Roles
+	for _, role := range types {
+		r = r.with(role)
+	}
+	return r
+}
+
+func TestDiffScope(t *testing.T) {
+	addr := common.HexToAddress("0x01")
+	d := NewDirectory()
+	d.users[addr] = &User{Address: addr, MetadataID: "m", Roles: rolesOf(roles.JobCreator, roles.Solver, roles.Validator)}
+
+	tests := []struct {
+		name  string
+		rec   Roles
+		scope Scope
+		want  []string
+	}{
+		{"unchanged", rolesOf(roles.JobCreator), Scope{}, nil},
+		{"switch managed role", rolesOf(roles.ResourceProvider), Scope{}, []string{"removeRole(JobCreator)", "addRole(ResourceProvider)"}},
+		{"remove unmanaged", rolesOf(roles.JobCreator), Scope{RemoveUnmanaged: true}, []string{"removeRole(Solver)", "removeRole(Validator)"}},
+		{"custom scope", rolesOf(roles.JobCreator), Scope{Managed: []roles.UserType{roles.Solver}}, []string{"removeRole(Solver)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := d.Diff(Record{Address: addr, MetadataID: "m", Roles: tt.rec}, tt.scope)
+			var got []string
+			for _, op := range ops {
+				got = append(got, op.String())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportRejectsUnmanagedRoles(t *testing.T) {
+	im := NewImporter(NewDirectory(), nil, nil, nil)
+	im.DryRun = true
+	rec := Record{Row: 1, Address: common.HexToAddress("0x01"), Roles: rolesOf(roles.Validator)}
+	res, err := im.Import(context.Background(), []Record{rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].Status != Invalid || len(res[0].Ops) != 0 {
+		t.Errorf("row with an unmanaged role: %s %v, want invalid", res[0].Status, res[0].Ops)
+	}
+}
+
+// fakeUsers is a Transactor and Backend that mines every transaction at
+// once. Calls listed in fail are rejected when sent and those in revert
+// are mined with a failed receipt; calls are keyed by address and method.
+type fakeUsers struct {
+	bind.ContractBackend
+	nonce  uint64
+	fail   map[string]bool
+	revert map[string]bool
+	// calls lists every call made, with the nonce it was given.
+	calls    []string
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (f *fakeUsers) transact(opts *bind.TransactOpts, addr common.Address, call string) (*types.Transaction, error) {
+	key := addr.Hex() + " " + call
+	f.calls = append(f.calls, fmt.Sprintf("%s %d", key, opts.Nonce))
+	if f.fail[key] {
+		return nil, errors.New("connection reset")
+	}
+	tx := types.NewTx(&types.LegacyTx{Nonce: opts.Nonce.Uint64(), To: &addr, Data: []byte(call)})
+	status := types.ReceiptStatusSuccessful
+	if f.revert[key] {
+		status = types.ReceiptStatusFailed
+	}
+	f.receipts[tx.Hash()] = &types.Receipt{Status: status, TxHash: tx.Hash()}
+	return tx, nil
+}
+
+func (f *fakeUsers) InsertUser(opts *bind.TransactOpts, addr common.Address, _, _ string, role uint8) (*types.Transaction, error) {
+	return f.transact(opts, addr, fmt.Sprintf("insertUser(%s)", roles.UserType(role)))
+}
+
+func (f *fakeUsers) UpdateUserMetadata(opts *bind.TransactOpts, addr common.Address, metadataID, url string) (*types.Transaction, error) {
+	return f.transact(opts, addr, fmt.Sprintf("updateUserMetadata(%q, %q)", metadataID, url))
+}
+
+func (f *fakeUsers) AddRole(opts *bind.TransactOpts, addr common.Address, role uint8) (*types.Transaction, error) {
+	return f.transact(opts, addr, fmt.Sprintf("addRole(%s)", roles.UserType(role)))
+}
+
+func (f *fakeUsers) RemoveRole(opts *bind.TransactOpts, addr common.Address, role uint8) (*types.Transaction, error) {
+	return f.transact(opts, addr, fmt.Sprintf("removeRole(%s)", roles.UserType(role)))
+}
+
+func (f *fakeUsers) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return f.nonce, nil
+}
+
+func (f *fakeUsers) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := f.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func TestImport(t *testing.T) {
+	dave := common.HexToAddress("0xd0")
+	d := NewDirectory()
+	d.users[dave] = &User{Address: dave, MetadataID: "old", Roles: rolesOf(roles.Solver)}
+
+	key := func(addr common.Address, call string) string { return addr.Hex() + " " + call }
+	f := &fakeUsers{
+		nonce:    5,
+		fail:     map[string]bool{key(carol, "insertUser(Solver)"): true},
+		revert:   map[string]bool{key(bob, "insertUser(Solver)"): true},
+		receipts: make(map[common.Hash]*types.Receipt),
+	}
+	im := NewImporter(d, f, f, &bind.TransactOpts{From: common.HexToAddress("0xc7")})
+	im.BatchSize = 2
+	im.Scope = Scope{Managed: []roles.UserType{roles.JobCreator, roles.Solver, roles.Validator}}
+	results, err := im.Import(context.Background(), []Record{
+		{Row: 1, Address: alice, MetadataID: "a", Roles: rolesOf(roles.JobCreator, roles.Solver, roles.Validator)},
+		{Row: 2, Address: bob, MetadataID: "b", Roles: rolesOf(roles.JobCreator, roles.Solver)},
+		{Row: 3, Address: carol, MetadataID: "c", Roles: rolesOf(roles.Solver)},
+		{Row: 4, Address: dave, MetadataID: "d", Roles: rolesOf(roles.Solver)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each row's calls go out in order, one wave at a time. Carol's failed
+	// send leaves its nonce to dave, and bob's reverted insertUser stops
+	// the addRole of that row.
+	want := []string{
+		key(alice, "insertUser(Solver)") + " 5",
+		key(bob, "insertUser(Solver)") + " 6",
+		key(carol, "insertUser(Solver)") + " 7",
+		key(dave, `updateUserMetadata("d", "")`) + " 7",
+		key(alice, "addRole(Validator)") + " 8",
+		key(alice, "addRole(JobCreator)") + " 9",
+	}
+	if !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("calls\n%s\nwant\n%s", strings.Join(f.calls, "\n"), strings.Join(want, "\n"))
+	}
+
+	for i, tc := range []struct {
+		status string
+		txs    int
+		err    string
+	}{
+		{Applied, 3, ""},
+		{Failed, 1, "insertUser(Solver): transaction reverted"},
+		{Failed, 0, "insertUser(Solver): connection reset"},
+		{Applied, 1, ""},
+	} {
+		res := results[i]
+		var msg string
+		if res.Err != nil {
+			msg = res.Err.Error()
+		}
+		if res.Status != tc.status || len(res.Txs) != tc.txs || msg != tc.err {
+			t.Errorf("row %d: %s with %d transactions, error %q; want %s with %d, %q", res.Row, res.Status, len(res.Txs), msg, tc.status, tc.txs, tc.err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/revert"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// Caller is the subset of the LilypadUser binding Verify reads. It is
+// satisfied by *lilypaduser.LilypadUserCaller.
+type Caller interface {
+	HasRole0(opts *bind.CallOpts, walletAddress common.Address, role uint8) (bool, error)
+	GetValidators(opts *bind.CallOpts) ([]common.Address, error)
+}
+
+// Mismatch is a difference between the directory and LilypadUser.
+type Mismatch struct {
+	Address common.Address
+	// Role is the role that differs; nil when the account itself is
+	// missing on one side or the validator list differs.
+	Role *roles.UserType
+	// Directory and Chain tell whether each side has the account or role.
+	Directory bool
+	Chain     bool
+}
+
+// String implements fmt.Stringer.
+func (m Mismatch) String() string {
+	what := "account"
+	if m.Role != nil {
+		what = "role " + m.Role.String()
+	}
+	return fmt.Sprintf("%s %s: directory %t, chain %t", m.Address.Hex(), what, m.Directory, m.Chain)
+}
+
+// VerifyOptions configures Verify.
+type VerifyOptions struct {
+	// Sample is how many directory accounts to check; zero or more than the
+	// directory holds checks them all.
+	Sample int
+	// Extra are further addresses to check, e.g. parties of recent deals
+	// the directory might have missed.
+	Extra []common.Address
+	// Rand picks the sample; nil uses the math/rand default source.
+	Rand *rand.Rand
+}
+
+// Verify spot checks the directory against LilypadUser.hasRole and
+// getValidators at the block of the last event observed, so the comparison
+// is not skewed by later events. It needs a node that serves that block's
+// state.
+func (d *Directory) Verify(ctx context.Context, caller Caller, cfg VerifyOptions) ([]Mismatch, error) {
+	parsed, err := lilypaduser.LilypadUserMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	if block := d.Block(); block > 0 {
+		opts.BlockNumber = new(big.Int).SetUint64(block)
+	}
+
+	all := d.Users()
+	addrs := make([]common.Address, len(all))
+	for i, u := range all {
+		addrs[i] = u.Address
+	}
+	if cfg.Sample > 0 && cfg.Sample < len(addrs) {
+		shuffle := rand.Shuffle
+		if cfg.Rand != nil {
+			shuffle = cfg.Rand.Shuffle
+		}
+		shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+		addrs = addrs[:cfg.Sample]
+	}
+	addrs = append(addrs, cfg.Extra...)
+
+	var out []Mismatch
+	checked := make(map[common.Address]bool)
+	for _, addr := range addrs {
+		if checked[addr] {
+			continue
+		}
+		checked[addr] = true
+		u, known := d.Get(addr)
+		for _, role := range roles.UserTypes {
+			held, err := caller.HasRole0(opts, addr, uint8(role))
+			if revert.Is(err, "LilypadUser__UserNotFound", parsed) {
+				if known {
+					out = append(out, Mismatch{Address: addr, Directory: true})
+				}
+				break
+			}
+			if err != nil {
+				return out, fmt.Errorf("users: checking role %s of %s: %w", role, addr.Hex(), err)
+			}
+			if !known {
+				out = append(out, Mismatch{Address: addr, Chain: true})
+				break
+			}
+			if held != u.Roles.Has(role) {
+				role := role
+				out = append(out, Mismatch{Address: addr, Role: &role, Directory: !held, Chain: held})
+			}
+		}
+	}
+
+	validators, err := caller.GetValidators(opts)
+	if err != nil {
+		return out, fmt.Errorf("users: reading validators: %w", err)
+	}
+	onChain := make(map[common.Address]bool, len(validators))
+	for _, addr := range validators {
+		onChain[addr] = true
+	}
+	role := roles.Validator
+	for _, u := range d.Validators() {
+		if !onChain[u.Address] {
+			out = append(out, Mismatch{Address: u.Address, Role: &role, Directory: true})
+		}
+		delete(onChain, u.Address)
+	}
+	for _, addr := range validators {
+		if onChain[addr] {
+			out = append(out, Mismatch{Address: addr, Role: &role, Chain: true})
+			delete(onChain, addr)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	lilypaduser "github.com/Lilypad-Tech/lilypad-smart-contracts/bindings/LilypadUser"
+	"github.com/Lilypad-Tech/lilypad-smart-contracts/pkg/roles"
+)
+
+// revertError carries revert data the way go-ethereum's RPC errors do.
+type revertError []byte
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorData() interface{} { return hexutil.Encode(e) }
+
+// fakeCaller answers hasRole from the chain's role sets; other accounts
+// revert with LilypadUser__UserNotFound.
+type fakeCaller struct {
+	chain      map[common.Address]Roles
+	validators []common.Address
+	err        error
+	// checked lists the accounts hasRole was called for, once each.
+	checked []common.Address
+	blocks  map[uint64]bool
+}
+
+func (f *fakeCaller) HasRole0(opts *bind.CallOpts, wallet common.Address, role uint8) (bool, error) {
+	f.blocks[opts.BlockNumber.Uint64()] = true
+	if n := len(f.checked); n == 0 || f.checked[n-1] != wallet {
+		f.checked = append(f.checked, wallet)
+	}
+	if f.err != nil {
+		return false, f.err
+	}
+	r, ok := f.chain[wallet]
+	if !ok {
+		parsed, err := lilypaduser.LilypadUserMetaData.GetAbi()
+		if err != nil {
+			return false, err
+		}
+		id := parsed.Errors["LilypadUser__UserNotFound"].ID
+		return false, revertError(id[:4])
+	}
+	return r.Has(roles.UserType(role)), nil
+}
+
+func (f *fakeCaller) GetValidators(opts *bind.CallOpts) ([]common.Address, error) {
+	f.blocks[opts.BlockNumber.Uint64()] = true
+	return f.validators, nil
+}
+
+func TestVerify(t *testing.T) {
+	d := NewDirectory()
+	d.Observe(event(1, alice, "a", roles.ResourceProvider, roles.NewUser))
+	d.Observe(event(2, alice, "a", roles.Solver, roles.RoleAdded))
+	d.Observe(event(3, bob, "b", roles.Validator, roles.NewUser))
+	d.Observe(event(4, carol, "c", roles.ModuleCreator, roles.NewUser))
+
+	dave, erin := common.HexToAddress("0xd0"), common.HexToAddress("0xe0")
+	caller := &fakeCaller{
+		chain: map[common.Address]Roles{
+			alice: rolesOf(roles.ResourceProvider),
+			bob:   rolesOf(roles.Validator),
+			dave:  rolesOf(roles.JobCreator),
+		},
+		validators: []common.Address{bob, erin},
+		blocks:     make(map[uint64]bool),
+	}
+	got, err := d.Verify(context.Background(), caller, VerifyOptions{Extra: []common.Address{dave, alice}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver, validator := roles.Solver, roles.Validator
+	want := []Mismatch{
+		{Address: alice, Role: &solver, Directory: true},
+		{Address: carol, Directory: true},
+		{Address: dave, Chain: true},
+		{Address: erin, Role: &validator, Chain: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify = %v, want %v", got, want)
+	}
+	if want := []common.Address{alice, bob, carol, dave}; !reflect.DeepEqual(caller.checked, want) {
+		t.Errorf("checked %v, want %v", caller.checked, want)
+	}
+	if !reflect.DeepEqual(caller.blocks, map[uint64]bool{4: true}) {
+		t.Errorf("read at blocks %v, want 4", caller.blocks)
+	}
+}
+
+func TestVerifySample(t *testing.T) {
+	d := NewDirectory()
+	for i, addr := range []common.Address{alice, bob, carol} {
+		d.Observe(event(uint64(i+1), addr, "u", roles.JobCreator, roles.NewUser))
+	}
+	chain := map[common.Address]Roles{alice: rolesOf(roles.JobCreator), bob: rolesOf(roles.JobCreator), carol: rolesOf(roles.JobCreator)}
+	caller := &fakeCaller{chain: chain, blocks: make(map[uint64]bool)}
+	got, err := d.Verify(context.Background(), caller, VerifyOptions{Sample: 2, Rand: rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 || len(caller.checked) != 2 || caller.checked[0] == caller.checked[1] {
+		t.Errorf("Verify = %v after checking %v, want two accounts without mismatches", got, caller.checked)
+	}
+
+	caller = &fakeCaller{err: errors.New("node down"), blocks: make(map[uint64]bool)}
+	if _, err := d.Verify(context.Background(), caller, VerifyOptions{}); !errors.Is(err, caller.err) {
+		t.Errorf("Verify = %v, want %v", err, caller.err)
+	}
+}